@@ -0,0 +1,164 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+var (
+	adaptiveTimeoutSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "client",
+		Name:      "adaptive_timeout_seconds",
+		Help:      "Current adaptive request deadline per model/agent.",
+	}, []string{"route"})
+
+	adaptiveTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "client",
+		Name:      "adaptive_timeouts_total",
+		Help:      "Requests cut off by their adaptive deadline.",
+	}, []string{"route"})
+)
+
+// latencyWindow is a ring buffer of recent latencies
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// AdaptiveTimeout sets chat deadlines from recent latency instead of a fixed
+// timeout: the Percentile latency of the last Window calls per model/agent
+// plus Margin, clamped to [Min, Max]. Until MinSamples calls have been seen
+// for a route, Max is used.
+type AdaptiveTimeout struct {
+	Percentile float64
+	Margin     time.Duration
+	Min        time.Duration
+	Max        time.Duration
+	Window     int
+	MinSamples int
+
+	mu     sync.Mutex
+	routes map[string]*latencyWindow
+}
+
+// NewAdaptiveTimeout tracks the p99 of the last 200 calls per route with a
+// 5s margin, between 10s and 90s
+func NewAdaptiveTimeout() *AdaptiveTimeout {
+	return &AdaptiveTimeout{
+		Percentile: 0.99,
+		Margin:     5 * time.Second,
+		Min:        10 * time.Second,
+		Max:        90 * time.Second,
+		Window:     200,
+		MinSamples: 20,
+		routes:     make(map[string]*latencyWindow),
+	}
+}
+
+// Observe records the latency of a call on route
+func (a *AdaptiveTimeout) Observe(route string, latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w, ok := a.routes[route]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, a.Window)}
+		a.routes[route] = w
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// Timeout returns the current deadline for route
+func (a *AdaptiveTimeout) Timeout(route string) time.Duration {
+	a.mu.Lock()
+	w, ok := a.routes[route]
+	var samples []time.Duration
+	if ok {
+		n := w.next
+		if w.full {
+			n = len(w.samples)
+		}
+		samples = append(samples, w.samples[:n]...)
+	}
+	a.mu.Unlock()
+
+	timeout := a.Max
+	if len(samples) >= a.MinSamples {
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		idx := int(float64(len(samples)-1) * a.Percentile)
+		timeout = samples[idx] + a.Margin
+		if timeout < a.Min {
+			timeout = a.Min
+		}
+		if timeout > a.Max {
+			timeout = a.Max
+		}
+	}
+	adaptiveTimeoutSeconds.WithLabelValues(route).Set(timeout.Seconds())
+	return timeout
+}
+
+// latencyRoute is the key latencies are tracked under: agent and model
+func latencyRoute(req ChatRequest) string {
+	agent, model := req.AgentID, req.Model
+	if agent == "" {
+		agent = "default"
+	}
+	if model == "" {
+		model = "default"
+	}
+	return agent + "/" + model
+}
+
+// adaptiveContext shortens ctx to the adaptive deadline of req's route. The
+// returned observe func must be called with the call's error to feed the
+// latency back; it reports whether the adaptive deadline cut the call off.
+func (c *AgnoClient) adaptiveContext(ctx context.Context, req ChatRequest) (context.Context, func(error) bool) {
+	if c.Adaptive == nil {
+		return ctx, func(error) bool { return false }
+	}
+
+	route := latencyRoute(req)
+	timeout := c.Adaptive.Timeout(route)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		// The caller's deadline is already tighter; just learn from the call
+		start := time.Now()
+		return ctx, func(err error) bool {
+			if err == nil {
+				c.Adaptive.Observe(route, time.Since(start))
+			}
+			return false
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	start := time.Now()
+	return callCtx, func(err error) bool {
+		defer cancel()
+		elapsed := time.Since(start)
+		cutOff := err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded)
+		if err == nil || cutOff {
+			// Timeouts count as samples too, so a slower backend raises the deadline
+			c.Adaptive.Observe(route, elapsed)
+		}
+		if cutOff {
+			adaptiveTimeoutsTotal.WithLabelValues(route).Inc()
+			logger.Warnf("Agno call on %s exceeded adaptive deadline %s (session %s)", route, timeout, req.SessionID)
+		}
+		return cutOff
+	}
+}
@@ -0,0 +1,164 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"start-feishubot/logger"
+)
+
+// AgentInfo describes an agent hosted by the Agno service
+type AgentInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// agentsResponse is the body returned by GET /agents
+type agentsResponse struct {
+	Agents []AgentInfo `json:"agents"`
+}
+
+// ListAgents returns the agents hosted by the Agno service
+func (c *AgnoClient) ListAgents() ([]AgentInfo, error) {
+	return c.ListAgentsContext(context.Background())
+}
+
+// ListAgentsContext is like ListAgents but carries ctx for cancellation and tracing
+func (c *AgnoClient) ListAgentsContext(ctx context.Context) (_ []AgentInfo, err error) {
+	ctx, span := startSpan(ctx, "ListAgents")
+	defer func() { endSpan(span, err) }()
+
+	url := fmt.Sprintf("%s/agents", c.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	statusCode, body, err := c.doRequest(req, "agents", "")
+	if err != nil {
+		logger.Errorf("Failed to list Agno agents: %v", err)
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		logger.Errorf("List agents failed (status %d): %s", statusCode, string(body))
+		return nil, newAPIError(statusCode, body)
+	}
+
+	var agentsResp agentsResponse
+	if err := json.Unmarshal(body, &agentsResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agents response: %w", err)
+	}
+
+	return agentsResp.Agents, nil
+}
+
+// AgentRouter binds Lark chats to agents and routes chat calls accordingly
+type AgentRouter struct {
+	Client       AgnoService
+	DefaultAgent string
+
+	mu       sync.RWMutex
+	bindings map[string]string
+}
+
+// NewAgentRouter creates a router whose default agent comes from AGNO_DEFAULT_AGENT
+func NewAgentRouter(client AgnoService) *AgentRouter {
+	return &AgentRouter{
+		Client:       client,
+		DefaultAgent: os.Getenv("AGNO_DEFAULT_AGENT"),
+		bindings:     make(map[string]string),
+	}
+}
+
+// Bind routes all messages of a chat to agentID
+func (r *AgentRouter) Bind(chatID, agentID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings[chatID] = agentID
+}
+
+// Unbind restores the default agent for a chat
+func (r *AgentRouter) Unbind(chatID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.bindings, chatID)
+}
+
+// AgentFor returns the agent bound to a chat, or the default agent
+func (r *AgentRouter) AgentFor(chatID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if agentID, ok := r.bindings[chatID]; ok {
+		return agentID
+	}
+	return r.DefaultAgent
+}
+
+// Chat sends a message to the agent bound to chatID
+func (r *AgentRouter) Chat(ctx context.Context, chatID, sessionID, message string, history []Message) (string, error) {
+	resp, err := r.Client.SendChat(ctx, ChatRequest{
+		SessionID: sessionID,
+		Message:   message,
+		History:   history,
+		AgentID:   r.AgentFor(chatID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
+
+// HandleUseCommand handles "/use <agent>" (and "/use default"). It returns
+// the reply to send and false if text is not a /use command.
+func (r *AgentRouter) HandleUseCommand(ctx context.Context, chatID, text string) (string, bool, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "/use" {
+		return "", false, nil
+	}
+
+	agents, err := r.Client.ListAgentsContext(ctx)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	if len(fields) < 2 {
+		return formatAgentList(agents, r.AgentFor(chatID)), true, nil
+	}
+
+	name := fields[1]
+	if name == "default" {
+		r.Unbind(chatID)
+		return "✅ This chat now uses the default agent.", true, nil
+	}
+
+	for _, agent := range agents {
+		if agent.ID == name || strings.EqualFold(agent.Name, name) {
+			r.Bind(chatID, agent.ID)
+			logger.Infof("Chat %s bound to agent %s", chatID, agent.ID)
+			return fmt.Sprintf("✅ This chat now uses the **%s** agent.", agent.Name), true, nil
+		}
+	}
+
+	return fmt.Sprintf("Unknown agent %q.\n\n%s", name, formatAgentList(agents, r.AgentFor(chatID))), true, nil
+}
+
+// formatAgentList renders the available agents, marking the current one
+func formatAgentList(agents []AgentInfo, current string) string {
+	var b strings.Builder
+	b.WriteString("Available agents (switch with `/use <agent>`):\n")
+	for _, agent := range agents {
+		marker := ""
+		if agent.ID == current {
+			marker = " ← current"
+		}
+		fmt.Fprintf(&b, "- **%s** (`%s`) %s%s\n", agent.Name, agent.ID, agent.Description, marker)
+	}
+	return b.String()
+}
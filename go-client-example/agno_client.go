@@ -2,21 +2,100 @@ package agno
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"start-feishubot/logger"
 )
 
 // AgnoClient wraps the Python Agno AI service
 type AgnoClient struct {
 	BaseURL    string
-	HTTPClient *http.Client
+	HTTPClient *http.Client // without a Timeout; calls are bounded by their context
+
+	// RequestTimeout bounds calls whose context has no deadline. It is a
+	// default rather than a cap: ChatWithPolicy and callers' own deadlines
+	// may be longer.
+	RequestTimeout time.Duration
+
+	// TenantFunc maps a session ID to the tenant label used in metrics
+	TenantFunc func(sessionID string) string
+
+	// Timeouts holds per-command/per-tool latency SLAs (see ChatWithPolicy);
+	// use SetTimeouts once the client is in use
+	Timeouts   map[string]TimeoutPolicy
+	timeoutsMu sync.RWMutex
+
+	// Guard screens messages for prompt injection before they are sent (optional)
+	Guard InjectionGuard
+
+	// Auth signs requests when credentials are configured (nil otherwise)
+	Auth *Authenticator
+
+	// Usage accumulates token usage and cost reported with chat responses (optional)
+	Usage *UsageAccumulator
+
+	// Fallback answers chats in degraded mode while the service is unreachable (optional)
+	Fallback FallbackProvider
+
+	// Prompts holds the system prompt templates used by ChatWithTemplate (optional)
+	Prompts *PromptTemplates
+
+	// Adaptive derives chat deadlines from recent latency per model/agent (optional)
+	Adaptive *AdaptiveTimeout
+
+	// Cache answers repeated identical questions without a model call (optional)
+	Cache *ResponseCache
+
+	// Debug enables verbose debugging for individual sessions (optional)
+	Debug *DebugSessions
+
+	// Events receives agent step events (tool calls, retries, errors) for
+	// live observation by ops (optional)
+	Events EventPublisher
+
+	// ChatAudit records who asked what and what was answered, for
+	// compliance (optional)
+	ChatAudit *ChatAuditor
+
+	// Holds refuses to clear or replace the history of sessions under
+	// legal hold (optional; see LegalHolds.Subjects)
+	Holds *LegalHolds
+
+	// Relay mirrors the Q&A pairs of configured chats to their webhooks,
+	// for chats made with WithRequester (optional)
+	Relay *AnswerRelay
+
+	// RPC carries Chat, ChatStream, ClearSession and Health over gRPC instead
+	// of HTTP when set (see agnogrpc.NewAgnoClient)
+	RPC RPCTransport
+
+	// ModelDefaults fills the model parameters requests leave unset, by
+	// tenant (see TenantFunc); the "" entry applies to all tenants
+	ModelDefaults map[string]ModelParams
+
+	// BatchConcurrency caps in-flight requests of ChatBatch when it fans out (default 8)
+	BatchConcurrency int
+
+	// APIVersion pins the HTTP API version (APIVersion1 or APIVersion2);
+	// 0 detects it from /health (see AGNO_API_VERSION)
+	APIVersion int
+
+	batchSupport    int32
+	detectedVersion int32
+	versionChecked  int64 // unix nanos of the last detection
+
+	middlewares []Middleware
 }
 
 // ChatRequest represents the request to the Python service
@@ -25,12 +104,53 @@ type ChatRequest struct {
 	Message      string    `json:"message"`
 	History      []Message `json:"history,omitempty"`
 	SystemPrompt string    `json:"system_prompt,omitempty"`
+
+	// Parts carries multimodal content (text and images) when set; Message
+	// still holds the text
+	Parts []ContentPart `json:"parts,omitempty"`
+
+	// AgentID selects one of the agents hosted by the service (empty uses its default)
+	AgentID string `json:"agent_id,omitempty"`
+
+	// Model overrides the LLM the agent runs on (empty uses the agent's default)
+	Model string `json:"model,omitempty"`
+
+	// Temperature, MaxTokens and ReasoningEffort override the sampling
+	// parameters (unset uses ModelDefaults, then the agent's defaults)
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxTokens       int      `json:"max_tokens,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"`
+
+	// ToolTimeouts caps individual tool calls inside the agent run (seconds)
+	ToolTimeouts map[string]float64 `json:"tool_timeouts,omitempty"`
+
+	// NoCache bypasses the client's response cache for this request
+	NoCache bool `json:"-"`
+
+	// Debug asks the service for verbose tracing and step events (see DebugSessions)
+	Debug bool `json:"debug,omitempty"`
+
+	// IncludeSteps asks the service for step events only (set while Events is attached)
+	IncludeSteps bool `json:"include_steps,omitempty"`
+
+	// Metadata describes the input, e.g. the original audio of a transcribed
+	// voice message (see ChatWithAudio)
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// Parts is the multimodal content of the message, if any (see ContentPart)
+	Parts []ContentPart `json:"parts,omitempty"`
+
+	// Timestamp is set on messages read back from session history
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Metadata is carried through export and import (e.g. "merged_from")
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // ChatResponse represents the response from the Python service
@@ -38,6 +158,36 @@ type ChatResponse struct {
 	SessionID string `json:"session_id"`
 	Response  string `json:"response"`
 	Timestamp string `json:"timestamp"`
+	Usage     *Usage `json:"usage,omitempty"`
+
+	// MessageID identifies the answer in the service, e.g. for SubmitFeedback
+	MessageID string `json:"message_id,omitempty"`
+
+	// Degraded is set when the answer came from the fallback provider
+	// (see DegradedNotice)
+	Degraded bool `json:"degraded,omitempty"`
+
+	// Cached is set when the answer came from the response cache
+	Cached bool `json:"cached,omitempty"`
+
+	// Steps lists the agent's steps when the request had Debug set
+	Steps []StepEvent `json:"steps,omitempty"`
+
+	// Citations lists the sources the answer draws on, if the agent cites any
+	Citations []Citation `json:"citations,omitempty"`
+
+	// Metadata carries service-defined details of the run (e.g. "run_id")
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Citation is a source cited by an answer
+type Citation struct {
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+
+	// Verification is set by a CitationVerifier (see CitationVerified)
+	Verification string `json:"verification,omitempty"`
 }
 
 // HealthResponse represents the health check response
@@ -46,145 +196,428 @@ type HealthResponse struct {
 	OpenAIConfigured bool   `json:"openai_configured"`
 	StoragePath      string `json:"storage_path"`
 	Timestamp        string `json:"timestamp"`
+
+	// Version and APIVersion are reported by newer services; APIVersion
+	// selects the protocol spoken by the client
+	Version    string `json:"version,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// WatchCredentials reloads the client's credentials whenever the process
+// receives SIGHUP, until the returned function is called. Without an
+// Authenticator it does nothing.
+func (c *AgnoClient) WatchCredentials() (stop func()) {
+	if c.Auth == nil {
+		return func() {}
+	}
+	return c.Auth.WatchSIGHUP()
 }
 
-// NewAgnoClient creates a new Agno service client
+// defaultServiceURL is used when neither AGNO_SERVICE_URL nor an option sets the URL
+const defaultServiceURL = "http://localhost:8000"
+
+// NewAgnoClient creates a new Agno service client configured from the
+// environment (see WithEnv). It also reloads AGNO_PROMPT_DIR templates every
+// 10 seconds for the life of the process.
 func NewAgnoClient() *AgnoClient {
-	baseURL := os.Getenv("AGNO_SERVICE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8000"
-		logger.Warn("AGNO_SERVICE_URL not set, using default: http://localhost:8000")
+	o := defaultClientOptions()
+	o.env = true
+	client := newAgnoClient(o)
+	if client.Prompts != nil {
+		client.Prompts.Watch(10 * time.Second)
+	}
+	return client
+}
+
+// newAgnoClient builds a client from o, reading the environment only if o.env is set
+func newAgnoClient(o *clientOptions) *AgnoClient {
+	client := &AgnoClient{
+		BaseURL:        defaultServiceURL,
+		RequestTimeout: o.timeout,
+		Timeouts:       copyTimeoutPolicies(DefaultTimeoutPolicies),
+		ModelDefaults:  o.modelDefaults,
+	}
+	if o.env {
+		client.loadEnv()
+	}
+	if o.baseURL != "" {
+		client.BaseURL = o.baseURL
+	}
+	if o.httpClient != nil {
+		client.HTTPClient = o.httpClient
+	} else {
+		client.HTTPClient = &http.Client{Transport: o.transport()}
 	}
+	client.Use(o.middlewares...)
+	if o.apiVersion != nil {
+		client.APIVersion = *o.apiVersion
+	}
+	return client
+}
 
-	logger.Info("Initializing Agno client with URL:", baseURL)
+// loadEnv applies AGNO_SERVICE_URL, AGNO_API_VERSION, the credentials and
+// the AGNO_PROMPT_DIR templates
+func (c *AgnoClient) loadEnv() {
+	if baseURL := os.Getenv("AGNO_SERVICE_URL"); baseURL != "" {
+		c.BaseURL = baseURL
+	} else {
+		logger.Warn("AGNO_SERVICE_URL not set, using default: " + defaultServiceURL)
+	}
+	logger.Infof("Initializing Agno client %s with URL: %s", GetBuildInfo(), c.BaseURL)
+	c.APIVersion = APIVersionFromEnv()
 
-	return &AgnoClient{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 90 * time.Second, // Increased timeout for AI processing
-		},
+	// Authenticate with AGNO_API_KEY / AGNO_HMAC_* when configured; see WatchCredentials
+	auth, err := NewAuthenticator(EnvCredentials)
+	if err != nil {
+		logger.Errorf("Failed to load Agno credentials, requests will be unauthenticated: %v", err)
+	} else if !auth.Credentials().IsZero() {
+		c.Auth = auth
+		c.Use(auth.Middleware())
+		logger.Info("Agno client authentication enabled")
+	}
+
+	// Load system prompt templates from AGNO_PROMPT_DIR
+	if dir := os.Getenv("AGNO_PROMPT_DIR"); dir != "" {
+		prompts, err := LoadPromptTemplates(dir)
+		if err != nil {
+			logger.Errorf("Failed to load prompt templates from %s: %v", dir, err)
+		} else {
+			c.Prompts = prompts
+			logger.Infof("Loaded %d prompt template(s) from %s", len(prompts.Names()), dir)
+		}
 	}
 }
 
 // Chat sends a message to the Agno service and returns the response
 func (c *AgnoClient) Chat(sessionID, message string, history []Message) (string, error) {
-	logger.Debugf("Agno Chat - SessionID: %s, Message: %s", sessionID, message)
+	return c.ChatContext(context.Background(), sessionID, message, history)
+}
 
-	// Prepare request
-	reqBody := ChatRequest{
+// ChatContext is like Chat but carries ctx for cancellation and tracing
+func (c *AgnoClient) ChatContext(ctx context.Context, sessionID, message string, history []Message) (string, error) {
+	chatResp, err := c.SendChat(ctx, ChatRequest{
 		SessionID: sessionID,
 		Message:   message,
 		History:   history,
+	})
+	if err != nil {
+		return "", err
+	}
+	return chatResp.Response, nil
+}
+
+// SendChat sends a fully populated ChatRequest and returns the parsed response.
+// If the service is unreachable, or slower than the Adaptive deadline, and
+// Fallback is set, the answer comes from the fallback provider and is marked
+// Degraded. With a Cache, repeated identical questions are answered from it.
+func (c *AgnoClient) SendChat(ctx context.Context, reqBody ChatRequest) (resp *ChatResponse, err error) {
+	if c.ChatAudit != nil {
+		start := time.Now()
+		defer func() { c.auditChat(ctx, reqBody, resp, err, time.Since(start)) }()
+	}
+	traced := TraceStage(ctx, StageAgno)
+	defer func() { traced(err, agnoTraceDetail(reqBody, resp)) }()
+	if c.Relay != nil {
+		defer func() {
+			if err == nil {
+				c.relayChat(ctx, reqBody, resp)
+			}
+		}()
+	}
+	if c.Debug.Enabled(ctx, reqBody.SessionID) {
+		reqBody.Debug, reqBody.NoCache = true, true
+	}
+	cached, cacheKey := c.lookupCache(ctx, reqBody)
+	if cached != nil {
+		return cached, nil
+	}
+
+	if c.Events != nil {
+		reqBody.IncludeSteps = true
 	}
 
+	callCtx, observe := c.adaptiveContext(ctx, reqBody)
+	resp, err = c.sendChat(callCtx, reqBody)
+	if observe(err) {
+		err = fmt.Errorf("%w: adaptive deadline exceeded: %v", ErrModelTimeout, err)
+	}
+	c.publishEvents(ctx, reqBody.SessionID, resp, err)
+	if err != nil && c.Fallback != nil && shouldFallback(ctx, err) {
+		return c.fallbackChat(ctx, reqBody, err)
+	}
+	if err == nil && cacheKey != "" {
+		c.Cache.Put(ctx, cacheKey, resp)
+	}
+	return resp, err
+}
+
+// sendChat sends reqBody to the Agno service
+func (c *AgnoClient) sendChat(ctx context.Context, reqBody ChatRequest) (_ *ChatResponse, err error) {
+	ctx, span := startSpan(ctx, "Chat", attribute.String("agno.session_id", reqBody.SessionID))
+	defer func() { endSpan(span, err) }()
+
+	sessionID := reqBody.SessionID
+	logger.Debugf("Agno Chat - SessionID: %s, Message: %s", sessionID, reqBody.Message)
+
+	if err := c.guardRequest(&reqBody); err != nil {
+		return nil, err
+	}
+	if err := c.applyModelParams(&reqBody); err != nil {
+		return nil, err
+	}
+	if reqBody.ToolTimeouts == nil {
+		reqBody.ToolTimeouts = c.toolTimeouts(ctx)
+	}
+	if reqBody.Debug && c.Debug != nil {
+		span.SetAttributes(attribute.Bool("agno.debug", true))
+		c.Debug.logPayload(sessionID, "request", reqBody)
+	}
+
+	var chatResp *ChatResponse
+	if c.RPC != nil {
+		err = c.rpcCall(ctx, "chat", sessionID, func() (err error) {
+			chatResp, err = c.RPC.Chat(ctx, reqBody)
+			return err
+		})
+	} else {
+		chatResp, err = c.httpChat(ctx, reqBody)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("Agno response received - SessionID: %s, Response length: %d", chatResp.SessionID, len(chatResp.Response))
+	if reqBody.Debug && c.Debug != nil {
+		c.Debug.capture(ctx, span, sessionID, chatResp)
+	}
+
+	if c.Usage != nil && chatResp.Usage != nil {
+		c.Usage.Add(c.tenant(sessionID), sessionID, *chatResp.Usage)
+	}
+
+	return chatResp, nil
+}
+
+// httpChat posts reqBody to /chat, or /v2/chat for v2 services
+func (c *AgnoClient) httpChat(ctx context.Context, reqBody ChatRequest) (*ChatResponse, error) {
+	if c.apiVersion(ctx) == APIVersion2 {
+		chatResp, err := c.v2Chat(ctx, reqBody)
+		c.forgetAPIVersion(err)
+		return chatResp, err
+	}
+
+	sessionID := reqBody.SessionID
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		logger.Errorf("Failed to marshal Agno request: %v", err)
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Make HTTP request
 	url := fmt.Sprintf("%s/chat", c.BaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		logger.Errorf("Failed to create Agno request: %v", err)
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	logger.Debug("Sending request to Agno service...")
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		logger.Errorf("Failed to send request to Agno service: %v", err)
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	statusCode, body, err := c.doRequest(req, "chat", sessionID)
 	if err != nil {
-		logger.Errorf("Failed to read Agno response: %v", err)
-		return "", fmt.Errorf("failed to read response: %w", err)
+		logger.Errorf("Agno chat request failed: %v", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Errorf("Agno service returned status %d: %s", resp.StatusCode, string(body))
-		return "", fmt.Errorf("service returned status %d: %s", resp.StatusCode, string(body))
+	if statusCode != http.StatusOK {
+		logger.Errorf("Agno service returned status %d: %s", statusCode, string(body))
+		apiErr := newAPIError(statusCode, body)
+		c.forgetAPIVersion(apiErr)
+		return nil, apiErr
 	}
 
 	// Parse response
 	var chatResp ChatResponse
 	if err := json.Unmarshal(body, &chatResp); err != nil {
 		logger.Errorf("Failed to unmarshal Agno response: %v", err)
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-
-	logger.Debugf("Agno response received - SessionID: %s, Response length: %d", chatResp.SessionID, len(chatResp.Response))
-
-	return chatResp.Response, nil
+	return &chatResp, nil
 }
 
 // Health checks if the Agno service is available
 func (c *AgnoClient) Health() (*HealthResponse, error) {
+	return c.HealthContext(context.Background())
+}
+
+// HealthContext is like Health but carries ctx for cancellation and tracing
+func (c *AgnoClient) HealthContext(ctx context.Context) (_ *HealthResponse, err error) {
+	ctx, span := startSpan(ctx, "Health")
+	defer func() { endSpan(span, err) }()
+
+	if c.RPC != nil {
+		var healthResp *HealthResponse
+		err := c.rpcCall(ctx, "health", "", func() (err error) {
+			healthResp, err = c.RPC.Health(ctx)
+			return err
+		})
+		if err != nil {
+			logger.Errorf("Agno health check failed: %v", err)
+			serviceUp.Set(0)
+			return nil, fmt.Errorf("health check failed: %w", err)
+		}
+		serviceUp.Set(1)
+		return healthResp, nil
+	}
+
 	url := fmt.Sprintf("%s/health", c.BaseURL)
-	resp, err := c.HTTPClient.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Errorf("Agno health check failed: %v", err)
-		return nil, fmt.Errorf("health check failed: %w", err)
+		return nil, fmt.Errorf("failed to create health request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	statusCode, body, err := c.doRequest(req, "health", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read health response: %w", err)
+		logger.Errorf("Agno health check failed: %v", err)
+		serviceUp.Set(0)
+		return nil, fmt.Errorf("health check failed: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("service is not healthy (status %d): %s", resp.StatusCode, string(body))
+	if statusCode != http.StatusOK {
+		serviceUp.Set(0)
+		return nil, fmt.Errorf("service is not healthy: %w", newAPIError(statusCode, body))
 	}
 
 	var healthResp HealthResponse
 	if err := json.Unmarshal(body, &healthResp); err != nil {
+		serviceUp.Set(0)
 		return nil, fmt.Errorf("failed to unmarshal health response: %w", err)
 	}
 
+	serviceUp.Set(1)
 	return &healthResp, nil
 }
 
 // ClearSession clears the conversation history for a session
 func (c *AgnoClient) ClearSession(sessionID string) error {
+	return c.ClearSessionContext(context.Background(), sessionID)
+}
+
+// ClearSessionContext is like ClearSession but carries ctx for cancellation and tracing
+func (c *AgnoClient) ClearSessionContext(ctx context.Context, sessionID string) (err error) {
+	ctx, span := startSpan(ctx, "ClearSession", attribute.String("agno.session_id", sessionID))
+	defer func() { endSpan(span, err) }()
+
+	if c.Holds != nil {
+		if err := c.Holds.CheckSession(ctx, "system", sessionID); err != nil {
+			return err
+		}
+	}
+
 	logger.Infof("Clearing Agno session: %s", sessionID)
 
+	if c.RPC != nil {
+		err := c.rpcCall(ctx, "clear-session", sessionID, func() error {
+			return c.RPC.ClearSession(ctx, sessionID)
+		})
+		if err != nil {
+			logger.Errorf("Clear session failed: %v", err)
+			return fmt.Errorf("clear session failed: %w", err)
+		}
+		logger.Infof("Session cleared successfully: %s", sessionID)
+		return nil
+	}
+
+	if c.apiVersion(ctx) == APIVersion2 {
+		if err := c.v2ClearSession(ctx, sessionID); err != nil {
+			c.forgetAPIVersion(err)
+			logger.Errorf("Clear session failed: %v", err)
+			return fmt.Errorf("clear session failed: %w", err)
+		}
+		logger.Infof("Session cleared successfully: %s", sessionID)
+		return nil
+	}
+
 	url := fmt.Sprintf("%s/clear-session?session_id=%s", c.BaseURL, sessionID)
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		logger.Errorf("Failed to create clear session request: %v", err)
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	statusCode, body, err := c.doRequest(req, "clear-session", sessionID)
 	if err != nil {
 		logger.Errorf("Failed to send clear session request: %v", err)
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		logger.Errorf("Clear session failed (status %d): %s", resp.StatusCode, string(body))
-		return fmt.Errorf("clear session failed with status %d", resp.StatusCode)
+	if statusCode != http.StatusOK {
+		logger.Errorf("Clear session failed (status %d): %s", statusCode, string(body))
+		return fmt.Errorf("clear session failed: %w", newAPIError(statusCode, body))
 	}
 
 	logger.Infof("Session cleared successfully: %s", sessionID)
 	return nil
 }
 
+// doRequest sends a request to the Agno service, records metrics for it and
+// returns the status code and body
+func (c *AgnoClient) doRequest(req *http.Request, endpoint, sessionID string) (int, []byte, error) {
+	tenant := c.tenant(sessionID)
+	inFlightRequests.WithLabelValues(endpoint).Inc()
+	defer inFlightRequests.WithLabelValues(endpoint).Dec()
+
+	ctx, cancel := c.withRequestTimeout(req.Context())
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	injectTraceHeaders(req)
+	setDeadlineHeader(req)
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		observeRequest(endpoint, "error", tenant, time.Since(start))
+		return 0, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		observeRequest(endpoint, "error", tenant, time.Since(start))
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	observeRequest(endpoint, strconv.Itoa(resp.StatusCode), tenant, time.Since(start))
+	recordStatus(req.Context(), resp.StatusCode)
+	return resp.StatusCode, body, nil
+}
+
+// tenant resolves the metrics tenant label for a session
+func (c *AgnoClient) tenant(sessionID string) string {
+	if c.TenantFunc == nil || sessionID == "" {
+		return defaultTenant
+	}
+	if t := c.TenantFunc(sessionID); t != "" {
+		return t
+	}
+	return defaultTenant
+}
+
 // CheckConnection verifies the Agno service is reachable and properly configured
 func (c *AgnoClient) CheckConnection() error {
+	return c.CheckConnectionContext(context.Background())
+}
+
+// CheckConnectionContext is like CheckConnection but carries ctx for cancellation and tracing
+func (c *AgnoClient) CheckConnectionContext(ctx context.Context) error {
 	logger.Info("Checking Agno service connection...")
 
-	health, err := c.Health()
+	health, err := c.HealthContext(ctx)
 	if err != nil {
 		return fmt.Errorf("connection check failed: %w", err)
 	}
@@ -0,0 +1,355 @@
+package agno_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"start-feishubot/services/agno"
+	"start-feishubot/services/agno/agnotest/server"
+	"start-feishubot/services/agno/cardstream"
+	"start-feishubot/services/agno/larktest"
+)
+
+// newStubClient starts a stub Agno service and a client pointed at it
+func newStubClient(t *testing.T) (*agno.AgnoClient, *server.Server) {
+	t.Helper()
+	srv := server.New()
+	t.Cleanup(srv.Close)
+	client, err := agno.NewAgnoClientWithOptions(agno.WithBaseURL(srv.URL), agno.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("NewAgnoClientWithOptions: %v", err)
+	}
+	return client, srv
+}
+
+// replyRecorder is a KafkaProducer keeping the published replies
+type replyRecorder struct {
+	mu      sync.Mutex
+	replies []agno.OutboxMessage
+}
+
+func (r *replyRecorder) Produce(ctx context.Context, topic string, key, value []byte) error {
+	var msg agno.OutboxMessage
+	if err := json.Unmarshal(value, &msg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replies = append(r.replies, msg)
+	return nil
+}
+
+// larkEvent builds an im.message.receive_v1 event carrying text
+func larkEvent(t *testing.T, eventID, text string) agno.QueueMessage {
+	t.Helper()
+	var event agno.LarkMessageEvent
+	event.Header.EventID = eventID
+	event.Header.EventType = "im.message.receive_v1"
+	event.Event.Sender.SenderID.OpenID = "ou_test"
+	event.Event.Sender.SenderType = "user"
+	event.Event.Message.MessageID = "om_" + eventID
+	event.Event.Message.ChatID = "oc_test"
+	event.Event.Message.ChatType = "p2p"
+	event.Event.Message.MessageType = "text"
+	content, _ := json.Marshal(map[string]string{"text": text})
+	event.Event.Message.Content = string(content)
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	return agno.QueueMessage{Value: value}
+}
+
+func TestQueueIngestorRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		failStatus   int
+		failTimes    int
+		wantRequests int
+		wantAnswer   bool
+		minElapsed   time.Duration // backoff is 20ms per attempt
+	}{
+		{name: "no failure", wantRequests: 1, wantAnswer: true},
+		{name: "unavailable once", failStatus: 503, failTimes: 1, wantRequests: 2, wantAnswer: true, minElapsed: 20 * time.Millisecond},
+		{name: "rate limited twice", failStatus: 429, failTimes: 2, wantRequests: 3, wantAnswer: true, minElapsed: 60 * time.Millisecond},
+		{name: "unavailable past max attempts", failStatus: 503, failTimes: 3, wantRequests: 3, wantAnswer: false, minElapsed: 60 * time.Millisecond},
+		{name: "bad request is not retried", failStatus: 400, failTimes: 1, wantRequests: 1, wantAnswer: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newStubClient(t)
+			if tt.failTimes > 0 {
+				srv.FailNext("/chat", tt.failStatus, tt.failTimes)
+			}
+			producer := &replyRecorder{}
+			ingestor := agno.NewQueueIngestor(nil, producer, "replies", client, agno.NewMemorySessionStore())
+			ingestor.Backoff = 20 * time.Millisecond
+
+			start := time.Now()
+			if err := ingestor.Handle(context.Background(), larkEvent(t, "ev_1", "hello")); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			elapsed := time.Since(start)
+
+			if got := srv.RequestCount("/chat"); got != tt.wantRequests {
+				t.Errorf("/chat requests = %d, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("Handle took %s, want at least %s of backoff", elapsed, tt.minElapsed)
+			}
+			if len(producer.replies) != 1 {
+				t.Fatalf("published %d replies, want 1", len(producer.replies))
+			}
+			answered := strings.Contains(producer.replies[0].Content, "echo: hello")
+			if answered != tt.wantAnswer {
+				t.Errorf("reply %q: answered = %v, want %v", producer.replies[0].Content, answered, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       server.Reply
+		wantContent string
+		wantErr     string // substring of the final chunk's error; "" expects Done
+	}{
+		{name: "words of the text", reply: server.Reply{Text: "hello there friend"}, wantContent: "hello there friend"},
+		{name: "scripted chunks", reply: server.Reply{Chunks: []string{"a", "b", "c"}, ChunkDelay: time.Millisecond}, wantContent: "abc"},
+		{name: "stream error", reply: server.Reply{Chunks: []string{"par", "tial"}, StreamError: "model overloaded"}, wantContent: "partial", wantErr: "model overloaded"},
+		{name: "connection drops", reply: server.Reply{Chunks: []string{"one ", "two ", "three"}, CutAfter: 2}, wantContent: "one two ", wantErr: "ended unexpectedly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newStubClient(t)
+			srv.Script(tt.reply)
+
+			chunks, err := client.ChatStream(context.Background(), agno.ChatRequest{SessionID: "s1", Message: "hi"})
+			if err != nil {
+				t.Fatalf("ChatStream: %v", err)
+			}
+			var content strings.Builder
+			var last agno.StreamChunk
+			for chunk := range chunks {
+				content.WriteString(chunk.Content)
+				last = chunk
+			}
+
+			if content.String() != tt.wantContent {
+				t.Errorf("content = %q, want %q", content.String(), tt.wantContent)
+			}
+			switch {
+			case tt.wantErr == "" && (!last.Done || last.Error != ""):
+				t.Errorf("final chunk = %+v, want Done", last)
+			case tt.wantErr != "" && !strings.Contains(last.Error, tt.wantErr):
+				t.Errorf("final chunk error = %q, want it to contain %q", last.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionHistoryAndClear(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  []string
+		clear     string
+		wantKept  int // messages left in session "s1"
+		wantError bool
+	}{
+		{name: "history without clear", messages: []string{"one", "two"}, wantKept: 4},
+		{name: "clear the session", messages: []string{"one", "two"}, clear: "s1", wantKept: 0},
+		{name: "clear another session", messages: []string{"one"}, clear: "s2", wantKept: 2},
+		{name: "clear without a session ID", messages: []string{"one"}, clear: "", wantKept: 2, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newStubClient(t)
+			ctx := context.Background()
+			for _, message := range tt.messages {
+				answer, err := client.ChatContext(ctx, "s1", message, nil)
+				if err != nil {
+					t.Fatalf("ChatContext(%q): %v", message, err)
+				}
+				if answer != "echo: "+message {
+					t.Errorf("answer = %q, want %q", answer, "echo: "+message)
+				}
+			}
+
+			if tt.clear != "" || tt.wantError {
+				err := client.ClearSessionContext(ctx, tt.clear)
+				if (err != nil) != tt.wantError {
+					t.Fatalf("ClearSessionContext(%q) error = %v, want error %v", tt.clear, err, tt.wantError)
+				}
+			}
+
+			history := srv.History("s1")
+			if len(history) != tt.wantKept {
+				t.Fatalf("history has %d messages, want %d", len(history), tt.wantKept)
+			}
+			for i := 0; i+1 < len(history); i += 2 {
+				if history[i].Role != "user" || history[i+1].Role != "assistant" {
+					t.Errorf("exchange %d roles = %s/%s, want user/assistant", i/2, history[i].Role, history[i+1].Role)
+				}
+			}
+		})
+	}
+}
+
+func TestChatErrorsFromStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   error
+	}{
+		{name: "unavailable", status: 503, want: agno.ErrServiceUnavailable},
+		{name: "rate limited", status: 429, want: agno.ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newStubClient(t)
+			srv.Script(server.Reply{Status: tt.status, RetryAfter: "1"})
+
+			_, err := client.SendChat(context.Background(), agno.ChatRequest{SessionID: "s1", Message: "hi"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SendChat error = %v, want %v", err, tt.want)
+			}
+			if n := len(srv.History("s1")); n != 0 {
+				t.Errorf("failed call left %d messages in the session", n)
+			}
+		})
+	}
+}
+
+// larkAPI is a minimal Lark Open API client for handler tests: it replies
+// with cards and patches them (a cardstream.Updater)
+type larkAPI struct {
+	baseURL string
+}
+
+// call sends body to path and returns the message ID of the response
+func (l *larkAPI) call(ctx context.Context, method, path string, body interface{}) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, l.baseURL+"/open-apis"+path, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer t-fake")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			MessageID string `json:"message_id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", &cardstream.RateLimitError{RetryAfter: 10 * time.Millisecond}
+	}
+	if result.Code != 0 {
+		return "", fmt.Errorf("lark error %d: %s", result.Code, result.Msg)
+	}
+	return result.Data.MessageID, nil
+}
+
+// replyCard replies to parentID with an interactive card
+func (l *larkAPI) replyCard(ctx context.Context, parentID string, card map[string]interface{}) (string, error) {
+	content, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return l.call(ctx, http.MethodPost, "/im/v1/messages/"+parentID+"/reply",
+		map[string]interface{}{"msg_type": "interactive", "content": string(content)})
+}
+
+// UpdateCard implements cardstream.Updater
+func (l *larkAPI) UpdateCard(ctx context.Context, messageID string, card map[string]interface{}) error {
+	content, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	_, err = l.call(ctx, http.MethodPatch, "/im/v1/messages/"+messageID, map[string]interface{}{"content": string(content)})
+	return err
+}
+
+// answerWithStreamedCard is the handler under test: it replies to a user
+// message with a placeholder card and streams the answer into it
+func answerWithStreamedCard(ctx context.Context, lark *larkAPI, client *agno.AgnoClient, messageID, text string) (string, error) {
+	cardID, err := lark.replyCard(ctx, messageID, cardstream.DefaultRender("", cardstream.StateTyping))
+	if err != nil {
+		return "", fmt.Errorf("reply: %w", err)
+	}
+	chunks, err := client.ChatStream(ctx, agno.ChatRequest{SessionID: "s1", Message: text})
+	if err != nil {
+		return "", fmt.Errorf("stream: %w", err)
+	}
+	streamer := cardstream.New(lark)
+	streamer.MinInterval = 10 * time.Millisecond
+	return streamer.Stream(ctx, cardID, chunks)
+}
+
+func TestHandlerStreamsAnswerIntoReplyCard(t *testing.T) {
+	tests := []struct {
+		name          string
+		rateLimitOnce bool // Lark answers the first card update with 429
+	}{
+		{name: "reply and card update"},
+		{name: "card update rate limited once", rateLimitOnce: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newStubClient(t)
+			lark := larktest.NewServer()
+			t.Cleanup(lark.Close)
+			if tt.rateLimitOnce {
+				lark.FailNext(http.MethodPatch, "/open-apis/im/v1/messages/", 429, 99991400)
+			}
+
+			reply, err := answerWithStreamedCard(context.Background(), &larkAPI{baseURL: lark.URL}, client, "om_user_1", "hi")
+			if err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if reply != "echo: hi" {
+				t.Errorf("reply = %q, want %q", reply, "echo: hi")
+			}
+
+			msgs := lark.Messages()
+			if len(msgs) != 1 {
+				t.Fatalf("sent %d messages, want 1 reply", len(msgs))
+			}
+			if msgs[0].ReplyTo != "om_user_1" || msgs[0].MsgType != "interactive" {
+				t.Errorf("reply = %+v, want an interactive reply to om_user_1", msgs[0])
+			}
+			updates := lark.CardUpdates()
+			if len(updates) == 0 {
+				t.Fatal("card was never updated")
+			}
+			last := updates[len(updates)-1]
+			if last.MessageID != msgs[0].MessageID {
+				t.Errorf("updated %s, want the reply card %s", last.MessageID, msgs[0].MessageID)
+			}
+			if !strings.Contains(last.Content, "echo: hi") || strings.Contains(last.Content, "▌") {
+				t.Errorf("final card = %s, want the finished answer", last.Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package agnoapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"start-feishubot/logger"
+	"start-feishubot/services/agno"
+)
+
+// Transport is an agno.RPCTransport backed by the generated OpenAPI client
+type Transport struct {
+	api *ClientWithResponses
+}
+
+var _ agno.RPCTransport = (*Transport)(nil)
+
+// NewTransport creates a transport calling baseURL through httpClient; pass
+// an agno.AgnoClient's HTTPClient to keep its middlewares (authentication,
+// retries, ...)
+func NewTransport(baseURL string, httpClient *http.Client) (*Transport, error) {
+	api, err := NewClientWithResponses(baseURL,
+		WithHTTPClient(httpClient),
+		WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			req.Header.Set("User-Agent", agno.UserAgent())
+			return nil
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenAPI client for %s: %w", baseURL, err)
+	}
+	return &Transport{api: api}, nil
+}
+
+// NewAgnoClient creates an agno.AgnoClient (see agno.NewAgnoClient) whose
+// core calls go through the generated client. Calls outside the spec keep
+// using the hand-written HTTP methods.
+func NewAgnoClient() (*agno.AgnoClient, error) {
+	client := agno.NewAgnoClient()
+	transport, err := NewTransport(client.BaseURL, client.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	client.RPC = transport
+	logger.Infof("Agno client using the generated OpenAPI client at %s", client.BaseURL)
+	return client, nil
+}
+
+// Chat implements agno.RPCTransport
+func (t *Transport) Chat(ctx context.Context, req agno.ChatRequest) (*agno.ChatResponse, error) {
+	resp, err := t.api.PostChatWithResponse(ctx, toAPIRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK || resp.JSON200 == nil {
+		return nil, agno.ParseAPIError(resp.StatusCode(), resp.Body)
+	}
+	return fromAPIResponse(resp.JSON200), nil
+}
+
+// Stream implements agno.RPCTransport; server-sent events are outside what
+// the generator types, so the raw response is parsed like the HTTP client does
+func (t *Transport) Stream(ctx context.Context, req agno.ChatRequest) (<-chan agno.StreamChunk, error) {
+	resp, err := t.api.PostChatStream(ctx, toAPIRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, agno.ParseAPIError(resp.StatusCode, body)
+	}
+
+	chunks := make(chan agno.StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+		agno.ReadEventStream(ctx, resp.Body, chunks)
+	}()
+	return chunks, nil
+}
+
+// ClearSession implements agno.RPCTransport
+func (t *Transport) ClearSession(ctx context.Context, sessionID string) error {
+	resp, err := t.api.PostClearSessionWithResponse(ctx, &PostClearSessionParams{SessionId: sessionID})
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return agno.ParseAPIError(resp.StatusCode(), resp.Body)
+	}
+	return nil
+}
+
+// Health implements agno.RPCTransport
+func (t *Transport) Health(ctx context.Context) (*agno.HealthResponse, error) {
+	resp, err := t.api.GetHealthWithResponse(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK || resp.JSON200 == nil {
+		return nil, agno.ParseAPIError(resp.StatusCode(), resp.Body)
+	}
+	return &agno.HealthResponse{
+		Status:           resp.JSON200.Status,
+		OpenAIConfigured: resp.JSON200.OpenaiConfigured,
+		StoragePath:      resp.JSON200.StoragePath,
+		Timestamp:        resp.JSON200.Timestamp,
+	}, nil
+}
+
+// Close implements agno.RPCTransport; the HTTP client is shared
+func (t *Transport) Close() error {
+	return nil
+}
+
+// toAPIRequest converts a chat request to its generated form
+func toAPIRequest(req agno.ChatRequest) ChatRequest {
+	history := make([]Message, len(req.History))
+	for i, msg := range req.History {
+		history[i] = Message{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Parts:     toAPIParts(msg.Parts),
+			Timestamp: msg.Timestamp,
+			Metadata:  msg.Metadata,
+		}
+	}
+	return ChatRequest{
+		SessionId:       req.SessionID,
+		Message:         req.Message,
+		History:         history,
+		SystemPrompt:    req.SystemPrompt,
+		Parts:           toAPIParts(req.Parts),
+		AgentId:         req.AgentID,
+		Model:           req.Model,
+		Temperature:     req.Temperature,
+		MaxTokens:       req.MaxTokens,
+		ReasoningEffort: req.ReasoningEffort,
+		ToolTimeouts:    req.ToolTimeouts,
+		Debug:           req.Debug,
+		IncludeSteps:    req.IncludeSteps,
+		Metadata:        req.Metadata,
+	}
+}
+
+// toAPIParts converts multimodal content parts
+func toAPIParts(parts []agno.ContentPart) []ContentPart {
+	var out []ContentPart
+	for _, part := range parts {
+		apiPart := ContentPart{Type: part.Type, Text: part.Text}
+		if part.ImageURL != nil {
+			apiPart.ImageUrl = &ImageURL{Url: part.ImageURL.URL, Detail: part.ImageURL.Detail}
+		}
+		out = append(out, apiPart)
+	}
+	return out
+}
+
+// fromAPIResponse converts a generated chat response
+func fromAPIResponse(resp *ChatResponse) *agno.ChatResponse {
+	chatResp := &agno.ChatResponse{
+		SessionID: resp.SessionId,
+		MessageID: resp.MessageId,
+		Response:  resp.Response,
+		Timestamp: resp.Timestamp,
+		Metadata:  resp.Metadata,
+	}
+	if resp.Usage != nil {
+		chatResp.Usage = &agno.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			Model:            resp.Usage.Model,
+		}
+	}
+	for _, step := range resp.Steps {
+		chatResp.Steps = append(chatResp.Steps, agno.StepEvent{
+			Type:       step.Type,
+			Name:       step.Name,
+			Input:      step.Input,
+			Output:     step.Output,
+			Error:      step.Error,
+			DurationMS: step.DurationMs,
+			Timestamp:  step.Timestamp,
+		})
+	}
+	for _, citation := range resp.Citations {
+		chatResp.Citations = append(chatResp.Citations, agno.Citation{
+			Title:   citation.Title,
+			URL:     citation.Url,
+			Snippet: citation.Snippet,
+		})
+	}
+	return chatResp
+}
@@ -0,0 +1,12 @@
+// Package agnoapi carries the core Agno calls (Chat, Stream, ClearSession,
+// Health) over the typed client generated from the service's OpenAPI spec.
+// It plugs into agno.AgnoClient as its RPCTransport like agnogrpc, so the
+// ergonomic agno methods stay on top while every field the service defines
+// is mapped explicitly: when the FastAPI schema changes, regenerating breaks
+// the build here instead of dropping fields silently.
+package agnoapi
+
+// openapi.json is a snapshot of the service's /openapi.json; refresh it by
+// setting AGNO_OPENAPI_URL (operation IDs must stay stable, see README)
+//go:generate sh -c "if [ -n \"$AGNO_OPENAPI_URL\" ]; then curl -fsS \"$AGNO_OPENAPI_URL\" -o openapi.json; fi"
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.4.1 -config oapi-codegen.yaml openapi.json
@@ -0,0 +1,276 @@
+// Package agnogrpc carries the core Agno calls (Chat, Stream, ClearSession,
+// Health) over gRPC instead of JSON-over-HTTP. It plugs into
+// agno.AgnoClient as its RPCTransport, so callers keep using agno.AgnoService.
+package agnogrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative agnopb/agno.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"start-feishubot/logger"
+	"start-feishubot/services/agno"
+	"start-feishubot/services/agno/agnogrpc/agnopb"
+)
+
+// Transport is an agno.RPCTransport backed by a gRPC connection
+type Transport struct {
+	conn *grpc.ClientConn
+	rpc  agnopb.AgnoServiceClient
+}
+
+var _ agno.RPCTransport = (*Transport)(nil)
+
+// Dial connects to the Agno gRPC endpoint at target (host:port)
+func Dial(target string, opts ...grpc.DialOption) (*Transport, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	return &Transport{conn: conn, rpc: agnopb.NewAgnoServiceClient(conn)}, nil
+}
+
+// NewAgnoClient creates an agno.AgnoClient (see agno.NewAgnoClient) whose
+// transport is selected by AGNO_TRANSPORT: "http" (default) or "grpc", which
+// dials AGNO_GRPC_ADDR (default localhost:50051) with TLS when AGNO_GRPC_TLS
+// is "true". Calls outside the gRPC contract keep using AGNO_SERVICE_URL.
+func NewAgnoClient() (*agno.AgnoClient, error) {
+	client := agno.NewAgnoClient()
+	switch transport := os.Getenv("AGNO_TRANSPORT"); transport {
+	case "", "http":
+		return client, nil
+	case "grpc":
+	default:
+		return nil, fmt.Errorf("unknown AGNO_TRANSPORT %q (want http or grpc)", transport)
+	}
+
+	addr := os.Getenv("AGNO_GRPC_ADDR")
+	if addr == "" {
+		addr = "localhost:50051"
+	}
+	creds := insecure.NewCredentials()
+	if os.Getenv("AGNO_GRPC_TLS") == "true" {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithUserAgent(agno.UserAgent())}
+	if family := agno.AddressFamilyFromEnv(); family != agno.FamilyDual {
+		opts = append(opts, WithAddressFamily(family))
+	}
+	if client.Auth != nil {
+		auth := client.Auth
+		opts = append(opts, WithBearerToken(func() string { return auth.Credentials().BearerToken }))
+	}
+
+	transport, err := Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client.RPC = transport
+	logger.Infof("Agno client using gRPC transport at %s", addr)
+	return client, nil
+}
+
+// WithAddressFamily dials over family (agno.FamilyIPv4 or agno.FamilyIPv6)
+// instead of both
+func WithAddressFamily(family string) grpc.DialOption {
+	dial := agno.FamilyDialer(family, (&net.Dialer{}).DialContext)
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, "tcp", addr)
+	})
+}
+
+// bearerToken sends the current API key with every call
+type bearerToken func() string
+
+// GetRequestMetadata implements credentials.PerRPCCredentials
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if token := t(); token != "" {
+		return map[string]string{"authorization": "Bearer " + token}, nil
+	}
+	return nil, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials; the
+// service usually sits on a private network without TLS
+func (t bearerToken) RequireTransportSecurity() bool { return false }
+
+// WithBearerToken authenticates every call with the token returned by token,
+// which is read per call so rotated keys apply immediately. HMAC request
+// signing is HTTP-only.
+func WithBearerToken(token func() string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(bearerToken(token))
+}
+
+// Chat implements agno.RPCTransport
+func (t *Transport) Chat(ctx context.Context, req agno.ChatRequest) (*agno.ChatResponse, error) {
+	resp, err := t.rpc.Chat(ctx, toProtoRequest(req))
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+	chatResp := &agno.ChatResponse{
+		SessionID: resp.GetSessionId(),
+		MessageID: resp.GetMessageId(),
+		Response:  resp.GetResponse(),
+		Timestamp: resp.GetTimestamp(),
+	}
+	if usage := resp.GetUsage(); usage != nil {
+		chatResp.Usage = &agno.Usage{
+			PromptTokens:     int(usage.GetPromptTokens()),
+			CompletionTokens: int(usage.GetCompletionTokens()),
+			TotalTokens:      int(usage.GetTotalTokens()),
+			Model:            usage.GetModel(),
+		}
+	}
+	for _, step := range resp.GetSteps() {
+		chatResp.Steps = append(chatResp.Steps, agno.StepEvent{
+			Type:       step.GetType(),
+			Name:       step.GetName(),
+			Input:      step.GetInput(),
+			Output:     step.GetOutput(),
+			Error:      step.GetError(),
+			DurationMS: step.GetDurationMs(),
+			Timestamp:  step.GetTimestamp(),
+		})
+	}
+	return chatResp, nil
+}
+
+// Stream implements agno.RPCTransport
+func (t *Transport) Stream(ctx context.Context, req agno.ChatRequest) (<-chan agno.StreamChunk, error) {
+	stream, err := t.rpc.Stream(ctx, toProtoRequest(req))
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	chunks := make(chan agno.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for {
+			var chunk agno.StreamChunk
+			msg, err := stream.Recv()
+			switch {
+			case err == io.EOF:
+				chunk.Error = "stream ended unexpectedly: EOF"
+			case err != nil:
+				chunk.Error = convertError(ctx, err).Error()
+			default:
+				chunk = agno.StreamChunk{Content: msg.GetContent(), Done: msg.GetDone(), Error: msg.GetError()}
+			}
+
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				return
+			}
+			if chunk.Done || chunk.Error != "" {
+				return
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+// ClearSession implements agno.RPCTransport
+func (t *Transport) ClearSession(ctx context.Context, sessionID string) error {
+	_, err := t.rpc.ClearSession(ctx, &agnopb.ClearSessionRequest{SessionId: sessionID})
+	return convertError(ctx, err)
+}
+
+// Health implements agno.RPCTransport
+func (t *Transport) Health(ctx context.Context) (*agno.HealthResponse, error) {
+	resp, err := t.rpc.Health(ctx, &agnopb.HealthRequest{})
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+	return &agno.HealthResponse{
+		Status:           resp.GetStatus(),
+		OpenAIConfigured: resp.GetOpenaiConfigured(),
+		StoragePath:      resp.GetStoragePath(),
+		Timestamp:        resp.GetTimestamp(),
+	}, nil
+}
+
+// Close implements agno.RPCTransport
+func (t *Transport) Close() error {
+	return t.conn.Close()
+}
+
+// toProtoRequest converts a chat request to its protobuf form
+func toProtoRequest(req agno.ChatRequest) *agnopb.ChatRequest {
+	history := make([]*agnopb.Message, len(req.History))
+	for i, msg := range req.History {
+		history[i] = &agnopb.Message{Role: msg.Role, Content: msg.Content, Timestamp: msg.Timestamp}
+	}
+	var parts []*agnopb.ContentPart
+	for _, part := range req.Parts {
+		pbPart := &agnopb.ContentPart{Type: part.Type, Text: part.Text}
+		if part.ImageURL != nil {
+			pbPart.ImageUrl = &agnopb.ImageURL{Url: part.ImageURL.URL, Detail: part.ImageURL.Detail}
+		}
+		parts = append(parts, pbPart)
+	}
+	return &agnopb.ChatRequest{
+		SessionId:       req.SessionID,
+		Message:         req.Message,
+		History:         history,
+		SystemPrompt:    req.SystemPrompt,
+		AgentId:         req.AgentID,
+		Model:           req.Model,
+		Temperature:     req.Temperature,
+		MaxTokens:       int32(req.MaxTokens),
+		ReasoningEffort: req.ReasoningEffort,
+		ToolTimeouts:    req.ToolTimeouts,
+		Debug:           req.Debug,
+		Metadata:        req.Metadata,
+		Parts:           parts,
+		IncludeSteps:    req.IncludeSteps,
+	}
+}
+
+// statusMapping maps gRPC codes to the HTTP status and error code the JSON
+// API would have returned, so agno.APIError unwraps to the same sentinels
+var statusMapping = map[codes.Code]struct {
+	status int
+	code   string
+}{
+	codes.InvalidArgument:   {http.StatusBadRequest, "invalid_request"},
+	codes.Unauthenticated:   {http.StatusUnauthorized, "unauthorized"},
+	codes.PermissionDenied:  {http.StatusForbidden, "unauthorized"},
+	codes.NotFound:          {http.StatusNotFound, "session_not_found"},
+	codes.ResourceExhausted: {http.StatusTooManyRequests, "rate_limited"},
+	codes.DeadlineExceeded:  {http.StatusGatewayTimeout, "model_timeout"},
+	codes.Unavailable:       {http.StatusServiceUnavailable, "service_unavailable"},
+}
+
+// convertError turns a gRPC status into an *agno.APIError. Cancellation by
+// the caller is returned as the context error.
+func convertError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(ctxErr, context.DeadlineExceeded) {
+		return ctxErr
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	apiErr := &agno.APIError{StatusCode: http.StatusInternalServerError, Detail: st.Message(), Body: st.Message()}
+	if mapping, ok := statusMapping[st.Code()]; ok {
+		apiErr.StatusCode, apiErr.Code = mapping.status, mapping.code
+	}
+	return apiErr
+}
@@ -0,0 +1,268 @@
+// Package agnotest provides a programmable in-memory agno.AgnoService for
+// unit-testing bot handlers without an Agno service
+package agnotest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/services/agno"
+)
+
+// Method names used for error injection and call recording
+const (
+	MethodChat         = "Chat"
+	MethodHealth       = "Health"
+	MethodClearSession = "ClearSession"
+	MethodListAgents   = "ListAgents"
+)
+
+// Call is a single recorded call to the fake
+type Call struct {
+	Method    string
+	SessionID string
+	Request   agno.ChatRequest // set for Chat calls
+}
+
+// Fake is a programmable agno.AgnoService. The zero value is not usable; call New.
+type Fake struct {
+	// ChatFunc, when set, computes the response for every Chat call that has
+	// no queued response or injected error
+	ChatFunc func(ctx context.Context, req agno.ChatRequest) (*agno.ChatResponse, error)
+
+	// HealthResponse is returned by Health
+	HealthResponse agno.HealthResponse
+
+	// Agents is returned by ListAgents
+	Agents []agno.AgentInfo
+
+	mu        sync.Mutex
+	responses []string
+	errs      map[string]error
+	nextErrs  map[string][]error
+	calls     []Call
+}
+
+var _ agno.AgnoService = (*Fake)(nil)
+
+// New creates a Fake that echoes chat messages and reports a healthy service
+func New() *Fake {
+	return &Fake{
+		HealthResponse: agno.HealthResponse{
+			Status:           "healthy",
+			OpenAIConfigured: true,
+			StoragePath:      "memory",
+		},
+		errs:     make(map[string]error),
+		nextErrs: make(map[string][]error),
+	}
+}
+
+// QueueResponse queues canned chat responses, returned in order
+func (f *Fake) QueueResponse(responses ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, responses...)
+}
+
+// SetError makes every call to method fail with err (nil clears it)
+func (f *Fake) SetError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// FailNext makes only the next call to method fail with err
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextErrs[method] = append(f.nextErrs[method], err)
+}
+
+// Calls returns a copy of all recorded calls
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount returns how many times method was called
+func (f *Fake) CallCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset clears recorded calls, queued responses and injected errors
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = nil
+	f.calls = nil
+	f.errs = make(map[string]error)
+	f.nextErrs = make(map[string][]error)
+}
+
+// record stores the call and returns the error injected for it, if any
+func (f *Fake) record(call Call) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+
+	if queued := f.nextErrs[call.Method]; len(queued) > 0 {
+		f.nextErrs[call.Method] = queued[1:]
+		return queued[0]
+	}
+	return f.errs[call.Method]
+}
+
+// popResponse returns the next queued chat response
+func (f *Fake) popResponse() (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return "", false
+	}
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, true
+}
+
+// Chat implements agno.AgnoService
+func (f *Fake) Chat(sessionID, message string, history []agno.Message) (string, error) {
+	return f.ChatContext(context.Background(), sessionID, message, history)
+}
+
+// ChatContext implements agno.AgnoService
+func (f *Fake) ChatContext(ctx context.Context, sessionID, message string, history []agno.Message) (string, error) {
+	resp, err := f.SendChat(ctx, agno.ChatRequest{SessionID: sessionID, Message: message, History: history})
+	if err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
+
+// SendChat implements agno.AgnoService
+func (f *Fake) SendChat(ctx context.Context, req agno.ChatRequest) (*agno.ChatResponse, error) {
+	if err := f.record(Call{Method: MethodChat, SessionID: req.SessionID, Request: req}); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if text, ok := f.popResponse(); ok {
+		return f.response(req.SessionID, text), nil
+	}
+	if f.ChatFunc != nil {
+		return f.ChatFunc(ctx, req)
+	}
+	return f.response(req.SessionID, "echo: "+req.Message), nil
+}
+
+// ChatStream implements agno.AgnoService by streaming the SendChat response
+// word by word
+func (f *Fake) ChatStream(ctx context.Context, req agno.ChatRequest) (<-chan agno.StreamChunk, error) {
+	resp, err := f.SendChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan agno.StreamChunk)
+	go func() {
+		defer close(chunks)
+		words := strings.SplitAfter(resp.Response, " ")
+		for _, word := range words {
+			select {
+			case chunks <- agno.StreamChunk{Content: word}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case chunks <- agno.StreamChunk{Done: true}:
+		case <-ctx.Done():
+		}
+	}()
+	return chunks, nil
+}
+
+// response wraps text in a ChatResponse
+func (f *Fake) response(sessionID, text string) *agno.ChatResponse {
+	return &agno.ChatResponse{
+		SessionID: sessionID,
+		Response:  text,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// Health implements agno.AgnoService
+func (f *Fake) Health() (*agno.HealthResponse, error) {
+	return f.HealthContext(context.Background())
+}
+
+// HealthContext implements agno.AgnoService
+func (f *Fake) HealthContext(ctx context.Context) (*agno.HealthResponse, error) {
+	if err := f.record(Call{Method: MethodHealth}); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	health := f.HealthResponse
+	f.mu.Unlock()
+	return &health, nil
+}
+
+// ClearSession implements agno.AgnoService
+func (f *Fake) ClearSession(sessionID string) error {
+	return f.ClearSessionContext(context.Background(), sessionID)
+}
+
+// ClearSessionContext implements agno.AgnoService
+func (f *Fake) ClearSessionContext(ctx context.Context, sessionID string) error {
+	return f.record(Call{Method: MethodClearSession, SessionID: sessionID})
+}
+
+// ListAgents implements agno.AgnoService
+func (f *Fake) ListAgents() ([]agno.AgentInfo, error) {
+	return f.ListAgentsContext(context.Background())
+}
+
+// ListAgentsContext implements agno.AgnoService
+func (f *Fake) ListAgentsContext(ctx context.Context) ([]agno.AgentInfo, error) {
+	if err := f.record(Call{Method: MethodListAgents}); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]agno.AgentInfo(nil), f.Agents...), nil
+}
+
+// CheckConnection implements agno.AgnoService
+func (f *Fake) CheckConnection() error {
+	return f.CheckConnectionContext(context.Background())
+}
+
+// CheckConnectionContext implements agno.AgnoService
+func (f *Fake) CheckConnectionContext(ctx context.Context) error {
+	health, err := f.HealthContext(ctx)
+	if err != nil {
+		return err
+	}
+	if health.Status != "healthy" {
+		return errors.New("service status is not healthy")
+	}
+	return nil
+}
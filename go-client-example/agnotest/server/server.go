@@ -0,0 +1,385 @@
+// Package server provides an in-process stub of the Agno service's HTTP
+// API (/chat, /chat/stream, /health and /clear-session) for end-to-end tests
+// of the client and the bot pipeline without the Python service or OpenAI
+// keys. Replies are scripted per call, with artificial latency and error
+// scenarios. Point the client at URL, e.g.
+// agno.NewAgnoClientWithOptions(agno.WithBaseURL(srv.URL)).
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/services/agno"
+)
+
+// Request is a raw request captured by the stub
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Reply scripts the answer to one chat or stream call
+type Reply struct {
+	Text  string        // the answer; streamed word by word on /chat/stream
+	Delay time.Duration // added to the server's latency before answering
+
+	// Status, when set to an error status, fails the call with Body (a JSON
+	// error by default) and RetryAfter as the Retry-After header
+	Status     int
+	Body       string
+	RetryAfter string
+
+	// Chunks, when set, are streamed instead of the words of Text
+	Chunks []string
+	// ChunkDelay is the pause between streamed chunks
+	ChunkDelay time.Duration
+	// CutAfter ends a stream without the final event after this many chunks
+	// (0 streams them all)
+	CutAfter int
+	// StreamError ends a stream with an error event
+	StreamError string
+
+	Usage     *agno.Usage
+	Citations []agno.Citation
+}
+
+// failure is an injected error response
+type failure struct {
+	path   string
+	status int
+}
+
+// Server is a stub Agno service
+type Server struct {
+	*httptest.Server
+
+	// ChatFunc, when set, answers chat and stream calls that have no
+	// scripted reply; the default echoes the message
+	ChatFunc func(req agno.ChatRequest) Reply
+
+	mu       sync.Mutex
+	latency  time.Duration
+	requests []Request
+	replies  []Reply
+	failures []failure
+	sessions map[string][]agno.Message
+	healthy  bool
+	nextID   int
+}
+
+// New starts a healthy stub echoing chat messages; call Close when done
+func New() *Server {
+	s := &Server{
+		sessions: make(map[string][]agno.Message),
+		healthy:  true,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Script queues replies, used by chat and stream calls in order
+func (s *Server) Script(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// FailNext makes the next times requests to path fail with status and a
+// JSON error body, e.g. FailNext("/chat", 503, 2) to exercise retries
+func (s *Server) FailNext(path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.failures = append(s.failures, failure{path: path, status: status})
+	}
+}
+
+// SetHealthy makes /health report a healthy or unhealthy service
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthy = healthy
+}
+
+// SetLatency delays every response by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestCount returns how many requests were made to path
+func (s *Server) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// History returns the messages exchanged in a session since it was last cleared
+func (s *Server) History(sessionID string) []agno.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]agno.Message(nil), s.sessions[sessionID]...)
+}
+
+// Sessions returns how many sessions have history
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Reset clears captured requests, sessions, scripted replies, failures and latency
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.replies, s.failures = nil, nil, nil
+	s.sessions = make(map[string][]agno.Message)
+	s.healthy, s.latency = true, 0
+}
+
+// handle routes requests to the stub endpoints
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	latency := s.latency
+	f, failed := s.takeFailure(r.URL.Path)
+	s.mu.Unlock()
+
+	if !s.sleep(r, latency) {
+		return
+	}
+	if failed {
+		writeError(w, f.status, "injected failure")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/health":
+		s.handleHealth(w)
+	case r.Method == http.MethodPost && r.URL.Path == "/chat":
+		s.handleChat(w, r, body)
+	case r.Method == http.MethodPost && r.URL.Path == "/chat/stream":
+		s.handleStream(w, r, body)
+	case r.Method == http.MethodPost && r.URL.Path == "/clear-session":
+		s.handleClear(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "agnotest/server: unhandled "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// takeFailure pops the first injected failure for path; callers must hold s.mu
+func (s *Server) takeFailure(path string) (failure, bool) {
+	for i, f := range s.failures {
+		if f.path == path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f, true
+		}
+	}
+	return failure{}, false
+}
+
+// sleep waits d unless the client gives up first
+func (s *Server) sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// handleHealth implements GET /health
+func (s *Server) handleHealth(w http.ResponseWriter) {
+	s.mu.Lock()
+	healthy := s.healthy
+	s.mu.Unlock()
+	status, code := "healthy", http.StatusOK
+	if !healthy {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, agno.HealthResponse{
+		Status:           status,
+		OpenAIConfigured: healthy,
+		StoragePath:      "memory",
+		Timestamp:        time.Now().Format(time.RFC3339),
+	})
+}
+
+// handleChat implements POST /chat
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request, body []byte) {
+	req, reply, ok := s.prepare(w, r, body)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.respond(req, reply))
+}
+
+// handleStream implements POST /chat/stream as server-sent events
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request, body []byte) {
+	req, reply, ok := s.prepare(w, r, body)
+	if !ok {
+		return
+	}
+	chunks := reply.Chunks
+	if chunks == nil {
+		chunks = strings.SplitAfter(reply.Text, " ")
+	}
+	if reply.Text == "" {
+		reply.Text = strings.Join(chunks, "")
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	event := func(chunk agno.StreamChunk) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for i, chunk := range chunks {
+		if reply.CutAfter > 0 && i == reply.CutAfter {
+			return // the connection drops mid-answer
+		}
+		if i > 0 && !s.sleep(r, reply.ChunkDelay) {
+			return
+		}
+		event(agno.StreamChunk{Content: chunk})
+	}
+	if reply.StreamError != "" {
+		event(agno.StreamChunk{Error: reply.StreamError})
+		return
+	}
+	s.respond(req, reply)
+	event(agno.StreamChunk{Done: true})
+}
+
+// handleClear implements POST /clear-session?session_id=
+func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "session_id is required")
+		return
+	}
+	s.mu.Lock()
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success", "message": "Session " + sessionID + " cleared"})
+}
+
+// prepare decodes a chat request and picks its reply, answering scripted
+// errors itself; ok is false when the response was written
+func (s *Server) prepare(w http.ResponseWriter, r *http.Request, body []byte) (req agno.ChatRequest, reply Reply, ok bool) {
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return req, reply, false
+	}
+	if req.SessionID == "" || (req.Message == "" && len(req.Parts) == 0) {
+		writeError(w, http.StatusUnprocessableEntity, "session_id and message are required")
+		return req, reply, false
+	}
+
+	s.mu.Lock()
+	scripted := len(s.replies) > 0
+	if scripted {
+		reply = s.replies[0]
+		s.replies = s.replies[1:]
+	}
+	s.mu.Unlock()
+	if !scripted {
+		if s.ChatFunc != nil {
+			reply = s.ChatFunc(req)
+		} else {
+			reply = Reply{Text: "echo: " + req.Message}
+		}
+	}
+
+	if !s.sleep(r, reply.Delay) {
+		return req, reply, false
+	}
+	if reply.Status >= 400 {
+		if reply.RetryAfter != "" {
+			w.Header().Set("Retry-After", reply.RetryAfter)
+		}
+		writeError(w, reply.Status, reply.Body)
+		return req, reply, false
+	}
+	return req, reply, true
+}
+
+// respond records an answered exchange in the session and builds its response
+func (s *Server) respond(req agno.ChatRequest, reply Reply) agno.ChatResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.sessions[req.SessionID] = append(s.sessions[req.SessionID],
+		agno.Message{Role: "user", Content: req.Message},
+		agno.Message{Role: "assistant", Content: reply.Text})
+	return agno.ChatResponse{
+		SessionID: req.SessionID,
+		Response:  reply.Text,
+		Timestamp: time.Now().Format(time.RFC3339),
+		MessageID: fmt.Sprintf("msg_stub_%d", s.nextID),
+		Usage:     reply.Usage,
+		Citations: reply.Citations,
+	}
+}
+
+// writeError writes an error response; body defaults to a FastAPI-style
+// {"detail": ...} message
+func writeError(w http.ResponseWriter, status int, body string) {
+	if body == "" || !json.Valid([]byte(body)) {
+		detail := body
+		if detail == "" {
+			detail = http.StatusText(status)
+		}
+		writeJSON(w, status, map[string]string{"detail": detail})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
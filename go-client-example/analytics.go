@@ -0,0 +1,46 @@
+package agno
+
+import (
+	"context"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// AnalyticsEvent is a single product analytics record
+type AnalyticsEvent struct {
+	Name       string
+	SessionID  string
+	UserID     string
+	ChatID     string
+	Timestamp  time.Time
+	Properties map[string]interface{}
+}
+
+// AnalyticsRecorder receives analytics events (implemented by the bot's analytics backend)
+type AnalyticsRecorder interface {
+	Record(ctx context.Context, event AnalyticsEvent) error
+}
+
+// LogAnalytics is an AnalyticsRecorder that writes events to the bot log
+type LogAnalytics struct{}
+
+// Record logs the event
+func (LogAnalytics) Record(ctx context.Context, event AnalyticsEvent) error {
+	logger.Infof("analytics: %s session=%s user=%s chat=%s props=%v",
+		event.Name, event.SessionID, event.UserID, event.ChatID, event.Properties)
+	return nil
+}
+
+// recordAnalytics stamps and records an event, logging (not returning) failures
+func recordAnalytics(ctx context.Context, recorder AnalyticsRecorder, event AnalyticsEvent) {
+	if recorder == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if err := recorder.Record(ctx, event); err != nil {
+		logger.Warnf("Failed to record analytics event %s: %v", event.Name, err)
+	}
+}
@@ -0,0 +1,341 @@
+package agno
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Agno HTTP API versions
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2
+)
+
+// apiVersionTTL is how long a detected API version is trusted, so a
+// service upgraded under a running bot is picked up
+const apiVersionTTL = 5 * time.Minute
+
+// APIVersionFromEnv returns the API version pinned by AGNO_API_VERSION
+// ("1" or "2"), or 0 to detect it ("auto", the default)
+func APIVersionFromEnv() int {
+	switch v := os.Getenv("AGNO_API_VERSION"); v {
+	case "", "auto":
+		return 0
+	case "1":
+		return APIVersion1
+	case "2":
+		return APIVersion2
+	default:
+		logger.Warnf("Ignoring invalid AGNO_API_VERSION %q, detecting the API version", v)
+		return 0
+	}
+}
+
+// apiVersion returns the API version to speak: the pinned one, or the one
+// the service reports on /health (X-Agno-Api-Version header or api_version
+// field), re-detected every few minutes. Services that report nothing
+// speak v1.
+func (c *AgnoClient) apiVersion(ctx context.Context) int {
+	if c.APIVersion != 0 {
+		return c.APIVersion
+	}
+	version := int(atomic.LoadInt32(&c.detectedVersion))
+	checked := atomic.LoadInt64(&c.versionChecked)
+	if version != 0 && time.Since(time.Unix(0, checked)) < apiVersionTTL {
+		return version
+	}
+
+	detected, err := c.detectAPIVersion(ctx)
+	if err != nil {
+		// Keep what we had and retry in 30s rather than on every call
+		logger.Warnf("Failed to detect Agno API version: %v", err)
+		atomic.StoreInt64(&c.versionChecked, time.Now().Add(30*time.Second-apiVersionTTL).UnixNano())
+		if version == 0 {
+			return APIVersion1
+		}
+		return version
+	}
+	if detected != version {
+		logger.Infof("Agno service speaks API v%d", detected)
+	}
+	atomic.StoreInt32(&c.detectedVersion, int32(detected))
+	atomic.StoreInt64(&c.versionChecked, time.Now().UnixNano())
+	return detected
+}
+
+// forgetAPIVersion drops the detected version after the service rejected
+// an endpoint, e.g. because it was upgraded or rolled back
+func (c *AgnoClient) forgetAPIVersion(err error) {
+	var apiErr *APIError
+	if c.APIVersion == 0 && errors.As(err, &apiErr) && apiErr.Code == "" &&
+		(apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		atomic.StoreInt64(&c.versionChecked, 0)
+	}
+}
+
+// detectAPIVersion asks /health which API version the service speaks
+func (c *AgnoClient) detectAPIVersion(ctx context.Context) (int, error) {
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/health", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	injectTraceHeaders(req)
+	req.Header.Set("User-Agent", UserAgent())
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	reported := resp.Header.Get("X-Agno-Api-Version")
+	if reported == "" {
+		var health HealthResponse
+		json.Unmarshal(body, &health)
+		reported = health.APIVersion
+	}
+	if reported == "" {
+		if resp.StatusCode != http.StatusOK {
+			return 0, newAPIError(resp.StatusCode, body)
+		}
+		return APIVersion1, nil
+	}
+	// "2", "2.1" and "v2" all speak v2
+	major, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(reported, "v"), ".", 2)[0])
+	if err != nil || major < APIVersion1 {
+		return 0, fmt.Errorf("unrecognized API version %q", reported)
+	}
+	if major > APIVersion2 {
+		logger.Warnf("Agno service reports API v%d, speaking v2", major)
+		major = APIVersion2
+	}
+	return major, nil
+}
+
+// v2ChatRequest is the body of POST /v2/chat
+type v2ChatRequest struct {
+	SessionID    string             `json:"session_id"`
+	Input        v2Input            `json:"input"`
+	History      []Message          `json:"history,omitempty"`
+	Instructions string             `json:"instructions,omitempty"`
+	Agent        string             `json:"agent,omitempty"`
+	Model        string             `json:"model,omitempty"`
+	Temperature  *float64           `json:"temperature,omitempty"`
+	MaxOutput    int                `json:"max_output_tokens,omitempty"`
+	Reasoning    *v2Reasoning       `json:"reasoning,omitempty"`
+	ToolTimeouts map[string]float64 `json:"tool_timeouts,omitempty"`
+	Debug        bool               `json:"debug,omitempty"`
+	IncludeSteps bool               `json:"include_steps,omitempty"`
+	Metadata     map[string]string  `json:"metadata,omitempty"`
+}
+
+// v2Input is the user input of a v2 request
+type v2Input struct {
+	Text  string        `json:"text"`
+	Parts []ContentPart `json:"parts,omitempty"`
+}
+
+// v2Reasoning configures reasoning models in a v2 request
+type v2Reasoning struct {
+	Effort string `json:"effort"`
+}
+
+// v2ChatResponse is the body returned by /v2/chat
+type v2ChatResponse struct {
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id,omitempty"`
+	Output    struct {
+		Text string `json:"text"`
+	} `json:"output"`
+	CreatedAt string            `json:"created_at"`
+	Usage     *v2Usage          `json:"usage,omitempty"`
+	Steps     []StepEvent       `json:"steps,omitempty"`
+	Citations []Citation        `json:"citations,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// v2Usage is the token usage of a v2 response
+type v2Usage struct {
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	TotalTokens  int    `json:"total_tokens"`
+	Model        string `json:"model"`
+}
+
+// toV2Request converts a chat request to its v2 form
+func toV2Request(req ChatRequest) v2ChatRequest {
+	var reasoning *v2Reasoning
+	if req.ReasoningEffort != "" {
+		reasoning = &v2Reasoning{Effort: req.ReasoningEffort}
+	}
+	return v2ChatRequest{
+		SessionID:    req.SessionID,
+		Input:        v2Input{Text: req.Message, Parts: req.Parts},
+		History:      req.History,
+		Instructions: req.SystemPrompt,
+		Agent:        req.AgentID,
+		Model:        req.Model,
+		Temperature:  req.Temperature,
+		MaxOutput:    req.MaxTokens,
+		Reasoning:    reasoning,
+		ToolTimeouts: req.ToolTimeouts,
+		Debug:        req.Debug,
+		IncludeSteps: req.IncludeSteps,
+		Metadata:     req.Metadata,
+	}
+}
+
+// fromV2Response converts a v2 response
+func fromV2Response(resp *v2ChatResponse) *ChatResponse {
+	chatResp := &ChatResponse{
+		SessionID: resp.SessionID,
+		MessageID: resp.MessageID,
+		Response:  resp.Output.Text,
+		Timestamp: resp.CreatedAt,
+		Steps:     resp.Steps,
+		Citations: resp.Citations,
+		Metadata:  resp.Metadata,
+	}
+	if resp.Usage != nil {
+		chatResp.Usage = &Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			Model:            resp.Usage.Model,
+		}
+	}
+	return chatResp
+}
+
+// v2Chat posts reqBody to /v2/chat
+func (c *AgnoClient) v2Chat(ctx context.Context, reqBody ChatRequest) (*ChatResponse, error) {
+	jsonData, err := json.Marshal(toV2Request(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v2/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "chat", reqBody.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		logger.Errorf("Agno service returned status %d: %s", statusCode, string(body))
+		return nil, newAPIError(statusCode, body)
+	}
+	var resp v2ChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return fromV2Response(&resp), nil
+}
+
+// v2ClearSession deletes a session with DELETE /v2/sessions/{id}
+func (c *AgnoClient) v2ClearSession(ctx context.Context, sessionID string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.BaseURL+"/v2/sessions/"+url.PathEscape(sessionID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	statusCode, body, err := c.doRequest(req, "clear-session", sessionID)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusNoContent {
+		return newAPIError(statusCode, body)
+	}
+	return nil
+}
+
+// readEventStreamV2 parses v2 framing, where the event name carries the
+// chunk type:
+//
+//	event: delta  data: {"text": "..."}
+//	event: done   data: {"usage": {...}}
+//	event: error  data: {"code": "...", "message": "..."}
+func readEventStreamV2(ctx context.Context, body io.Reader, chunks chan<- StreamChunk) error {
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := "delta"
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			event = "delta" // end of an event
+			continue
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		case !strings.HasPrefix(line, "data:"):
+			continue // comments and ids
+		}
+		data := []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+
+		switch event {
+		case "delta":
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(data, &delta); err != nil {
+				err = fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+				send(StreamChunk{Error: err.Error()})
+				return err
+			}
+			if !send(StreamChunk{Content: delta.Text}) {
+				return ctx.Err()
+			}
+		case "done":
+			send(StreamChunk{Done: true})
+			return nil
+		case "error":
+			var failure struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(data, &failure)
+			if failure.Message == "" {
+				failure.Message = string(data)
+			}
+			send(StreamChunk{Error: failure.Message})
+			return nil
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	send(StreamChunk{Error: fmt.Sprintf("stream ended unexpectedly: %v", err)})
+	return err
+}
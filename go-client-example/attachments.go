@@ -0,0 +1,285 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Attachment index states
+const (
+	AttachmentIndexing = "indexing"
+	AttachmentIndexed  = "indexed"
+	AttachmentFailed   = "failed"
+)
+
+// Card action values used by the /files card
+const (
+	attachmentActionKey    = "attachment_action"
+	attachmentIDKey        = "attachment_id"
+	attachmentActionRemove = "remove"
+)
+
+// Attachment is a file ingested into a conversation's knowledge scope
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int       `json:"size"`
+	UploadedBy  string    `json:"uploaded_by,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	DocumentID  string    `json:"document_id,omitempty"`
+	Chunks      int       `json:"chunks,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentIndex tracks the files ingested in each session and indexes
+// them into a per-session knowledge collection, so users can see what the
+// agent knows about with /files and remove files from its scope
+type AttachmentIndex struct {
+	Store     SessionStore
+	Knowledge *KnowledgeClient
+	TTL       time.Duration // how long a session's index is kept after its last change
+
+	// Collection names the knowledge collection of a session
+	Collection func(sessionID string) string
+
+	mu sync.Mutex
+}
+
+// NewAttachmentIndex creates an index keeping session file lists for 30
+// days, indexing each session into a "session-<id>" collection
+func NewAttachmentIndex(store SessionStore, knowledge *KnowledgeClient) *AttachmentIndex {
+	return &AttachmentIndex{
+		Store:      store,
+		Knowledge:  knowledge,
+		TTL:        30 * 24 * time.Hour,
+		Collection: func(sessionID string) string { return "session-" + sessionID },
+	}
+}
+
+// Ingest records a file in the session's index and uploads it to the
+// session's knowledge collection. A failed upload stays listed as failed so
+// the user can see (and remove) it.
+func (x *AttachmentIndex) Ingest(ctx context.Context, sessionID, uploaderID, name, contentType string, file io.Reader) (*Attachment, error) {
+	id := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, fmt.Errorf("failed to generate attachment ID: %w", err)
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	att := Attachment{
+		ID:          hex.EncodeToString(id),
+		Name:        name,
+		ContentType: contentType,
+		Size:        len(content),
+		UploadedBy:  uploaderID,
+		Status:      AttachmentIndexing,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := x.update(ctx, sessionID, func(list []Attachment) []Attachment { return append(list, att) }); err != nil {
+		return nil, err
+	}
+
+	doc, err := x.Knowledge.UploadDocument(ctx, x.Collection(sessionID), bytes.NewReader(content), DocumentMetadata{
+		SourceID:    sessionID + "/" + att.ID,
+		Title:       name,
+		ContentType: contentType,
+		Extra:       map[string]string{"session_id": sessionID, "uploaded_by": uploaderID},
+	})
+	if err != nil {
+		att.Status, att.Error = AttachmentFailed, err.Error()
+		logger.Warnf("Failed to index attachment %s of session %s: %v", name, sessionID, err)
+	} else {
+		att.Status, att.DocumentID, att.Chunks = AttachmentIndexed, doc.ID, doc.Chunks
+	}
+	if saveErr := x.replace(ctx, sessionID, att); saveErr != nil {
+		logger.Warnf("Failed to record index status of attachment %s: %v", att.ID, saveErr)
+	}
+	if err != nil {
+		return &att, fmt.Errorf("failed to index attachment: %w", err)
+	}
+	return &att, nil
+}
+
+// List returns the files of a session, oldest first
+func (x *AttachmentIndex) List(ctx context.Context, sessionID string) ([]Attachment, error) {
+	data, err := x.Store.Get(ctx, "attachments:"+sessionID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list []Attachment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachments of session %s: %w", sessionID, err)
+	}
+	return list, nil
+}
+
+// Remove deletes a file from the session's knowledge collection and index
+func (x *AttachmentIndex) Remove(ctx context.Context, sessionID, attachmentID string) (*Attachment, error) {
+	list, err := x.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var att *Attachment
+	for i := range list {
+		if list[i].ID == attachmentID {
+			att = &list[i]
+			break
+		}
+	}
+	if att == nil {
+		return nil, fmt.Errorf("%w: attachment %s", ErrKeyNotFound, attachmentID)
+	}
+	if att.DocumentID != "" {
+		if err := x.Knowledge.DeleteDocument(ctx, x.Collection(sessionID), att.DocumentID); err != nil {
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+				return nil, fmt.Errorf("failed to remove %s from knowledge: %w", att.Name, err)
+			}
+		}
+	}
+	removed := *att
+	err = x.update(ctx, sessionID, func(list []Attachment) []Attachment {
+		kept := list[:0]
+		for _, a := range list {
+			if a.ID != attachmentID {
+				kept = append(kept, a)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Removed attachment %s (%s) from session %s", removed.ID, removed.Name, sessionID)
+	return &removed, nil
+}
+
+// replace stores the new state of an attachment
+func (x *AttachmentIndex) replace(ctx context.Context, sessionID string, att Attachment) error {
+	return x.update(ctx, sessionID, func(list []Attachment) []Attachment {
+		for i := range list {
+			if list[i].ID == att.ID {
+				list[i] = att
+			}
+		}
+		return list
+	})
+}
+
+// update applies fn to a session's file list and saves it
+func (x *AttachmentIndex) update(ctx context.Context, sessionID string, fn func([]Attachment) []Attachment) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	list, err := x.List(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	list = fn(list)
+	if len(list) == 0 {
+		return x.Store.Delete(ctx, "attachments:"+sessionID)
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachments: %w", err)
+	}
+	if err := x.Store.Set(ctx, "attachments:"+sessionID, data, x.TTL); err != nil {
+		return fmt.Errorf("failed to save attachments: %w", err)
+	}
+	return nil
+}
+
+// BuildFilesCard lists a session's files with their index status and a
+// remove button each
+func BuildFilesCard(list []Attachment) map[string]interface{} {
+	elements := make([]interface{}, 0, len(list)+1)
+	if len(list) == 0 {
+		elements = append(elements, markdownElement("No files in this conversation yet. Send a file and I'll index it for this chat."))
+	}
+	for _, att := range list {
+		status := "✅ indexed"
+		switch att.Status {
+		case AttachmentIndexing:
+			status = "⏳ indexing"
+		case AttachmentFailed:
+			status = "⚠️ failed"
+		}
+		line := fmt.Sprintf("**%s**\n%s · %s · %s", att.Name, status, formatBytes(att.Size), att.CreatedAt.Format("Jan 2 15:04"))
+		remove := callbackButton("Remove", "danger", map[string]interface{}{
+			attachmentActionKey: attachmentActionRemove,
+			attachmentIDKey:     att.ID,
+		})
+		remove["confirm"] = map[string]interface{}{
+			"title": plainText("Remove " + att.Name + "?"),
+			"text":  plainText("I won't use this file to answer questions in this chat anymore."),
+		}
+		elements = append(elements, map[string]interface{}{
+			"tag":   "div",
+			"text":  map[string]interface{}{"tag": "lark_md", "content": line},
+			"extra": remove,
+		})
+	}
+	return map[string]interface{}{
+		"config":   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header":   cardHeader(fmt.Sprintf("📎 Files in this conversation (%d)", len(list)), "blue"),
+		"elements": elements,
+	}
+}
+
+// formatBytes renders a file size for humans
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// HandleFilesCommand answers "/files" with the session's files card. It
+// returns false if text is not the command.
+func (x *AttachmentIndex) HandleFilesCommand(ctx context.Context, sessionID, text string) (map[string]interface{}, bool, error) {
+	if strings.TrimSpace(text) != "/files" {
+		return nil, false, nil
+	}
+	list, err := x.List(ctx, sessionID)
+	if err != nil {
+		return nil, true, err
+	}
+	return BuildFilesCard(list), true, nil
+}
+
+// HandleAttachmentAction processes the remove buttons of the files card. It
+// returns the refreshed card and false if the action does not belong to it.
+func (x *AttachmentIndex) HandleAttachmentAction(ctx context.Context, action CardAction, sessionID string) (map[string]interface{}, bool, error) {
+	if action.StringValue(attachmentActionKey) != attachmentActionRemove {
+		return nil, false, nil
+	}
+	if _, err := x.Remove(ctx, sessionID, action.StringValue(attachmentIDKey)); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, true, err
+	}
+	list, err := x.List(ctx, sessionID)
+	if err != nil {
+		return nil, true, err
+	}
+	return BuildFilesCard(list), true, nil
+}
@@ -0,0 +1,163 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// LarkAudioMIME is the format of Lark voice messages (Opus in an Ogg container)
+const LarkAudioMIME = "audio/ogg; codecs=opus"
+
+// maxAudioSize caps audio clips sent to /transcribe
+const maxAudioSize = 25 << 20
+
+// ErrEmptyTranscript is returned when an audio clip contains no speech
+var ErrEmptyTranscript = errors.New("agno: no speech in audio")
+
+// Transcription is the result of POST /transcribe
+type Transcription struct {
+	Text     string  `json:"text"`
+	Language string  `json:"language,omitempty"`
+	Duration float64 `json:"duration_seconds,omitempty"`
+	// AudioID references the uploaded clip, which the service keeps so the
+	// agent run can refer back to the original audio
+	AudioID string `json:"audio_id,omitempty"`
+}
+
+// Transcribe posts an audio clip to /transcribe as multipart form data
+func (c *AgnoClient) Transcribe(ctx context.Context, audio io.Reader, mime string) (_ *Transcription, err error) {
+	ctx, span := startSpan(ctx, "Transcribe", attribute.String("agno.audio_mime", mime))
+	defer func() { endSpan(span, err) }()
+
+	content, err := io.ReadAll(io.LimitReader(audio, maxAudioSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if len(content) > maxAudioSize {
+		return nil, fmt.Errorf("%w: audio exceeds %d bytes", ErrPayloadTooLarge, maxAudioSize)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="audio"`)
+	header.Set("Content-Type", mime)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build transcription request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/transcribe", &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	statusCode, respBody, err := c.doRequest(req, "transcribe", "")
+	if err != nil {
+		logger.Errorf("Agno transcription request failed: %v", err)
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		logger.Errorf("Agno transcribe returned status %d: %s", statusCode, string(respBody))
+		return nil, newAPIError(statusCode, respBody)
+	}
+
+	var transcription Transcription
+	if err := json.Unmarshal(respBody, &transcription); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transcription: %w", err)
+	}
+	logger.Debugf("Transcribed %d bytes of %s audio (%.1fs, %s)", len(content), mime, transcription.Duration, transcription.Language)
+	return &transcription, nil
+}
+
+// ChatWithAudio transcribes a voice message and sends the transcript to the
+// agent, with the original audio referenced in the request metadata
+func (c *AgnoClient) ChatWithAudio(sessionID string, audio io.Reader, mime string) (string, error) {
+	return c.ChatWithAudioContext(context.Background(), sessionID, audio, mime)
+}
+
+// ChatWithAudioContext is like ChatWithAudio but carries ctx for cancellation and tracing
+func (c *AgnoClient) ChatWithAudioContext(ctx context.Context, sessionID string, audio io.Reader, mime string) (string, error) {
+	_, resp, err := c.SendAudioChat(ctx, sessionID, audio, mime)
+	if err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
+
+// SendAudioChat is like ChatWithAudioContext but also returns the
+// transcription, e.g. to show the user what was heard
+func (c *AgnoClient) SendAudioChat(ctx context.Context, sessionID string, audio io.Reader, mime string) (*Transcription, *ChatResponse, error) {
+	transcription, err := c.Transcribe(ctx, audio, mime)
+	if err != nil {
+		return nil, nil, err
+	}
+	if transcription.Text == "" {
+		return transcription, nil, ErrEmptyTranscript
+	}
+
+	resp, err := c.SendChat(ctx, ChatRequest{
+		SessionID: sessionID,
+		Message:   transcription.Text,
+		Metadata: map[string]string{
+			"input":               "audio",
+			"audio_id":            transcription.AudioID,
+			"audio_mime":          mime,
+			"audio_duration":      strconv.FormatFloat(transcription.Duration, 'f', 1, 64),
+			"transcript_language": transcription.Language,
+		},
+	})
+	if err != nil {
+		return transcription, nil, err
+	}
+	return transcription, resp, nil
+}
+
+// larkAudioContent is the content of a Lark "audio" message
+type larkAudioContent struct {
+	FileKey  string `json:"file_key"`
+	Duration int    `json:"duration"` // milliseconds
+}
+
+// ResourceDownloader fetches a message resource from Lark, i.e. the
+// im/v1/messages/:message_id/resources/:file_key API (implemented by the bot)
+type ResourceDownloader interface {
+	DownloadResource(ctx context.Context, messageID, fileKey, resourceType string) (io.ReadCloser, error)
+}
+
+// ChatWithLarkAudio downloads the audio of a Lark voice message (content is
+// the message's JSON content) and answers it with SendAudioChat
+func (c *AgnoClient) ChatWithLarkAudio(ctx context.Context, downloader ResourceDownloader, sessionID, messageID, content string) (*Transcription, *ChatResponse, error) {
+	var audio larkAudioContent
+	if err := json.Unmarshal([]byte(content), &audio); err != nil || audio.FileKey == "" {
+		return nil, nil, fmt.Errorf("invalid audio message content: %q", content)
+	}
+
+	body, err := downloader.DownloadResource(ctx, messageID, audio.FileKey, "file")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download audio %s: %w", audio.FileKey, err)
+	}
+	defer body.Close()
+
+	logger.Infof("Transcribing %dms voice message %s for session %s", audio.Duration, messageID, sessionID)
+	return c.SendAudioChat(ctx, sessionID, body, LarkAudioMIME)
+}
@@ -0,0 +1,308 @@
+package agno
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Headers carrying the HMAC request signature
+const (
+	KeyIDHeader     = "X-Agno-Key-Id"
+	TimestampHeader = "X-Agno-Timestamp"
+	SignatureHeader = "X-Agno-Signature"
+)
+
+// maxSignatureSkew is how far a signed timestamp may drift from the verifier's clock
+const maxSignatureSkew = 5 * time.Minute
+
+// ErrInvalidSignature is returned when a request signature does not verify
+var ErrInvalidSignature = errors.New("agno: invalid request signature")
+
+// ErrMissingSecret is returned when a request is verified against an empty secret
+var ErrMissingSecret = errors.New("agno: no request signing secret configured")
+
+// Credentials is the key material used to authenticate to the Agno service
+type Credentials struct {
+	BearerToken string
+	HMACKeyID   string
+	HMACSecret  []byte
+}
+
+// IsZero reports whether no authentication is configured
+func (c Credentials) IsZero() bool {
+	return c.BearerToken == "" && len(c.HMACSecret) == 0
+}
+
+// CredentialSource loads the current credentials
+type CredentialSource func() (Credentials, error)
+
+// EnvCredentials loads credentials from AGNO_API_KEY, AGNO_HMAC_KEY_ID and
+// AGNO_HMAC_SECRET. Each can instead point to a file with a _FILE suffix
+// (e.g. AGNO_API_KEY_FILE); files are re-read on reload, which is what
+// makes rotation through mounted secrets work.
+func EnvCredentials() (Credentials, error) {
+	token, err := envOrFile("AGNO_API_KEY")
+	if err != nil {
+		return Credentials{}, err
+	}
+	keyID, err := envOrFile("AGNO_HMAC_KEY_ID")
+	if err != nil {
+		return Credentials{}, err
+	}
+	secret, err := envOrFile("AGNO_HMAC_SECRET")
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{BearerToken: token, HMACKeyID: keyID, HMACSecret: []byte(secret)}, nil
+}
+
+// envOrFile returns $name, or the trimmed contents of the file at $name_FILE
+func envOrFile(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return os.Getenv(name), nil
+}
+
+// Authenticator signs requests with the current credentials and reloads them on demand
+type Authenticator struct {
+	source CredentialSource
+
+	mu    sync.RWMutex
+	creds Credentials
+}
+
+// NewAuthenticator loads the initial credentials from source
+func NewAuthenticator(source CredentialSource) (*Authenticator, error) {
+	a := &Authenticator{source: source}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload re-reads the credentials; on failure the previous ones stay active
+func (a *Authenticator) Reload() error {
+	creds, err := a.source()
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+	a.mu.Lock()
+	a.creds = creds
+	a.mu.Unlock()
+	return nil
+}
+
+// Credentials returns the active credentials
+func (a *Authenticator) Credentials() Credentials {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.creds
+}
+
+// WatchSIGHUP reloads credentials whenever the process receives SIGHUP.
+// Call the returned function to stop watching.
+func (a *Authenticator) WatchSIGHUP() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := a.Reload(); err != nil {
+					logger.Errorf("Agno credential reload failed, keeping previous credentials: %v", err)
+					continue
+				}
+				logger.Info("Agno credentials reloaded")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
+
+// Middleware authenticates every request with a bearer token and/or HMAC signature
+func (a *Authenticator) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			creds := a.Credentials()
+			if creds.IsZero() {
+				return next.RoundTrip(req)
+			}
+
+			req = req.Clone(req.Context())
+			if creds.BearerToken != "" {
+				req.Header.Set("Authorization", "Bearer "+creds.BearerToken)
+			}
+			if len(creds.HMACSecret) > 0 {
+				body, err := readRequestBody(req)
+				if err != nil {
+					return nil, err
+				}
+				timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+				req.Header.Set(KeyIDHeader, creds.HMACKeyID)
+				req.Header.Set(TimestampHeader, timestamp)
+				req.Header.Set(SignatureHeader, SignRequest(creds.HMACSecret, timestamp, req.Method, req.URL.RequestURI(), body))
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SignRequest computes the hex HMAC-SHA256 signature of a request:
+// HMAC(secret, timestamp + "\n" + method + "\n" + requestURI + "\n" + hex(sha256(body)))
+func SignRequest(secret []byte, timestamp, method, requestURI string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, method, requestURI, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest checks the HMAC signature of an incoming request (e.g. a
+// callback from the Agno service) and restores its body for later reads
+func VerifyRequest(req *http.Request, secret []byte) error {
+	if len(secret) == 0 {
+		return ErrMissingSecret
+	}
+	timestamp := req.Header.Get(TimestampHeader)
+	if err := checkTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	expected := SignRequest(secret, timestamp, req.Method, req.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get(SignatureHeader))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// checkSecret fails construction of a signed handler without a secret, so
+// a missing secret surfaces at startup instead of leaving the handler open
+// or rejecting every request
+func checkSecret(handler string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("%s: %w", handler, ErrMissingSecret)
+	}
+	return nil
+}
+
+// VerifyLarkSignature checks the signature Lark attaches to event webhooks:
+// hex(sha256(timestamp + nonce + encryptKey + body)). Without an encrypt key
+// anyone can compute it, so an empty key rejects every request.
+func VerifyLarkSignature(header http.Header, body []byte, encryptKey string) error {
+	if encryptKey == "" {
+		return ErrMissingSecret
+	}
+	timestamp := header.Get("X-Lark-Request-Timestamp")
+	if err := checkTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(timestamp + header.Get("X-Lark-Request-Nonce") + encryptKey + string(body)))
+	if !hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(header.Get("X-Lark-Signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyLarkToken compares the verification token of a (decrypted) Lark event
+// with the configured one in constant time
+func VerifyLarkToken(got, want string) error {
+	if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// maxLarkEventSize caps the webhook bodies LarkSignatureMiddleware reads
+const maxLarkEventSize = 1 << 20
+
+// LarkSignatureMiddleware rejects webhook requests without a valid Lark
+// signature. It returns ErrMissingSecret when encryptKey is empty.
+func LarkSignatureMiddleware(encryptKey string, next http.Handler) (http.Handler, error) {
+	if err := checkSecret("LarkSignatureMiddleware", []byte(encryptKey)); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxLarkEventSize+1))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxLarkEventSize {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := VerifyLarkSignature(r.Header, body, encryptKey); err != nil {
+			logger.Warnf("Rejected Lark webhook from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	}), nil
+}
+
+// checkTimestamp rejects missing or stale unix timestamps (replay protection)
+func checkTimestamp(timestamp string) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or malformed timestamp", ErrInvalidSignature)
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return fmt.Errorf("%w: timestamp outside allowed window", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// readRequestBody returns the body of an outgoing request without consuming it
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("cannot sign request: body is not replayable")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
@@ -0,0 +1,248 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"start-feishubot/logger"
+)
+
+// defaultBatchConcurrency is used when AgnoClient.BatchConcurrency is unset
+const defaultBatchConcurrency = 8
+
+// Batch endpoint support, detected on first use
+const (
+	batchUnknown int32 = iota
+	batchSupported
+	batchUnsupported
+)
+
+// ChatResult is the outcome of one request of a batch
+type ChatResult struct {
+	Request  ChatRequest
+	Response *ChatResponse
+	Err      error
+}
+
+// batchRequest is the body of POST /chat/batch
+type batchRequest struct {
+	Requests []ChatRequest `json:"requests"`
+}
+
+// batchResponse is the body returned by POST /chat/batch; results are in request order
+type batchResponse struct {
+	Results []struct {
+		ChatResponse
+		Error *struct {
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+			Detail string `json:"detail"`
+		} `json:"error,omitempty"`
+	} `json:"results"`
+}
+
+// ChatBatch sends many chat requests at once, e.g. for daily digests of
+// dozens of group chats. Results are in request order; per-item failures are
+// reported in ChatResult.Err. The error is only non-nil if ctx ends first.
+func (c *AgnoClient) ChatBatch(reqs []ChatRequest) ([]ChatResult, error) {
+	return c.ChatBatchContext(context.Background(), reqs)
+}
+
+// ChatBatchContext is like ChatBatch but carries ctx for cancellation and tracing.
+// It uses the service's /chat/batch endpoint when available and otherwise
+// fans out over SendChat with at most BatchConcurrency requests in flight.
+func (c *AgnoClient) ChatBatchContext(ctx context.Context, reqs []ChatRequest) (_ []ChatResult, err error) {
+	ctx, span := startSpan(ctx, "ChatBatch", attribute.Int("agno.batch_size", len(reqs)))
+	defer func() { endSpan(span, err) }()
+
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	if atomic.LoadInt32(&c.batchSupport) != batchUnsupported {
+		start := time.Now()
+		results, ok := c.chatBatchEndpoint(ctx, reqs)
+		if ok {
+			if c.ChatAudit != nil {
+				c.auditBatch(ctx, results, time.Since(start))
+			}
+			return results, ctx.Err()
+		}
+	}
+	return c.chatBatchFanOut(ctx, reqs), ctx.Err()
+}
+
+// chatBatchEndpoint sends reqs to /chat/batch. It returns false if the
+// endpoint is missing or failed as a whole, so the caller fans out instead.
+// Each item goes through the same steps as SendChat: debug, cache, events,
+// guards before the call and usage, fallback, caching and relay after it.
+func (c *AgnoClient) chatBatchEndpoint(ctx context.Context, reqs []ChatRequest) ([]ChatResult, bool) {
+	results := make([]ChatResult, len(reqs))
+	// items are the requests before guards, as SendChat hands them to the
+	// fallback and relay; send is what goes over the wire
+	var items, send []ChatRequest
+	var sendIndex []int
+	var cacheKeys []string
+	for i, req := range reqs {
+		results[i].Request = req
+		if c.Debug.Enabled(ctx, req.SessionID) {
+			req.Debug, req.NoCache = true, true
+		}
+		cached, cacheKey := c.lookupCache(ctx, req)
+		if cached != nil {
+			results[i].Response = cached
+			c.relayBatchItem(ctx, req, cached)
+			continue
+		}
+		if c.Events != nil {
+			req.IncludeSteps = true
+		}
+		item := req
+		if err := c.guardRequest(&req); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := c.applyModelParams(&req); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if req.ToolTimeouts == nil {
+			req.ToolTimeouts = c.toolTimeouts(ctx)
+		}
+		if req.Debug && c.Debug != nil {
+			c.Debug.logPayload(req.SessionID, "request", req)
+		}
+		items = append(items, item)
+		send = append(send, req)
+		sendIndex = append(sendIndex, i)
+		cacheKeys = append(cacheKeys, cacheKey)
+	}
+	if len(send) == 0 {
+		return results, true
+	}
+
+	jsonData, err := json.Marshal(batchRequest{Requests: send})
+	if err != nil {
+		logger.Errorf("Failed to marshal batch request: %v", err)
+		return nil, false
+	}
+	url := fmt.Sprintf("%s/chat/batch", c.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "chat-batch", "")
+	if err != nil {
+		logger.Warnf("Agno batch request failed, sending individually: %v", err)
+		return nil, false
+	}
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		if atomic.CompareAndSwapInt32(&c.batchSupport, batchUnknown, batchUnsupported) {
+			logger.Info("Agno service has no /chat/batch endpoint, fanning out batches")
+		}
+		return nil, false
+	default:
+		logger.Warnf("Agno batch request returned status %d, sending individually: %s", statusCode, string(body))
+		return nil, false
+	}
+
+	var batchResp batchResponse
+	if err := json.Unmarshal(body, &batchResp); err != nil || len(batchResp.Results) != len(send) {
+		logger.Warnf("Invalid Agno batch response, sending individually: %v", err)
+		return nil, false
+	}
+	atomic.StoreInt32(&c.batchSupport, batchSupported)
+
+	span := trace.SpanFromContext(ctx)
+	for j, result := range batchResp.Results {
+		i, item := sendIndex[j], items[j]
+		var resp *ChatResponse
+		var err error
+		if result.Error != nil {
+			status := result.Error.Status
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			err = &APIError{StatusCode: status, Code: result.Error.Code, Detail: result.Error.Detail}
+		} else {
+			chatResp := result.ChatResponse
+			resp = &chatResp
+			if send[j].Debug && c.Debug != nil {
+				c.Debug.capture(ctx, span, item.SessionID, resp)
+			}
+			if c.Usage != nil && resp.Usage != nil {
+				c.Usage.Add(c.tenant(item.SessionID), item.SessionID, *resp.Usage)
+			}
+		}
+		c.publishEvents(ctx, item.SessionID, resp, err)
+		if err != nil && c.Fallback != nil && shouldFallback(ctx, err) {
+			resp, err = c.fallbackChat(ctx, item, err)
+		} else if err == nil && cacheKeys[j] != "" {
+			c.Cache.Put(ctx, cacheKeys[j], resp)
+		}
+		if err == nil {
+			c.relayBatchItem(ctx, item, resp)
+		}
+		results[i].Response, results[i].Err = resp, err
+	}
+	return results, true
+}
+
+// relayBatchItem relays one answered batch item like SendChat does
+func (c *AgnoClient) relayBatchItem(ctx context.Context, req ChatRequest, resp *ChatResponse) {
+	if c.Relay != nil {
+		c.relayChat(ctx, req, resp)
+	}
+}
+
+// chatBatchFanOut sends every request through SendChat with bounded concurrency
+func (c *AgnoClient) chatBatchFanOut(ctx context.Context, reqs []ChatRequest) []ChatResult {
+	concurrency := c.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	results := make([]ChatResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		results[i].Request = req
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req ChatRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Response, results[i].Err = c.SendChat(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		logger.Warnf("Agno batch finished with %d/%d failed requests", failed, len(reqs))
+	}
+	return results
+}
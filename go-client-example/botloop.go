@@ -0,0 +1,163 @@
+package agno
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Reasons a message is dropped by LoopGuard
+const (
+	LoopBotSender = "bot_sender" // sent by another bot (Lark sender_type "app")
+	LoopSignature = "signature"  // carries our own reply signature
+	LoopEcho      = "echo"       // repeats one of our recent answers
+	LoopBurst     = "burst"      // too many replies in the chat in a short time
+)
+
+// loopSignature is appended to answers so quoted or forwarded copies can be
+// recognized (zero-width characters, invisible to readers)
+const loopSignature = "\u200b\u200c\u200b\u200d"
+
+var loopBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "loop_guard",
+	Name:      "blocked_total",
+	Help:      "Incoming messages ignored to prevent bot-to-bot loops, by reason.",
+}, []string{"reason"})
+
+// IncomingMessage is what LoopGuard needs to know about a Lark message
+type IncomingMessage struct {
+	ChatID     string
+	SenderID   string
+	SenderType string // Lark sender.sender_type: "user" or "app"
+	Text       string
+}
+
+// chatLoopState tracks the bot's recent answers in one chat
+type chatLoopState struct {
+	answers    map[string]time.Time // fingerprint -> when it was sent
+	replies    []time.Time
+	pauseUntil time.Time
+}
+
+// LoopGuard keeps the bot from talking to other bots or to its own quoted
+// answers in integrated groups. It drops messages from bots, messages
+// carrying the bot's reply signature or repeating a recent answer, and
+// pauses a chat for Cooldown when the bot replies more than MaxReplies
+// times within Window.
+type LoopGuard struct {
+	AllowBots  map[string]bool // bot IDs the bot may answer (e.g. a trusted relay)
+	EchoWindow time.Duration   // how long answers are remembered for echo detection
+	MaxReplies int
+	Window     time.Duration
+	Cooldown   time.Duration
+
+	mu    sync.Mutex
+	chats map[string]*chatLoopState
+}
+
+// NewLoopGuard creates a guard remembering answers for 10 minutes and
+// pausing a chat for 5 minutes after 10 replies within a minute
+func NewLoopGuard() *LoopGuard {
+	return &LoopGuard{
+		AllowBots:  make(map[string]bool),
+		EchoWindow: 10 * time.Minute,
+		MaxReplies: 10,
+		Window:     time.Minute,
+		Cooldown:   5 * time.Minute,
+		chats:      make(map[string]*chatLoopState),
+	}
+}
+
+// Check reports whether the bot may answer msg, or why it must stay silent
+func (g *LoopGuard) Check(msg IncomingMessage) (reason string, ok bool) {
+	switch {
+	case msg.SenderType == "app" && !g.AllowBots[msg.SenderID]:
+		reason = LoopBotSender
+	case strings.Contains(msg.Text, loopSignature):
+		reason = LoopSignature
+	}
+
+	if reason == "" {
+		now := time.Now()
+		g.mu.Lock()
+		state := g.state(msg.ChatID, now)
+		if now.Before(state.pauseUntil) {
+			reason = LoopBurst
+		} else if fp, ok := fingerprint(msg.Text); ok {
+			if _, seen := state.answers[fp]; seen {
+				reason = LoopEcho
+			}
+		}
+		g.mu.Unlock()
+	}
+
+	if reason == "" {
+		return "", true
+	}
+	loopBlocked.WithLabelValues(reason).Inc()
+	logger.Debugf("Loop guard ignored a message from %s in chat %s: %s", msg.SenderID, msg.ChatID, reason)
+	return reason, false
+}
+
+// Sign records an answer about to be posted in a chat and returns it with
+// the reply signature appended. Every answer should go through Sign so
+// echoes and bursts are detected.
+func (g *LoopGuard) Sign(chatID, answer string) string {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.state(chatID, now)
+	if fp, ok := fingerprint(answer); ok {
+		state.answers[fp] = now
+	}
+	state.replies = append(state.replies, now)
+	if len(state.replies) > g.MaxReplies && !now.Before(state.pauseUntil) {
+		state.pauseUntil = now.Add(g.Cooldown)
+		logger.Warnf("Loop guard paused chat %s for %s after %d replies within %s",
+			chatID, g.Cooldown, len(state.replies), g.Window)
+	}
+	return answer + loopSignature
+}
+
+// state returns a chat's state with expired entries dropped; g.mu must be held
+func (g *LoopGuard) state(chatID string, now time.Time) *chatLoopState {
+	state, ok := g.chats[chatID]
+	if !ok {
+		state = &chatLoopState{answers: make(map[string]time.Time)}
+		g.chats[chatID] = state
+	}
+	for fp, sent := range state.answers {
+		if now.Sub(sent) > g.EchoWindow {
+			delete(state.answers, fp)
+		}
+	}
+	recent := state.replies[:0]
+	for _, t := range state.replies {
+		if now.Sub(t) <= g.Window {
+			recent = append(recent, t)
+		}
+	}
+	state.replies = recent
+	return state
+}
+
+// fingerprint normalizes a text and hashes it; short texts ("ok", "thanks")
+// are not fingerprinted as they repeat naturally
+func fingerprint(text string) (string, bool) {
+	text = strings.ReplaceAll(text, loopSignature, "")
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if len([]rune(normalized)) < 20 {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16]), true
+}
@@ -0,0 +1,134 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X start-feishubot/services/agno.Version=$(git describe --tags) \
+//	  -X start-feishubot/services/agno.Commit=$(git rev-parse HEAD) \
+//	  -X start-feishubot/services/agno.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Commit and BuildDate fall back to the VCS stamp Go embeds in binaries
+// built from a checkout.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty checkout
+}
+
+var (
+	buildInfoOnce sync.Once
+	buildInfo     BuildInfo
+)
+
+// GetBuildInfo returns the build metadata of the running binary
+func GetBuildInfo() BuildInfo {
+	buildInfoOnce.Do(func() {
+		buildInfo = BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if buildInfo.Commit == "" {
+					buildInfo.Commit = s.Value
+				}
+			case "vcs.time":
+				if buildInfo.BuildDate == "" {
+					buildInfo.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				buildInfo.Modified = s.Value == "true"
+			}
+		}
+	})
+	return buildInfo
+}
+
+// ShortCommit returns the first 7 characters of the commit
+func (b BuildInfo) ShortCommit() string {
+	if len(b.Commit) > 7 {
+		return b.Commit[:7]
+	}
+	return b.Commit
+}
+
+// String renders the build as "v1.2.3 (abc1234, 2024-05-01T10:00:00Z, go1.22.3)"
+func (b BuildInfo) String() string {
+	details := []string{}
+	if commit := b.ShortCommit(); commit != "" {
+		if b.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, commit)
+	}
+	if b.BuildDate != "" {
+		details = append(details, b.BuildDate)
+	}
+	details = append(details, b.GoVersion)
+	return fmt.Sprintf("%s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+// UserAgent is sent with every request to the Agno service so it can track
+// which bot versions call it, e.g. "agno-lark-bot/v1.2.3 (abc1234; go1.22.3)"
+func UserAgent() string {
+	b := GetBuildInfo()
+	if commit := b.ShortCommit(); commit != "" {
+		return fmt.Sprintf("agno-lark-bot/%s (%s; %s)", b.Version, commit, b.GoVersion)
+	}
+	return fmt.Sprintf("agno-lark-bot/%s (%s)", b.Version, b.GoVersion)
+}
+
+// AdminCommand is "/admin <subcommand>" for admins. "version" reports the
+// build; subcommands adds more (e.g. "reload").
+func AdminCommand(subcommands map[string]CommandFunc) Command {
+	all := map[string]CommandFunc{
+		"version": func(ctx context.Context, cmd CommandContext) (string, error) {
+			return "🤖 " + GetBuildInfo().String(), nil
+		},
+	}
+	for name, fn := range subcommands {
+		all[name] = fn
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	usage := "/admin " + strings.Join(names, "|")
+
+	return Command{
+		Name:        "admin",
+		Usage:       usage,
+		Description: "Bot administration",
+		MinArgs:     1,
+		AdminOnly:   true,
+		Handler: func(ctx context.Context, cmd CommandContext) (string, error) {
+			fn, ok := all[strings.ToLower(cmd.Args[0])]
+			if !ok {
+				return fmt.Sprintf("Usage: `%s`", usage), nil
+			}
+			cmd.Args = cmd.Args[1:]
+			return fn(ctx, cmd)
+		},
+	}
+}
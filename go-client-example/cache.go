@@ -0,0 +1,256 @@
+package agno
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+
+	"start-feishubot/logger"
+)
+
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "cache",
+	Name:      "requests_total",
+	Help:      "Chat requests by response cache result (hit, miss, bypass).",
+}, []string{"result"})
+
+// CacheBackend stores serialized cached responses
+type CacheBackend interface {
+	Get(ctx context.Context, key string) ([]byte, error) // ErrKeyNotFound on a miss
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ResponseCache answers repeated identical questions (e.g. "what's the VPN
+// address?") without calling the model. Entries are keyed on the normalized
+// agent, model, sampling parameters, system prompt and message. Only text
+// requests without explicit history are cached, since history and images
+// change the answer.
+type ResponseCache struct {
+	Backend       CacheBackend
+	TTL           time.Duration
+	MaxEntryBytes int // responses larger than this are not cached (0 = no limit)
+}
+
+// NewResponseCache creates a cache keeping answers for an hour, up to 64KB each
+func NewResponseCache(backend CacheBackend) *ResponseCache {
+	return &ResponseCache{Backend: backend, TTL: time.Hour, MaxEntryBytes: 64 << 10}
+}
+
+// Key returns the cache key of a request, or false if it must not be cached
+func (c *ResponseCache) Key(req ChatRequest) (string, bool) {
+	if req.NoCache || len(req.History) > 0 || len(req.Parts) > 0 {
+		return "", false
+	}
+	temperature := ""
+	if req.Temperature != nil {
+		temperature = strconv.FormatFloat(*req.Temperature, 'g', -1, 64)
+	}
+	h := sha256.New()
+	for _, part := range []string{
+		req.AgentID, req.Model, temperature, strconv.Itoa(req.MaxTokens), req.ReasoningEffort,
+		normalizeCacheText(req.SystemPrompt), normalizeCacheText(req.Message),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// Get returns the cached answer for key
+func (c *ResponseCache) Get(ctx context.Context, key string) (*ChatResponse, bool) {
+	data, err := c.Backend.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			logger.Warnf("Response cache lookup failed: %v", err)
+		}
+		return nil, false
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		logger.Warnf("Dropping unreadable cached response: %v", err)
+		return nil, false
+	}
+	return &resp, true
+}
+
+// Put caches an answer under key
+func (c *ResponseCache) Put(ctx context.Context, key string, resp *ChatResponse) {
+	cached := *resp
+	cached.SessionID, cached.Usage, cached.Steps = "", nil, nil
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if c.MaxEntryBytes > 0 && len(data) > c.MaxEntryBytes {
+		return
+	}
+	if err := c.Backend.Set(ctx, key, data, c.TTL); err != nil {
+		logger.Warnf("Failed to cache response: %v", err)
+	}
+}
+
+// lookupCache returns a cached answer for reqBody and the key to store the
+// fresh answer under ("" when the request is not cacheable). A hit is only
+// served once the exchange is recorded in the session, so follow-up
+// questions keep their context; otherwise the model is asked.
+func (c *AgnoClient) lookupCache(ctx context.Context, reqBody ChatRequest) (*ChatResponse, string) {
+	if c.Cache == nil {
+		return nil, ""
+	}
+	key, ok := c.cacheKey(reqBody)
+	if !ok {
+		cacheRequests.WithLabelValues("bypass").Inc()
+		return nil, ""
+	}
+	resp, ok := c.Cache.Get(ctx, key)
+	if !ok {
+		cacheRequests.WithLabelValues("miss").Inc()
+		return nil, key
+	}
+	if err := c.recordCachedExchange(ctx, reqBody, resp); err != nil {
+		logger.Warnf("Failed to record cached answer in session %s, asking the model: %v", reqBody.SessionID, err)
+		cacheRequests.WithLabelValues("miss").Inc()
+		return nil, key
+	}
+	cacheRequests.WithLabelValues("hit").Inc()
+	logger.Debugf("Answering session %s from the response cache", reqBody.SessionID)
+	resp.SessionID = reqBody.SessionID
+	resp.Cached = true
+	return resp, ""
+}
+
+// cacheKey keys reqBody as it will be sent, with the tenant's model
+// defaults filled in
+func (c *AgnoClient) cacheKey(reqBody ChatRequest) (string, bool) {
+	if err := c.applyModelParams(&reqBody); err != nil {
+		return "", false
+	}
+	return c.Cache.Key(reqBody)
+}
+
+// recordCachedExchange appends the question and its cached answer to the
+// session's history, as the service would have
+func (c *AgnoClient) recordCachedExchange(ctx context.Context, reqBody ChatRequest, resp *ChatResponse) error {
+	if err := c.guardRequest(&reqBody); err != nil {
+		return err
+	}
+	return c.ImportSessionContext(ctx, reqBody.SessionID, []Message{
+		{Role: "user", Content: reqBody.Message},
+		{Role: "assistant", Content: resp.Response},
+	}, ImportAppend)
+}
+
+// normalizeCacheText lowercases text, collapses whitespace and drops
+// trailing punctuation so trivially different phrasings share an entry
+func normalizeCacheText(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimRight(text, "?!.。？！ ")
+}
+
+// lruCacheEntry is an element of MemoryCacheBackend's recency list
+type lruCacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCacheBackend is an in-process CacheBackend holding at most
+// MaxEntries entries, evicting the least recently used
+type MemoryCacheBackend struct {
+	MaxEntries int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewMemoryCacheBackend creates a backend holding up to maxEntries responses
+func NewMemoryCacheBackend(maxEntries int) *MemoryCacheBackend {
+	return &MemoryCacheBackend{
+		MaxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheBackend
+func (b *MemoryCacheBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	el, ok := b.items[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	entry := el.Value.(*lruCacheEntry)
+	if time.Now().After(entry.expires) {
+		b.order.Remove(el)
+		delete(b.items, key)
+		return nil, ErrKeyNotFound
+	}
+	b.order.MoveToFront(el)
+	return entry.value, nil
+}
+
+// Set implements CacheBackend
+func (b *MemoryCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry := &lruCacheEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if el, ok := b.items[key]; ok {
+		el.Value = entry
+		b.order.MoveToFront(el)
+		return nil
+	}
+	b.items[key] = b.order.PushFront(entry)
+	for b.order.Len() > b.MaxEntries {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.items, oldest.Value.(*lruCacheEntry).key)
+	}
+	return nil
+}
+
+// RedisCacheBackend is a CacheBackend shared by all replicas. Bound its
+// size with Redis' maxmemory and an LRU eviction policy.
+type RedisCacheBackend struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+// NewRedisCacheBackend creates a backend using keys prefixed with "agno:cache:"
+func NewRedisCacheBackend(client redis.Cmdable) *RedisCacheBackend {
+	return &RedisCacheBackend{Client: client, Prefix: "agno:cache:"}
+}
+
+// Get implements CacheBackend
+func (b *RedisCacheBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := b.Client.Get(ctx, b.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	return data, nil
+}
+
+// Set implements CacheBackend
+func (b *RedisCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := b.Client.Set(ctx, b.Prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
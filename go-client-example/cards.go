@@ -0,0 +1,48 @@
+package agno
+
+// CardAction is the "action" object Lark sends to the card callback URL
+type CardAction struct {
+	Tag       string                 `json:"tag"`
+	Name      string                 `json:"name,omitempty"`
+	Value     map[string]interface{} `json:"value,omitempty"`
+	FormValue map[string]interface{} `json:"form_value,omitempty"`
+	Option    string                 `json:"option,omitempty"`
+}
+
+// StringValue returns a string field from the action value payload
+func (a CardAction) StringValue(key string) string {
+	if v, ok := a.Value[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// plainText builds a Lark plain_text element
+func plainText(content string) map[string]interface{} {
+	return map[string]interface{}{"tag": "plain_text", "content": content}
+}
+
+// markdownElement builds a Lark markdown element
+func markdownElement(content string) map[string]interface{} {
+	return map[string]interface{}{"tag": "markdown", "content": content}
+}
+
+// cardHeader builds a Lark card header with the given title and color template
+func cardHeader(title, template string) map[string]interface{} {
+	return map[string]interface{}{"title": plainText(title), "template": template}
+}
+
+// callbackButton builds a button that posts value back to the card callback URL
+func callbackButton(text, buttonType string, value map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"tag":   "button",
+		"text":  plainText(text),
+		"type":  buttonType,
+		"value": value,
+	}
+}
+
+// actionModule wraps buttons in the "action" container Lark requires outside forms
+func actionModule(buttons ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"tag": "action", "actions": buttons}
+}
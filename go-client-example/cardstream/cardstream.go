@@ -0,0 +1,234 @@
+// Package cardstream renders a streaming Agno reply into a Feishu interactive
+// card by progressively patching it, within Lark's card update rate limits
+package cardstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+	"start-feishubot/services/agno"
+)
+
+// ErrRateLimited should be wrapped by Updater implementations when Lark
+// answers a card update with HTTP 429 (or error code 230020)
+var ErrRateLimited = errors.New("cardstream: lark rate limited")
+
+// RateLimitError is a rate-limit error carrying Lark's suggested retry delay
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("lark rate limited, retry after %s", e.RetryAfter)
+}
+
+// Unwrap makes errors.Is(err, ErrRateLimited) true
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// State is the lifecycle stage of a streamed card
+type State int
+
+const (
+	// StateTyping is shown while chunks are still arriving
+	StateTyping State = iota
+	// StateDone is the final successful state
+	StateDone
+	// StateFailed is the final state when the stream failed
+	StateFailed
+)
+
+// Updater patches an already-sent interactive card (PATCH /im/v1/messages/:message_id)
+type Updater interface {
+	UpdateCard(ctx context.Context, messageID string, card map[string]interface{}) error
+}
+
+// RenderFunc builds the card for the current text and state
+type RenderFunc func(text string, state State) map[string]interface{}
+
+// Streamer consumes a chunk stream and keeps a card in sync with it
+type Streamer struct {
+	Updater     Updater
+	Render      RenderFunc
+	MinInterval time.Duration // minimum gap between intermediate updates
+	MaxRetries  int           // retries per update on Lark rate limits
+	RetryDelay  time.Duration // base backoff when Lark gives no retry hint
+}
+
+// New creates a Streamer that updates at most twice a second
+func New(updater Updater) *Streamer {
+	return &Streamer{
+		Updater:     updater,
+		Render:      DefaultRender,
+		MinInterval: 500 * time.Millisecond,
+		MaxRetries:  3,
+		RetryDelay:  time.Second,
+	}
+}
+
+// Stream applies chunks to the card identified by messageID until the stream
+// ends, then writes the final card. It returns the full reply text.
+func (s *Streamer) Stream(ctx context.Context, messageID string, chunks <-chan agno.StreamChunk) (string, error) {
+	var text strings.Builder
+	dirty, started := false, false
+	nextUpdate := time.Time{}
+
+	push := func() {
+		if err := s.update(ctx, messageID, text.String()); err != nil {
+			// Keep the card dirty so a later tick retries, honoring Lark's hint
+			nextUpdate = time.Now().Add(s.backoff(err, 0))
+			return
+		}
+		dirty, nextUpdate = false, time.Now().Add(s.MinInterval)
+	}
+
+	ticker := time.NewTicker(s.MinInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return s.finish(ctx, messageID, text.String(), errors.New("stream closed before completion"))
+			}
+			if chunk.Error != "" {
+				return s.finish(ctx, messageID, text.String(), errors.New(chunk.Error))
+			}
+			text.WriteString(chunk.Content)
+			dirty = dirty || chunk.Content != ""
+			if chunk.Done {
+				return s.finish(ctx, messageID, text.String(), nil)
+			}
+			// Push the first chunk immediately so the user sees the reply start
+			if !started && dirty {
+				started = true
+				push()
+			}
+
+		case <-ticker.C:
+			if dirty && !time.Now().Before(nextUpdate) {
+				push()
+			}
+
+		case <-ctx.Done():
+			return s.finish(context.Background(), messageID, text.String(), ctx.Err())
+		}
+	}
+}
+
+// update sends an intermediate card
+func (s *Streamer) update(ctx context.Context, messageID, text string) error {
+	err := s.Updater.UpdateCard(ctx, messageID, s.Render(text, StateTyping))
+	if err != nil {
+		logger.Warnf("Card stream update for %s failed: %v", messageID, err)
+	}
+	return err
+}
+
+// backoff returns how long to wait after a failed update
+func (s *Streamer) backoff(err error, attempt int) time.Duration {
+	var rl *RateLimitError
+	if errors.As(err, &rl) && rl.RetryAfter > 0 {
+		return rl.RetryAfter
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return s.RetryDelay << attempt
+	}
+	return s.MinInterval
+}
+
+// finish writes the final card, retrying through rate limits
+func (s *Streamer) finish(ctx context.Context, messageID, text string, streamErr error) (string, error) {
+	state := StateDone
+	if streamErr != nil {
+		state = StateFailed
+		logger.Errorf("Card stream for %s failed: %v", messageID, streamErr)
+	}
+
+	card := s.Render(text, state)
+	var err error
+	for attempt := 0; attempt <= s.MaxRetries; attempt++ {
+		if err = s.Updater.UpdateCard(ctx, messageID, card); err == nil || !errors.Is(err, ErrRateLimited) {
+			break
+		}
+
+		delay := s.backoff(err, attempt)
+		logger.Warnf("Lark rate limited final card update for %s, retrying in %s", messageID, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return text, ctx.Err()
+		}
+	}
+
+	if err != nil {
+		return text, fmt.Errorf("failed to finalize card: %w", err)
+	}
+	return text, streamErr
+}
+
+// DefaultRender renders the reply as a markdown card with a typing indicator
+// while streaming and a status header once finished
+func DefaultRender(text string, state State) map[string]interface{} {
+	title, template := "🤖 Typing…", "blue"
+	body := CloseMarkdown(text) + " ▌"
+
+	switch state {
+	case StateDone:
+		title, template = "🤖 Done", "green"
+		body = text
+	case StateFailed:
+		title, template = "🤖 Something went wrong", "red"
+		body = CloseMarkdown(text) + "\n\n_The answer was interrupted. Please try again._"
+	}
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": map[string]interface{}{
+			"title":    map[string]interface{}{"tag": "plain_text", "content": title},
+			"template": template,
+		},
+		"elements": []interface{}{
+			map[string]interface{}{"tag": "markdown", "content": body},
+		},
+	}
+}
+
+// WithFooter wraps render so the final card ends with a disclosure footer
+// (see agno.Disclosures.Footer). Footers carry a per-answer marker, so wrap
+// a copy of the Streamer for each answer.
+func WithFooter(render RenderFunc, footer string) RenderFunc {
+	if footer == "" {
+		return render
+	}
+	return func(text string, state State) map[string]interface{} {
+		card := render(text, state)
+		if state != StateDone {
+			return card
+		}
+		if elements, ok := card["elements"].([]interface{}); ok {
+			card["elements"] = append(elements, agno.DisclosureElement(footer))
+		}
+		return card
+	}
+}
+
+// CloseMarkdown balances constructs that would otherwise break rendering of a
+// partial answer: unclosed code fences, inline code and bold markers
+func CloseMarkdown(text string) string {
+	if strings.Count(text, "```")%2 == 1 {
+		return text + "\n```"
+	}
+	if strings.Count(strings.ReplaceAll(text, "```", ""), "`")%2 == 1 {
+		text += "`"
+	}
+	if strings.Count(text, "**")%2 == 1 {
+		text += "**"
+	}
+	return text
+}
@@ -0,0 +1,478 @@
+package agno
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+var chatAuditRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "chat_audit",
+	Name:      "records_total",
+	Help:      "Chat audit records by outcome (written, sampled_out, failed).",
+}, []string{"result"})
+
+// ChatAuditRecord is the compliance record of one chat: who asked, in which
+// session, and fingerprints of what was asked and answered. The prompt and
+// response themselves are only kept (redacted) when IncludeContent is set.
+type ChatAuditRecord struct {
+	Time             time.Time `json:"time"`
+	Tenant           string    `json:"tenant"`
+	UserID           string    `json:"user_id,omitempty"`
+	ChatID           string    `json:"chat_id,omitempty"`
+	SessionID        string    `json:"session_id"`
+	AgentID          string    `json:"agent_id,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	PromptHash       string    `json:"prompt_hash"`
+	ResponseHash     string    `json:"response_hash,omitempty"`
+	Prompt           string    `json:"prompt,omitempty"`
+	Response         string    `json:"response,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens,omitempty"`
+	LatencyMS        int64     `json:"latency_ms"`
+	Cached           bool      `json:"cached,omitempty"`
+	Degraded         bool      `json:"degraded,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// AuditSink stores chat audit records
+type AuditSink interface {
+	Write(ctx context.Context, record ChatAuditRecord) error
+}
+
+// FileAuditSink appends records as JSON lines to a file
+type FileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink opens (or creates) path for appending
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// Write implements AuditSink
+func (s *FileAuditSink) Write(ctx context.Context, record ChatAuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// SQLAuditSink inserts records into a chat_audit table, e.g. in SQLite. The
+// bot opens the *sql.DB with the driver it links in.
+type SQLAuditSink struct {
+	DB *sql.DB
+}
+
+// NewSQLAuditSink creates the chat_audit table if it doesn't exist
+func NewSQLAuditSink(ctx context.Context, db *sql.DB) (*SQLAuditSink, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS chat_audit (
+		time TIMESTAMP NOT NULL,
+		tenant TEXT NOT NULL,
+		user_id TEXT,
+		chat_id TEXT,
+		session_id TEXT NOT NULL,
+		agent_id TEXT,
+		model TEXT,
+		prompt_hash TEXT NOT NULL,
+		response_hash TEXT,
+		prompt TEXT,
+		response TEXT,
+		prompt_tokens INTEGER,
+		completion_tokens INTEGER,
+		total_tokens INTEGER,
+		latency_ms INTEGER,
+		cached BOOLEAN,
+		degraded BOOLEAN,
+		error TEXT
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat_audit table: %w", err)
+	}
+	return &SQLAuditSink{DB: db}, nil
+}
+
+// Write implements AuditSink
+func (s *SQLAuditSink) Write(ctx context.Context, r ChatAuditRecord) error {
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO chat_audit VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Time, r.Tenant, r.UserID, r.ChatID, r.SessionID, r.AgentID, r.Model, r.PromptHash, r.ResponseHash,
+		r.Prompt, r.Response, r.PromptTokens, r.CompletionTokens, r.TotalTokens, r.LatencyMS, r.Cached, r.Degraded, r.Error)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit record: %w", err)
+	}
+	return nil
+}
+
+// KafkaProducer is the subset of a Kafka client used by KafkaAuditSink
+// (implemented by the bot around its client of choice)
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaAuditSink produces records as JSON to Topic, keyed by session so a
+// conversation's records stay ordered within a partition
+type KafkaAuditSink struct {
+	Producer KafkaProducer
+	Topic    string
+}
+
+// Write implements AuditSink
+func (s *KafkaAuditSink) Write(ctx context.Context, record ChatAuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	if err := s.Producer.Produce(ctx, s.Topic, []byte(record.SessionID), data); err != nil {
+		return fmt.Errorf("failed to produce audit record: %w", err)
+	}
+	return nil
+}
+
+// RedactionRule replaces matches of Pattern in audited content
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultRedactionRules mask the personal data RedactPII masks
+func DefaultRedactionRules() []RedactionRule {
+	names := []string{"email", "phone", "card", "api_key"}
+	rules := make([]RedactionRule, len(piiPatterns))
+	for i, p := range piiPatterns {
+		rules[i] = RedactionRule{Name: names[i], Pattern: p, Replacement: "[REDACTED]"}
+	}
+	return rules
+}
+
+// requesterKey is the context key carrying the Lark user and chat of a request
+type requesterKey struct{}
+
+// requester is the user and chat carried by WithRequester
+type requester struct{ userID, chatID string }
+
+// WithRequester returns a context naming the Lark user and chat a chat
+// request is made for, recorded by ChatAuditor
+func WithRequester(ctx context.Context, userID, chatID string) context.Context {
+	return context.WithValue(ctx, requesterKey{}, requester{userID: userID, chatID: chatID})
+}
+
+// RequesterFromContext returns the user and chat set by WithRequester
+func RequesterFromContext(ctx context.Context) (userID, chatID string) {
+	r, _ := ctx.Value(requesterKey{}).(requester)
+	return r.userID, r.chatID
+}
+
+// ChatAuditor records every chat of a client to a sink (set
+// AgnoClient.ChatAudit). Records are written in the background once
+// started; a full queue makes callers write synchronously rather than lose
+// records.
+type ChatAuditor struct {
+	Sink AuditSink
+
+	// SampleRate is the share of sessions audited (0 to 1). Sampling is per
+	// session, so audited conversations are complete; failed chats are
+	// always recorded.
+	SampleRate float64
+
+	// HashKey, when set, makes prompt and response hashes HMAC-SHA256 so
+	// they can't be matched against guessed prompts without the key
+	HashKey []byte
+
+	// IncludeContent keeps the prompt and response, after Redaction
+	IncludeContent bool
+	Redaction      []RedactionRule
+
+	Queue int // records buffered for the background writer
+
+	// JobMaxAge is how long an async job's record waits for its answer
+	// before it is written without one (default 24h)
+	JobMaxAge time.Duration
+
+	mu    sync.RWMutex
+	queue chan ChatAuditRecord
+	done  chan struct{}
+
+	jobsMu sync.Mutex
+	jobs   map[string]pendingJobAudit
+}
+
+// defaultJobAuditAge is used when ChatAuditor.JobMaxAge is unset
+const defaultJobAuditAge = 24 * time.Hour
+
+// NewChatAuditor creates an auditor recording every session, hashes only
+func NewChatAuditor(sink AuditSink) *ChatAuditor {
+	return &ChatAuditor{
+		Sink:       sink,
+		SampleRate: 1,
+		Redaction:  DefaultRedactionRules(),
+		Queue:      1024,
+	}
+}
+
+// NewChatAuditorFromEnv configures an auditor from AGNO_AUDIT_SAMPLE_RATE
+// (default 1), AGNO_AUDIT_HASH_KEY and AGNO_AUDIT_CONTENT ("true" keeps
+// redacted content)
+func NewChatAuditorFromEnv(sink AuditSink) (*ChatAuditor, error) {
+	a := NewChatAuditor(sink)
+	if v := os.Getenv("AGNO_AUDIT_SAMPLE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid AGNO_AUDIT_SAMPLE_RATE %q: want a number from 0 to 1", v)
+		}
+		a.SampleRate = rate
+	}
+	if key := os.Getenv("AGNO_AUDIT_HASH_KEY"); key != "" {
+		a.HashKey = []byte(key)
+	}
+	a.IncludeContent = os.Getenv("AGNO_AUDIT_CONTENT") == "true"
+	return a, nil
+}
+
+// Start writes queued records in the background
+func (a *ChatAuditor) Start() {
+	queue := make(chan ChatAuditRecord, a.Queue)
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		for record := range queue {
+			a.write(context.Background(), record)
+		}
+	}()
+
+	a.mu.Lock()
+	a.queue = queue
+	a.mu.Unlock()
+}
+
+// Stop writes the remaining queued records and stops the background writer
+func (a *ChatAuditor) Stop() {
+	a.mu.Lock()
+	queue := a.queue
+	a.queue = nil
+	a.mu.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-a.done
+}
+
+// Record audits one chat
+func (a *ChatAuditor) Record(ctx context.Context, record ChatAuditRecord) {
+	if record.Error == "" && !a.sampled(record.SessionID) {
+		chatAuditRecords.WithLabelValues("sampled_out").Inc()
+		return
+	}
+	a.mu.RLock()
+	if a.queue != nil {
+		select {
+		case a.queue <- record:
+			a.mu.RUnlock()
+			return
+		default:
+		}
+	}
+	a.mu.RUnlock()
+	a.write(ctx, record)
+}
+
+// sampled decides deterministically whether a session is audited
+func (a *ChatAuditor) sampled(sessionID string) bool {
+	if a.SampleRate >= 1 {
+		return true
+	}
+	sum := sha256.Sum256([]byte(sessionID))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/float64(^uint64(0)) < a.SampleRate
+}
+
+// write sends a record to the sink, logging failures
+func (a *ChatAuditor) write(ctx context.Context, record ChatAuditRecord) {
+	if err := a.Sink.Write(ctx, record); err != nil {
+		chatAuditRecords.WithLabelValues("failed").Inc()
+		logger.Errorf("Failed to write chat audit record for session %s: %v", record.SessionID, err)
+		return
+	}
+	chatAuditRecords.WithLabelValues("written").Inc()
+}
+
+// hash fingerprints audited content
+func (a *ChatAuditor) hash(s string) string {
+	if len(a.HashKey) > 0 {
+		mac := hmac.New(sha256.New, a.HashKey)
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// redact applies the redaction rules to audited content
+func (a *ChatAuditor) redact(s string) string {
+	for _, rule := range a.Redaction {
+		s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+	return s
+}
+
+// auditChat builds and records the audit record of a finished chat
+func (c *AgnoClient) auditChat(ctx context.Context, req ChatRequest, resp *ChatResponse, err error, latency time.Duration) {
+	record := c.auditRecord(ctx, req)
+	c.ChatAudit.finish(&record, resp, err, latency)
+	c.ChatAudit.Record(ctx, record)
+}
+
+// auditRecord starts the audit record of a chat with who asked what
+func (c *AgnoClient) auditRecord(ctx context.Context, req ChatRequest) ChatAuditRecord {
+	a := c.ChatAudit
+	userID, chatID := RequesterFromContext(ctx)
+	record := ChatAuditRecord{
+		Time:       time.Now().UTC(),
+		Tenant:     c.tenant(req.SessionID),
+		UserID:     userID,
+		ChatID:     chatID,
+		SessionID:  req.SessionID,
+		AgentID:    req.AgentID,
+		Model:      req.Model,
+		PromptHash: a.hash(req.Message),
+	}
+	if a.IncludeContent {
+		record.Prompt = a.redact(req.Message)
+	}
+	return record
+}
+
+// finish completes record with the outcome of the chat
+func (a *ChatAuditor) finish(record *ChatAuditRecord, resp *ChatResponse, err error, latency time.Duration) {
+	record.LatencyMS = latency.Milliseconds()
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if resp != nil {
+		record.ResponseHash = a.hash(resp.Response)
+		record.Cached, record.Degraded = resp.Cached, resp.Degraded
+		if a.IncludeContent {
+			record.Response = a.redact(resp.Response)
+		}
+		if resp.Usage != nil {
+			record.PromptTokens = resp.Usage.PromptTokens
+			record.CompletionTokens = resp.Usage.CompletionTokens
+			record.TotalTokens = resp.Usage.TotalTokens
+		}
+	}
+}
+
+// auditStream forwards the chunks of a stream and records the streamed
+// answer once the stream ends
+func (c *AgnoClient) auditStream(ctx context.Context, req ChatRequest, in <-chan StreamChunk, start time.Time) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var answer strings.Builder
+		var streamErr error
+		for chunk := range in {
+			answer.WriteString(chunk.Content)
+			if chunk.Error != "" {
+				streamErr = errors.New(chunk.Error)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				streamErr = ctx.Err()
+				for range in {
+				}
+			}
+		}
+		resp := &ChatResponse{SessionID: req.SessionID, Response: answer.String()}
+		c.auditChat(ctx, req, resp, streamErr, time.Since(start))
+	}()
+	return out
+}
+
+// auditBatch records the results of a batch answered by /chat/batch
+func (c *AgnoClient) auditBatch(ctx context.Context, results []ChatResult, latency time.Duration) {
+	for _, result := range results {
+		c.auditChat(ctx, result.Request, result.Response, result.Err, latency)
+	}
+}
+
+// pendingJobAudit is the audit record of a submitted job awaiting its answer
+type pendingJobAudit struct {
+	record    ChatAuditRecord
+	submitted time.Time
+}
+
+// submitJob holds the record of an accepted job until its answer is seen.
+// Jobs pending longer than JobMaxAge are recorded without an answer.
+func (a *ChatAuditor) submitJob(ctx context.Context, jobID string, record ChatAuditRecord) {
+	maxAge := a.JobMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultJobAuditAge
+	}
+	var expired []pendingJobAudit
+	a.jobsMu.Lock()
+	for id, job := range a.jobs {
+		if time.Since(job.submitted) > maxAge {
+			expired = append(expired, job)
+			delete(a.jobs, id)
+		}
+	}
+	if a.jobs == nil {
+		a.jobs = make(map[string]pendingJobAudit)
+	}
+	a.jobs[jobID] = pendingJobAudit{record: record, submitted: time.Now()}
+	a.jobsMu.Unlock()
+
+	for _, job := range expired {
+		a.finish(&job.record, nil, fmt.Errorf("no job result seen within %s", maxAge), time.Since(job.submitted))
+		a.Record(ctx, job.record)
+	}
+}
+
+// finishJob records the answer or failure of a submitted job once
+func (a *ChatAuditor) finishJob(ctx context.Context, jobID string, resp *ChatResponse, err error) {
+	a.jobsMu.Lock()
+	job, ok := a.jobs[jobID]
+	delete(a.jobs, jobID)
+	a.jobsMu.Unlock()
+	if !ok {
+		return
+	}
+	a.finish(&job.record, resp, err, time.Since(job.submitted))
+	a.Record(ctx, job.record)
+}
@@ -0,0 +1,84 @@
+package agno
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"start-feishubot/logger"
+)
+
+// ChatMode controls how the bot behaves in a chat
+type ChatMode string
+
+const (
+	// ChatModeInteractive answers questions as usual
+	ChatModeInteractive ChatMode = "interactive"
+	// ChatModeKiosk only posts digests/announcements and declines questions
+	ChatModeKiosk ChatMode = "kiosk"
+)
+
+// DefaultKioskReply is sent when someone asks a question in a kiosk chat
+const DefaultKioskReply = "👋 This is an announcement channel, so I don't answer questions here. " +
+	"Send me a direct message and I'll be happy to help!"
+
+// ChatModes holds the per-chat mode configuration
+type ChatModes struct {
+	KioskReply string
+
+	mu    sync.RWMutex
+	modes map[string]ChatMode
+}
+
+// NewChatModes creates an empty configuration; unknown chats are interactive
+func NewChatModes() *ChatModes {
+	return &ChatModes{
+		KioskReply: DefaultKioskReply,
+		modes:      make(map[string]ChatMode),
+	}
+}
+
+// NewChatModesFromEnv loads kiosk chats from AGNO_KIOSK_CHATS (comma-separated chat IDs)
+func NewChatModesFromEnv() *ChatModes {
+	modes := NewChatModes()
+	for _, chatID := range strings.Split(os.Getenv("AGNO_KIOSK_CHATS"), ",") {
+		if chatID = strings.TrimSpace(chatID); chatID != "" {
+			modes.Set(chatID, ChatModeKiosk)
+		}
+	}
+	if n := len(modes.modes); n > 0 {
+		logger.Infof("Kiosk mode enabled for %d chat(s)", n)
+	}
+	return modes
+}
+
+// Set configures the mode for a chat
+func (m *ChatModes) Set(chatID string, mode ChatMode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mode == ChatModeInteractive {
+		delete(m.modes, chatID)
+		return
+	}
+	m.modes[chatID] = mode
+}
+
+// Get returns the mode for a chat
+func (m *ChatModes) Get(chatID string) ChatMode {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if mode, ok := m.modes[chatID]; ok {
+		return mode
+	}
+	return ChatModeInteractive
+}
+
+// InteractiveReply reports whether the bot may answer a user message in the
+// chat. If not, it returns the polite decline to send instead. Direct
+// messages (p2p chats) are always interactive.
+func (m *ChatModes) InteractiveReply(chatID, chatType string) (string, bool) {
+	if chatType == "p2p" || m.Get(chatID) != ChatModeKiosk {
+		return "", true
+	}
+	return m.KioskReply, false
+}
@@ -0,0 +1,294 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Citation verification results, stored in Citation.Verification
+const (
+	// CitationVerified: the source contains the cited snippet
+	CitationVerified = "verified"
+	// CitationUnverified: the source was read but doesn't contain the snippet
+	CitationUnverified = "unverified"
+	// CitationUnreachable: the source couldn't be read
+	CitationUnreachable = "unreachable"
+)
+
+var citationChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "citations",
+	Name:      "checked_total",
+	Help:      "Cited sources checked by result (verified, unverified, unreachable).",
+}, []string{"result"})
+
+var (
+	htmlNoise      = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+	htmlTag        = regexp.MustCompile(`(?s)<[^>]*>`)
+	nonWordPattern = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// SourceFetcher reads the text of a cited source
+type SourceFetcher interface {
+	FetchSource(ctx context.Context, sourceURL string) (string, error)
+}
+
+// HTTPSourceFetcher reads http(s) sources, reduced to their text if HTML
+type HTTPSourceFetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+// NewHTTPSourceFetcher creates a fetcher reading up to 2MB per source
+func NewHTTPSourceFetcher() *HTTPSourceFetcher {
+	return &HTTPSourceFetcher{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		MaxBytes: 2 << 20,
+	}
+}
+
+// FetchSource implements SourceFetcher
+func (f *HTTPSourceFetcher) FetchSource(ctx context.Context, sourceURL string) (string, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("unsupported source URL %q", sourceURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", sourceURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to fetch %s: status %d", sourceURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", sourceURL, err)
+	}
+	text := string(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		text = htmlNoise.ReplaceAllString(text, " ")
+		text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	}
+	return text, nil
+}
+
+// CitationVerifier checks, before an answer is rendered, that its cited
+// sources contain the content the agent attributes to them: first by
+// looking for the snippet in the source text, then, with an Embedder, by
+// comparing the snippet with the source's passages. Citations that fail
+// are marked so FormatSources can flag them, or dropped with
+// DropUnverified, so a made-up quote isn't shown as confidently sourced.
+type CitationVerifier struct {
+	Fetcher  SourceFetcher
+	Embedder Embedder // optional; without it only literal matches verify
+
+	MinSimilarity  float64       // cosine similarity for an embedding match
+	Timeout        time.Duration // budget for checking one answer's citations
+	DropUnverified bool          // drop failed citations instead of flagging them
+}
+
+// NewCitationVerifier creates a verifier fetching sources over HTTP, with
+// a 5s budget per answer
+func NewCitationVerifier(embedder Embedder) *CitationVerifier {
+	return &CitationVerifier{
+		Fetcher:       NewHTTPSourceFetcher(),
+		Embedder:      embedder,
+		MinSimilarity: 0.85,
+		Timeout:       5 * time.Second,
+	}
+}
+
+// VerifyResponse verifies resp.Citations in place
+func (v *CitationVerifier) VerifyResponse(ctx context.Context, resp *ChatResponse) {
+	if resp != nil && len(resp.Citations) > 0 {
+		resp.Citations = v.Verify(ctx, resp.Citations)
+	}
+}
+
+// Verify returns citations with Verification set, leaving out the failed
+// ones with DropUnverified. Each source is fetched once, concurrently;
+// sources not read within Timeout are unreachable.
+func (v *CitationVerifier) Verify(ctx context.Context, citations []Citation) []Citation {
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
+	}
+
+	type source struct {
+		text string
+		err  error
+	}
+	sources := make(map[string]*source)
+	var wg sync.WaitGroup
+	for _, citation := range citations {
+		if citation.URL == "" || sources[citation.URL] != nil {
+			continue
+		}
+		src := &source{}
+		sources[citation.URL] = src
+		wg.Add(1)
+		go func(sourceURL string) {
+			defer wg.Done()
+			src.text, src.err = v.Fetcher.FetchSource(ctx, sourceURL)
+		}(citation.URL)
+	}
+	wg.Wait()
+
+	verified := make([]Citation, 0, len(citations))
+	for _, citation := range citations {
+		src := sources[citation.URL]
+		switch {
+		case src == nil:
+			citation.Verification = CitationUnreachable
+		case src.err != nil:
+			logger.Debugf("Cited source %s unreachable: %v", citation.URL, src.err)
+			citation.Verification = CitationUnreachable
+		case v.supports(ctx, src.text, citation.Snippet):
+			citation.Verification = CitationVerified
+		default:
+			citation.Verification = CitationUnverified
+		}
+		citationChecks.WithLabelValues(citation.Verification).Inc()
+		if citation.Verification != CitationVerified && v.DropUnverified {
+			logger.Infof("Dropped %s citation %s", citation.Verification, citation.URL)
+			continue
+		}
+		verified = append(verified, citation)
+	}
+	return verified
+}
+
+// supports reports whether text contains snippet, literally (ignoring case,
+// punctuation and spacing) or, with an Embedder, in meaning. A citation
+// without a snippet claims only that the source exists.
+func (v *CitationVerifier) supports(ctx context.Context, text, snippet string) bool {
+	snippet = normalizeForMatch(snippet)
+	if snippet == "" {
+		return true
+	}
+	text = normalizeForMatch(text)
+	if strings.Contains(text, snippet) {
+		return true
+	}
+	if v.Embedder == nil {
+		return false
+	}
+
+	passages := sourcePassages(text, len(strings.Fields(snippet)), 64)
+	if len(passages) == 0 {
+		return false
+	}
+	vectors, err := v.Embedder.Embed(ctx, append([]string{snippet}, passages...))
+	if err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			logger.Warnf("Failed to embed cited source: %v", err)
+		}
+		return false
+	}
+	for _, vector := range vectors[1:] {
+		if cosineSimilarity(vectors[0], vector) >= v.MinSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeForMatch lowercases s and reduces it to words separated by single spaces
+func normalizeForMatch(s string) string {
+	return strings.TrimSpace(nonWordPattern.ReplaceAllString(strings.ToLower(s), " "))
+}
+
+// sourcePassages splits text into overlapping windows of twice the snippet
+// length, at most limit of them
+func sourcePassages(text string, snippetWords, limit int) []string {
+	words := strings.Fields(text)
+	size := 2 * snippetWords
+	if size < 20 {
+		size = 20
+	}
+	stride := size / 2
+	if n := (len(words) + stride - 1) / stride; n > limit {
+		stride = (len(words) + limit - 1) / limit // cover the whole source
+	}
+	var passages []string
+	for start := 0; start < len(words) && len(passages) < limit; start += stride {
+		end := start + size
+		if end > len(words) {
+			end = len(words)
+		}
+		passages = append(passages, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+	}
+	return passages
+}
+
+// FormatSources renders citations as a markdown sources section for an
+// answer card. Verified and unchecked sources come first; the ones that
+// failed verification follow with a warning and without their snippet.
+func FormatSources(citations []Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	var trusted, flagged []Citation
+	for _, citation := range citations {
+		if citation.Verification == CitationUnverified || citation.Verification == CitationUnreachable {
+			flagged = append(flagged, citation)
+		} else {
+			trusted = append(trusted, citation)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("**Sources**")
+	n := 0
+	for _, citation := range trusted {
+		n++
+		fmt.Fprintf(&b, "\n%d. %s", n, sourceLink(citation))
+		if citation.Snippet != "" {
+			fmt.Fprintf(&b, "\n> %s", citation.Snippet)
+		}
+	}
+	for _, citation := range flagged {
+		n++
+		note := "could not be verified"
+		if citation.Verification == CitationUnreachable {
+			note = "could not be checked"
+		}
+		fmt.Fprintf(&b, "\n%d. ⚠️ %s _(%s)_", n, sourceLink(citation), note)
+	}
+	return b.String()
+}
+
+// sourceLink formats a citation as a markdown link, or its title without a URL
+func sourceLink(citation Citation) string {
+	title := citation.Title
+	if title == "" {
+		title = citation.URL
+	}
+	if citation.URL == "" {
+		return title
+	}
+	return fmt.Sprintf("[%s](%s)", title, citation.URL)
+}
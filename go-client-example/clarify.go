@@ -0,0 +1,388 @@
+package agno
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Card action values used by the clarification card
+const (
+	clarifyActionKey    = "clarify_action"
+	clarifyIDKey        = "clarify_id"
+	clarifyOptionKey    = "clarify_option"
+	clarifyActionOption = "option"
+	clarifyActionSubmit = "submit"
+	clarifyActionSkip   = "skip"
+
+	clarifyPartField = "clarify_part_"
+	clarifyKeyPrefix = "clarify:"
+)
+
+// clarifyPrompt asks the cheap model whether a message needs clarifying
+const clarifyPrompt = "Decide whether the user's message can be answered well as it is. " +
+	"If it is ambiguous in a way that would change the answer, reply with " +
+	`{"ambiguous": true, "parts": [{"question": "...", "options": ["...", "..."]}]}` +
+	" using at most %d short questions with 2 to %d short options each. Otherwise reply with " +
+	`{"ambiguous": false}` + ". Reply with JSON only."
+
+var clarifications = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "clarify",
+	Name:      "questions_total",
+	Help:      "Messages checked for ambiguity by result (clear, asked, answered, skipped, failed).",
+}, []string{"result"})
+
+// ClarificationPart is one question asked back, with the options offered
+type ClarificationPart struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+// Clarification is a pending clarification of an ambiguous message
+type Clarification struct {
+	ID        string              `json:"id"`
+	SessionID string              `json:"session_id"`
+	UserID    string              `json:"user_id,omitempty"` // only the asker may answer
+	Question  string              `json:"question"`          // the original message
+	Parts     []ClarificationPart `json:"parts"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// ClarifiedQuestion is a clarification answered from the card
+type ClarifiedQuestion struct {
+	SessionID string
+	UserID    string
+	Original  string
+	Answers   []string // one per part; empty when skipped
+	// Message is the prompt to send: the original message with the
+	// clarifications merged in
+	Message string
+	// Card replaces the clarification card
+	Card map[string]interface{}
+}
+
+// Clarifier asks a structured clarification question, with option buttons,
+// before an ambiguous message is answered, so tokens aren't spent on an
+// answer to the wrong question. The cheap model decides what is ambiguous;
+// pending clarifications are kept under "clarify:<id>" keys.
+type Clarifier struct {
+	Client     AgnoService
+	Model      string // cheap model used for the check
+	Store      SessionStore
+	TTL        time.Duration // how long a clarification can be answered
+	MaxParts   int
+	MaxOptions int
+	Timeout    time.Duration
+}
+
+// NewClarifierFromEnv checks with AGNO_FAST_MODEL (default gpt-4o-mini),
+// asking up to AGNO_CLARIFY_MAX_PARTS questions (default 2)
+func NewClarifierFromEnv(client AgnoService, store SessionStore) *Clarifier {
+	model := os.Getenv("AGNO_FAST_MODEL")
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &Clarifier{
+		Client:     client,
+		Model:      model,
+		Store:      store,
+		TTL:        time.Hour,
+		MaxParts:   envInt("AGNO_CLARIFY_MAX_PARTS", 2),
+		MaxOptions: 4,
+		Timeout:    10 * time.Second,
+	}
+}
+
+// clarifyVerdict is the model's answer to clarifyPrompt
+type clarifyVerdict struct {
+	Ambiguous bool                `json:"ambiguous"`
+	Parts     []ClarificationPart `json:"parts"`
+}
+
+// Check asks whether message needs clarifying. It returns nil for clear
+// messages, and nil with an error when the check failed, in which case the
+// message should be answered as it is.
+func (c *Clarifier) Check(ctx context.Context, sessionID, userID, message string) (*Clarification, error) {
+	if chitChatPattern.MatchString(message) {
+		clarifications.WithLabelValues("clear").Inc()
+		return nil, nil
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Check in a throwaway session so the turn doesn't pollute the conversation
+	checkSession := sessionID + ":clarify"
+	resp, err := c.Client.SendChat(ctx, ChatRequest{
+		SessionID:    checkSession,
+		Message:      message,
+		SystemPrompt: fmt.Sprintf(clarifyPrompt, c.MaxParts, c.MaxOptions),
+		Model:        c.Model,
+		NoCache:      true,
+	})
+	if err != nil {
+		clarifications.WithLabelValues("failed").Inc()
+		return nil, fmt.Errorf("failed to check for ambiguity: %w", err)
+	}
+	if err := c.Client.ClearSessionContext(ctx, checkSession); err != nil {
+		logger.Warnf("Failed to clear clarification session %s: %v", checkSession, err)
+	}
+
+	parts, err := c.parseVerdict(resp.Response)
+	if err != nil {
+		clarifications.WithLabelValues("failed").Inc()
+		return nil, err
+	}
+	if len(parts) == 0 {
+		clarifications.WithLabelValues("clear").Inc()
+		return nil, nil
+	}
+
+	id := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, fmt.Errorf("failed to generate clarification ID: %w", err)
+	}
+	clarification := &Clarification{
+		ID:        hex.EncodeToString(id),
+		SessionID: sessionID,
+		UserID:    userID,
+		Question:  message,
+		Parts:     parts,
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(clarification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal clarification: %w", err)
+	}
+	if err := c.Store.Set(ctx, clarifyKeyPrefix+clarification.ID, data, c.TTL); err != nil {
+		clarifications.WithLabelValues("failed").Inc()
+		return nil, fmt.Errorf("failed to save clarification: %w", err)
+	}
+	clarifications.WithLabelValues("asked").Inc()
+	return clarification, nil
+}
+
+// parseVerdict reads the model's JSON (possibly in a code fence) and keeps
+// the usable parts within the limits
+func (c *Clarifier) parseVerdict(answer string) ([]ClarificationPart, error) {
+	answer = strings.TrimSpace(answer)
+	if start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}"); start >= 0 && end > start {
+		answer = answer[start : end+1]
+	}
+	var verdict clarifyVerdict
+	if err := json.Unmarshal([]byte(answer), &verdict); err != nil {
+		return nil, fmt.Errorf("failed to parse ambiguity check %q: %w", answer, err)
+	}
+	if !verdict.Ambiguous {
+		return nil, nil
+	}
+
+	var parts []ClarificationPart
+	for _, part := range verdict.Parts {
+		var options []string
+		for _, option := range part.Options {
+			if option = strings.TrimSpace(option); option != "" && len(options) < c.MaxOptions {
+				options = append(options, option)
+			}
+		}
+		if strings.TrimSpace(part.Question) == "" || len(options) < 2 {
+			continue
+		}
+		parts = append(parts, ClarificationPart{Question: strings.TrimSpace(part.Question), Options: options})
+		if len(parts) == c.MaxParts {
+			break
+		}
+	}
+	return parts, nil
+}
+
+// BuildClarificationCard asks the parts of a clarification. A single part
+// gets one button per option; several parts get a form with a select each.
+// "Just answer" skips the clarification.
+func BuildClarificationCard(c *Clarification) map[string]interface{} {
+	skip := callbackButton("Just answer", "default", map[string]interface{}{
+		clarifyActionKey: clarifyActionSkip,
+		clarifyIDKey:     c.ID,
+	})
+
+	elements := []interface{}{markdownElement("Before I answer, could you clarify?")}
+	if len(c.Parts) == 1 {
+		buttons := make([]interface{}, 0, len(c.Parts[0].Options)+1)
+		for i, option := range c.Parts[0].Options {
+			buttons = append(buttons, callbackButton(option, "primary", map[string]interface{}{
+				clarifyActionKey: clarifyActionOption,
+				clarifyIDKey:     c.ID,
+				clarifyOptionKey: strconv.Itoa(i),
+			}))
+		}
+		elements = append(elements,
+			markdownElement("**"+c.Parts[0].Question+"**"),
+			actionModule(append(buttons, skip)...))
+	} else {
+		submit := callbackButton("Answer", "primary", map[string]interface{}{
+			clarifyActionKey: clarifyActionSubmit,
+			clarifyIDKey:     c.ID,
+		})
+		submit["action_type"] = "form_submit"
+		submit["name"] = "clarify_submit"
+
+		var formElements []interface{}
+		for i, part := range c.Parts {
+			options := make([]interface{}, 0, len(part.Options))
+			for j, option := range part.Options {
+				options = append(options, map[string]interface{}{"text": plainText(option), "value": strconv.Itoa(j)})
+			}
+			formElements = append(formElements,
+				markdownElement("**"+part.Question+"**"),
+				map[string]interface{}{
+					"tag":         "select_static",
+					"name":        clarifyPartField + strconv.Itoa(i),
+					"placeholder": plainText("Choose one"),
+					"options":     options,
+				})
+		}
+		elements = append(elements,
+			map[string]interface{}{"tag": "form", "name": "clarify_form", "elements": append(formElements, submit)},
+			actionModule(skip))
+	}
+
+	return map[string]interface{}{
+		"config":   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header":   cardHeader("🤔 Quick question", "blue"),
+		"elements": elements,
+	}
+}
+
+// HandleClarifyAction processes clarification card callbacks. It returns
+// the question to answer now, with the clarifications merged into its
+// Message, and false if the action does not belong to a clarification.
+func (c *Clarifier) HandleClarifyAction(ctx context.Context, action CardAction, operatorID string) (*ClarifiedQuestion, bool, error) {
+	kind := action.StringValue(clarifyActionKey)
+	if kind != clarifyActionOption && kind != clarifyActionSubmit && kind != clarifyActionSkip {
+		return nil, false, nil
+	}
+	clarification, err := c.lookup(ctx, action.StringValue(clarifyIDKey))
+	if err != nil {
+		return nil, true, err
+	}
+	if clarification.UserID != "" && operatorID != clarification.UserID {
+		return nil, true, errors.New("only the person who asked can answer this")
+	}
+
+	var answers []string
+	switch kind {
+	case clarifyActionOption:
+		answer, ok := optionAt(clarification.Parts[0], action.StringValue(clarifyOptionKey))
+		if !ok {
+			return nil, true, errors.New("unknown option")
+		}
+		answers = []string{answer}
+	case clarifyActionSubmit:
+		for i, part := range clarification.Parts {
+			value, _ := action.FormValue[clarifyPartField+strconv.Itoa(i)].(string)
+			answer, ok := optionAt(part, value)
+			if !ok {
+				return nil, true, fmt.Errorf("please answer %q", part.Question)
+			}
+			answers = append(answers, answer)
+		}
+	}
+
+	// Answer once: a second click finds nothing
+	if err := c.Store.Delete(ctx, clarifyKeyPrefix+clarification.ID); err != nil {
+		logger.Warnf("Failed to delete clarification %s: %v", clarification.ID, err)
+	}
+	result := "answered"
+	if answers == nil {
+		result = "skipped"
+	}
+	clarifications.WithLabelValues(result).Inc()
+
+	return &ClarifiedQuestion{
+		SessionID: clarification.SessionID,
+		UserID:    clarification.UserID,
+		Original:  clarification.Question,
+		Answers:   answers,
+		Message:   MergeClarification(clarification.Question, clarification.Parts, answers),
+		Card:      buildClarifiedCard(clarification, answers),
+	}, true, nil
+}
+
+// MergeClarification appends the answered parts to the original message,
+// forming the prompt sent to the agent
+func MergeClarification(original string, parts []ClarificationPart, answers []string) string {
+	if len(answers) == 0 {
+		return original
+	}
+	var b strings.Builder
+	b.WriteString(original)
+	b.WriteString("\n\nClarifications:")
+	for i, answer := range answers {
+		if i < len(parts) {
+			fmt.Fprintf(&b, "\n- %s %s", parts[i].Question, answer)
+		}
+	}
+	return b.String()
+}
+
+// buildClarifiedCard shows the answers in place of the clarification card
+func buildClarifiedCard(c *Clarification, answers []string) map[string]interface{} {
+	var lines []string
+	for i, answer := range answers {
+		lines = append(lines, fmt.Sprintf("%s **%s**", c.Parts[i].Question, answer))
+	}
+	note := "Answering as asked…"
+	if len(lines) > 0 {
+		note = "Thanks! Answering now…"
+	}
+	elements := []interface{}{}
+	if len(lines) > 0 {
+		elements = append(elements, markdownElement(strings.Join(lines, "\n")))
+	}
+	elements = append(elements, map[string]interface{}{"tag": "note", "elements": []interface{}{plainText(note)}})
+	return map[string]interface{}{
+		"config":   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header":   cardHeader("🤔 Quick question", "grey"),
+		"elements": elements,
+	}
+}
+
+// optionAt returns the option of a part whose index is value
+func optionAt(part ClarificationPart, value string) (string, bool) {
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 || i >= len(part.Options) {
+		return "", false
+	}
+	return part.Options[i], true
+}
+
+// lookup reads a pending clarification
+func (c *Clarifier) lookup(ctx context.Context, id string) (*Clarification, error) {
+	data, err := c.Store.Get(ctx, clarifyKeyPrefix+id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, errors.New("this question has expired or was already answered, please ask again")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read clarification: %w", err)
+	}
+	var clarification Clarification
+	if err := json.Unmarshal(data, &clarification); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal clarification: %w", err)
+	}
+	return &clarification, nil
+}
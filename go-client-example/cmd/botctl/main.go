@@ -0,0 +1,180 @@
+// Command botctl is the operator tool of the Lark bot's Agno integration.
+//
+//	botctl trace [-json] <message-id>
+//	botctl tenant add [flags]
+//
+// trace prints where the processing of a Lark message got to (event, queue,
+// Agno, render, send), from the marks the bot stored for it.
+//
+// tenant add onboards a Lark app as a tenant: it validates the app
+// credentials with Lark, fills in the default quotas, checks the Agno
+// deployment, runs a smoke test conversation and registers the tenant.
+// Settings not given as flags are asked for.
+//
+// The store is the Redis at AGNO_REDIS_URL.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	agno "start-feishubot/services/agno"
+)
+
+// errUsage marks errors caused by wrong arguments
+var errUsage = errors.New("usage")
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "trace":
+		err = runTrace(args)
+	case "tenant":
+		err = runTenant(args)
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "botctl: %v\n", err)
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "botctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: botctl <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  trace [-json] <message-id>   print the processing timeline of a Lark message")
+	fmt.Fprintln(os.Stderr, "  tenant add [flags]           onboard a Lark app as a tenant (-h for flags)")
+}
+
+// runTrace prints the timeline of a message
+func runTrace(args []string) error {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: trace takes one message ID", errUsage)
+	}
+
+	store, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report, err := agno.NewMessageTracer(store).Report(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	report.Format(os.Stdout)
+	return nil
+}
+
+// runTenant runs a tenant subcommand
+func runTenant(args []string) error {
+	if len(args) == 0 || args[0] != "add" {
+		return fmt.Errorf("%w: tenant takes the add subcommand", errUsage)
+	}
+
+	fs := flag.NewFlagSet("tenant add", flag.ContinueOnError)
+	var req agno.TenantOnboarding
+	fs.StringVar(&req.AppID, "app-id", "", "Lark app ID (cli_...)")
+	fs.StringVar(&req.AppSecret, "app-secret", "", "Lark app secret")
+	fs.StringVar(&req.Name, "name", "", "tenant name used in metrics and quotas (default: the app ID)")
+	fs.StringVar(&req.BaseURL, "base-url", "", "URL of the tenant's Agno deployment")
+	fs.StringVar(&req.APIKeyFile, "api-key-file", "", "file holding the Agno API key")
+	fs.StringVar(&req.DefaultAgent, "agent", "", "default agent")
+	fs.StringVar(&req.Model, "model", "", "default model")
+	fs.StringVar(&req.SystemPrompt, "system-prompt", "", "default system prompt")
+	fs.BoolVar(&req.Replace, "replace", false, "re-register an existing app")
+	fs.BoolVar(&req.SkipSmokeTest, "skip-smoke-test", false, "register without a test conversation")
+	larkURL := fs.String("lark-url", "https://open.feishu.cn", "Lark Open API (https://open.larksuite.com for Lark)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: tenant add takes flags only", errUsage)
+	}
+	// an API key is best kept in a file; AGNO_TENANT_API_KEY avoids the
+	// shell history otherwise
+	req.APIKey = os.Getenv("AGNO_TENANT_API_KEY")
+
+	in := bufio.NewReader(os.Stdin)
+	for _, field := range []struct {
+		value  *string
+		prompt string
+	}{
+		{&req.AppID, "Lark app ID"},
+		{&req.AppSecret, "Lark app secret"},
+		{&req.BaseURL, "Agno base URL"},
+	} {
+		if *field.value != "" {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", field.prompt)
+		line, _ := in.ReadString('\n')
+		if *field.value = strings.TrimSpace(line); *field.value == "" {
+			return fmt.Errorf("%w: %s is required", errUsage, field.prompt)
+		}
+	}
+
+	store, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	onboarder := agno.NewTenantOnboarder(store)
+	onboarder.LarkBaseURL = *larkURL
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	report, err := onboarder.Onboard(ctx, req)
+	report.Format(os.Stdout)
+	return err
+}
+
+// openStore connects to the bot's Redis at AGNO_REDIS_URL
+func openStore() (agno.SessionStore, func(), error) {
+	rawURL := os.Getenv("AGNO_REDIS_URL")
+	if rawURL == "" {
+		return nil, nil, errors.New("AGNO_REDIS_URL is not set")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid AGNO_REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	return agno.NewRedisSessionStore(client), func() { client.Close() }, nil
+}
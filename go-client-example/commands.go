@@ -0,0 +1,277 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+var commandsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "commands",
+	Name:      "handled_total",
+	Help:      "Slash commands handled, by command and result (ok, error, usage, denied, unknown).",
+}, []string{"command", "result"})
+
+// leadingMentions matches the @-mention placeholders Lark puts in front of
+// group messages ("@_user_1 /clear")
+var leadingMentions = regexp.MustCompile(`^(\s*@_user_\d+)+\s*`)
+
+// commandName matches what is taken for a command name; other texts
+// starting with "/" (e.g. paths) go to the agent
+var commandName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// CommandContext is a command invocation as seen by its handler
+type CommandContext struct {
+	ChatID    string
+	ChatType  string // "p2p" or "group"
+	SessionID string
+	UserID    string // Lark open_id of the sender
+	TenantID  string
+	Text      string // the message without leading mentions
+
+	Name string   // the command name as registered (aliases resolved)
+	Args []string // arguments; double-quoted arguments may contain spaces
+}
+
+// CommandFunc handles a command and returns the reply to send
+type CommandFunc func(ctx context.Context, cmd CommandContext) (string, error)
+
+// Command is a slash command registered with a CommandRouter
+type Command struct {
+	Name        string // without the slash, e.g. "clear"
+	Aliases     []string
+	Usage       string // e.g. "/use [agent]"; defaults to "/<name>"
+	Description string
+	MinArgs     int
+	MaxArgs     int  // 0 for no limit
+	AdminOnly   bool // only for users RoleResolver reports as admins
+	Handler     CommandFunc
+}
+
+// RoleResolver reports whether a Lark user is an admin of the tenant, e.g.
+// from the contact API's is_tenant_manager or an allowlist
+type RoleResolver interface {
+	IsAdmin(ctx context.Context, tenantID, userID string) (bool, error)
+}
+
+// AdminUsers is a RoleResolver backed by a fixed set of open_ids
+type AdminUsers map[string]bool
+
+// IsAdmin implements RoleResolver
+func (a AdminUsers) IsAdmin(ctx context.Context, tenantID, userID string) (bool, error) {
+	return a[userID], nil
+}
+
+// AdminUsersFromEnv reads AGNO_ADMIN_USERS (comma-separated open_ids)
+func AdminUsersFromEnv() AdminUsers {
+	admins := make(AdminUsers)
+	for _, id := range strings.Split(os.Getenv("AGNO_ADMIN_USERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			admins[id] = true
+		}
+	}
+	return admins
+}
+
+// CommandRouter dispatches slash commands to registered handlers and
+// everything else to Agent. It answers "/help" itself, listing the commands
+// the sender may use, and "/help <command>" with its usage.
+type CommandRouter struct {
+	Roles RoleResolver // nil denies all admin-only commands
+	Agent CommandFunc  // receives non-commands, with Text set
+
+	commands map[string]*Command // by name and alias
+	names    []string            // registered names, sorted
+}
+
+// NewCommandRouter creates a router falling through to agent
+func NewCommandRouter(roles RoleResolver, agent CommandFunc) *CommandRouter {
+	return &CommandRouter{
+		Roles:    roles,
+		Agent:    agent,
+		commands: make(map[string]*Command),
+	}
+}
+
+// Register adds commands; a name or alias already taken is an error
+func (r *CommandRouter) Register(commands ...Command) error {
+	for i := range commands {
+		cmd := commands[i]
+		if cmd.Usage == "" {
+			cmd.Usage = "/" + cmd.Name
+		}
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, dup := r.commands[name]; dup || name == "help" {
+				return fmt.Errorf("%w: command /%s is already registered", ErrInvalidRequest, name)
+			}
+		}
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			r.commands[name] = &cmd
+		}
+		r.names = append(r.names, cmd.Name)
+	}
+	sort.Strings(r.names)
+	return nil
+}
+
+// Route handles one message: a registered command, "/help", or a message
+// for the agent. Unknown commands get a hint to /help.
+func (r *CommandRouter) Route(ctx context.Context, msg CommandContext) (string, error) {
+	msg.Text = leadingMentions.ReplaceAllString(msg.Text, "")
+	name, args, ok := ParseCommand(msg.Text)
+	if !ok {
+		if r.Agent == nil {
+			return "", nil
+		}
+		return r.Agent(ctx, msg)
+	}
+	msg.Args = args
+	if name == "help" {
+		msg.Name = name
+		return r.help(ctx, msg), nil
+	}
+
+	cmd, ok := r.commands[name]
+	if !ok {
+		commandsHandled.WithLabelValues("unknown", "unknown").Inc()
+		return fmt.Sprintf("Unknown command `/%s`. Send `/help` for the list of commands.", name), nil
+	}
+	msg.Name = cmd.Name
+
+	if cmd.AdminOnly && !r.isAdmin(ctx, msg) {
+		commandsHandled.WithLabelValues(cmd.Name, "denied").Inc()
+		logger.Warnf("User %s tried /%s in chat %s without admin rights", msg.UserID, cmd.Name, msg.ChatID)
+		return fmt.Sprintf("⛔ /%s is restricted to admins.", cmd.Name), nil
+	}
+	if len(args) < cmd.MinArgs || (cmd.MaxArgs > 0 && len(args) > cmd.MaxArgs) {
+		commandsHandled.WithLabelValues(cmd.Name, "usage").Inc()
+		return fmt.Sprintf("Usage: `%s`", cmd.Usage), nil
+	}
+
+	reply, err := cmd.Handler(ctx, msg)
+	if err != nil {
+		commandsHandled.WithLabelValues(cmd.Name, "error").Inc()
+		return "", fmt.Errorf("command /%s failed: %w", cmd.Name, err)
+	}
+	commandsHandled.WithLabelValues(cmd.Name, "ok").Inc()
+	return reply, nil
+}
+
+// ClearCommand is "/clear", which clears the sender's session
+func ClearCommand(client AgnoService) Command {
+	return Command{
+		Name:        "clear",
+		Aliases:     []string{"reset"},
+		Description: "Start a new conversation",
+		Handler: func(ctx context.Context, cmd CommandContext) (string, error) {
+			if err := client.ClearSessionContext(ctx, cmd.SessionID); err != nil {
+				return "", err
+			}
+			return "🧹 Conversation cleared.", nil
+		},
+	}
+}
+
+// help lists the commands available to the sender, or one command's usage
+func (r *CommandRouter) help(ctx context.Context, msg CommandContext) string {
+	admin := r.isAdmin(ctx, msg)
+	if len(msg.Args) > 0 {
+		cmd, ok := r.commands[strings.TrimPrefix(msg.Args[0], "/")]
+		if !ok || (cmd.AdminOnly && !admin) {
+			return fmt.Sprintf("Unknown command `%s`. Send `/help` for the list of commands.", msg.Args[0])
+		}
+		help := fmt.Sprintf("`%s`\n%s", cmd.Usage, cmd.Description)
+		if len(cmd.Aliases) > 0 {
+			help += "\nAliases: /" + strings.Join(cmd.Aliases, ", /")
+		}
+		return help
+	}
+
+	var b strings.Builder
+	b.WriteString("**Commands**\n")
+	for _, name := range r.names {
+		cmd := r.commands[name]
+		if cmd.AdminOnly && !admin {
+			continue
+		}
+		fmt.Fprintf(&b, "- `%s` %s", cmd.Usage, cmd.Description)
+		if cmd.AdminOnly {
+			b.WriteString(" _(admin)_")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("- `/help [command]` Show this list or a command's usage\n")
+	b.WriteString("Anything else is sent to the assistant.")
+	return b.String()
+}
+
+// isAdmin asks Roles whether the sender is an admin; lookup failures deny
+func (r *CommandRouter) isAdmin(ctx context.Context, msg CommandContext) bool {
+	if r.Roles == nil || msg.UserID == "" {
+		return false
+	}
+	admin, err := r.Roles.IsAdmin(ctx, msg.TenantID, msg.UserID)
+	if err != nil {
+		logger.Errorf("Failed to resolve role of user %s: %v", msg.UserID, err)
+		return false
+	}
+	return admin
+}
+
+// ParseCommand splits "/name arg \"quoted arg\"" into the lowercased name
+// and its arguments. ok is false when text is not a command.
+func ParseCommand(text string) (name string, args []string, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", nil, false
+	}
+	fields := splitArgs(text[1:])
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	name = strings.ToLower(fields[0])
+	if !commandName.MatchString(name) {
+		return "", nil, false
+	}
+	return name, fields[1:], true
+}
+
+// splitArgs splits on whitespace, keeping double-quoted runs (straight or
+// the curly quotes Lark clients insert) together
+func splitArgs(s string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '“' || c == '”':
+			quoted = !quoted
+			started = true
+		case !quoted && (c == ' ' || c == '\t' || c == '\n'):
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(c)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,308 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// defaultCompactionPrompt asks for the summary replacing old turns
+const defaultCompactionPrompt = "Summarize the conversation so far in a few bullet points so it can " +
+	"continue without the full transcript. Keep names, decisions, numbers, open questions and anything " +
+	"the user asked to remember."
+
+// Summarize endpoint support, detected on first use
+const (
+	summarizeUnknown int32 = iota
+	summarizeSupported
+	summarizeUnsupported
+)
+
+var historyCompactions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "history",
+	Name:      "compactions_total",
+	Help:      "Session histories compacted into a summary, by method (endpoint, prompt) and result.",
+}, []string{"method", "result"})
+
+// summarizeRequest is the body of POST /summarize
+type summarizeRequest struct {
+	SessionID string    `json:"session_id"`
+	Messages  []Message `json:"messages"`
+	Prompt    string    `json:"prompt,omitempty"`
+}
+
+// summarizeResponse is the body returned by /summarize
+type summarizeResponse struct {
+	Summary string `json:"summary"`
+}
+
+// Summarize asks the service's /summarize endpoint to condense messages
+func (c *AgnoClient) Summarize(ctx context.Context, sessionID string, messages []Message, prompt string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "Summarize",
+		attribute.String("agno.session_id", sessionID),
+		attribute.Int("agno.messages", len(messages)))
+	defer func() { endSpan(span, err) }()
+
+	jsonData, err := json.Marshal(summarizeRequest{SessionID: sessionID, Messages: messages, Prompt: prompt})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal summarize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/summarize", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "summarize", sessionID)
+	if err != nil {
+		return "", err
+	}
+	if statusCode != http.StatusOK {
+		return "", newAPIError(statusCode, body)
+	}
+	var resp summarizeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal summary: %w", err)
+	}
+	return resp.Summary, nil
+}
+
+// EstimateTokens approximates the token count of a text: about four
+// characters per token for Latin script and one token per CJK character
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// messagesTokens estimates the tokens of messages, counting a few per
+// message for the role and framing
+func messagesTokens(messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m.Content) + 4
+	}
+	return total
+}
+
+// sessionHistory is a session's history as kept by HistoryManager
+type sessionHistory struct {
+	Summary  string    `json:"summary,omitempty"`
+	Messages []Message `json:"messages"`
+	Tokens   int       `json:"tokens"`
+}
+
+// HistoryManager keeps the history passed with each ChatRequest of a
+// session under MaxTokens. When the estimated history exceeds it, all but
+// the last KeepRecent messages are replaced by a summary from the
+// service's /summarize endpoint, or from SummaryPrompt if the service has
+// none. Histories are stored under "chathistory:<session>" keys; set Cache
+// to keep the hot ones in memory.
+type HistoryManager struct {
+	Client        *AgnoClient
+	Store         SessionStore
+	Cache         *HistoryCache // optional, written through to Store
+	MaxTokens     int
+	KeepRecent    int
+	SummaryPrompt string
+	TTL           time.Duration // how long idle histories are kept
+
+	endpoint int32
+}
+
+// NewHistoryManager creates a manager compacting histories above 6000
+// tokens down to a summary and the last 6 messages, keeping idle histories
+// for a week
+func NewHistoryManager(client *AgnoClient, store SessionStore) *HistoryManager {
+	return &HistoryManager{
+		Client:        client,
+		Store:         store,
+		MaxTokens:     6000,
+		KeepRecent:    6,
+		SummaryPrompt: defaultCompactionPrompt,
+		TTL:           7 * 24 * time.Hour,
+	}
+}
+
+// Chat sends req with the session's (compacted) history and records the
+// exchange
+func (h *HistoryManager) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	req, err := h.Prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Client.SendChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Record(ctx, req.SessionID, Message{Role: "user", Content: req.Message},
+		Message{Role: "assistant", Content: resp.Response}); err != nil {
+		logger.Warnf("Failed to record history of session %s: %v", req.SessionID, err)
+	}
+	return resp, nil
+}
+
+// Prepare sets req.History from the session's history, compacting it first
+// when it exceeds MaxTokens. A failed compaction sends the most recent
+// messages that fit instead.
+func (h *HistoryManager) Prepare(ctx context.Context, req ChatRequest) (ChatRequest, error) {
+	state, err := h.load(ctx, req.SessionID)
+	if err != nil {
+		return req, err
+	}
+	if state.Tokens > h.MaxTokens && len(state.Messages) > h.KeepRecent {
+		if err := h.compact(ctx, req.SessionID, state); err != nil {
+			logger.Warnf("Failed to compact history of session %s, truncating: %v", req.SessionID, err)
+		}
+	}
+
+	var history []Message
+	if state.Summary != "" {
+		history = append(history, Message{Role: "system", Content: "Summary of the earlier conversation:\n" + state.Summary})
+	}
+	budget := h.MaxTokens - messagesTokens(history)
+	recent := state.Messages
+	for len(recent) > 0 && messagesTokens(recent) > budget {
+		recent = recent[1:]
+	}
+	req.History = append(history, recent...)
+	return req, nil
+}
+
+// Record appends messages to the session's history
+func (h *HistoryManager) Record(ctx context.Context, sessionID string, messages ...Message) error {
+	state, err := h.load(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	state.Messages = append(state.Messages, messages...)
+	state.Tokens += messagesTokens(messages)
+	return h.save(ctx, sessionID, state)
+}
+
+// Forget drops the session's history, e.g. on /clear
+func (h *HistoryManager) Forget(ctx context.Context, sessionID string) error {
+	if h.Cache != nil {
+		h.Cache.remove(sessionID)
+	}
+	return h.Store.Delete(ctx, "chathistory:"+sessionID)
+}
+
+// Tokens returns the estimated token count of the session's history
+func (h *HistoryManager) Tokens(ctx context.Context, sessionID string) (int, error) {
+	state, err := h.load(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	return state.Tokens + EstimateTokens(state.Summary), nil
+}
+
+// compact replaces the old turns of state with a summary and saves it
+func (h *HistoryManager) compact(ctx context.Context, sessionID string, state *sessionHistory) error {
+	cut := len(state.Messages) - h.KeepRecent
+	old := state.Messages[:cut]
+	if state.Summary != "" {
+		old = append([]Message{{Role: "system", Content: "Summary of the earlier conversation:\n" + state.Summary}}, old...)
+	}
+
+	summary, err := h.summarize(ctx, sessionID, old)
+	if err != nil {
+		return err
+	}
+	state.Summary = summary
+	state.Messages = append([]Message(nil), state.Messages[cut:]...)
+	state.Tokens = messagesTokens(state.Messages)
+	logger.Infof("Compacted %d message(s) of session %s into a summary", cut, sessionID)
+	return h.save(ctx, sessionID, state)
+}
+
+// summarize condenses messages with the /summarize endpoint, falling back
+// to SummaryPrompt in a scratch session when the service has no endpoint
+func (h *HistoryManager) summarize(ctx context.Context, sessionID string, messages []Message) (string, error) {
+	if atomic.LoadInt32(&h.endpoint) != summarizeUnsupported {
+		summary, err := h.Client.Summarize(ctx, sessionID, messages, h.SummaryPrompt)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			atomic.StoreInt32(&h.endpoint, summarizeSupported)
+			historyCompactions.WithLabelValues("endpoint", "ok").Inc()
+			return summary, nil
+		case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed):
+			if atomic.CompareAndSwapInt32(&h.endpoint, summarizeUnknown, summarizeUnsupported) {
+				logger.Info("Agno service has no /summarize endpoint, summarizing with a prompt")
+			}
+		default:
+			historyCompactions.WithLabelValues("endpoint", "error").Inc()
+			return "", fmt.Errorf("failed to summarize history: %w", err)
+		}
+	}
+
+	scratch := sessionID + ":compact"
+	summary, err := h.Client.ChatContext(ctx, scratch, h.SummaryPrompt, messages)
+	if err != nil {
+		historyCompactions.WithLabelValues("prompt", "error").Inc()
+		return "", fmt.Errorf("failed to summarize history: %w", err)
+	}
+	if err := h.Client.ClearSessionContext(ctx, scratch); err != nil {
+		logger.Warnf("Failed to clear compaction session %s: %v", scratch, err)
+	}
+	historyCompactions.WithLabelValues("prompt", "ok").Inc()
+	return summary, nil
+}
+
+// load reads a session's history; a missing history is empty
+func (h *HistoryManager) load(ctx context.Context, sessionID string) (*sessionHistory, error) {
+	if h.Cache != nil {
+		if state, ok := h.Cache.get(sessionID); ok {
+			return state, nil
+		}
+	}
+	var state sessionHistory
+	data, err := sessionHistorySchema.loadRecord(ctx, h.Store, "chathistory:"+sessionID, &state, h.TTL)
+	if errors.Is(err, ErrKeyNotFound) {
+		return &sessionHistory{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if h.Cache != nil {
+		h.Cache.put(sessionID, &state, len(data))
+	}
+	return &state, nil
+}
+
+// save writes a session's history
+func (h *HistoryManager) save(ctx context.Context, sessionID string, state *sessionHistory) error {
+	data, err := sessionHistorySchema.Encode(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	if err := h.Store.Set(ctx, "chathistory:"+sessionID, data, h.TTL); err != nil {
+		if h.Cache != nil {
+			h.Cache.remove(sessionID)
+		}
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	if h.Cache != nil {
+		h.Cache.put(sessionID, state, len(data))
+	}
+	return nil
+}
@@ -0,0 +1,557 @@
+package agno
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"start-feishubot/logger"
+)
+
+// Where an effective setting comes from, as reported by /config
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "file"
+	ConfigSourceEnv     = "env"
+)
+
+// Config is the bot's layered configuration: defaults, then the YAML file,
+// then environment variables. Tool timeouts, rate limits and templates are
+// applied to a running client on reload; the other settings need a restart.
+type Config struct {
+	ServiceURL     string
+	APIVersion     string
+	APIKey         string
+	HMACSecret     string
+	RequestTimeout time.Duration
+	PromptDir      string
+
+	ToolTimeouts map[string]time.Duration
+	RateUser     BucketConfig
+	RateChat     BucketConfig
+	RateMaxWait  time.Duration
+	Templates    map[string]string // inline prompt templates, by name
+
+	sources map[string]string // setting key -> ConfigSource*
+}
+
+// configSetting describes one setting of the schema. Map settings (tool
+// timeouts, templates) take a name after their key, e.g. "timeouts.calendar".
+type configSetting struct {
+	key    string
+	env    string
+	secret bool // masked by /config and only read from the environment
+	hot    bool // applied without a restart
+	isMap  bool
+	set    func(c *Config, name, value string) error
+	get    func(c *Config) map[string]string // name -> value; "" for plain settings
+}
+
+// configSchema lists every setting the configuration accepts
+var configSchema = []configSetting{
+	{key: "service_url", env: "AGNO_SERVICE_URL",
+		set: func(c *Config, _, v string) error { c.ServiceURL = v; return nil },
+		get: func(c *Config) map[string]string { return plainSetting(c.ServiceURL) }},
+	{key: "api_version", env: "AGNO_API_VERSION",
+		set: func(c *Config, _, v string) error { c.APIVersion = v; return nil },
+		get: func(c *Config) map[string]string { return plainSetting(c.APIVersion) }},
+	{key: "api_key", env: "AGNO_API_KEY", secret: true,
+		set: func(c *Config, _, v string) error { c.APIKey = v; return nil },
+		get: func(c *Config) map[string]string { return plainSetting(c.APIKey) }},
+	{key: "hmac_secret", env: "AGNO_HMAC_SECRET", secret: true,
+		set: func(c *Config, _, v string) error { c.HMACSecret = v; return nil },
+		get: func(c *Config) map[string]string { return plainSetting(c.HMACSecret) }},
+	{key: "request_timeout", env: "AGNO_REQUEST_TIMEOUT",
+		set: func(c *Config, _, v string) error { return parseSettingDuration(v, &c.RequestTimeout) },
+		get: func(c *Config) map[string]string { return plainSetting(c.RequestTimeout.String()) }},
+	{key: "prompt_dir", env: "AGNO_PROMPT_DIR",
+		set: func(c *Config, _, v string) error { c.PromptDir = v; return nil },
+		get: func(c *Config) map[string]string { return plainSetting(c.PromptDir) }},
+	{key: "timeouts", env: "AGNO_TOOL_TIMEOUTS", hot: true, isMap: true,
+		set: func(c *Config, name, v string) error {
+			var d time.Duration
+			if err := parseSettingDuration(v, &d); err != nil {
+				return err
+			}
+			c.ToolTimeouts[name] = d
+			return nil
+		},
+		get: func(c *Config) map[string]string {
+			values := make(map[string]string, len(c.ToolTimeouts))
+			for name, d := range c.ToolTimeouts {
+				values[name] = d.String()
+			}
+			return values
+		}},
+	{key: "rate.user_burst", env: "AGNO_RATE_USER_BURST", hot: true,
+		set: func(c *Config, _, v string) error { return parseSettingInt(v, &c.RateUser.Burst) },
+		get: func(c *Config) map[string]string { return plainSetting(strconv.Itoa(c.RateUser.Burst)) }},
+	{key: "rate.user_refill", env: "AGNO_RATE_USER_REFILL", hot: true,
+		set: func(c *Config, _, v string) error { return parseSettingDuration(v, &c.RateUser.Refill) },
+		get: func(c *Config) map[string]string { return plainSetting(c.RateUser.Refill.String()) }},
+	{key: "rate.chat_burst", env: "AGNO_RATE_CHAT_BURST", hot: true,
+		set: func(c *Config, _, v string) error { return parseSettingInt(v, &c.RateChat.Burst) },
+		get: func(c *Config) map[string]string { return plainSetting(strconv.Itoa(c.RateChat.Burst)) }},
+	{key: "rate.chat_refill", env: "AGNO_RATE_CHAT_REFILL", hot: true,
+		set: func(c *Config, _, v string) error { return parseSettingDuration(v, &c.RateChat.Refill) },
+		get: func(c *Config) map[string]string { return plainSetting(c.RateChat.Refill.String()) }},
+	{key: "rate.max_wait", env: "AGNO_RATE_MAX_WAIT", hot: true,
+		set: func(c *Config, _, v string) error { return parseSettingDuration(v, &c.RateMaxWait) },
+		get: func(c *Config) map[string]string { return plainSetting(c.RateMaxWait.String()) }},
+	{key: "templates", hot: true, isMap: true,
+		set: func(c *Config, name, v string) error { c.Templates[name] = v; return nil },
+		get: func(c *Config) map[string]string { return c.Templates }},
+}
+
+// plainSetting returns the value of a setting that is not a map
+func plainSetting(value string) map[string]string {
+	return map[string]string{"": value}
+}
+
+// parseSettingDuration parses a duration like "10s"
+func parseSettingDuration(value string, d *time.Duration) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("want a duration like 10s: %w", err)
+	}
+	*d = parsed
+	return nil
+}
+
+// parseSettingInt parses an integer
+func parseSettingInt(value string, n *int) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("want an integer: %w", err)
+	}
+	*n = parsed
+	return nil
+}
+
+// DefaultConfig returns the configuration used when nothing is set
+func DefaultConfig() *Config {
+	timeouts := make(map[string]time.Duration, len(DefaultTimeoutPolicies))
+	for name, policy := range DefaultTimeoutPolicies {
+		timeouts[name] = policy.Timeout
+	}
+	return &Config{
+		ServiceURL:     "http://localhost:8000",
+		APIVersion:     "auto",
+		RequestTimeout: 90 * time.Second,
+		ToolTimeouts:   timeouts,
+		RateUser:       BucketConfig{Burst: 5, Refill: 10 * time.Second},
+		RateChat:       BucketConfig{Burst: 20, Refill: 3 * time.Second},
+		Templates:      make(map[string]string),
+		sources:        make(map[string]string),
+	}
+}
+
+// LoadConfig layers the YAML file at path (optional) and the environment
+// over the defaults, and validates the result
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		values := make(map[string]string)
+		flattenConfig("", doc, values)
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := cfg.set(key, values[key], ConfigSourceFile); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	for _, setting := range configSchema {
+		v := os.Getenv(setting.env)
+		if setting.env == "" || v == "" {
+			continue
+		}
+		if !setting.isMap {
+			if err := cfg.set(setting.key, v, ConfigSourceEnv); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// name=value pairs, e.g. AGNO_TOOL_TIMEOUTS=calendar=5s,web_search=10s
+		for _, pair := range strings.Split(v, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid %s entry %q: want name=value", setting.env, pair)
+			}
+			if err := cfg.set(setting.key+"."+name, value, ConfigSourceEnv); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// flattenConfig turns nested YAML mappings into dotted keys
+func flattenConfig(prefix string, node map[string]interface{}, out map[string]string) {
+	for key, value := range node {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenConfig(key, v, out)
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// set assigns a setting by its dotted key
+func (c *Config) set(key, value, source string) error {
+	for _, setting := range configSchema {
+		name := ""
+		switch {
+		case key == setting.key && !setting.isMap:
+		case setting.isMap && strings.HasPrefix(key, setting.key+".") && len(key) > len(setting.key)+1:
+			name = key[len(setting.key)+1:]
+		default:
+			continue
+		}
+		if setting.secret && source == ConfigSourceFile {
+			return fmt.Errorf("%s can only be set through %s, keep secrets out of config files", key, setting.env)
+		}
+		if err := setting.set(c, name, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		c.sources[key] = source
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+// Validate checks the values of the configuration
+func (c *Config) Validate() error {
+	var errs []error
+	if u, err := url.Parse(c.ServiceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("service_url %q is not an http(s) URL", c.ServiceURL))
+	}
+	switch c.APIVersion {
+	case "", "auto", "1", "2":
+	default:
+		errs = append(errs, fmt.Errorf("api_version %q is not 1, 2 or auto", c.APIVersion))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request_timeout must be positive"))
+	}
+	for name, d := range c.ToolTimeouts {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must not be negative", name))
+		}
+	}
+	for scope, bucket := range map[string]BucketConfig{"user": c.RateUser, "chat": c.RateChat} {
+		if bucket.Burst < 0 || bucket.Refill < 0 {
+			errs = append(errs, fmt.Errorf("rate.%s limits must not be negative", scope))
+		}
+	}
+	if c.RateMaxWait < 0 {
+		errs = append(errs, errors.New("rate.max_wait must not be negative"))
+	}
+	for name, text := range c.Templates {
+		if _, err := parsePrompt(name, text); err != nil {
+			errs = append(errs, fmt.Errorf("templates.%s: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: invalid configuration: %v", ErrInvalidRequest, errors.Join(errs...))
+	}
+	return nil
+}
+
+// TimeoutPolicies returns the tool timeouts as policies, keeping the
+// fallback replies of base
+func (c *Config) TimeoutPolicies(base map[string]TimeoutPolicy) map[string]TimeoutPolicy {
+	policies := make(map[string]TimeoutPolicy, len(c.ToolTimeouts))
+	for name, d := range c.ToolTimeouts {
+		policies[name] = TimeoutPolicy{Timeout: d, Fallback: base[name].Fallback}
+	}
+	return policies
+}
+
+// ConfigValue is an effective setting as shown by /config
+type ConfigValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Reload string `json:"reload"` // "hot" or "restart"
+}
+
+// Effective lists every setting with its value and source, secrets masked
+func (c *Config) Effective() []ConfigValue {
+	var values []ConfigValue
+	for _, setting := range configSchema {
+		reload := "restart"
+		if setting.hot {
+			reload = "hot"
+		}
+		for name, value := range setting.get(c) {
+			key := setting.key
+			if name != "" {
+				key += "." + name
+			}
+			source := c.sources[key]
+			if source == "" {
+				source = ConfigSourceDefault
+			}
+			if setting.secret && value != "" {
+				value = "********"
+			}
+			values = append(values, ConfigValue{Key: key, Value: value, Source: source, Reload: reload})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// restartOnlyChanges lists the settings that differ between c and next but
+// only take effect after a restart
+func (c *Config) restartOnlyChanges(next *Config) []string {
+	var changed []string
+	for _, setting := range configSchema {
+		if setting.hot {
+			continue
+		}
+		if setting.get(c)[""] != setting.get(next)[""] {
+			changed = append(changed, setting.key)
+		}
+	}
+	return changed
+}
+
+// ConfigManager holds the current configuration and reloads it when its
+// file changes, applying the hot settings to the bound components
+type ConfigManager struct {
+	Path string
+
+	mu       sync.RWMutex
+	current  *Config
+	loadedAt time.Time
+	modTime  time.Time
+	appliers []func(*Config)
+}
+
+// NewConfigManager loads the configuration from path (may be empty for
+// environment only)
+func NewConfigManager(path string) (*ConfigManager, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &ConfigManager{Path: path, current: cfg, loadedAt: time.Now()}
+	m.modTime, _ = m.fileModTime()
+	return m, nil
+}
+
+// NewConfigManagerFromEnv loads the configuration from the YAML file at
+// AGNO_CONFIG_FILE, if set, and the environment
+func NewConfigManagerFromEnv() (*ConfigManager, error) {
+	return NewConfigManager(os.Getenv("AGNO_CONFIG_FILE"))
+}
+
+// Current returns the configuration in effect; callers must not modify it
+func (m *ConfigManager) Current() *Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// OnReload calls apply with the current configuration now and with every
+// reloaded one
+func (m *ConfigManager) OnReload(apply func(cfg *Config)) {
+	m.mu.Lock()
+	m.appliers = append(m.appliers, apply)
+	cfg := m.current
+	m.mu.Unlock()
+	apply(cfg)
+}
+
+// Bind keeps the tool timeouts and prompt templates of client in sync with
+// the configuration
+func (m *ConfigManager) Bind(client *AgnoClient) {
+	if client.Prompts == nil && len(m.Current().Templates) > 0 {
+		client.Prompts = NewPromptTemplates()
+	}
+	m.OnReload(func(cfg *Config) {
+		client.SetTimeouts(cfg.TimeoutPolicies(DefaultTimeoutPolicies))
+		if client.Prompts == nil {
+			return
+		}
+		for name, text := range cfg.Templates {
+			if err := client.Prompts.Add(name, text); err != nil {
+				logger.Errorf("Failed to apply prompt template %s: %v", name, err)
+			}
+		}
+	})
+}
+
+// BindRateLimiter keeps the limits of limiter in sync with the configuration
+func (m *ConfigManager) BindRateLimiter(limiter *RateLimiter) {
+	m.OnReload(func(cfg *Config) {
+		limiter.SetLimits(cfg.RateUser, cfg.RateChat, cfg.RateMaxWait)
+	})
+}
+
+// Reload re-reads the configuration. An invalid configuration is rejected
+// as a whole and the current one stays in effect. Restart-only settings
+// keep their running values until the next restart.
+func (m *ConfigManager) Reload() (bool, error) {
+	modTime, _ := m.fileModTime()
+	next, err := LoadConfig(m.Path)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	current := m.current
+	m.modTime = modTime
+	if changed := current.restartOnlyChanges(next); len(changed) > 0 {
+		logger.Warnf("Config changes to %s take effect after a restart", strings.Join(changed, ", "))
+		for _, setting := range configSchema {
+			if setting.hot {
+				continue
+			}
+			setting.set(next, "", setting.get(current)[""])
+			next.sources[setting.key] = current.sources[setting.key]
+		}
+	}
+	if configEqual(current, next) {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.current, m.loadedAt = next, time.Now()
+	appliers := append([]func(*Config){}, m.appliers...)
+	m.mu.Unlock()
+
+	for _, apply := range appliers {
+		apply(next)
+	}
+	return true, nil
+}
+
+// configEqual reports whether two configurations have the same settings
+func configEqual(a, b *Config) bool {
+	ea, eb := a.Effective(), b.Effective()
+	if len(ea) != len(eb) {
+		return false
+	}
+	for i := range ea {
+		if ea[i].Key != eb[i].Key || ea[i].Value != eb[i].Value {
+			return false
+		}
+	}
+	// Secrets are masked in Effective
+	return a.APIKey == b.APIKey && a.HMACSecret == b.HMACSecret
+}
+
+// fileModTime returns the modification time of the config file
+func (m *ConfigManager) fileModTime() (time.Time, error) {
+	if m.Path == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(m.Path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Watch reloads the configuration whenever its file changes, checking every
+// interval until the returned function is called
+func (m *ConfigManager) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				modTime, err := m.fileModTime()
+				m.mu.RLock()
+				unchanged := err != nil || modTime.Equal(m.modTime)
+				m.mu.RUnlock()
+				if unchanged {
+					continue
+				}
+				changed, err := m.Reload()
+				if err != nil {
+					logger.Errorf("Config reload failed, keeping the current configuration: %v", err)
+				}
+				if changed {
+					logger.Info("Configuration reloaded")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// configReport is the body served by /config
+type configReport struct {
+	File     string        `json:"file,omitempty"`
+	LoadedAt string        `json:"loaded_at"`
+	Settings []ConfigValue `json:"settings"`
+}
+
+// Handler serves the effective configuration as JSON, secrets masked. Mount
+// it on the admin listener only (e.g. /config). Requests must be signed with
+// secret (see VerifyRequest).
+// It returns ErrMissingSecret when secret is empty.
+func (m *ConfigManager) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("ConfigManager.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected config request from %s: %v", r.RemoteAddr, err)
+			http.Error(rw, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		m.mu.RLock()
+		report := configReport{
+			File:     m.Path,
+			LoadedAt: m.loadedAt.UTC().Format(time.RFC3339),
+			Settings: m.current.Effective(),
+		}
+		m.mu.RUnlock()
+		writeJSON(rw, http.StatusOK, report)
+	}), nil
+}
@@ -0,0 +1,253 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"start-feishubot/logger"
+)
+
+// maxDebugSteps caps the step events kept per debugged session
+const maxDebugSteps = 200
+
+// StepEvent is one step of an agent run (tool call, model call, retrieval, ...),
+// returned by the service when ChatRequest.Debug is set
+type StepEvent struct {
+	Type       string `json:"type"`
+	Name       string `json:"name,omitempty"`
+	Input      string `json:"input,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"`
+}
+
+// debugState is the stored marker of a debugged session
+type debugState struct {
+	Operator string    `json:"operator"`
+	Expires  time.Time `json:"expires"`
+}
+
+// DebugSessions turns on verbose debugging for single sessions without
+// changing global log levels. While a session is debugged the client asks
+// the service for step events and verbose tracing, logs redacted request
+// and response payloads at info level, and keeps the captured steps.
+// Debugging expires after TTL.
+type DebugSessions struct {
+	Store     SessionStore
+	TTL       time.Duration
+	Operators map[string]bool // user IDs allowed to use /debug
+	Redact    func(string) string
+}
+
+// NewDebugSessions creates debug switches stored in store, expiring after an hour
+func NewDebugSessions(store SessionStore, operators ...string) *DebugSessions {
+	d := &DebugSessions{
+		Store:     store,
+		TTL:       time.Hour,
+		Operators: make(map[string]bool),
+		Redact:    RedactPII,
+	}
+	for _, userID := range operators {
+		if userID = strings.TrimSpace(userID); userID != "" {
+			d.Operators[userID] = true
+		}
+	}
+	return d
+}
+
+// NewDebugSessionsFromEnv allows the users in AGNO_OPS_USERS (comma-separated
+// open IDs) to use /debug
+func NewDebugSessionsFromEnv(store SessionStore) *DebugSessions {
+	return NewDebugSessions(store, strings.Split(os.Getenv("AGNO_OPS_USERS"), ",")...)
+}
+
+// Enable turns debugging on for a session until TTL elapses
+func (d *DebugSessions) Enable(ctx context.Context, operator, sessionID string) (time.Time, error) {
+	expires := time.Now().Add(d.TTL)
+	data, err := json.Marshal(debugState{Operator: operator, Expires: expires})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to marshal debug state: %w", err)
+	}
+	if err := d.Store.Set(ctx, "debug:"+sessionID, data, d.TTL); err != nil {
+		return time.Time{}, fmt.Errorf("failed to enable debugging: %w", err)
+	}
+	logger.Infof("Debugging enabled for session %s by %s until %s", sessionID, operator, expires.Format(time.RFC3339))
+	return expires, nil
+}
+
+// Disable turns debugging off for a session and drops its captured steps
+func (d *DebugSessions) Disable(ctx context.Context, operator, sessionID string) error {
+	if err := d.Store.Delete(ctx, "debug:"+sessionID); err != nil {
+		return fmt.Errorf("failed to disable debugging: %w", err)
+	}
+	if err := d.Store.Delete(ctx, "debugsteps:"+sessionID); err != nil {
+		logger.Warnf("Failed to drop debug steps of session %s: %v", sessionID, err)
+	}
+	logger.Infof("Debugging disabled for session %s by %s", sessionID, operator)
+	return nil
+}
+
+// Enabled reports whether a session is being debugged. It is safe to call
+// on a nil *DebugSessions.
+func (d *DebugSessions) Enabled(ctx context.Context, sessionID string) bool {
+	_, ok := d.state(ctx, sessionID)
+	return ok
+}
+
+// Footer returns the diagnostics footer for an answer in a debugged
+// session (bot build, step count and total step time), or "" when the
+// session is not debugged
+func (d *DebugSessions) Footer(ctx context.Context, sessionID string, resp *ChatResponse) string {
+	if !d.Enabled(ctx, sessionID) {
+		return ""
+	}
+	var total int64
+	for _, step := range resp.Steps {
+		total += step.DurationMS
+	}
+	return fmt.Sprintf("🐞 bot %s · session %s · %d step(s), %dms", GetBuildInfo(), sessionID, len(resp.Steps), total)
+}
+
+// Steps returns the step events captured for a debugged session, oldest first
+func (d *DebugSessions) Steps(ctx context.Context, sessionID string) ([]StepEvent, error) {
+	data, err := d.Store.Get(ctx, "debugsteps:"+sessionID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug steps: %w", err)
+	}
+	var steps []StepEvent
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal debug steps: %w", err)
+	}
+	return steps, nil
+}
+
+// HandleDebugCommand handles "/debug on|off|status" for operators. It
+// returns the reply to send and false if text is not a /debug command.
+func (d *DebugSessions) HandleDebugCommand(ctx context.Context, userID, sessionID, text string) (string, bool, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "/debug" {
+		return "", false, nil
+	}
+	if !d.Operators[userID] {
+		logger.Warnf("User %s tried /debug on session %s without operator rights", userID, sessionID)
+		return "⛔ /debug is restricted to operators.", true, nil
+	}
+
+	action := "status"
+	if len(fields) > 1 {
+		action = strings.ToLower(fields[1])
+	}
+	switch action {
+	case "on":
+		expires, err := d.Enable(ctx, userID, sessionID)
+		if err != nil {
+			return "", true, err
+		}
+		return fmt.Sprintf("🐞 Debugging is on for this session until %s.", expires.Format("15:04 MST")), true, nil
+	case "off":
+		if err := d.Disable(ctx, userID, sessionID); err != nil {
+			return "", true, err
+		}
+		return "✅ Debugging is off for this session.", true, nil
+	case "status":
+		state, ok := d.state(ctx, sessionID)
+		if !ok {
+			return "Debugging is off for this session. Turn it on with `/debug on`.", true, nil
+		}
+		steps, err := d.Steps(ctx, sessionID)
+		if err != nil {
+			return "", true, err
+		}
+		return fmt.Sprintf("🐞 Debugging is on (by %s) until %s; %d step event(s) captured.",
+			state.Operator, state.Expires.Format("15:04 MST"), len(steps)), true, nil
+	default:
+		return "Usage: `/debug on`, `/debug off` or `/debug status`", true, nil
+	}
+}
+
+// state returns the debug state of a session, if it is being debugged
+func (d *DebugSessions) state(ctx context.Context, sessionID string) (debugState, bool) {
+	if d == nil || sessionID == "" {
+		return debugState{}, false
+	}
+	data, err := d.Store.Get(ctx, "debug:"+sessionID)
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			logger.Warnf("Failed to read debug state of session %s: %v", sessionID, err)
+		}
+		return debugState{}, false
+	}
+	var state debugState
+	if err := json.Unmarshal(data, &state); err != nil || time.Now().After(state.Expires) {
+		return debugState{}, false
+	}
+	return state, true
+}
+
+// logPayload logs a redacted payload of a debugged session
+func (d *DebugSessions) logPayload(sessionID, kind string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	payload := string(data)
+	if d.Redact != nil {
+		payload = d.Redact(payload)
+	}
+	logger.Infof("DEBUG session %s %s: %s", sessionID, kind, payload)
+}
+
+// capture logs a debugged response, adds its steps to the span and keeps them
+func (d *DebugSessions) capture(ctx context.Context, span trace.Span, sessionID string, resp *ChatResponse) {
+	d.logPayload(sessionID, "response", resp)
+	if len(resp.Steps) == 0 {
+		return
+	}
+
+	for _, step := range resp.Steps {
+		span.AddEvent("agno.step", trace.WithAttributes(
+			attribute.String("agno.step.type", step.Type),
+			attribute.String("agno.step.name", step.Name),
+			attribute.Int64("agno.step.duration_ms", step.DurationMS),
+			attribute.String("agno.step.error", step.Error),
+		))
+	}
+
+	steps, err := d.Steps(ctx, sessionID)
+	if err != nil {
+		logger.Warnf("Failed to read debug steps of session %s: %v", sessionID, err)
+	}
+	for _, step := range resp.Steps {
+		if d.Redact != nil {
+			step.Input, step.Output = d.Redact(step.Input), d.Redact(step.Output)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) > maxDebugSteps {
+		steps = steps[len(steps)-maxDebugSteps:]
+	}
+
+	state, ok := d.state(ctx, sessionID)
+	if !ok {
+		return // expired during the call
+	}
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return
+	}
+	if err := d.Store.Set(ctx, "debugsteps:"+sessionID, data, time.Until(state.Expires)); err != nil {
+		logger.Warnf("Failed to store debug steps of session %s: %v", sessionID, err)
+	}
+}
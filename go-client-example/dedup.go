@@ -0,0 +1,279 @@
+package agno
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+
+	"start-feishubot/logger"
+)
+
+var (
+	eventsDeduplicated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "events",
+		Name:      "deduplicated_total",
+		Help:      "Lark events dropped because their event_id was already handled.",
+	})
+
+	eventsReplayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "events",
+		Name:      "replayed_total",
+		Help:      "Events retried from the replay buffer after their handler failed or crashed.",
+	}, []string{"result"})
+)
+
+// IdempotencyStore remembers which keys have been claimed
+type IdempotencyStore interface {
+	// Claim returns true the first time key is claimed within ttl
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// lruEntry is an element of MemoryIdempotencyStore's recency list
+type lruEntry struct {
+	key     string
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping the most recent
+// Capacity keys in process memory
+type MemoryIdempotencyStore struct {
+	Capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+// NewMemoryIdempotencyStore creates a store remembering up to capacity keys
+func NewMemoryIdempotencyStore(capacity int) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		Capacity: capacity,
+		order:    list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+// Claim implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := s.keys[key]; ok {
+		if now.Before(el.Value.(*lruEntry).expires) {
+			s.order.MoveToFront(el)
+			return false, nil
+		}
+		s.order.Remove(el)
+		delete(s.keys, key)
+	}
+
+	s.keys[key] = s.order.PushFront(&lruEntry{key: key, expires: now.Add(ttl)})
+	for s.order.Len() > s.Capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.keys, oldest.Value.(*lruEntry).key)
+	}
+	return true, nil
+}
+
+// RedisIdempotencyStore is an IdempotencyStore shared by all replicas (SET NX)
+type RedisIdempotencyStore struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+// NewRedisIdempotencyStore creates a store using keys prefixed with "agno:event:"
+func NewRedisIdempotencyStore(client redis.Cmdable) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{Client: client, Prefix: "agno:event:"}
+}
+
+// Claim implements IdempotencyStore
+func (s *RedisIdempotencyStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := s.Client.SetNX(ctx, s.Prefix+key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim %s in redis: %w", key, err)
+	}
+	return ok, nil
+}
+
+// EventHandler processes the payload of a Lark event
+type EventHandler func(ctx context.Context, payload []byte) error
+
+// pendingEvent is an event in the replay buffer
+type pendingEvent struct {
+	EventID string    `json:"event_id"`
+	Payload []byte    `json:"payload"`
+	Started time.Time `json:"started"`
+	Failed  bool      `json:"failed,omitempty"`
+}
+
+// EventGate deduplicates Lark events by event_id before they reach the
+// handler, and keeps each event in a replay buffer until its handler has
+// succeeded. Events whose handler failed, panicked or died with the process
+// are retried exactly once by Replay.
+type EventGate struct {
+	Seen       IdempotencyStore
+	Buffer     SessionStore  // replay buffer; nil disables replay
+	TTL        time.Duration // how long event IDs are remembered (Lark retries for up to ~7h)
+	StaleAfter time.Duration // in-flight events older than this are considered crashed
+	Interval   time.Duration // how often the background replayer runs
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewEventGate creates a gate remembering event IDs for 12h and replaying
+// events stuck for more than 5 minutes
+func NewEventGate(seen IdempotencyStore, buffer SessionStore) *EventGate {
+	return &EventGate{
+		Seen:       seen,
+		Buffer:     buffer,
+		TTL:        12 * time.Hour,
+		StaleAfter: 5 * time.Minute,
+		Interval:   time.Minute,
+	}
+}
+
+// Handle runs handler for the event unless its ID was seen before. A
+// duplicate returns nil so the webhook acknowledges it to Lark.
+func (g *EventGate) Handle(ctx context.Context, eventID string, payload []byte, handler EventHandler) error {
+	if eventID == "" {
+		return handler(ctx, payload)
+	}
+
+	first, err := g.Seen.Claim(ctx, "seen:"+eventID, g.TTL)
+	if err != nil {
+		// Fail open: a duplicate reply is better than a dropped question
+		logger.Errorf("Event dedup unavailable, handling %s anyway: %v", eventID, err)
+	} else if !first {
+		eventsDeduplicated.Inc()
+		logger.Infof("Dropping duplicate Lark event %s", eventID)
+		return nil
+	}
+
+	g.buffer(ctx, pendingEvent{EventID: eventID, Payload: payload, Started: time.Now()})
+	if err := runHandler(ctx, handler, payload); err != nil {
+		g.buffer(ctx, pendingEvent{EventID: eventID, Payload: payload, Started: time.Now(), Failed: true})
+		return err
+	}
+	g.unbuffer(ctx, eventID)
+	return nil
+}
+
+// Replay retries buffered events that failed or have been in flight longer
+// than StaleAfter. Each event is retried at most once, even across replicas.
+func (g *EventGate) Replay(ctx context.Context, handler EventHandler) {
+	if g.Buffer == nil {
+		return
+	}
+	keys, err := g.Buffer.Keys(ctx, "replay:")
+	if err != nil {
+		logger.Errorf("Failed to list replay buffer: %v", err)
+		return
+	}
+
+	for _, key := range keys {
+		data, err := g.Buffer.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var event pendingEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			logger.Warnf("Dropping unreadable replay entry %s: %v", key, err)
+			g.Buffer.Delete(ctx, key)
+			continue
+		}
+		if !event.Failed && time.Since(event.Started) < g.StaleAfter {
+			continue // still being handled
+		}
+
+		first, err := g.Seen.Claim(ctx, "replayed:"+event.EventID, g.TTL)
+		if err != nil || !first {
+			continue
+		}
+		g.unbuffer(ctx, event.EventID)
+
+		logger.Infof("Replaying Lark event %s", event.EventID)
+		if err := runHandler(ctx, handler, event.Payload); err != nil {
+			eventsReplayed.WithLabelValues("error").Inc()
+			logger.Errorf("Replay of event %s failed, giving up: %v", event.EventID, err)
+			continue
+		}
+		eventsReplayed.WithLabelValues("ok").Inc()
+	}
+}
+
+// Start replays stuck events every Interval
+func (g *EventGate) Start(handler EventHandler) {
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go func() {
+		defer close(g.done)
+		ticker := time.NewTicker(g.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.Replay(context.Background(), handler)
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background replayer
+func (g *EventGate) Stop() {
+	if g.stop == nil {
+		return
+	}
+	close(g.stop)
+	<-g.done
+	g.stop = nil
+}
+
+// buffer stores an event in the replay buffer
+func (g *EventGate) buffer(ctx context.Context, event pendingEvent) {
+	if g.Buffer == nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := g.Buffer.Set(ctx, "replay:"+event.EventID, data, g.TTL); err != nil {
+		logger.Warnf("Failed to buffer event %s for replay: %v", event.EventID, err)
+	}
+}
+
+// unbuffer removes an event from the replay buffer
+func (g *EventGate) unbuffer(ctx context.Context, eventID string) {
+	if g.Buffer == nil {
+		return
+	}
+	if err := g.Buffer.Delete(ctx, "replay:"+eventID); err != nil {
+		logger.Warnf("Failed to remove event %s from replay buffer: %v", eventID, err)
+	}
+}
+
+// runHandler calls handler, turning a panic into an error
+func runHandler(ctx context.Context, handler EventHandler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Event handler panicked: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("event handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, payload)
+}
@@ -0,0 +1,219 @@
+package agno
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDisclosureFooter is the footer used when none is configured
+const DefaultDisclosureFooter = "AI-generated, verify before acting."
+
+// zeroWidthDigits encode two bits each; markerStart introduces a marker
+var zeroWidthDigits = []rune{'\u200b', '\u200c', '\u200d', '\u2060'}
+
+const markerStart = '\ufeff'
+
+// Disclosure configures the footer appended to a tenant's answers
+type Disclosure struct {
+	Footer  string // empty disables the footer
+	Marker  bool   // embed an invisible marker ID tracing the answer to its session
+	ShowRef bool   // also print the marker ID, which survives screenshots
+}
+
+// MarkerRecord is what a marker ID resolves to
+type MarkerRecord struct {
+	MarkerID  string    `json:"marker_id"`
+	Tenant    string    `json:"tenant"`
+	SessionID string    `json:"session_id"`
+	Created   time.Time `json:"created"`
+}
+
+// Disclosures holds per-tenant disclosure footers. Marker IDs are recorded
+// in Store so that a leaked answer can be traced back with Trace.
+type Disclosures struct {
+	Default   Disclosure
+	Store     SessionStore  // required for markers
+	MarkerTTL time.Duration // how long marker IDs stay traceable (0 = forever)
+
+	mu      sync.RWMutex
+	tenants map[string]Disclosure
+}
+
+// NewDisclosures creates a registry using def for tenants without their own footer
+func NewDisclosures(def Disclosure, store SessionStore) *Disclosures {
+	return &Disclosures{
+		Default:   def,
+		Store:     store,
+		MarkerTTL: 365 * 24 * time.Hour,
+		tenants:   make(map[string]Disclosure),
+	}
+}
+
+// NewDisclosuresFromEnv configures the default disclosure from
+// AGNO_DISCLOSURE_FOOTER (default DefaultDisclosureFooter, "off" disables it),
+// AGNO_DISCLOSURE_MARKER and AGNO_DISCLOSURE_SHOW_REF
+func NewDisclosuresFromEnv(store SessionStore) *Disclosures {
+	footer := os.Getenv("AGNO_DISCLOSURE_FOOTER")
+	switch footer {
+	case "":
+		footer = DefaultDisclosureFooter
+	case "off":
+		footer = ""
+	}
+	return NewDisclosures(Disclosure{
+		Footer:  footer,
+		Marker:  os.Getenv("AGNO_DISCLOSURE_MARKER") == "true",
+		ShowRef: os.Getenv("AGNO_DISCLOSURE_SHOW_REF") == "true",
+	}, store)
+}
+
+// Set configures a tenant's disclosure
+func (d *Disclosures) Set(tenant string, disclosure Disclosure) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tenants[tenant] = disclosure
+}
+
+// For returns the disclosure of a tenant
+func (d *Disclosures) For(tenant string) Disclosure {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if disclosure, ok := d.tenants[tenant]; ok {
+		return disclosure
+	}
+	return d.Default
+}
+
+// Footer returns the footer for one answer in sessionID, with a fresh
+// marker ID if the tenant enables markers. It is empty when disabled.
+func (d *Disclosures) Footer(ctx context.Context, tenant, sessionID string) (string, error) {
+	disclosure := d.For(tenant)
+	if disclosure.Footer == "" {
+		return "", nil
+	}
+	if !disclosure.Marker || d.Store == nil {
+		return disclosure.Footer, nil
+	}
+
+	markerID, err := d.newMarker(ctx, tenant, sessionID)
+	if err != nil {
+		return "", err
+	}
+	footer := disclosure.Footer + encodeMarker(markerID)
+	if disclosure.ShowRef {
+		footer += " · ref " + markerID
+	}
+	return footer, nil
+}
+
+// Apply appends the tenant's footer to a plain-text or markdown answer
+func (d *Disclosures) Apply(ctx context.Context, tenant, sessionID, text string) (string, error) {
+	footer, err := d.Footer(ctx, tenant, sessionID)
+	if err != nil || footer == "" {
+		return text, err
+	}
+	return text + "\n\n---\n" + footer, nil
+}
+
+// Trace resolves a marker ID, or text containing an invisible marker (e.g.
+// pasted from a leaked answer), to the session that produced it
+func (d *Disclosures) Trace(ctx context.Context, markerOrText string) (*MarkerRecord, error) {
+	markerID, ok := decodeMarker(markerOrText)
+	if !ok {
+		markerID = strings.TrimSpace(markerOrText)
+	}
+	if d.Store == nil {
+		return nil, ErrKeyNotFound
+	}
+	data, err := d.Store.Get(ctx, "marker:"+markerID)
+	if err != nil {
+		return nil, err
+	}
+	var record MarkerRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal marker record: %w", err)
+	}
+	return &record, nil
+}
+
+// DisclosureElement renders a footer as a card note element
+func DisclosureElement(footer string) map[string]interface{} {
+	return map[string]interface{}{
+		"tag":      "note",
+		"elements": []interface{}{plainText(footer)},
+	}
+}
+
+// newMarker generates and records a marker ID for an answer
+func (d *Disclosures) newMarker(ctx context.Context, tenant, sessionID string) (string, error) {
+	id := make([]byte, 6)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", fmt.Errorf("failed to generate marker ID: %w", err)
+	}
+	markerID := hex.EncodeToString(id)
+
+	data, err := json.Marshal(MarkerRecord{MarkerID: markerID, Tenant: tenant, SessionID: sessionID, Created: time.Now().UTC()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal marker record: %w", err)
+	}
+	if err := d.Store.Set(ctx, "marker:"+markerID, data, d.MarkerTTL); err != nil {
+		return "", fmt.Errorf("failed to store marker: %w", err)
+	}
+	return markerID, nil
+}
+
+// encodeMarker renders a hex marker ID as zero-width characters
+func encodeMarker(markerID string) string {
+	raw, err := hex.DecodeString(markerID)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteRune(markerStart)
+	for _, c := range raw {
+		for shift := 6; shift >= 0; shift -= 2 {
+			b.WriteRune(zeroWidthDigits[(c>>shift)&3])
+		}
+	}
+	return b.String()
+}
+
+// decodeMarker extracts the first invisible marker ID from text
+func decodeMarker(text string) (string, bool) {
+	start := strings.IndexRune(text, markerStart)
+	if start < 0 {
+		return "", false
+	}
+
+	var raw []byte
+	var c byte
+	n := 0
+	for _, r := range text[start+len(string(markerStart)):] {
+		digit := -1
+		for i, z := range zeroWidthDigits {
+			if r == z {
+				digit = i
+			}
+		}
+		if digit < 0 {
+			break
+		}
+		c = c<<2 | byte(digit)
+		if n++; n%4 == 0 {
+			raw = append(raw, c)
+			c = 0
+		}
+	}
+	if len(raw) == 0 {
+		return "", false
+	}
+	return hex.EncodeToString(raw), true
+}
@@ -0,0 +1,310 @@
+package agno
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Reply draft states
+const (
+	DraftPending   = "pending"
+	DraftPosted    = "posted"
+	DraftDiscarded = "discarded"
+)
+
+// Card action values used by the draft review flow
+const (
+	draftActionKey     = "draft_action"
+	draftIDKey         = "draft_id"
+	draftActionApprove = "approve"
+	draftActionDiscard = "discard"
+
+	draftTextField = "draft_text"
+)
+
+// ErrDraftClosed is returned when a draft was already posted, discarded or has expired
+var ErrDraftClosed = errors.New("agno: reply draft is no longer pending")
+
+// ReplyDraft is a bot answer waiting for a reviewer before it is posted to a chat
+type ReplyDraft struct {
+	ID         string    `json:"id"`
+	ChatID     string    `json:"chat_id"`
+	ReplyTo    string    `json:"reply_to,omitempty"` // Lark message ID of the question
+	SessionID  string    `json:"session_id,omitempty"`
+	AskerID    string    `json:"asker_id,omitempty"`
+	Question   string    `json:"question"`
+	Answer     string    `json:"answer"`
+	Reviewer   string    `json:"reviewer"` // open_id
+	Status     string    `json:"status"`
+	Posted     string    `json:"posted,omitempty"` // text actually posted
+	ReviewedBy string    `json:"reviewed_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReplyDrafts implements draft mode for sensitive chats (e.g. customer-facing
+// groups): answers are first sent privately to the chat's reviewer, who can
+// edit, approve or discard them from the review card before anything is
+// posted to the group.
+type ReplyDrafts struct {
+	Store  SessionStore
+	Sender MessageSender // sends the review card and the approved reply
+	Audit  AuditLog
+	TTL    time.Duration // how long drafts wait for a review
+
+	mu        sync.RWMutex
+	reviewers map[string]string // chat ID -> reviewer open_id
+}
+
+// NewReplyDrafts creates a draft flow whose drafts wait a day for review
+func NewReplyDrafts(store SessionStore, sender MessageSender) *ReplyDrafts {
+	return &ReplyDrafts{
+		Store:     store,
+		Sender:    sender,
+		Audit:     LoggerAuditLog{},
+		TTL:       24 * time.Hour,
+		reviewers: make(map[string]string),
+	}
+}
+
+// NewReplyDraftsFromEnv loads draft chats from AGNO_DRAFT_CHATS
+// (comma-separated chat_id:reviewer_open_id pairs)
+func NewReplyDraftsFromEnv(store SessionStore, sender MessageSender) *ReplyDrafts {
+	drafts := NewReplyDrafts(store, sender)
+	for _, pair := range strings.Split(os.Getenv("AGNO_DRAFT_CHATS"), ",") {
+		chatID, reviewer, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || chatID == "" || reviewer == "" {
+			continue
+		}
+		drafts.SetReviewer(chatID, reviewer)
+	}
+	if n := len(drafts.reviewers); n > 0 {
+		logger.Infof("Draft mode enabled for %d chat(s)", n)
+	}
+	return drafts
+}
+
+// SetReviewer enables draft mode for a chat; an empty reviewer disables it
+func (d *ReplyDrafts) SetReviewer(chatID, reviewerID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if reviewerID == "" {
+		delete(d.reviewers, chatID)
+		return
+	}
+	d.reviewers[chatID] = reviewerID
+}
+
+// Reviewer returns the reviewer of a chat and whether the chat is in draft mode
+func (d *ReplyDrafts) Reviewer(chatID string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	reviewer, ok := d.reviewers[chatID]
+	return reviewer, ok
+}
+
+// Submit holds an answer for review and sends the review card to the chat's
+// reviewer. Callers should check Reviewer first and post directly for chats
+// outside draft mode.
+func (d *ReplyDrafts) Submit(ctx context.Context, draft ReplyDraft) (*ReplyDraft, error) {
+	reviewer, ok := d.Reviewer(draft.ChatID)
+	if !ok {
+		return nil, fmt.Errorf("%w: chat %s is not in draft mode", ErrInvalidRequest, draft.ChatID)
+	}
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, fmt.Errorf("failed to generate draft ID: %w", err)
+	}
+	draft.ID = hex.EncodeToString(id)
+	draft.Reviewer = reviewer
+	draft.Status = DraftPending
+	draft.CreatedAt = time.Now().UTC()
+	if err := d.save(ctx, draft); err != nil {
+		return nil, err
+	}
+
+	card, err := json.Marshal(BuildDraftReviewCard(draft))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal review card: %w", err)
+	}
+	msg := OutboxMessage{ReceiveID: reviewer, ReceiveIDType: "open_id", MsgType: "interactive", Content: string(card), SessionID: draft.SessionID}
+	if _, err := d.Sender.SendMessage(ctx, msg); err != nil {
+		logger.Errorf("Failed to send reply draft %s to reviewer %s: %v", draft.ID, reviewer, err)
+		return nil, fmt.Errorf("failed to send draft for review: %w", err)
+	}
+	logger.Infof("Reply draft %s for chat %s sent to reviewer %s", draft.ID, draft.ChatID, reviewer)
+	return &draft, nil
+}
+
+// Get returns a draft
+func (d *ReplyDrafts) Get(ctx context.Context, id string) (*ReplyDraft, error) {
+	data, err := d.Store.Get(ctx, "draft:"+id)
+	if err != nil {
+		return nil, err
+	}
+	var draft ReplyDraft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reply draft %s: %w", id, err)
+	}
+	return &draft, nil
+}
+
+// BuildDraftReviewCard builds the card sent to the reviewer: the question,
+// the answer in an editable text area, and approve/discard buttons
+func BuildDraftReviewCard(draft ReplyDraft) map[string]interface{} {
+	approve := callbackButton("✅ Post to group", "primary", map[string]interface{}{
+		draftActionKey: draftActionApprove,
+		draftIDKey:     draft.ID,
+	})
+	approve["action_type"] = "form_submit"
+	approve["name"] = "draft_approve"
+
+	discard := callbackButton("🗑 Discard", "danger", map[string]interface{}{
+		draftActionKey: draftActionDiscard,
+		draftIDKey:     draft.ID,
+	})
+	discard["action_type"] = "form_submit"
+	discard["name"] = "draft_discard"
+
+	question := draft.Question
+	if draft.AskerID != "" {
+		question = fmt.Sprintf("<at id=%s></at>: %s", draft.AskerID, draft.Question)
+	}
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": cardHeader("📝 Reply draft awaiting review", "orange"),
+		"elements": []interface{}{
+			markdownElement("**Question**\n" + question),
+			map[string]interface{}{"tag": "hr"},
+			map[string]interface{}{
+				"tag":  "form",
+				"name": "draft_form",
+				"elements": []interface{}{
+					map[string]interface{}{
+						"tag":           "input",
+						"name":          draftTextField,
+						"input_type":    "multiline_text",
+						"rows":          8,
+						"default_value": draft.Answer,
+						"label":         plainText("Answer (edit before posting if needed)"),
+					},
+					approve,
+					discard,
+				},
+			},
+		},
+	}
+}
+
+// buildDraftResultCard replaces the review card once the draft is handled
+func buildDraftResultCard(draft ReplyDraft) map[string]interface{} {
+	title, template, body := "✅ Reply posted", "green", draft.Posted
+	if draft.Status == DraftDiscarded {
+		title, template, body = "🗑 Reply discarded", "grey", draft.Answer
+	}
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": cardHeader(title, template),
+		"elements": []interface{}{
+			markdownElement("**Question**\n" + draft.Question),
+			markdownElement("**Answer**\n" + body),
+			markdownElement(fmt.Sprintf("Reviewed by <at id=%s></at>", draft.ReviewedBy)),
+		},
+	}
+}
+
+// HandleDraftAction processes review card callbacks. It returns the card
+// replacing the review card and false if the action does not belong to the
+// draft flow. Only the chat's reviewer may act on a draft.
+func (d *ReplyDrafts) HandleDraftAction(ctx context.Context, action CardAction, operatorID string) (map[string]interface{}, bool, error) {
+	decision := action.StringValue(draftActionKey)
+	if decision != draftActionApprove && decision != draftActionDiscard {
+		return nil, false, nil
+	}
+	draft, err := d.Get(ctx, action.StringValue(draftIDKey))
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, true, ErrDraftClosed
+		}
+		return nil, true, err
+	}
+	if draft.Status != DraftPending {
+		return buildDraftResultCard(*draft), true, ErrDraftClosed
+	}
+	if reviewer, _ := d.Reviewer(draft.ChatID); operatorID != draft.Reviewer && operatorID != reviewer {
+		return nil, true, errors.New("only the chat's reviewer can handle this draft")
+	}
+	draft.ReviewedBy = operatorID
+
+	detail := map[string]string{"draft_id": draft.ID, "session_id": draft.SessionID}
+	if decision == draftActionDiscard {
+		draft.Status = DraftDiscarded
+		if err := d.save(ctx, *draft); err != nil {
+			return nil, true, err
+		}
+		d.record(ctx, operatorID, "draft.discard", draft.ChatID, detail)
+		logger.Infof("Reply draft %s for chat %s discarded by %s", draft.ID, draft.ChatID, operatorID)
+		return buildDraftResultCard(*draft), true, nil
+	}
+
+	text, _ := action.FormValue[draftTextField].(string)
+	if text = strings.TrimSpace(text); text == "" {
+		return nil, true, errors.New("the reply is empty; discard the draft instead")
+	}
+	content, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to marshal reply: %w", err)
+	}
+	msg := OutboxMessage{ReceiveID: draft.ChatID, ReceiveIDType: "chat_id", ReplyTo: draft.ReplyTo, MsgType: "text", Content: string(content), SessionID: draft.SessionID}
+	if _, err := d.Sender.SendMessage(ctx, msg); err != nil {
+		logger.Errorf("Failed to post reply draft %s to chat %s: %v", draft.ID, draft.ChatID, err)
+		return nil, true, fmt.Errorf("failed to post reply: %w", err)
+	}
+
+	draft.Status = DraftPosted
+	draft.Posted = text
+	if err := d.save(ctx, *draft); err != nil {
+		logger.Warnf("Failed to mark reply draft %s as posted: %v", draft.ID, err)
+	}
+	edited := text != strings.TrimSpace(draft.Answer)
+	detail["edited"] = fmt.Sprint(edited)
+	d.record(ctx, operatorID, "draft.approve", draft.ChatID, detail)
+	logger.Infof("Reply draft %s posted to chat %s by %s (edited: %v)", draft.ID, draft.ChatID, operatorID, edited)
+	return buildDraftResultCard(*draft), true, nil
+}
+
+// save writes a draft; handled drafts are kept for TTL as a review record
+func (d *ReplyDrafts) save(ctx context.Context, draft ReplyDraft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply draft: %w", err)
+	}
+	if err := d.Store.Set(ctx, "draft:"+draft.ID, data, d.TTL); err != nil {
+		return fmt.Errorf("failed to save reply draft: %w", err)
+	}
+	return nil
+}
+
+// record writes an audit event, logging (not failing on) audit errors
+func (d *ReplyDrafts) record(ctx context.Context, actor, action, chatID string, detail map[string]string) {
+	if d.Audit == nil {
+		return
+	}
+	event := AuditEvent{Time: time.Now().UTC(), Actor: actor, Action: action, Subject: "chat:" + chatID, Detail: detail}
+	if err := d.Audit.Record(ctx, event); err != nil {
+		logger.Errorf("Failed to write audit event %s on chat %s: %v", action, chatID, err)
+	}
+}
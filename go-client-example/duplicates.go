@@ -0,0 +1,243 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"start-feishubot/logger"
+)
+
+// Card action values for the duplicate-question card
+const (
+	duplicateActionKey       = "duplicate_action"
+	duplicateQuestionKey     = "question"
+	duplicateMessageKey      = "prior_message_id"
+	duplicateActionAskAgain  = "ask_anyway"
+	duplicateActionShowPrior = "show_prior"
+)
+
+// Embedder turns texts into embedding vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// embeddingsRequest is the body sent to POST /embeddings
+type embeddingsRequest struct {
+	Input []string `json:"input"`
+}
+
+// embeddingsResponse is the body returned by POST /embeddings
+type embeddingsResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// Embed returns embeddings for texts using the Agno service's /embeddings endpoint
+func (c *AgnoClient) Embed(ctx context.Context, texts []string) (_ [][]float64, err error) {
+	ctx, span := startSpan(ctx, "Embed")
+	defer func() { endSpan(span, err) }()
+
+	jsonData, err := json.Marshal(embeddingsRequest{Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/embeddings", c.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "embeddings", "")
+	if err != nil {
+		logger.Errorf("Agno embeddings request failed: %v", err)
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, newAPIError(statusCode, body)
+	}
+
+	var embResp embeddingsResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embeddings response: %w", err)
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embResp.Embeddings))
+	}
+	return embResp.Embeddings, nil
+}
+
+// PriorAnswer is a recent answer the bot gave in a chat
+type PriorAnswer struct {
+	Question   string
+	Answer     string
+	MessageID  string // Lark message ID of the bot's answer
+	AnsweredAt time.Time
+
+	embedding []float64
+}
+
+// DuplicateDetector spots questions that were recently answered in the same chat
+type DuplicateDetector struct {
+	Embedder  Embedder
+	Threshold float64       // cosine similarity above which questions are duplicates
+	Window    time.Duration // how far back answers are considered
+	PerChat   int           // answers remembered per chat
+
+	mu      sync.Mutex
+	answers map[string][]PriorAnswer
+}
+
+// NewDuplicateDetector creates a detector remembering the last 50 answers per chat for 24h
+func NewDuplicateDetector(embedder Embedder) *DuplicateDetector {
+	return &DuplicateDetector{
+		Embedder:  embedder,
+		Threshold: 0.92,
+		Window:    24 * time.Hour,
+		PerChat:   50,
+		answers:   make(map[string][]PriorAnswer),
+	}
+}
+
+// Remember records an answer so later questions can be matched against it
+func (d *DuplicateDetector) Remember(ctx context.Context, chatID string, answer PriorAnswer) error {
+	embeddings, err := d.Embedder.Embed(ctx, []string{answer.Question})
+	if err != nil {
+		return fmt.Errorf("failed to embed question: %w", err)
+	}
+	answer.embedding = embeddings[0]
+	if answer.AnsweredAt.IsZero() {
+		answer.AnsweredAt = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	answers := append(d.prune(chatID), answer)
+	if len(answers) > d.PerChat {
+		answers = answers[len(answers)-d.PerChat:]
+	}
+	d.answers[chatID] = answers
+	return nil
+}
+
+// Find returns the most similar recent answer in the chat, if it is a near-duplicate
+func (d *DuplicateDetector) Find(ctx context.Context, chatID, question string) (*PriorAnswer, error) {
+	d.mu.Lock()
+	candidates := append([]PriorAnswer(nil), d.prune(chatID)...)
+	d.mu.Unlock()
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := d.Embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	var best *PriorAnswer
+	bestScore := d.Threshold
+	for i := range candidates {
+		if score := cosineSimilarity(embeddings[0], candidates[i].embedding); score >= bestScore {
+			best, bestScore = &candidates[i], score
+		}
+	}
+	if best != nil {
+		logger.Debugf("Duplicate question in chat %s (similarity %.3f): %s", chatID, bestScore, question)
+	}
+	return best, nil
+}
+
+// prune drops expired answers for a chat; callers must hold d.mu
+func (d *DuplicateDetector) prune(chatID string) []PriorAnswer {
+	cutoff := time.Now().Add(-d.Window)
+	answers := d.answers[chatID]
+	i := 0
+	for i < len(answers) && answers[i].AnsweredAt.Before(cutoff) {
+		i++
+	}
+	answers = answers[i:]
+	if len(answers) == 0 {
+		delete(d.answers, chatID)
+		return nil
+	}
+	d.answers[chatID] = answers
+	return answers
+}
+
+// BuildDuplicateCard builds the instant reply quoting the prior answer, with
+// a "Show earlier answer" button leading to the bot's original reply (when
+// its MessageID is known) and an "Ask anyway" button that sends the
+// question to the agent
+func BuildDuplicateCard(question string, prior PriorAnswer) map[string]interface{} {
+	excerpt := prior.Answer
+	if utf8.RuneCountInString(excerpt) > 500 {
+		excerpt = string([]rune(excerpt)[:500]) + "…"
+	}
+
+	var buttons []interface{}
+	if prior.MessageID != "" {
+		buttons = append(buttons, callbackButton("Show earlier answer", "primary", map[string]interface{}{
+			duplicateActionKey:  duplicateActionShowPrior,
+			duplicateMessageKey: prior.MessageID,
+		}))
+	}
+	buttons = append(buttons, callbackButton("Ask anyway", "default", map[string]interface{}{
+		duplicateActionKey:   duplicateActionAskAgain,
+		duplicateQuestionKey: question,
+	}))
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": cardHeader("🔁 This was answered recently", "wathet"),
+		"elements": []interface{}{
+			markdownElement(fmt.Sprintf("A similar question was answered %s ago:\n> %s",
+				time.Since(prior.AnsweredAt).Round(time.Minute), prior.Question)),
+			markdownElement(excerpt),
+			actionModule(buttons...),
+		},
+	}
+}
+
+// ParseAskAnyway returns the original question if action is an "Ask anyway" click
+func ParseAskAnyway(action CardAction) (string, bool) {
+	if action.StringValue(duplicateActionKey) != duplicateActionAskAgain {
+		return "", false
+	}
+	return action.StringValue(duplicateQuestionKey), true
+}
+
+// ParseShowPrior returns the Lark message ID of the earlier answer if action
+// is a "Show earlier answer" click; reply to that message in its thread so
+// Lark takes the user to it
+func ParseShowPrior(action CardAction) (string, bool) {
+	if action.StringValue(duplicateActionKey) != duplicateActionShowPrior {
+		return "", false
+	}
+	messageID := action.StringValue(duplicateMessageKey)
+	return messageID, messageID != ""
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors (0 if incompatible)
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,329 @@
+package agno
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// ErrDecrypt is returned when stored data cannot be decrypted with its tenant's key
+var ErrDecrypt = errors.New("agno: decryption failed")
+
+var plaintextReads = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "encryption",
+	Name:      "plaintext_reads_total",
+	Help:      "Values read unencrypted from an EncryptedSessionStore, written before encryption was turned on.",
+})
+
+// ciphertextVersion prefixes every ciphertext produced by TenantCipher
+const ciphertextVersion = 1
+
+// KeyManager wraps and unwraps per-tenant data keys with a master key, e.g.
+// AWS KMS GenerateDataKey/Decrypt with the tenant as encryption context
+type KeyManager interface {
+	// GenerateDataKey returns a new 32-byte data key and its wrapped form
+	GenerateDataKey(ctx context.Context, tenant string) (plaintext, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key generated for tenant
+	DecryptDataKey(ctx context.Context, tenant string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyManager is a KeyManager holding the master key in process memory.
+// Use it for development or where no KMS is available.
+type LocalKeyManager struct {
+	master cipher.AEAD
+}
+
+// NewLocalKeyManager creates a key manager from a 32-byte master key
+func NewLocalKeyManager(masterKey []byte) (*LocalKeyManager, error) {
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return &LocalKeyManager{master: aead}, nil
+}
+
+// NewLocalKeyManagerFromEnv reads a base64 master key from AGNO_MASTER_KEY
+// (or the file named by AGNO_MASTER_KEY_FILE)
+func NewLocalKeyManagerFromEnv() (*LocalKeyManager, error) {
+	encoded, err := envOrFile("AGNO_MASTER_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if encoded == "" {
+		return nil, errors.New("AGNO_MASTER_KEY is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AGNO_MASTER_KEY: %w", err)
+	}
+	return NewLocalKeyManager(key)
+}
+
+// GenerateDataKey implements KeyManager
+func (m *LocalKeyManager) GenerateDataKey(ctx context.Context, tenant string) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := sealGCM(m.master, key, []byte(tenant))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, wrapped, nil
+}
+
+// DecryptDataKey implements KeyManager
+func (m *LocalKeyManager) DecryptDataKey(ctx context.Context, tenant string, wrapped []byte) ([]byte, error) {
+	return openGCM(m.master, wrapped, []byte(tenant))
+}
+
+// TenantCipher encrypts conversation data with a per-tenant data key
+// (envelope encryption). Wrapped data keys are kept in a SessionStore;
+// unwrapped keys are cached in memory. Compromising one tenant's data key
+// exposes no other tenant's data.
+type TenantCipher struct {
+	KMS   KeyManager
+	Store SessionStore
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD // by tenant + "/" + key ID
+}
+
+// NewTenantCipher creates a cipher whose wrapped keys live in store
+func NewTenantCipher(kms KeyManager, store SessionStore) *TenantCipher {
+	return &TenantCipher{KMS: kms, Store: store, keys: make(map[string]cipher.AEAD)}
+}
+
+// Encrypt encrypts plaintext with the tenant's current data key, creating
+// one on first use. The output is version | key ID | nonce | sealed data.
+func (t *TenantCipher) Encrypt(ctx context.Context, tenant string, plaintext []byte) ([]byte, error) {
+	keyID, aead, err := t.currentKey(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealGCM(aead, plaintext, []byte(tenant))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 2+len(keyID)+len(sealed))
+	out = append(out, ciphertextVersion, byte(len(keyID)))
+	out = append(out, keyID...)
+	return append(out, sealed...), nil
+}
+
+// Decrypt decrypts data produced by Encrypt for the same tenant
+func (t *TenantCipher) Decrypt(ctx context.Context, tenant string, data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != ciphertextVersion || len(data) < 2+int(data[1]) {
+		return nil, fmt.Errorf("%w: malformed ciphertext", ErrDecrypt)
+	}
+	keyID := string(data[2 : 2+int(data[1])])
+	aead, err := t.key(ctx, tenant, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return openGCM(aead, data[2+len(keyID):], []byte(tenant))
+}
+
+// RotateKey creates a new data key for tenant. New data is encrypted with
+// it; existing data stays readable with the previous keys.
+func (t *TenantCipher) RotateKey(ctx context.Context, tenant string) error {
+	_, err := t.newKey(ctx, tenant)
+	return err
+}
+
+// currentKey returns the tenant's active data key, creating it if needed
+func (t *TenantCipher) currentKey(ctx context.Context, tenant string) (string, cipher.AEAD, error) {
+	current, err := t.Store.Get(ctx, dataKeyKey(tenant, "current"))
+	if errors.Is(err, ErrKeyNotFound) {
+		keyID, err := t.newKey(ctx, tenant)
+		if err != nil {
+			return "", nil, err
+		}
+		current = []byte(keyID)
+	} else if err != nil {
+		return "", nil, fmt.Errorf("failed to read current data key: %w", err)
+	}
+
+	aead, err := t.key(ctx, tenant, string(current))
+	return string(current), aead, err
+}
+
+// newKey generates, stores and activates a data key for tenant
+func (t *TenantCipher) newKey(ctx context.Context, tenant string) (string, error) {
+	plaintext, wrapped, err := t.KMS.GenerateDataKey(ctx, tenant)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate data key for tenant %s: %w", tenant, err)
+	}
+	id := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	keyID := time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(id)
+
+	if err := t.Store.Set(ctx, dataKeyKey(tenant, keyID), wrapped, 0); err != nil {
+		return "", fmt.Errorf("failed to store data key: %w", err)
+	}
+	if err := t.Store.Set(ctx, dataKeyKey(tenant, "current"), []byte(keyID), 0); err != nil {
+		return "", fmt.Errorf("failed to activate data key: %w", err)
+	}
+
+	aead, err := newGCM(plaintext)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.keys[tenant+"/"+keyID] = aead
+	t.mu.Unlock()
+	logger.Infof("Created data key %s for tenant %s", keyID, tenant)
+	return keyID, nil
+}
+
+// key returns a tenant's data key by ID, unwrapping it through the KMS on first use
+func (t *TenantCipher) key(ctx context.Context, tenant, keyID string) (cipher.AEAD, error) {
+	t.mu.Lock()
+	aead, ok := t.keys[tenant+"/"+keyID]
+	t.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	wrapped, err := t.Store.Get(ctx, dataKeyKey(tenant, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key %s of tenant %s: %v", ErrDecrypt, keyID, tenant, err)
+	}
+	plaintext, err := t.KMS.DecryptDataKey(ctx, tenant, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %s of tenant %s: %w", keyID, tenant, err)
+	}
+	if aead, err = newGCM(plaintext); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.keys[tenant+"/"+keyID] = aead
+	t.mu.Unlock()
+	return aead, nil
+}
+
+// dataKeyKey is the store key of a tenant's wrapped data key
+func dataKeyKey(tenant, keyID string) string {
+	return fmt.Sprintf("dek:%s:%s", tenant, keyID)
+}
+
+// EncryptedSessionStore is a SessionStore that encrypts every value with the
+// data key of the tenant owning its key.
+//
+// Cipher.Store must not be the EncryptedSessionStore itself: the wrapped
+// data keys would then have to be decrypted with themselves. It may be
+// Inner, as the wrapped keys are stored as they are under dek:<tenant>:.
+//
+// Values written before encryption was turned on are returned as they are
+// and encrypted by their next Set. Once every record has been rewritten,
+// set RejectPlaintext so planted plaintext values fail to read.
+type EncryptedSessionStore struct {
+	Inner    SessionStore
+	Cipher   *TenantCipher
+	TenantOf func(key string) string // maps a store key to its tenant
+
+	RejectPlaintext bool // fail reads of unencrypted values
+}
+
+// errCipherLoop is returned when the cipher keeps its keys in the encrypted store
+var errCipherLoop = errors.New("agno: TenantCipher.Store must not be the EncryptedSessionStore it encrypts")
+
+// Get implements SessionStore
+func (s *EncryptedSessionStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.Cipher.Store == SessionStore(s) {
+		return nil, errCipherLoop
+	}
+	data, err := s.Inner.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 && data[0] != ciphertextVersion && !s.RejectPlaintext {
+		plaintextReads.Inc()
+		logger.Debugf("Read unencrypted value of %s", key)
+		return data, nil
+	}
+	return s.Cipher.Decrypt(ctx, s.tenant(key), data)
+}
+
+// Set implements SessionStore
+func (s *EncryptedSessionStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if s.Cipher.Store == SessionStore(s) {
+		return errCipherLoop
+	}
+	data, err := s.Cipher.Encrypt(ctx, s.tenant(key), value)
+	if err != nil {
+		return err
+	}
+	return s.Inner.Set(ctx, key, data, ttl)
+}
+
+// Delete implements SessionStore
+func (s *EncryptedSessionStore) Delete(ctx context.Context, key string) error {
+	return s.Inner.Delete(ctx, key)
+}
+
+// Keys implements SessionStore
+func (s *EncryptedSessionStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	return s.Inner.Keys(ctx, prefix)
+}
+
+// tenant returns the tenant owning key
+func (s *EncryptedSessionStore) tenant(key string) string {
+	if s.TenantOf == nil {
+		return defaultTenant
+	}
+	if tenant := s.TenantOf(key); tenant != "" {
+		return tenant
+	}
+	return defaultTenant
+}
+
+// newGCM creates an AES-256-GCM AEAD
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealGCM encrypts plaintext with a random nonce prepended
+func sealGCM(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// openGCM decrypts data produced by sealGCM
+func openGCM(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
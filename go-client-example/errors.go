@@ -0,0 +1,127 @@
+package agno
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for failures reported by the Agno service. Use errors.Is
+// on any error returned by the client to test for them.
+var (
+	ErrRateLimited        = errors.New("agno: rate limited")
+	ErrContentBlocked     = errors.New("agno: content blocked")
+	ErrSessionNotFound    = errors.New("agno: session not found")
+	ErrModelTimeout       = errors.New("agno: model timeout")
+	ErrUnauthorized       = errors.New("agno: unauthorized")
+	ErrInvalidRequest     = errors.New("agno: invalid request")
+	ErrServiceUnavailable = errors.New("agno: service unavailable")
+)
+
+// errorCodes maps the "code" field of structured error bodies to sentinels
+var errorCodes = map[string]error{
+	"rate_limited":        ErrRateLimited,
+	"content_blocked":     ErrContentBlocked,
+	"content_filtered":    ErrContentBlocked,
+	"session_not_found":   ErrSessionNotFound,
+	"model_timeout":       ErrModelTimeout,
+	"unauthorized":        ErrUnauthorized,
+	"invalid_request":     ErrInvalidRequest,
+	"service_unavailable": ErrServiceUnavailable,
+}
+
+// APIError is a non-2xx response from the Agno service. Its body is parsed
+// from {"code": ..., "detail": ...}; FastAPI's plain {"detail": ...} also works.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Detail     string
+	Body       string
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("service returned status %d (%s): %s", e.StatusCode, e.Code, e.Detail)
+	}
+	return fmt.Sprintf("service returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap returns the sentinel error matching the code or, failing that, the status
+func (e *APIError) Unwrap() error {
+	if err, ok := errorCodes[e.Code]; ok {
+		return err
+	}
+
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrInvalidRequest
+	case http.StatusGatewayTimeout:
+		return ErrModelTimeout
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return ErrServiceUnavailable
+	}
+	return nil
+}
+
+// ParseAPIError builds an APIError from a failed response, for transports
+// outside this package
+func ParseAPIError(statusCode int, body []byte) *APIError {
+	return newAPIError(statusCode, body)
+}
+
+// newAPIError builds an APIError from a failed response
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: string(body)}
+
+	var payload struct {
+		Code   string          `json:"code"`
+		Detail json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return apiErr
+	}
+
+	apiErr.Code = payload.Code
+	// detail is usually a string, but FastAPI validation errors return a list
+	if err := json.Unmarshal(payload.Detail, &apiErr.Detail); err != nil {
+		apiErr.Detail = string(payload.Detail)
+	}
+	return apiErr
+}
+
+// UserMessage returns a short, user-facing explanation of err suitable for a Lark reply
+func UserMessage(err error) string {
+	var quotaErr *QuotaError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &quotaErr):
+		return quotaErr.UserMessage()
+	case errors.Is(err, ErrModerated):
+		return "🤖️: Your message looks like it contains a secret or blocked content, so I didn't send it. Please remove it and try again."
+	case errors.Is(err, ErrUnsupportedImage):
+		return "🤖️: I can't read that image. Please send a PNG, JPEG, GIF or WebP under 10MB."
+	case errors.Is(err, ErrEmptyTranscript):
+		return "🤖️: I couldn't hear anything in that voice message. Please try again or type your question."
+	case errors.Is(err, ErrDraftClosed):
+		return "🤖️: This draft was already handled or has expired."
+	case errors.Is(err, ErrRateLimited):
+		return "🤖️: I'm getting too many requests right now. Please try again in a minute."
+	case errors.Is(err, ErrContentBlocked):
+		return "🤖️: Sorry, I can't help with that request."
+	case errors.Is(err, ErrSessionNotFound):
+		return "🤖️: This conversation has expired. Please start a new one."
+	case errors.Is(err, ErrModelTimeout):
+		return "🤖️: That took too long to answer. Please try a simpler question or try again later."
+	case errors.Is(err, ErrServiceUnavailable):
+		return "🤖️: The assistant is temporarily unavailable. Please try again later."
+	default:
+		return "🤖️: The message bot encountered an error, please try again later."
+	}
+}
@@ -0,0 +1,225 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Agent event types emitted by the client itself; step events keep the
+// type reported by the service (tool_call, model_call, retry, error, ...)
+const (
+	AgentEventError    = "error"
+	AgentEventFallback = "fallback"
+)
+
+var eventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "event_log",
+	Name:      "dropped_total",
+	Help:      "Agent events dropped because a live subscriber was too slow.",
+})
+
+// AgentEvent is one entry of the structured agent event log. It never
+// carries message content or tool payloads, only what ops need to follow a
+// run live.
+type AgentEvent struct {
+	Time       time.Time `json:"time"`
+	SessionID  string    `json:"session_id"`
+	Tenant     string    `json:"tenant"`
+	Type       string    `json:"type"`
+	Name       string    `json:"name,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// EventPublisher receives agent events (set AgnoClient.Events)
+type EventPublisher interface {
+	Publish(ctx context.Context, event AgentEvent) error
+}
+
+// MultiPublisher publishes every event to each of its publishers
+type MultiPublisher []EventPublisher
+
+// Publish implements EventPublisher
+func (m MultiPublisher) Publish(ctx context.Context, event AgentEvent) error {
+	var firstErr error
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NATSConn is the subset of *nats.Conn used by NATSPublisher
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publishes agent events as JSON to "<Subject>.<tenant>", so
+// ops can follow everything (agno.events.>) or a single tenant
+type NATSPublisher struct {
+	Conn    NATSConn
+	Subject string
+}
+
+// NewNATSPublisher creates a publisher on the "agno.events" subject
+func NewNATSPublisher(conn NATSConn) *NATSPublisher {
+	return &NATSPublisher{Conn: conn, Subject: "agno.events"}
+}
+
+// Publish implements EventPublisher
+func (p *NATSPublisher) Publish(ctx context.Context, event AgentEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent event: %w", err)
+	}
+	if err := p.Conn.Publish(p.Subject+"."+event.Tenant, data); err != nil {
+		return fmt.Errorf("failed to publish agent event: %w", err)
+	}
+	return nil
+}
+
+// EventStream fans agent events out to live subscribers in process, e.g.
+// the admin port's event stream. Slow subscribers miss events rather than
+// slowing down chats.
+type EventStream struct {
+	Buffer int // events buffered per subscriber
+
+	mu   sync.Mutex
+	subs map[chan AgentEvent]struct{}
+}
+
+// NewEventStream creates a stream buffering 256 events per subscriber
+func NewEventStream() *EventStream {
+	return &EventStream{Buffer: 256, subs: make(map[chan AgentEvent]struct{})}
+}
+
+// Publish implements EventPublisher
+func (s *EventStream) Publish(ctx context.Context, event AgentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- event:
+		default:
+			eventsDropped.Inc()
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel of new events and a function ending the subscription
+func (s *EventStream) Subscribe() (<-chan AgentEvent, func()) {
+	ch := make(chan AgentEvent, s.Buffer)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Handler streams events as server-sent events ("data: {...}" lines) for
+// the admin port, optionally filtered by ?session= and ?tenant=. Follow it
+// with curl -N or a browser EventSource. Requests must be signed with
+// secret (see VerifyRequest).
+// It returns ErrMissingSecret when secret is empty.
+func (s *EventStream) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("EventStream.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected event stream request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		session, tenant := r.URL.Query().Get("session"), r.URL.Query().Get("tenant")
+
+		events, unsubscribe := s.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+		for {
+			select {
+			case event := <-events:
+				if (session != "" && event.SessionID != session) || (tenant != "" && event.Tenant != tenant) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				flusher.Flush()
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}), nil
+}
+
+// publishEvents emits the step events of a chat, and an error event if it failed
+func (c *AgnoClient) publishEvents(ctx context.Context, sessionID string, resp *ChatResponse, err error) {
+	if c.Events == nil {
+		return
+	}
+	tenant := c.tenant(sessionID)
+	if err != nil {
+		c.publish(ctx, AgentEvent{Time: time.Now().UTC(), SessionID: sessionID, Tenant: tenant, Type: AgentEventError, Error: err.Error()})
+		return
+	}
+	for _, step := range resp.Steps {
+		event := AgentEvent{
+			Time:       time.Now().UTC(),
+			SessionID:  sessionID,
+			Tenant:     tenant,
+			Type:       step.Type,
+			Name:       step.Name,
+			DurationMS: step.DurationMS,
+			Error:      step.Error,
+		}
+		if t, err := time.Parse(time.RFC3339Nano, step.Timestamp); err == nil {
+			event.Time = t.UTC()
+		}
+		c.publish(ctx, event)
+	}
+}
+
+// publish sends one event to Events, logging failures
+func (c *AgnoClient) publish(ctx context.Context, event AgentEvent) {
+	if err := c.Events.Publish(ctx, event); err != nil {
+		logger.Warnf("Failed to publish agent event for session %s: %v", event.SessionID, err)
+	}
+}
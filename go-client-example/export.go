@@ -0,0 +1,296 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"start-feishubot/logger"
+)
+
+// Export file formats
+const (
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+)
+
+// ObjectStore uploads export files (implemented by the bot's S3/OSS/GCS client)
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// DirObjectStore is an ObjectStore writing files below a local directory
+type DirObjectStore string
+
+// PutObject writes body to dir/key
+func (d DirObjectStore) PutObject(ctx context.Context, key string, body []byte, contentType string) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return os.WriteFile(path, body, 0o644)
+}
+
+// ExportRow is one anonymized analytics event. It never contains message
+// content: IDs are salted hashes and only numeric/boolean properties are kept.
+type ExportRow struct {
+	Timestamp   string `parquet:"timestamp" json:"timestamp"`
+	Date        string `parquet:"date" json:"date"`
+	Event       string `parquet:"event" json:"event"`
+	UserHash    string `parquet:"user_hash" json:"user_hash"`
+	ChatHash    string `parquet:"chat_hash" json:"chat_hash"`
+	SessionHash string `parquet:"session_hash" json:"session_hash"`
+	Metrics     string `parquet:"metrics" json:"metrics"` // JSON object of numeric properties
+}
+
+// exportColumns is the CSV header, in ExportRow field order
+var exportColumns = []string{"timestamp", "date", "event", "user_hash", "chat_hash", "session_hash", "metrics"}
+
+// AnalyticsExporter is an AnalyticsRecorder that buffers anonymized events
+// and uploads them as daily-partitioned CSV and/or Parquet files for BI
+type AnalyticsExporter struct {
+	Store    ObjectStore
+	Salt     []byte   // HMAC key for ID hashing; rotate to unlink old exports
+	Formats  []string // ExportCSV and/or ExportParquet
+	Prefix   string   // object key prefix, e.g. "bi/agno"
+	Interval time.Duration
+	Next     AnalyticsRecorder // optional recorder to forward events to
+
+	// MaxBuffered caps the rows kept per format while uploads fail; the
+	// oldest are dropped beyond it (default 100000)
+	MaxBuffered int
+
+	mu      sync.Mutex
+	pending map[string][]ExportRow // rows not yet uploaded, by format
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// defaultExportBuffer is the MaxBuffered used when it isn't set
+const defaultExportBuffer = 100000
+
+// NewAnalyticsExporter creates an exporter uploading CSV and Parquet every hour
+func NewAnalyticsExporter(store ObjectStore, salt []byte) *AnalyticsExporter {
+	return &AnalyticsExporter{
+		Store:       store,
+		Salt:        salt,
+		Formats:     []string{ExportCSV, ExportParquet},
+		Prefix:      "agno/conversations",
+		Interval:    time.Hour,
+		MaxBuffered: defaultExportBuffer,
+	}
+}
+
+// Record implements AnalyticsRecorder
+func (e *AnalyticsExporter) Record(ctx context.Context, event AnalyticsEvent) error {
+	row, err := e.anonymize(event)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	if e.pending == nil {
+		e.pending = make(map[string][]ExportRow)
+	}
+	for _, format := range e.Formats {
+		e.pending[format] = append(e.pending[format], row)
+		e.trimLocked(format)
+	}
+	e.mu.Unlock()
+
+	if e.Next != nil {
+		return e.Next.Record(ctx, event)
+	}
+	return nil
+}
+
+// anonymize converts an event into an export row
+func (e *AnalyticsExporter) anonymize(event AnalyticsEvent) (ExportRow, error) {
+	ts := event.Timestamp.UTC()
+	if event.Timestamp.IsZero() {
+		ts = time.Now().UTC()
+	}
+
+	metrics := make(map[string]interface{})
+	for key, value := range event.Properties {
+		switch value.(type) {
+		case int, int32, int64, uint, uint32, uint64, float32, float64, bool, time.Duration:
+			metrics[key] = value
+		}
+	}
+	encoded, err := json.Marshal(metrics)
+	if err != nil {
+		return ExportRow{}, fmt.Errorf("failed to marshal export metrics: %w", err)
+	}
+
+	return ExportRow{
+		Timestamp:   ts.Format(time.RFC3339),
+		Date:        ts.Format("2006-01-02"),
+		Event:       event.Name,
+		UserHash:    e.hash(event.UserID),
+		ChatHash:    e.hash(event.ChatID),
+		SessionHash: e.hash(event.SessionID),
+		Metrics:     string(encoded),
+	}, nil
+}
+
+// hash pseudonymizes an ID with the exporter's salt
+func (e *AnalyticsExporter) hash(id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, e.Salt)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Start uploads buffered rows every Interval
+func (e *AnalyticsExporter) Start() {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.Flush(context.Background()); err != nil {
+					logger.Errorf("Analytics export failed: %v", err)
+				}
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background uploads and flushes what is left
+func (e *AnalyticsExporter) Stop() {
+	if e.stop != nil {
+		close(e.stop)
+		<-e.done
+		e.stop = nil
+	}
+	if err := e.Flush(context.Background()); err != nil {
+		logger.Errorf("Final analytics export failed: %v", err)
+	}
+}
+
+// Flush uploads all buffered rows as one part file per day and format, at
+// <prefix>/date=YYYY-MM-DD/part-<unix nanos>.<format>. Rows of a failed
+// upload are kept for the next flush of that format only, so a failed
+// Parquet upload doesn't upload the CSV again.
+func (e *AnalyticsExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	part := strconv.FormatInt(time.Now().UnixNano(), 10)
+	failed := make(map[string][]ExportRow)
+	var firstErr error
+	for format, rows := range pending {
+		byDate := make(map[string][]ExportRow)
+		for _, row := range rows {
+			byDate[row.Date] = append(byDate[row.Date], row)
+		}
+		for date, dayRows := range byDate {
+			if err := e.upload(ctx, fmt.Sprintf("%s/date=%s/part-%s", e.Prefix, date, part), format, dayRows); err != nil {
+				failed[format] = append(failed[format], dayRows...)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			logger.Infof("Exported %d analytics rows for %s as %s", len(dayRows), date, format)
+		}
+	}
+
+	if len(failed) > 0 {
+		e.mu.Lock()
+		if e.pending == nil {
+			e.pending = make(map[string][]ExportRow)
+		}
+		for format, rows := range failed {
+			e.pending[format] = append(rows, e.pending[format]...)
+			e.trimLocked(format)
+		}
+		e.mu.Unlock()
+	}
+	return firstErr
+}
+
+// trimLocked drops the oldest rows of format beyond MaxBuffered. e.mu must be held.
+func (e *AnalyticsExporter) trimLocked(format string) {
+	limit := e.MaxBuffered
+	if limit <= 0 {
+		limit = defaultExportBuffer
+	}
+	if over := len(e.pending[format]) - limit; over > 0 {
+		logger.Warnf("Analytics export buffer for %s full, dropping %d oldest rows", format, over)
+		e.pending[format] = append([]ExportRow(nil), e.pending[format][over:]...)
+	}
+}
+
+// upload writes rows in format
+func (e *AnalyticsExporter) upload(ctx context.Context, key, format string, rows []ExportRow) error {
+	var body []byte
+	var contentType string
+	var err error
+	switch format {
+	case ExportCSV:
+		body, err = encodeCSV(rows)
+		contentType = "text/csv"
+	case ExportParquet:
+		body, err = encodeParquet(rows)
+		contentType = "application/vnd.apache.parquet"
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	if err := e.Store.PutObject(ctx, key+"."+format, body, contentType); err != nil {
+		return fmt.Errorf("failed to upload %s.%s: %w", key, format, err)
+	}
+	return nil
+}
+
+// encodeCSV renders rows as CSV with a header line
+func encodeCSV(rows []ExportRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(exportColumns)
+	for _, r := range rows {
+		w.Write([]string{r.Timestamp, r.Date, r.Event, r.UserHash, r.ChatHash, r.SessionHash, r.Metrics})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeParquet renders rows as a Parquet file
+func encodeParquet(rows []ExportRow) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		return nil, fmt.Errorf("failed to encode Parquet export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
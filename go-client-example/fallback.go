@@ -0,0 +1,191 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// DegradedNotice is the annotation the bot adds to degraded-mode answers
+const DegradedNotice = "⚠️ _The assistant is running in degraded mode: tools and long-term memory are unavailable, so this answer may be less accurate._"
+
+// FallbackProvider answers chats with a plain LLM completion when the Agno
+// service is unreachable
+type FallbackProvider interface {
+	Complete(ctx context.Context, systemPrompt string, history []Message, message string) (string, *Usage, error)
+}
+
+var fallbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Name:      "fallback_total",
+	Help:      "Chats answered by the fallback provider because the Agno service was unavailable.",
+}, []string{"result"})
+
+// shouldFallback reports whether err means the Agno service is unreachable or
+// too slow (as opposed to rejecting the request or the caller giving up)
+func shouldFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrServiceUnavailable) || errors.Is(err, ErrModelTimeout) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// fallbackChat answers reqBody with the fallback provider after cause made
+// the Agno call fail; the response is marked Degraded
+func (c *AgnoClient) fallbackChat(ctx context.Context, reqBody ChatRequest, cause error) (_ *ChatResponse, err error) {
+	ctx, span := startSpan(ctx, "ChatFallback", attribute.String("agno.session_id", reqBody.SessionID))
+	defer func() { endSpan(span, err) }()
+
+	logger.Warnf("Agno service unavailable (%v), answering session %s in degraded mode", cause, reqBody.SessionID)
+
+	if err := c.guardRequest(&reqBody); err != nil {
+		return nil, err
+	}
+	answer, usage, err := c.Fallback.Complete(ctx, reqBody.SystemPrompt, reqBody.History, reqBody.Message)
+	if err != nil {
+		fallbackTotal.WithLabelValues("error").Inc()
+		logger.Errorf("Fallback provider failed: %v", err)
+		// Report the original outage; the fallback failing is secondary
+		return nil, cause
+	}
+	fallbackTotal.WithLabelValues("ok").Inc()
+	if c.Events != nil {
+		c.publish(ctx, AgentEvent{
+			Time:      time.Now().UTC(),
+			SessionID: reqBody.SessionID,
+			Tenant:    c.tenant(reqBody.SessionID),
+			Type:      AgentEventFallback,
+			Error:     cause.Error(),
+		})
+	}
+
+	if c.Usage != nil && usage != nil {
+		c.Usage.Add(c.tenant(reqBody.SessionID), reqBody.SessionID, *usage)
+	}
+	return &ChatResponse{
+		SessionID: reqBody.SessionID,
+		Response:  answer,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Usage:     usage,
+		Degraded:  true,
+	}, nil
+}
+
+// OpenAIFallback is a FallbackProvider for any OpenAI-compatible
+// /chat/completions endpoint. It sends no tools and only the most recent
+// MaxHistory messages.
+type OpenAIFallback struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	MaxHistory int
+	HTTPClient *http.Client
+}
+
+// NewOpenAIFallbackFromEnv configures the fallback from OPENAI_API_KEY,
+// AGNO_FALLBACK_BASE_URL (default https://api.openai.com/v1) and
+// AGNO_FALLBACK_MODEL (default gpt-4o-mini). It returns nil without an API key.
+func NewOpenAIFallbackFromEnv() *OpenAIFallback {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil
+	}
+	baseURL := os.Getenv("AGNO_FALLBACK_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	model := os.Getenv("AGNO_FALLBACK_MODEL")
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &OpenAIFallback{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		Model:      model,
+		MaxHistory: 6,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// openAIRequest is the body of POST /chat/completions
+type openAIRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+}
+
+// openAIResponse is the part of the /chat/completions response we use
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// Complete implements FallbackProvider
+func (f *OpenAIFallback) Complete(ctx context.Context, systemPrompt string, history []Message, message string) (string, *Usage, error) {
+	var messages []Message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	}
+	if f.MaxHistory > 0 && len(history) > f.MaxHistory {
+		history = history[len(history)-f.MaxHistory:]
+	}
+	for _, m := range history {
+		messages = append(messages, Message{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, Message{Role: "user", Content: message})
+
+	jsonData, err := json.Marshal(openAIRequest{Model: f.Model, Messages: messages})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal completion request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.APIKey)
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to send completion request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read completion response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("completion endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var completion openAIResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal completion response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", nil, errors.New("completion response has no choices")
+	}
+	if completion.Usage != nil {
+		completion.Usage.Model = completion.Model
+	}
+	return completion.Choices[0].Message.Content, completion.Usage, nil
+}
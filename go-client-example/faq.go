@@ -0,0 +1,332 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"start-feishubot/logger"
+)
+
+// faqPrompt asks the agent to turn a cluster of similar exchanges into one FAQ entry
+const faqPrompt = `The following questions were asked by different users and the answers were rated helpful.
+Write one canonical FAQ entry covering them. Reply with JSON only: {"question": "...", "answer": "..."}
+
+%s`
+
+// faqSessionID is the Agno session used for drafting FAQ entries
+const faqSessionID = "faq-miner"
+
+// RatedExchange is a question/answer pair that received positive feedback
+type RatedExchange struct {
+	SessionID string
+	Question  string
+	Answer    string
+	RatedAt   time.Time
+}
+
+// FAQCandidate is a proposed FAQ entry awaiting review
+type FAQCandidate struct {
+	RecordID    string // review board record, set once proposed
+	Question    string
+	Answer      string
+	Occurrences int
+	Examples    []string
+}
+
+// FAQSource supplies positively rated exchanges (e.g. from the feedback store)
+type FAQSource interface {
+	PositiveExchanges(ctx context.Context, since time.Time) ([]RatedExchange, error)
+}
+
+// FAQReviewBoard is where candidates are reviewed (e.g. a Lark Bitable)
+type FAQReviewBoard interface {
+	Propose(ctx context.Context, candidate FAQCandidate) (recordID string, err error)
+	Approved(ctx context.Context) ([]FAQCandidate, error)
+	MarkPublished(ctx context.Context, recordID string) error
+}
+
+// KnowledgePublisher pushes approved FAQ entries into the agent's knowledge base
+type KnowledgePublisher interface {
+	PublishFAQ(ctx context.Context, faq FAQCandidate) error
+}
+
+// FAQMiner periodically mines rated conversations for recurring questions,
+// proposes them for review and publishes approved entries
+type FAQMiner struct {
+	Client         AgnoService
+	Source         FAQSource
+	Board          FAQReviewBoard
+	Publisher      KnowledgePublisher
+	Interval       time.Duration
+	Lookback       time.Duration
+	MinOccurrences int
+	Similarity     float64 // Jaccard similarity needed to group two questions
+
+	// ProposedTTL is how long a proposed question isn't proposed again
+	// (default 90 days)
+	ProposedTTL time.Duration
+
+	mu       sync.Mutex
+	proposed map[string]time.Time // question key -> when it was proposed
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewFAQMiner creates a miner that runs daily over the last 7 days of feedback
+func NewFAQMiner(client AgnoService, source FAQSource, board FAQReviewBoard, publisher KnowledgePublisher) *FAQMiner {
+	return &FAQMiner{
+		Client:         client,
+		Source:         source,
+		Board:          board,
+		Publisher:      publisher,
+		Interval:       24 * time.Hour,
+		Lookback:       7 * 24 * time.Hour,
+		MinOccurrences: 3,
+		Similarity:     0.6,
+		ProposedTTL:    90 * 24 * time.Hour,
+		proposed:       make(map[string]time.Time),
+	}
+}
+
+// Start runs the miner in the background until Stop is called
+func (m *FAQMiner) Start() {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	stop, done := m.stop, m.done
+	m.mu.Unlock()
+
+	logger.Infof("FAQ miner started (interval %s)", m.Interval)
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.RunOnce(context.Background()); err != nil {
+					logger.Errorf("FAQ mining run failed: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the miner and waits for the current run to finish
+func (m *FAQMiner) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	logger.Info("FAQ miner stopped")
+}
+
+// RunOnce publishes approved entries, then proposes new candidates
+func (m *FAQMiner) RunOnce(ctx context.Context) error {
+	if err := m.publishApproved(ctx); err != nil {
+		return err
+	}
+
+	exchanges, err := m.Source.PositiveExchanges(ctx, time.Now().Add(-m.Lookback))
+	if err != nil {
+		return fmt.Errorf("failed to load rated exchanges: %w", err)
+	}
+
+	m.forgetProposed()
+	for _, cluster := range clusterExchanges(exchanges, m.Similarity) {
+		if len(cluster) < m.MinOccurrences {
+			continue
+		}
+
+		key := strings.Join(questionTokens(cluster[0].Question), " ")
+		m.mu.Lock()
+		_, seen := m.proposed[key]
+		m.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		candidate, err := m.draftCandidate(ctx, cluster)
+		if err != nil {
+			logger.Warnf("Failed to draft FAQ entry for %q: %v", cluster[0].Question, err)
+			continue
+		}
+
+		recordID, err := m.Board.Propose(ctx, candidate)
+		if err != nil {
+			return fmt.Errorf("failed to propose FAQ entry: %w", err)
+		}
+		logger.Infof("Proposed FAQ entry %s: %s (%d occurrences)", recordID, candidate.Question, candidate.Occurrences)
+
+		m.mu.Lock()
+		m.proposed[key] = time.Now()
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// forgetProposed drops the questions proposed more than ProposedTTL ago
+func (m *FAQMiner) forgetProposed() {
+	ttl := m.ProposedTTL
+	if ttl <= 0 {
+		ttl = 90 * 24 * time.Hour
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, at := range m.proposed {
+		if time.Since(at) > ttl {
+			delete(m.proposed, key)
+		}
+	}
+}
+
+// publishApproved pushes reviewed entries into the knowledge base
+func (m *FAQMiner) publishApproved(ctx context.Context) error {
+	approved, err := m.Board.Approved(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load approved FAQ entries: %w", err)
+	}
+
+	for _, faq := range approved {
+		if err := m.Publisher.PublishFAQ(ctx, faq); err != nil {
+			logger.Errorf("Failed to publish FAQ entry %s: %v", faq.RecordID, err)
+			continue
+		}
+		if err := m.Board.MarkPublished(ctx, faq.RecordID); err != nil {
+			logger.Errorf("Failed to mark FAQ entry %s as published: %v", faq.RecordID, err)
+			continue
+		}
+		logger.Infof("Published FAQ entry %s: %s", faq.RecordID, faq.Question)
+	}
+	return nil
+}
+
+// draftCandidate asks the agent to merge a cluster into a canonical entry
+func (m *FAQMiner) draftCandidate(ctx context.Context, cluster []RatedExchange) (FAQCandidate, error) {
+	var b strings.Builder
+	examples := make([]string, 0, len(cluster))
+	for i, ex := range cluster {
+		fmt.Fprintf(&b, "Q%d: %s\nA%d: %s\n\n", i+1, ex.Question, i+1, ex.Answer)
+		examples = append(examples, ex.Question)
+	}
+
+	reply, err := m.Client.ChatContext(ctx, faqSessionID, fmt.Sprintf(faqPrompt, b.String()), nil)
+	if err != nil {
+		return FAQCandidate{}, err
+	}
+	// Every draft is independent; don't let earlier clusters leak into the next one
+	if err := m.Client.ClearSessionContext(ctx, faqSessionID); err != nil {
+		logger.Warnf("Failed to clear FAQ miner session: %v", err)
+	}
+
+	var entry struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(reply)), &entry); err != nil {
+		return FAQCandidate{}, fmt.Errorf("failed to parse FAQ draft: %w", err)
+	}
+
+	return FAQCandidate{
+		Question:    entry.Question,
+		Answer:      entry.Answer,
+		Occurrences: len(cluster),
+		Examples:    examples,
+	}, nil
+}
+
+// clusterExchanges greedily groups exchanges whose questions are similar,
+// largest clusters first
+func clusterExchanges(exchanges []RatedExchange, threshold float64) [][]RatedExchange {
+	var clusters [][]RatedExchange
+	var heads [][]string
+
+	for _, ex := range exchanges {
+		tokens := questionTokens(ex.Question)
+		if len(tokens) == 0 {
+			continue
+		}
+		placed := false
+		for i, head := range heads {
+			if jaccard(head, tokens) >= threshold {
+				clusters[i] = append(clusters[i], ex)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			clusters = append(clusters, []RatedExchange{ex})
+			heads = append(heads, tokens)
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i]) > len(clusters[j]) })
+	return clusters
+}
+
+// questionTokens returns the sorted set of lowercase words in a question
+func questionTokens(question string) []string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	tokens := make([]string, 0, len(set))
+	for w := range set {
+		tokens = append(tokens, w)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// jaccard computes the Jaccard similarity of two sorted token sets
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	i, j, common := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// extractJSON trims any prose or code fences around a JSON object in a model reply
+func extractJSON(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
@@ -0,0 +1,308 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// Ratings accepted by SubmitFeedback
+const (
+	FeedbackUp   = 1
+	FeedbackDown = -1
+)
+
+// Card action values used by the feedback buttons
+const (
+	feedbackActionKey     = "feedback_action"
+	feedbackMessageKey    = "feedback_message_id"
+	feedbackActionUp      = "up"
+	feedbackActionDown    = "down"
+	feedbackActionComment = "comment"
+
+	feedbackCommentField = "feedback_comment"
+	feedbackKeyPrefix    = "feedback:"
+)
+
+var feedbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "feedback",
+	Name:      "submitted_total",
+	Help:      "Answer ratings submitted from the feedback buttons, by rating.",
+}, []string{"rating"})
+
+// feedbackRequest is the body of POST /feedback
+type feedbackRequest struct {
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	Rating    int    `json:"rating"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// SubmitFeedback rates the answer messageID of a session: FeedbackUp or
+// FeedbackDown, with an optional comment
+func (c *AgnoClient) SubmitFeedback(sessionID, messageID string, rating int, comment string) error {
+	return c.SubmitFeedbackContext(context.Background(), sessionID, messageID, rating, comment)
+}
+
+// SubmitFeedbackContext is like SubmitFeedback but carries ctx for cancellation and tracing
+func (c *AgnoClient) SubmitFeedbackContext(ctx context.Context, sessionID, messageID string, rating int, comment string) (err error) {
+	ctx, span := startSpan(ctx, "SubmitFeedback",
+		attribute.String("agno.session_id", sessionID),
+		attribute.Int("agno.rating", rating))
+	defer func() { endSpan(span, err) }()
+
+	if messageID == "" {
+		return fmt.Errorf("%w: feedback needs the answer's message ID", ErrInvalidRequest)
+	}
+	if rating != FeedbackUp && rating != FeedbackDown {
+		return fmt.Errorf("%w: rating %d is neither %d nor %d", ErrInvalidRequest, rating, FeedbackUp, FeedbackDown)
+	}
+
+	jsonData, err := json.Marshal(feedbackRequest{
+		SessionID: sessionID,
+		MessageID: messageID,
+		Rating:    rating,
+		Comment:   strings.TrimSpace(comment),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/feedback", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "feedback", sessionID)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusNoContent {
+		logger.Errorf("Submit feedback failed (status %d): %s", statusCode, string(body))
+		return fmt.Errorf("submit feedback failed: %w", newAPIError(statusCode, body))
+	}
+	logger.Infof("Feedback %+d recorded for message %s of session %s", rating, messageID, sessionID)
+	return nil
+}
+
+// FeedbackSubmitter sends ratings to the Agno service (implemented by AgnoClient)
+type FeedbackSubmitter interface {
+	SubmitFeedbackContext(ctx context.Context, sessionID, messageID string, rating int, comment string) error
+}
+
+// FeedbackTarget is the answer behind a bot reply, stored under the reply's
+// Lark message ID so the buttons on it can be resolved
+type FeedbackTarget struct {
+	SessionID string    `json:"session_id"`
+	MessageID string    `json:"message_id"` // the agent's message ID (ChatResponse.MessageID)
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Set once the answer is rated
+	Rating  int       `json:"rating,omitempty"`
+	RatedBy string    `json:"rated_by,omitempty"`
+	RatedAt time.Time `json:"rated_at,omitempty"`
+}
+
+// FeedbackTracker implements the 👍/👎 buttons on bot replies. Ratings are
+// kept with their answers, so the tracker also serves as the FAQSource of an
+// FAQMiner.
+type FeedbackTracker struct {
+	Client FeedbackSubmitter
+	Store  SessionStore
+	TTL    time.Duration // how long replies can be rated
+}
+
+var _ FAQSource = (*FeedbackTracker)(nil)
+
+// NewFeedbackTracker creates a FeedbackTracker whose replies can be rated for 30 days
+func NewFeedbackTracker(client FeedbackSubmitter, store SessionStore) *FeedbackTracker {
+	return &FeedbackTracker{
+		Client: client,
+		Store:  store,
+		TTL:    30 * 24 * time.Hour,
+	}
+}
+
+// Remember maps the Lark message ID of a bot reply to the answer it shows
+func (t *FeedbackTracker) Remember(ctx context.Context, larkMsgID string, target FeedbackTarget) error {
+	if target.MessageID == "" {
+		return fmt.Errorf("%w: reply %s has no agent message ID", ErrInvalidRequest, larkMsgID)
+	}
+	if target.CreatedAt.IsZero() {
+		target.CreatedAt = time.Now()
+	}
+	return t.save(ctx, larkMsgID, target)
+}
+
+// save stores target for the remainder of its TTL
+func (t *FeedbackTracker) save(ctx context.Context, larkMsgID string, target FeedbackTarget) error {
+	value, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feedback target: %w", err)
+	}
+	ttl := t.TTL - time.Since(target.CreatedAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := t.Store.Set(ctx, feedbackKeyPrefix+larkMsgID, value, ttl); err != nil {
+		return fmt.Errorf("failed to store feedback target: %w", err)
+	}
+	return nil
+}
+
+// lookup returns the answer behind a bot reply
+func (t *FeedbackTracker) lookup(ctx context.Context, larkMsgID string) (FeedbackTarget, error) {
+	var target FeedbackTarget
+	value, err := t.Store.Get(ctx, feedbackKeyPrefix+larkMsgID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return target, errors.New("this answer can no longer be rated")
+	}
+	if err != nil {
+		return target, err
+	}
+	if err := json.Unmarshal(value, &target); err != nil {
+		return target, fmt.Errorf("failed to unmarshal feedback target: %w", err)
+	}
+	return target, nil
+}
+
+// FeedbackButtons returns the 👍/👎 action row to add to an answer card
+func FeedbackButtons(larkMsgID string) map[string]interface{} {
+	return actionModule(
+		callbackButton("👍", "default", map[string]interface{}{
+			feedbackActionKey:  feedbackActionUp,
+			feedbackMessageKey: larkMsgID,
+		}),
+		callbackButton("👎", "default", map[string]interface{}{
+			feedbackActionKey:  feedbackActionDown,
+			feedbackMessageKey: larkMsgID,
+		}),
+	)
+}
+
+// buildFeedbackCommentCard asks what was wrong with an answer rated 👎; the
+// comment is optional
+func buildFeedbackCommentCard(larkMsgID string, target FeedbackTarget) map[string]interface{} {
+	submit := callbackButton("Send feedback", "primary", map[string]interface{}{
+		feedbackActionKey:  feedbackActionComment,
+		feedbackMessageKey: larkMsgID,
+	})
+	submit["action_type"] = "form_submit"
+	submit["name"] = "feedback_submit"
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"elements": []interface{}{
+			markdownElement(target.Answer),
+			map[string]interface{}{"tag": "hr"},
+			map[string]interface{}{"tag": "form", "name": "feedback_form", "elements": []interface{}{
+				map[string]interface{}{
+					"tag":         "input",
+					"name":        feedbackCommentField,
+					"placeholder": plainText("What was wrong with this answer? (optional)"),
+				},
+				submit,
+			}},
+		},
+	}
+}
+
+// buildRatedAnswerCard shows the answer again with the rating acknowledged
+// in place of the buttons
+func buildRatedAnswerCard(target FeedbackTarget) map[string]interface{} {
+	note := "👍 Thanks, glad this helped."
+	if target.Rating == FeedbackDown {
+		note = "👎 Thanks, your feedback helps us improve these answers."
+	}
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"elements": []interface{}{
+			markdownElement(target.Answer),
+			map[string]interface{}{"tag": "hr"},
+			map[string]interface{}{"tag": "note", "elements": []interface{}{plainText(note)}},
+		},
+	}
+}
+
+// HandleFeedbackAction processes feedback card callbacks. 👍 is submitted
+// right away; 👎 first asks for an optional comment. It returns the card to
+// show in place of the reply and false if the action does not belong to
+// the feedback buttons.
+func (t *FeedbackTracker) HandleFeedbackAction(ctx context.Context, action CardAction, operatorID string) (map[string]interface{}, bool, error) {
+	larkMsgID := action.StringValue(feedbackMessageKey)
+	switch action.StringValue(feedbackActionKey) {
+	case feedbackActionUp:
+		card, err := t.rate(ctx, larkMsgID, operatorID, FeedbackUp, "")
+		return card, true, err
+	case feedbackActionDown:
+		target, err := t.lookup(ctx, larkMsgID)
+		if err != nil {
+			return nil, true, err
+		}
+		return buildFeedbackCommentCard(larkMsgID, target), true, nil
+	case feedbackActionComment:
+		comment, _ := action.FormValue[feedbackCommentField].(string)
+		card, err := t.rate(ctx, larkMsgID, operatorID, FeedbackDown, comment)
+		return card, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// rate submits a rating for the reply and keeps it with the answer
+func (t *FeedbackTracker) rate(ctx context.Context, larkMsgID, operatorID string, rating int, comment string) (map[string]interface{}, error) {
+	target, err := t.lookup(ctx, larkMsgID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Client.SubmitFeedbackContext(ctx, target.SessionID, target.MessageID, rating, comment); err != nil {
+		return nil, fmt.Errorf("failed to submit feedback: %w", err)
+	}
+	feedbackTotal.WithLabelValues(fmt.Sprintf("%+d", rating)).Inc()
+
+	target.Rating, target.RatedBy, target.RatedAt = rating, operatorID, time.Now()
+	if err := t.save(ctx, larkMsgID, target); err != nil {
+		// The service has the rating; only the FAQ source misses it
+		logger.Warnf("Failed to keep rating of %s: %v", larkMsgID, err)
+	}
+	return buildRatedAnswerCard(target), nil
+}
+
+// PositiveExchanges implements FAQSource with the answers rated 👍 since since
+func (t *FeedbackTracker) PositiveExchanges(ctx context.Context, since time.Time) ([]RatedExchange, error) {
+	keys, err := t.Store.Keys(ctx, feedbackKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feedback: %w", err)
+	}
+	var exchanges []RatedExchange
+	for _, key := range keys {
+		target, err := t.lookup(ctx, strings.TrimPrefix(key, feedbackKeyPrefix))
+		if err != nil {
+			continue // expired since listing, or unreadable
+		}
+		if target.Rating != FeedbackUp || target.RatedAt.Before(since) {
+			continue
+		}
+		exchanges = append(exchanges, RatedExchange{
+			SessionID: target.SessionID,
+			Question:  target.Question,
+			Answer:    target.Answer,
+			RatedAt:   target.RatedAt,
+		})
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,181 @@
+package agno
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// ErrPromptInjection is returned when a guard in GuardBlock mode rejects a request
+var ErrPromptInjection = errors.New("agno: prompt injection detected")
+
+// Sources of screened text, used as a metrics label
+const (
+	GuardSourceUser     = "user"
+	GuardSourceHistory  = "history"
+	GuardSourceDocument = "document"
+)
+
+// GuardMode is what a guard does with detected injections
+type GuardMode string
+
+const (
+	// GuardFlag logs and counts detections but leaves the text untouched
+	GuardFlag GuardMode = "flag"
+	// GuardStrip removes the offending phrases
+	GuardStrip GuardMode = "strip"
+	// GuardBlock rejects the request with ErrPromptInjection
+	GuardBlock GuardMode = "block"
+)
+
+// InjectionFinding is a single detected injection attempt
+type InjectionFinding struct {
+	Pattern string
+	Match   string
+}
+
+// InjectionGuard screens text before it is assembled into the agent context
+type InjectionGuard interface {
+	Screen(source, text string) (cleaned string, findings []InjectionFinding, err error)
+}
+
+// InjectionPattern is a named regular expression for a known injection phrase
+type InjectionPattern struct {
+	Name  string
+	Regex *regexp.Regexp
+}
+
+// DefaultInjectionPatterns cover the most common instruction-override attempts
+var DefaultInjectionPatterns = []InjectionPattern{
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget)\b.{0,20}\b(previous|prior|above|all|earlier)\b.{0,20}\b(instructions?|prompts?|rules?|directions?)`)},
+	{"new_instructions", regexp.MustCompile(`(?i)\b(new|updated|real)\s+(system\s+)?instructions?\s*:`)},
+	{"role_override", regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|the|in)\b`)},
+	{"system_prompt_leak", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output)\b.{0,30}\b(system\s+prompt|hidden\s+instructions?|initial\s+prompt)`)},
+	{"fake_role_tag", regexp.MustCompile(`(?i)(<\|?(system|im_start|assistant)\|?>|\[/?(system|INST)\])`)},
+	{"developer_mode", regexp.MustCompile(`(?i)\b(developer|jailbreak|DAN)\s+mode\b`)},
+}
+
+var injectionDetections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "guard",
+	Name:      "injection_detections_total",
+	Help:      "Prompt injection patterns detected in screened text.",
+}, []string{"source", "pattern", "mode"})
+
+// PatternGuard is a regex-based InjectionGuard
+type PatternGuard struct {
+	Mode     GuardMode
+	Patterns []InjectionPattern
+}
+
+// NewPatternGuard creates a guard using DefaultInjectionPatterns
+func NewPatternGuard(mode GuardMode) *PatternGuard {
+	return &PatternGuard{Mode: mode, Patterns: DefaultInjectionPatterns}
+}
+
+// Screen implements InjectionGuard
+func (g *PatternGuard) Screen(source, text string) (string, []InjectionFinding, error) {
+	var findings []InjectionFinding
+	for _, p := range g.Patterns {
+		for _, match := range p.Regex.FindAllString(text, -1) {
+			findings = append(findings, InjectionFinding{Pattern: p.Name, Match: match})
+			injectionDetections.WithLabelValues(source, p.Name, string(g.Mode)).Inc()
+		}
+		if g.Mode == GuardStrip {
+			text = p.Regex.ReplaceAllString(text, "[removed]")
+		}
+	}
+
+	if len(findings) == 0 {
+		return text, nil, nil
+	}
+
+	logger.Warnf("Prompt injection detected in %s text (%d finding(s), mode %s): %q",
+		source, len(findings), g.Mode, findings[0].Match)
+	if g.Mode == GuardBlock {
+		return text, findings, fmt.Errorf("%w: %s", ErrPromptInjection, findings[0].Pattern)
+	}
+	return text, findings, nil
+}
+
+// ScreenDocuments screens retrieved or uploaded documents before they are
+// added to the agent context
+func ScreenDocuments(guard InjectionGuard, docs []string) ([]string, error) {
+	cleaned := make([]string, len(docs))
+	for i, doc := range docs {
+		text, _, err := guard.Screen(GuardSourceDocument, doc)
+		if err != nil {
+			return nil, err
+		}
+		cleaned[i] = text
+	}
+	return cleaned, nil
+}
+
+// guardRequest screens the message, text parts and history of a chat
+// request in place
+func (c *AgnoClient) guardRequest(req *ChatRequest) error {
+	if c.Guard == nil {
+		return nil
+	}
+
+	text, _, err := c.Guard.Screen(GuardSourceUser, req.Message)
+	if err != nil {
+		return err
+	}
+	if req.Parts, err = c.screenParts(GuardSourceUser, req.Parts, req.Message, text); err != nil {
+		return err
+	}
+	req.Message = text
+
+	if len(req.History) == 0 {
+		return nil
+	}
+	history := make([]Message, len(req.History))
+	for i, msg := range req.History {
+		if msg.Role != "user" {
+			history[i] = msg
+			continue
+		}
+		text, _, err := c.Guard.Screen(GuardSourceHistory, msg.Content)
+		if err != nil {
+			return err
+		}
+		if msg.Parts, err = c.screenParts(GuardSourceHistory, msg.Parts, msg.Content, text); err != nil {
+			return err
+		}
+		msg.Content = text
+		history[i] = msg
+	}
+	req.History = history
+	return nil
+}
+
+// screenParts returns a copy of parts with every text part screened. Parts
+// repeating the message (as ChatWithImages sends it) take its screened text.
+func (c *AgnoClient) screenParts(source string, parts []ContentPart, message, screened string) ([]ContentPart, error) {
+	if len(parts) == 0 {
+		return parts, nil
+	}
+	out := make([]ContentPart, len(parts))
+	for i, part := range parts {
+		if part.Type == PartText {
+			if part.Text == message {
+				part.Text = screened
+			} else {
+				text, _, err := c.Guard.Screen(source, part.Text)
+				if err != nil {
+					return nil, err
+				}
+				part.Text = text
+			}
+		}
+		out[i] = part
+	}
+	return out, nil
+}
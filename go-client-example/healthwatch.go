@@ -0,0 +1,311 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Backend health states reported by HealthWatcher
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthChange describes a transition of the Agno backend's health
+type HealthChange struct {
+	From     string
+	To       string
+	Err      error     // last check error when To is HealthUnhealthy
+	Since    time.Time // when the previous state began
+	Failures int       // consecutive failed checks
+}
+
+// HealthWatcher checks the Agno backend in the background and serves
+// /livez and /readyz. Checks run every Interval while healthy and back off
+// exponentially up to MaxBackoff while failing. After FailureThreshold
+// consecutive failures the backend is unhealthy, Heal is called to recover
+// (by default idle connections are dropped) and change callbacks fire.
+type HealthWatcher struct {
+	Check            func(ctx context.Context) error
+	Heal             func()
+	Interval         time.Duration
+	MaxBackoff       time.Duration
+	Timeout          time.Duration // per check
+	FailureThreshold int
+
+	mu        sync.RWMutex
+	state     string
+	since     time.Time
+	failures  int
+	lastErr   error
+	lastCheck time.Time
+	running   bool
+	onChange  []func(HealthChange)
+	checks    map[string]func(ctx context.Context) error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHealthWatcher creates a watcher checking client every 15s, backing off
+// up to 2 minutes and turning unhealthy after 3 failed checks
+func NewHealthWatcher(client *AgnoClient) *HealthWatcher {
+	return &HealthWatcher{
+		Check: client.CheckConnectionContext,
+		Heal: func() {
+			if client.HTTPClient != nil {
+				client.HTTPClient.CloseIdleConnections()
+			}
+		},
+		Interval:         15 * time.Second,
+		MaxBackoff:       2 * time.Minute,
+		Timeout:          5 * time.Second,
+		FailureThreshold: 3,
+		state:            HealthUnknown,
+		since:            time.Now(),
+		checks:           make(map[string]func(ctx context.Context) error),
+	}
+}
+
+// OnChange registers fn to be called on every backend state change, e.g.
+// to alert an ops chat (see HealthAlert)
+func (w *HealthWatcher) OnChange(fn func(HealthChange)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// AddReadinessCheck adds a bot dependency (e.g. Redis) that must pass for /readyz
+func (w *HealthWatcher) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.checks[name] = check
+}
+
+// State returns the backend state and the error of the last failed check
+func (w *HealthWatcher) State() (string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.state, w.lastErr
+}
+
+// CheckNow runs a backend check immediately and returns its result
+func (w *HealthWatcher) CheckNow(ctx context.Context) error {
+	err := w.runCheck(ctx, w.Check)
+	w.observe(err)
+	return err
+}
+
+// Start checks the backend in the background, starting immediately
+func (w *HealthWatcher) Start() {
+	w.mu.Lock()
+	w.running = true
+	w.mu.Unlock()
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		for {
+			delay := w.Interval
+			if err := w.CheckNow(context.Background()); err != nil {
+				delay = w.backoff()
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-w.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background checks
+func (w *HealthWatcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+
+	w.mu.Lock()
+	w.running = false
+	w.mu.Unlock()
+}
+
+// observe records a check result and fires callbacks on state changes
+func (w *HealthWatcher) observe(err error) {
+	w.mu.Lock()
+	w.lastCheck = time.Now()
+	previous, since := w.state, w.since
+	if err == nil {
+		w.failures, w.lastErr = 0, nil
+		if w.state != HealthHealthy {
+			w.state, w.since = HealthHealthy, time.Now()
+		}
+	} else {
+		w.failures++
+		w.lastErr = err
+		if w.failures >= w.FailureThreshold && w.state != HealthUnhealthy {
+			w.state, w.since = HealthUnhealthy, time.Now()
+		}
+	}
+	change := HealthChange{From: previous, To: w.state, Err: err, Since: since, Failures: w.failures}
+	callbacks := make([]func(HealthChange), len(w.onChange))
+	copy(callbacks, w.onChange)
+	w.mu.Unlock()
+
+	if change.From == change.To {
+		return
+	}
+	if change.To == HealthUnhealthy {
+		logger.Errorf("Agno backend is unhealthy after %d failed checks: %v", change.Failures, err)
+		if w.Heal != nil {
+			w.Heal()
+		}
+	} else {
+		logger.Infof("Agno backend is %s (was %s)", change.To, change.From)
+	}
+	for _, fn := range callbacks {
+		fn(change)
+	}
+}
+
+// backoff returns the delay after the current run of failed checks
+func (w *HealthWatcher) backoff() time.Duration {
+	w.mu.RLock()
+	failures := w.failures
+	w.mu.RUnlock()
+
+	delay := w.Interval
+	for i := 1; i < failures && delay < w.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.MaxBackoff {
+		delay = w.MaxBackoff
+	}
+	return delay
+}
+
+// healthReport is the body served by /livez and /readyz
+type healthReport struct {
+	Status    string            `json:"status"`
+	Agno      string            `json:"agno"`
+	Error     string            `json:"error,omitempty"`
+	Checks    map[string]string `json:"checks,omitempty"`
+	LastCheck string            `json:"last_check,omitempty"`
+	Build     BuildInfo         `json:"build"`
+}
+
+// LivezHandler reports whether the bot process is alive: it fails only when
+// the watcher loop itself has stalled, never because the backend is down,
+// so an Agno outage doesn't make the orchestrator restart every replica
+func (w *HealthWatcher) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.RLock()
+		state, lastCheck, running := w.state, w.lastCheck, w.running
+		w.mu.RUnlock()
+
+		report := healthReport{Status: "ok", Agno: state, Build: GetBuildInfo()}
+		if !lastCheck.IsZero() {
+			report.LastCheck = lastCheck.UTC().Format(time.RFC3339)
+		}
+		stalledAfter := 3*w.MaxBackoff + w.Timeout
+		if running && !lastCheck.IsZero() && time.Since(lastCheck) > stalledAfter {
+			report.Status = "stalled"
+			writeJSON(rw, http.StatusServiceUnavailable, report)
+			return
+		}
+		writeJSON(rw, http.StatusOK, report)
+	})
+}
+
+// ReadyzHandler reports whether the bot can serve traffic: the backend must
+// be healthy and every readiness check must pass
+func (w *HealthWatcher) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		state, lastErr := w.State()
+		report := healthReport{Status: "ok", Agno: state, Build: GetBuildInfo()}
+		if lastErr != nil {
+			report.Error = lastErr.Error()
+		}
+		ready := state == HealthHealthy
+
+		w.mu.RLock()
+		names := make([]string, 0, len(w.checks))
+		for name := range w.checks {
+			names = append(names, name)
+		}
+		w.mu.RUnlock()
+		sort.Strings(names)
+
+		if len(names) > 0 {
+			report.Checks = make(map[string]string, len(names))
+		}
+		for _, name := range names {
+			w.mu.RLock()
+			check := w.checks[name]
+			w.mu.RUnlock()
+
+			if err := w.runCheck(r.Context(), check); err != nil {
+				report.Checks[name] = err.Error()
+				ready = false
+				continue
+			}
+			report.Checks[name] = "ok"
+		}
+
+		if !ready {
+			report.Status = "not ready"
+			writeJSON(rw, http.StatusServiceUnavailable, report)
+			return
+		}
+		writeJSON(rw, http.StatusOK, report)
+	})
+}
+
+// runCheck runs a readiness check bounded by Timeout
+func (w *HealthWatcher) runCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	return check(ctx)
+}
+
+// HealthAlert returns an OnChange callback posting backend state changes
+// into a Lark ops chat through sender (e.g. an Outbox, so alerts survive
+// the restarts they may be about)
+func HealthAlert(sender MessageSender, chatID string) func(HealthChange) {
+	return func(change HealthChange) {
+		var text string
+		switch change.To {
+		case HealthUnhealthy:
+			text = fmt.Sprintf("🔴 Agno backend is DOWN after %d failed checks: %v", change.Failures, change.Err)
+		case HealthHealthy:
+			if change.From == HealthUnknown {
+				return // startup, not a recovery
+			}
+			text = fmt.Sprintf("🟢 Agno backend recovered after %s", time.Since(change.Since).Round(time.Second))
+		default:
+			return
+		}
+		content, _ := json.Marshal(map[string]string{"text": text})
+		msg := OutboxMessage{ReceiveID: chatID, ReceiveIDType: "chat_id", MsgType: "text", Content: string(content)}
+		if _, err := sender.SendMessage(context.Background(), msg); err != nil {
+			logger.Errorf("Failed to post health alert to %s: %v", chatID, err)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// HeatEventMessage is the analytics event the bot records for every message
+// it receives; HeatTracker counts it. Properties "department" and "intent"
+// are used when present.
+const HeatEventMessage = "message"
+
+// heatHour is the layout of hourly heat bucket keys
+const heatHour = "2006010215"
+
+// Heat grouping dimensions and bucket sizes
+const (
+	HeatByChat       = "chat"
+	HeatByDepartment = "department"
+	HeatBucketHour   = "hour"
+	HeatBucketDay    = "day"
+)
+
+// heatCell counts the messages of one chat in one hour
+type heatCell struct {
+	Department string         `json:"department,omitempty"`
+	Messages   int            `json:"messages"`
+	Intents    map[string]int `json:"intents,omitempty"`
+}
+
+// heatHourData is one replica's cells of one hour, by chat
+type heatHourData struct {
+	chats   map[string]*heatCell
+	loaded  bool
+	changed bool
+}
+
+// HeatTracker aggregates message volume per chat and department per hour,
+// with intent counts, for the usage dashboard. Like UsageAccumulator it
+// keeps per-replica aggregates in a SessionStore (heat:<hour>:<instance>)
+// that Heat sums across replicas.
+type HeatTracker struct {
+	Store        SessionStore
+	Next         AnalyticsRecorder          // receives every event after counting (optional)
+	DepartmentOf func(userID string) string // fallback when events carry no department
+	Instance     string                     // replica name, defaults to the hostname
+	Interval     time.Duration              // how often aggregates are flushed
+	Retention    time.Duration              // how long hourly aggregates are kept
+
+	mu    sync.Mutex
+	hours map[string]*heatHourData
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewHeatTracker creates a tracker flushing to store every minute and
+// keeping 90 days of data
+func NewHeatTracker(store SessionStore) *HeatTracker {
+	instance, err := os.Hostname()
+	if err != nil || instance == "" {
+		instance = "local"
+	}
+	return &HeatTracker{
+		Store:     store,
+		Instance:  instance,
+		Interval:  time.Minute,
+		Retention: 90 * 24 * time.Hour,
+		hours:     make(map[string]*heatHourData),
+	}
+}
+
+// Record implements AnalyticsRecorder, counting HeatEventMessage events
+func (h *HeatTracker) Record(ctx context.Context, event AnalyticsEvent) error {
+	if event.Name == HeatEventMessage && event.ChatID != "" {
+		department, _ := event.Properties["department"].(string)
+		if department == "" && h.DepartmentOf != nil && event.UserID != "" {
+			department = h.DepartmentOf(event.UserID)
+		}
+		intent, _ := event.Properties["intent"].(string)
+		at := event.Timestamp
+		if at.IsZero() {
+			at = time.Now()
+		}
+		h.Observe(event.ChatID, department, intent, at)
+	}
+	if h.Next != nil {
+		return h.Next.Record(ctx, event)
+	}
+	return nil
+}
+
+// Observe counts one message in a chat
+func (h *HeatTracker) Observe(chatID, department, intent string, at time.Time) {
+	hour := at.UTC().Format(heatHour)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data, ok := h.hours[hour]
+	if !ok {
+		data = &heatHourData{chats: make(map[string]*heatCell)}
+		h.hours[hour] = data
+	}
+	cell, ok := data.chats[chatID]
+	if !ok {
+		cell = &heatCell{Intents: make(map[string]int)}
+		data.chats[chatID] = cell
+	}
+	if department != "" {
+		cell.Department = department
+	}
+	cell.Messages++
+	if intent != "" {
+		cell.Intents[intent]++
+	}
+	data.changed = true
+}
+
+// Start flushes aggregates every Interval
+func (h *HeatTracker) Start() {
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := h.Flush(context.Background()); err != nil {
+					logger.Errorf("Failed to flush heat data: %v", err)
+				}
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background flushes and flushes what is left
+func (h *HeatTracker) Stop() {
+	if h.stop != nil {
+		close(h.stop)
+		<-h.done
+		h.stop = nil
+	}
+	if err := h.Flush(context.Background()); err != nil {
+		logger.Errorf("Failed to flush heat data: %v", err)
+	}
+}
+
+// Flush writes changed hourly aggregates to the store
+func (h *HeatTracker) Flush(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := time.Now().UTC().Format(heatHour)
+	var firstErr error
+	for hour, data := range h.hours {
+		if !data.changed {
+			if hour != current {
+				delete(h.hours, hour)
+			}
+			continue
+		}
+		if err := h.write(ctx, hour, data); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		data.changed = false
+	}
+	return firstErr
+}
+
+// write merges an hour with what this replica stored before and saves it;
+// callers must hold h.mu
+func (h *HeatTracker) write(ctx context.Context, hour string, data *heatHourData) error {
+	key := heatKey(hour, h.Instance)
+	if !data.loaded {
+		stored, err := h.Store.Get(ctx, key)
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			var previous map[string]*heatCell
+			if err := json.Unmarshal(stored, &previous); err != nil {
+				return fmt.Errorf("failed to unmarshal heat data %s: %w", key, err)
+			}
+			for chatID, cell := range previous {
+				mergeHeatCell(data.chats, chatID, cell)
+			}
+		}
+		data.loaded = true
+	}
+
+	encoded, err := json.Marshal(data.chats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal heat data: %w", err)
+	}
+	return h.Store.Set(ctx, key, encoded, h.Retention)
+}
+
+// mergeHeatCell adds cell to the chat's cell in cells
+func mergeHeatCell(cells map[string]*heatCell, key string, cell *heatCell) {
+	merged, ok := cells[key]
+	if !ok {
+		merged = &heatCell{Intents: make(map[string]int)}
+		cells[key] = merged
+	}
+	if merged.Department == "" {
+		merged.Department = cell.Department
+	}
+	merged.Messages += cell.Messages
+	for intent, n := range cell.Intents {
+		merged.Intents[intent] += n
+	}
+}
+
+// heatKey is the store key of one replica's hourly aggregate; with an
+// empty instance it is the prefix of all replicas' keys for that hour
+func heatKey(hour, instance string) string {
+	return fmt.Sprintf("heat:%s:%s", hour, instance)
+}
+
+// HeatQuery selects the data returned by Heat
+type HeatQuery struct {
+	From       time.Time
+	To         time.Time
+	Bucket     string // HeatBucketHour or HeatBucketDay
+	GroupBy    string // HeatByChat or HeatByDepartment
+	TopIntents int    // intents reported per series
+}
+
+// IntentCount is the number of messages with one intent
+type IntentCount struct {
+	Intent   string `json:"intent"`
+	Messages int    `json:"messages"`
+}
+
+// HeatSeries is the volume of one chat or department in one bucket
+type HeatSeries struct {
+	Key        string        `json:"key"`
+	Messages   int           `json:"messages"`
+	TopIntents []IntentCount `json:"top_intents,omitempty"`
+}
+
+// HeatBucket is one time bucket of a heat report
+type HeatBucket struct {
+	Start  time.Time    `json:"start"`
+	Series []HeatSeries `json:"series"`
+}
+
+// HeatReport is time-bucketed message volume with top intents
+type HeatReport struct {
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Bucket     string        `json:"bucket"`
+	GroupBy    string        `json:"group_by"`
+	Buckets    []HeatBucket  `json:"buckets"`
+	TopIntents []IntentCount `json:"top_intents,omitempty"` // over the whole range
+}
+
+// Heat returns message volume per chat or department in hourly or daily
+// buckets, including aggregates not yet flushed by this replica
+func (h *HeatTracker) Heat(ctx context.Context, q HeatQuery) (*HeatReport, error) {
+	if err := h.Flush(ctx); err != nil {
+		logger.Warnf("Heat report may miss recent messages: %v", err)
+	}
+	if q.Bucket == "" {
+		q.Bucket = HeatBucketHour
+	}
+	if q.GroupBy == "" {
+		q.GroupBy = HeatByChat
+	}
+	if q.Bucket != HeatBucketHour && q.Bucket != HeatBucketDay {
+		return nil, fmt.Errorf("invalid bucket %q", q.Bucket)
+	}
+	if q.GroupBy != HeatByChat && q.GroupBy != HeatByDepartment {
+		return nil, fmt.Errorf("invalid grouping %q", q.GroupBy)
+	}
+
+	report := &HeatReport{From: q.From.UTC(), To: q.To.UTC(), Bucket: q.Bucket, GroupBy: q.GroupBy}
+	total := make(map[string]int)
+	var bucket *HeatBucket
+	var cells map[string]*heatCell
+
+	closeBucket := func() {
+		if bucket != nil && len(cells) > 0 {
+			bucket.Series = heatSeries(cells, q.TopIntents)
+			report.Buckets = append(report.Buckets, *bucket)
+		}
+	}
+
+	for hour := report.From.Truncate(time.Hour); !hour.After(report.To); hour = hour.Add(time.Hour) {
+		start := hour
+		if q.Bucket == HeatBucketDay {
+			start = time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		if bucket == nil || !bucket.Start.Equal(start) {
+			closeBucket()
+			bucket = &HeatBucket{Start: start}
+			cells = make(map[string]*heatCell)
+		}
+
+		chats, err := h.readHour(ctx, hour.Format(heatHour))
+		if err != nil {
+			return nil, err
+		}
+		for chatID, cell := range chats {
+			key := chatID
+			if q.GroupBy == HeatByDepartment {
+				key = cell.Department
+				if key == "" {
+					key = "unknown"
+				}
+			}
+			mergeHeatCell(cells, key, cell)
+			for intent, n := range cell.Intents {
+				total[intent] += n
+			}
+		}
+	}
+	closeBucket()
+
+	report.TopIntents = topIntents(total, q.TopIntents)
+	return report, nil
+}
+
+// readHour sums all replicas' cells of one hour
+func (h *HeatTracker) readHour(ctx context.Context, hour string) (map[string]*heatCell, error) {
+	keys, err := h.Store.Keys(ctx, heatKey(hour, ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list heat keys: %w", err)
+	}
+	chats := make(map[string]*heatCell)
+	for _, key := range keys {
+		data, err := h.Store.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read heat data: %w", err)
+		}
+		var stored map[string]*heatCell
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal heat data %s: %w", key, err)
+		}
+		for chatID, cell := range stored {
+			mergeHeatCell(chats, chatID, cell)
+		}
+	}
+	return chats, nil
+}
+
+// heatSeries turns cells into series sorted by volume
+func heatSeries(cells map[string]*heatCell, top int) []HeatSeries {
+	series := make([]HeatSeries, 0, len(cells))
+	for key, cell := range cells {
+		series = append(series, HeatSeries{Key: key, Messages: cell.Messages, TopIntents: topIntents(cell.Intents, top)})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Messages != series[j].Messages {
+			return series[i].Messages > series[j].Messages
+		}
+		return series[i].Key < series[j].Key
+	})
+	return series
+}
+
+// topIntents returns the n most frequent intents
+func topIntents(counts map[string]int, n int) []IntentCount {
+	if n <= 0 || len(counts) == 0 {
+		return nil
+	}
+	intents := make([]IntentCount, 0, len(counts))
+	for intent, messages := range counts {
+		intents = append(intents, IntentCount{Intent: intent, Messages: messages})
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		if intents[i].Messages != intents[j].Messages {
+			return intents[i].Messages > intents[j].Messages
+		}
+		return intents[i].Intent < intents[j].Intent
+	})
+	if len(intents) > n {
+		intents = intents[:n]
+	}
+	return intents
+}
+
+// Handler serves the dashboard API: GET ?from=&to= (RFC 3339, default the
+// last 24 hours), bucket=hour|day, group=chat|department and top=N intents
+// (default 5). Requests must be signed with secret (see VerifyRequest).
+// It returns ErrMissingSecret when secret is empty.
+func (h *HeatTracker) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("HeatTracker.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected heat dashboard request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		params := r.URL.Query()
+		q := HeatQuery{
+			To:         time.Now(),
+			Bucket:     params.Get("bucket"),
+			GroupBy:    params.Get("group"),
+			TopIntents: 5,
+		}
+		q.From = q.To.Add(-24 * time.Hour)
+		for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+			if value := params.Get(name); value != "" {
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s: %v", name, err), http.StatusBadRequest)
+					return
+				}
+				*dst = t
+			}
+		}
+		if value := params.Get("top"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid top", http.StatusBadRequest)
+				return
+			}
+			q.TopIntents = n
+		}
+		if q.To.Sub(q.From) > h.Retention || q.To.Before(q.From) {
+			http.Error(w, "invalid time range", http.StatusBadRequest)
+			return
+		}
+
+		report, err := h.Heat(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}), nil
+}
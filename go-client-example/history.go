@@ -0,0 +1,219 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// Session export formats
+const (
+	ExportJSON     = "json"
+	ExportMarkdown = "markdown"
+)
+
+// Session import modes
+const (
+	ImportAppend  = "append"
+	ImportReplace = "replace"
+)
+
+// historyPageSize is the number of messages requested per page
+const historyPageSize = 100
+
+// historyPage is the body returned by GET /sessions/{id}/messages
+type historyPage struct {
+	SessionID  string    `json:"session_id"`
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// GetHistory returns up to limit messages of a session in chronological
+// order, following pagination as needed (limit <= 0 returns all of them)
+func (c *AgnoClient) GetHistory(sessionID string, limit int) ([]Message, error) {
+	return c.GetHistoryContext(context.Background(), sessionID, limit)
+}
+
+// GetHistoryContext is like GetHistory but carries ctx for cancellation and tracing
+func (c *AgnoClient) GetHistoryContext(ctx context.Context, sessionID string, limit int) (_ []Message, err error) {
+	ctx, span := startSpan(ctx, "GetHistory", attribute.String("agno.session_id", sessionID))
+	defer func() { endSpan(span, err) }()
+
+	var messages []Message
+	cursor := ""
+	for {
+		pageSize := historyPageSize
+		if limit > 0 && limit-len(messages) < pageSize {
+			pageSize = limit - len(messages)
+		}
+
+		page, err := c.historyPage(ctx, sessionID, cursor, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, page.Messages...)
+
+		if page.NextCursor == "" || len(page.Messages) == 0 || (limit > 0 && len(messages) >= limit) {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// historyPage fetches one page of session history
+func (c *AgnoClient) historyPage(ctx context.Context, sessionID, cursor string, pageSize int) (*historyPage, error) {
+	query := url.Values{"limit": {fmt.Sprint(pageSize)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	endpoint := fmt.Sprintf("%s/sessions/%s/messages?%s", c.BaseURL, url.PathEscape(sessionID), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	statusCode, body, err := c.doRequest(req, "session-messages", sessionID)
+	if err != nil {
+		logger.Errorf("Failed to fetch Agno session history: %v", err)
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		logger.Errorf("Get history failed (status %d): %s", statusCode, string(body))
+		return nil, newAPIError(statusCode, body)
+	}
+
+	var page historyPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal history page: %w", err)
+	}
+	return &page, nil
+}
+
+// importRequest is the body of POST /sessions/{id}/messages
+type importRequest struct {
+	Mode     string    `json:"mode"`
+	Messages []Message `json:"messages"`
+}
+
+// ImportSession writes messages into a session's history, either appending
+// them (ImportAppend) or replacing the history (ImportReplace). Timestamps
+// and metadata are preserved.
+func (c *AgnoClient) ImportSession(sessionID string, messages []Message, mode string) error {
+	return c.ImportSessionContext(context.Background(), sessionID, messages, mode)
+}
+
+// ImportSessionContext is like ImportSession but carries ctx for cancellation and tracing
+func (c *AgnoClient) ImportSessionContext(ctx context.Context, sessionID string, messages []Message, mode string) (err error) {
+	ctx, span := startSpan(ctx, "ImportSession",
+		attribute.String("agno.session_id", sessionID),
+		attribute.Int("agno.messages", len(messages)))
+	defer func() { endSpan(span, err) }()
+
+	if mode != ImportAppend && mode != ImportReplace {
+		return fmt.Errorf("%w: unknown import mode %q", ErrInvalidRequest, mode)
+	}
+	if mode == ImportReplace && c.Holds != nil {
+		if err := c.Holds.CheckSession(ctx, "system", sessionID); err != nil {
+			return err
+		}
+	}
+	jsonData, err := json.Marshal(importRequest{Mode: mode, Messages: messages})
+	if err != nil {
+		return fmt.Errorf("failed to marshal import request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/sessions/%s/messages", c.BaseURL, url.PathEscape(sessionID))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "session-import", sessionID)
+	if err != nil {
+		logger.Errorf("Failed to import Agno session history: %v", err)
+		return err
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
+		logger.Errorf("Import history failed (status %d): %s", statusCode, string(body))
+		return newAPIError(statusCode, body)
+	}
+
+	logger.Infof("Imported %d message(s) into session %s (%s)", len(messages), sessionID, mode)
+	return nil
+}
+
+// ExportSession renders the full transcript of a session as ExportJSON or
+// ExportMarkdown, e.g. for pasting into a Lark doc or an audit
+func (c *AgnoClient) ExportSession(sessionID, format string) ([]byte, error) {
+	return c.ExportSessionContext(context.Background(), sessionID, format)
+}
+
+// ExportSessionContext is like ExportSession but carries ctx for cancellation and tracing
+func (c *AgnoClient) ExportSessionContext(ctx context.Context, sessionID, format string) ([]byte, error) {
+	if format != ExportJSON && format != ExportMarkdown {
+		return nil, fmt.Errorf("%w: unknown export format %q", ErrInvalidRequest, format)
+	}
+
+	messages, err := c.GetHistoryContext(ctx, sessionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return FormatTranscript(sessionID, messages, format)
+}
+
+// FormatTranscript renders messages as ExportJSON or ExportMarkdown
+func FormatTranscript(sessionID string, messages []Message, format string) ([]byte, error) {
+	switch format {
+	case ExportJSON:
+		data, err := json.MarshalIndent(historyPage{SessionID: sessionID, Messages: messages}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal transcript: %w", err)
+		}
+		return data, nil
+	case ExportMarkdown:
+		var b strings.Builder
+		fmt.Fprintf(&b, "# Conversation %s\n", sessionID)
+		for _, m := range messages {
+			b.WriteString("\n### ")
+			b.WriteString(roleTitle(m.Role))
+			if m.Timestamp != "" {
+				fmt.Fprintf(&b, " · %s", m.Timestamp)
+			}
+			b.WriteString("\n\n")
+			b.WriteString(strings.TrimSpace(m.Content))
+			b.WriteString("\n")
+		}
+		return []byte(b.String()), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown export format %q", ErrInvalidRequest, format)
+	}
+}
+
+// roleTitle returns a heading for a message role
+func roleTitle(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	default:
+		return role
+	}
+}
@@ -0,0 +1,169 @@
+package agno
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	historyCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "history_cache",
+		Name:      "requests_total",
+		Help:      "Session history lookups by result (hit, miss).",
+	}, []string{"result"})
+
+	historyCacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "history_cache",
+		Name:      "evictions_total",
+		Help:      "Session histories evicted from the cache by reason (entries, bytes, expired).",
+	}, []string{"reason"})
+
+	historyCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "history_cache",
+		Name:      "entries",
+		Help:      "Session histories held in the cache.",
+	})
+
+	historyCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "history_cache",
+		Name:      "bytes",
+		Help:      "Encoded size of the session histories held in the cache.",
+	})
+)
+
+// historyCacheEntry is an element of HistoryCache's recency list
+type historyCacheEntry struct {
+	sessionID string
+	state     sessionHistory
+	size      int
+	expires   time.Time
+}
+
+// HistoryCache keeps the hot session histories of a HistoryManager in
+// memory, so active sessions skip the store on every message. It holds at
+// most MaxEntries histories and MaxBytes of encoded history, evicting the
+// least recently used. Writes go to the store first (write-through), so
+// an evicted history is simply read back. Entries expire after TTL, which
+// bounds how stale a history can be when other replicas write the session.
+type HistoryCache struct {
+	MaxEntries int
+	MaxBytes   int
+	TTL        time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+	bytes int
+}
+
+// NewHistoryCache creates a cache of up to 1000 histories and 64MB,
+// keeping each for 10 minutes
+func NewHistoryCache() *HistoryCache {
+	return &HistoryCache{
+		MaxEntries: 1000,
+		MaxBytes:   64 << 20,
+		TTL:        10 * time.Minute,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// NewHistoryCacheFromEnv bounds the cache with AGNO_HISTORY_CACHE_ENTRIES
+// (default 1000) and AGNO_HISTORY_CACHE_MB (default 64)
+func NewHistoryCacheFromEnv() *HistoryCache {
+	c := NewHistoryCache()
+	c.MaxEntries = envInt("AGNO_HISTORY_CACHE_ENTRIES", c.MaxEntries)
+	c.MaxBytes = envInt("AGNO_HISTORY_CACHE_MB", c.MaxBytes>>20) << 20
+	c.TTL = envDuration("AGNO_HISTORY_CACHE_TTL", c.TTL)
+	return c
+}
+
+// get returns a copy of a session's cached history
+func (c *HistoryCache) get(sessionID string) (*sessionHistory, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[sessionID]
+	if !ok {
+		historyCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	entry := el.Value.(*historyCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.removeLocked(el, "expired")
+		historyCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	historyCacheRequests.WithLabelValues("hit").Inc()
+	state := entry.state
+	state.Messages = append([]Message(nil), entry.state.Messages...)
+	return &state, true
+}
+
+// put caches a copy of a session's history; size is its encoded length
+func (c *HistoryCache) put(sessionID string, state *sessionHistory, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[sessionID]; ok {
+		c.removeLocked(el, "")
+	}
+	if c.MaxBytes > 0 && size > c.MaxBytes {
+		return // would evict everything else
+	}
+	entry := &historyCacheEntry{
+		sessionID: sessionID,
+		state:     *state,
+		size:      size,
+		expires:   time.Now().Add(c.TTL),
+	}
+	entry.state.Messages = append([]Message(nil), state.Messages...)
+	c.items[sessionID] = c.order.PushFront(entry)
+	c.bytes += size
+	historyCacheEntries.Inc()
+	historyCacheBytes.Add(float64(size))
+
+	for c.MaxEntries > 0 && c.order.Len() > c.MaxEntries {
+		c.removeLocked(c.order.Back(), "entries")
+	}
+	for c.MaxBytes > 0 && c.bytes > c.MaxBytes {
+		c.removeLocked(c.order.Back(), "bytes")
+	}
+}
+
+// remove drops a session's history from the cache
+func (c *HistoryCache) remove(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[sessionID]; ok {
+		c.removeLocked(el, "")
+	}
+}
+
+// Len returns how many histories are cached
+func (c *HistoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// removeLocked removes an element with c.mu held, counting an eviction
+// when reason is set
+func (c *HistoryCache) removeLocked(el *list.Element, reason string) {
+	entry := el.Value.(*historyCacheEntry)
+	c.order.Remove(el)
+	delete(c.items, entry.sessionID)
+	c.bytes -= entry.size
+	historyCacheEntries.Dec()
+	historyCacheBytes.Sub(float64(entry.size))
+	if reason != "" {
+		historyCacheEvictions.WithLabelValues(reason).Inc()
+	}
+}
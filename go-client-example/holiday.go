@@ -0,0 +1,343 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// DefaultCalendar is the calendar name used for tenants without their own calendar
+const DefaultCalendar = "default"
+
+// Holiday is a day off, or with Working set a make-up working day (e.g. a
+// Saturday worked to bridge a holiday)
+type Holiday struct {
+	Date    string `json:"date"` // YYYY-MM-DD in the calendar's time zone
+	Name    string `json:"name,omitempty"`
+	Working bool   `json:"working,omitempty"`
+}
+
+// CalendarConfig configures a tenant's holiday calendar
+type CalendarConfig struct {
+	TimeZone string    `json:"time_zone,omitempty"` // IANA name, default UTC
+	Weekend  []string  `json:"weekend,omitempty"`   // e.g. ["sat", "sun"] (the default)
+	Holidays []Holiday `json:"holidays,omitempty"`
+}
+
+// HolidayCalendar decides which days are business days. Reminders, digests
+// and SLA timers use it to skip weekends and holidays; overrides set at
+// runtime take precedence over the configured holidays.
+type HolidayCalendar struct {
+	Location *time.Location
+	Weekend  map[time.Weekday]bool
+
+	mu        sync.RWMutex
+	holidays  map[string]Holiday
+	overrides map[string]Holiday
+}
+
+// NewHolidayCalendar builds a calendar from its configuration
+func NewHolidayCalendar(cfg CalendarConfig) (*HolidayCalendar, error) {
+	loc := time.UTC
+	if cfg.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid calendar time zone: %w", err)
+		}
+	}
+	c := &HolidayCalendar{
+		Location:  loc,
+		Weekend:   map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		holidays:  make(map[string]Holiday),
+		overrides: make(map[string]Holiday),
+	}
+	if len(cfg.Weekend) > 0 {
+		c.Weekend = make(map[time.Weekday]bool)
+		for _, day := range cfg.Weekend {
+			wd, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekend day %q", day)
+			}
+			c.Weekend[wd] = true
+		}
+	}
+	for _, h := range cfg.Holidays {
+		if err := validHoliday(h); err != nil {
+			return nil, err
+		}
+		c.holidays[h.Date] = h
+	}
+	return c, nil
+}
+
+// validHoliday checks the date format of a holiday
+func validHoliday(h Holiday) error {
+	if _, err := time.Parse("2006-01-02", h.Date); err != nil {
+		return fmt.Errorf("%w: invalid holiday date %q", ErrInvalidRequest, h.Date)
+	}
+	return nil
+}
+
+// Override sets a runtime override for a day
+func (c *HolidayCalendar) Override(h Holiday) error {
+	if err := validHoliday(h); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.overrides[h.Date] = h
+	return nil
+}
+
+// RemoveOverride drops the runtime override of a day
+func (c *HolidayCalendar) RemoveOverride(date string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.overrides, date)
+}
+
+// Holidays returns the effective holidays and working days, sorted by date
+func (c *HolidayCalendar) Holidays() []Holiday {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	days := make(map[string]Holiday, len(c.holidays)+len(c.overrides))
+	for date, h := range c.holidays {
+		days[date] = h
+	}
+	for date, h := range c.overrides {
+		days[date] = h
+	}
+	list := make([]Holiday, 0, len(days))
+	for _, h := range days {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	return list
+}
+
+// workingDay reports whether t's day is worked: a holiday or make-up day
+// decides, otherwise fallback does
+func (c *HolidayCalendar) workingDay(t time.Time, fallback bool) bool {
+	date := t.In(c.Location).Format("2006-01-02")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if h, ok := c.overrides[date]; ok {
+		return h.Working
+	}
+	if h, ok := c.holidays[date]; ok {
+		return h.Working
+	}
+	return fallback
+}
+
+// IsBusinessDay reports whether t falls on a business day; a nil calendar
+// treats every day as one
+func (c *HolidayCalendar) IsBusinessDay(t time.Time) bool {
+	if c == nil {
+		return true
+	}
+	return c.workingDay(t, !c.Weekend[t.In(c.Location).Weekday()])
+}
+
+// NextBusinessDay returns t if it falls on a business day, otherwise the
+// same time of day on the next one, e.g. to move a digest off a holiday
+func (c *HolidayCalendar) NextBusinessDay(t time.Time) time.Time {
+	if c == nil {
+		return t
+	}
+	t = t.In(c.Location)
+	for i := 0; i < 366 && !c.IsBusinessDay(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// HolidayCalendars holds the calendar of each tenant and persists runtime
+// overrides under "holiday:<tenant>:<date>" keys
+type HolidayCalendars struct {
+	Store SessionStore
+	Audit AuditLog
+
+	mu        sync.RWMutex
+	calendars map[string]*HolidayCalendar
+}
+
+// NewHolidayCalendars creates a registry whose default calendar has
+// Saturday/Sunday weekends and no holidays
+func NewHolidayCalendars(store SessionStore) *HolidayCalendars {
+	def, _ := NewHolidayCalendar(CalendarConfig{})
+	return &HolidayCalendars{
+		Store:     store,
+		Audit:     LoggerAuditLog{},
+		calendars: map[string]*HolidayCalendar{DefaultCalendar: def},
+	}
+}
+
+// Configure sets a tenant's calendar (DefaultCalendar for the fallback) and
+// applies its stored overrides
+func (r *HolidayCalendars) Configure(ctx context.Context, tenant string, cfg CalendarConfig) error {
+	calendar, err := NewHolidayCalendar(cfg)
+	if err != nil {
+		return fmt.Errorf("calendar of tenant %s: %w", tenant, err)
+	}
+	prefix := "holiday:" + tenant + ":"
+	keys, err := r.Store.Keys(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list holiday overrides: %w", err)
+	}
+	for _, key := range keys {
+		var h Holiday
+		if _, err := holidaySchema.loadRecord(ctx, r.Store, key, &h, 0); errors.Is(err, ErrKeyNotFound) {
+			continue
+		} else if err != nil || calendar.Override(h) != nil {
+			logger.Warnf("Skipping unreadable holiday override %s", key)
+		}
+	}
+
+	r.mu.Lock()
+	r.calendars[tenant] = calendar
+	r.mu.Unlock()
+	logger.Infof("Calendar of tenant %s has %d holiday(s) and override(s)", tenant, len(calendar.Holidays()))
+	return nil
+}
+
+// Calendar returns a tenant's calendar, or the default one
+func (r *HolidayCalendars) Calendar(tenant string) *HolidayCalendar {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if calendar, ok := r.calendars[tenant]; ok {
+		return calendar
+	}
+	return r.calendars[DefaultCalendar]
+}
+
+// Override persists and applies a holiday or make-up working day for a tenant
+func (r *HolidayCalendars) Override(ctx context.Context, actor, tenant string, h Holiday) error {
+	calendar := r.configured(tenant)
+	if calendar == nil {
+		return fmt.Errorf("%w: tenant %q has no calendar", ErrUnknownTenant, tenant)
+	}
+	if err := calendar.Override(h); err != nil {
+		return err
+	}
+	data, err := holidaySchema.Encode(h)
+	if err != nil {
+		return fmt.Errorf("failed to marshal holiday: %w", err)
+	}
+	if err := r.Store.Set(ctx, "holiday:"+tenant+":"+h.Date, data, 0); err != nil {
+		return fmt.Errorf("failed to save holiday override: %w", err)
+	}
+	r.record(ctx, actor, "calendar.override", tenant, map[string]string{"date": h.Date, "name": h.Name, "working": fmt.Sprint(h.Working)})
+	return nil
+}
+
+// RemoveOverride deletes a tenant's override of a day, restoring the
+// configured calendar for it
+func (r *HolidayCalendars) RemoveOverride(ctx context.Context, actor, tenant, date string) error {
+	calendar := r.configured(tenant)
+	if calendar == nil {
+		return fmt.Errorf("%w: tenant %q has no calendar", ErrUnknownTenant, tenant)
+	}
+	if err := r.Store.Delete(ctx, "holiday:"+tenant+":"+date); err != nil {
+		return fmt.Errorf("failed to delete holiday override: %w", err)
+	}
+	calendar.RemoveOverride(date)
+	r.record(ctx, actor, "calendar.remove_override", tenant, map[string]string{"date": date})
+	return nil
+}
+
+// configured returns a tenant's own calendar, or nil
+func (r *HolidayCalendars) configured(tenant string) *HolidayCalendar {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.calendars[tenant]
+}
+
+// Handler serves the calendar override API:
+//
+//	GET    /calendars/{tenant}                   list holidays and working days
+//	POST   /calendars/{tenant}/overrides         add an override ({"date","name","working"})
+//	DELETE /calendars/{tenant}/overrides/{date}  remove an override
+//
+// Requests must be signed with secret (see VerifyRequest) and name the
+// acting admin in the X-Agno-Actor header.
+// It returns ErrMissingSecret when secret is empty.
+func (r *HolidayCalendars) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("HolidayCalendars.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := VerifyRequest(req, secret); err != nil {
+			logger.Warnf("Rejected calendar admin request from %s: %v", req.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		actor := req.Header.Get("X-Agno-Actor")
+		if actor == "" {
+			http.Error(w, "X-Agno-Actor header is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx := req.Context()
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/calendars"), "/"), "/")
+		switch {
+		case len(parts) == 1 && parts[0] != "" && req.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, r.Calendar(parts[0]).Holidays())
+
+		case len(parts) == 2 && parts[1] == "overrides" && req.Method == http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
+			}
+			var h Holiday
+			if err := json.Unmarshal(body, &h); err != nil {
+				http.Error(w, "invalid holiday", http.StatusBadRequest)
+				return
+			}
+			err = r.Override(ctx, actor, parts[0], h)
+			if errors.Is(err, ErrUnknownTenant) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+
+		case len(parts) == 3 && parts[1] == "overrides" && req.Method == http.MethodDelete:
+			err := r.RemoveOverride(ctx, actor, parts[0], parts[2])
+			if errors.Is(err, ErrUnknownTenant) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}), nil
+}
+
+// record writes an audit event, logging (not failing on) audit errors
+func (r *HolidayCalendars) record(ctx context.Context, actor, action, tenant string, detail map[string]string) {
+	if r.Audit == nil {
+		return
+	}
+	event := AuditEvent{Time: time.Now().UTC(), Actor: actor, Action: action, Subject: "tenant:" + tenant, Detail: detail}
+	if err := r.Audit.Record(ctx, event); err != nil {
+		logger.Errorf("Failed to write audit event %s on tenant %s: %v", action, tenant, err)
+	}
+}
@@ -0,0 +1,168 @@
+package agno
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Content part types
+const (
+	PartText     = "text"
+	PartImageURL = "image_url"
+)
+
+// ErrUnsupportedImage is returned for images of an unsupported format or size
+var ErrUnsupportedImage = errors.New("agno: unsupported image")
+
+// supportedImageTypes are the image formats accepted by the vision models
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ImageURL references an image by URL or base64 data URL
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // low, high or auto
+}
+
+// ContentPart is one part of a multimodal message
+type ContentPart struct {
+	Type     string    `json:"type"` // PartText or PartImageURL
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// TextPart returns a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+// ImagePresigner uploads an image and returns a pre-signed URL the service
+// can fetch it from (implemented by the bot's S3/OSS/GCS client)
+type ImagePresigner interface {
+	PresignImage(ctx context.Context, key string, body []byte, contentType string) (string, error)
+}
+
+// ImageLoader turns Lark images (e.g. pasted screenshots) into content
+// parts. Images up to InlineLimit are inlined as base64 data URLs; larger
+// ones are uploaded through Presigner when it is set.
+type ImageLoader struct {
+	Downloader  ResourceDownloader
+	Presigner   ImagePresigner // optional
+	MaxBytes    int
+	InlineLimit int
+	Detail      string
+}
+
+// NewImageLoader creates a loader accepting images up to 10MB and inlining
+// those up to 1MB
+func NewImageLoader(downloader ResourceDownloader) *ImageLoader {
+	return &ImageLoader{
+		Downloader:  downloader,
+		MaxBytes:    10 << 20,
+		InlineLimit: 1 << 20,
+		Detail:      "auto",
+	}
+}
+
+// Load downloads an image of a Lark message and converts it to a content part
+func (l *ImageLoader) Load(ctx context.Context, messageID, imageKey string) (ContentPart, error) {
+	body, err := l.Downloader.DownloadResource(ctx, messageID, imageKey, "image")
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to download image %s: %w", imageKey, err)
+	}
+	defer body.Close()
+	return l.Part(ctx, imageKey, body)
+}
+
+// Part validates an image and converts it to a content part; key names the
+// upload when the image is pre-signed
+func (l *ImageLoader) Part(ctx context.Context, key string, image io.Reader) (ContentPart, error) {
+	data, err := io.ReadAll(io.LimitReader(image, int64(l.MaxBytes)+1))
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) == 0 {
+		return ContentPart{}, fmt.Errorf("%w: empty image", ErrUnsupportedImage)
+	}
+	if len(data) > l.MaxBytes {
+		return ContentPart{}, fmt.Errorf("%w: image exceeds %d bytes", ErrUnsupportedImage, l.MaxBytes)
+	}
+	contentType := http.DetectContentType(data)
+	if !supportedImageTypes[contentType] {
+		return ContentPart{}, fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+
+	if len(data) > l.InlineLimit && l.Presigner != nil {
+		url, err := l.Presigner.PresignImage(ctx, "images/"+key, data, contentType)
+		if err != nil {
+			return ContentPart{}, fmt.Errorf("failed to upload image %s: %w", key, err)
+		}
+		return ContentPart{Type: PartImageURL, ImageURL: &ImageURL{URL: url, Detail: l.Detail}}, nil
+	}
+	dataURL := "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return ContentPart{Type: PartImageURL, ImageURL: &ImageURL{URL: dataURL, Detail: l.Detail}}, nil
+}
+
+// LarkImageKeys returns the image keys in a Lark message's JSON content:
+// the image_key of an "image" message and every image embedded in a "post"
+// (each key once)
+func LarkImageKeys(content string) []string {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if key, ok := v["image_key"].(string); ok && key != "" && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	return keys
+}
+
+// ChatWithImages sends text together with images (see ImageLoader) so the
+// agent can describe or analyze them
+func (c *AgnoClient) ChatWithImages(ctx context.Context, sessionID, text string, images []ContentPart) (*ChatResponse, error) {
+	parts := make([]ContentPart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, TextPart(text))
+	}
+	parts = append(parts, images...)
+	return c.SendChat(ctx, ChatRequest{SessionID: sessionID, Message: text, Parts: parts})
+}
+
+// ChatWithLarkImages loads the images of a Lark message and sends them with text
+func (c *AgnoClient) ChatWithLarkImages(ctx context.Context, loader *ImageLoader, sessionID, messageID, content, text string) (*ChatResponse, error) {
+	var images []ContentPart
+	for _, key := range LarkImageKeys(content) {
+		part, err := loader.Load(ctx, messageID, key)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, part)
+	}
+	return c.ChatWithImages(ctx, sessionID, text, images)
+}
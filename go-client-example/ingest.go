@@ -0,0 +1,303 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+var ingestedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "ingest",
+	Name:      "events_total",
+	Help:      "Lark events consumed from the event bus, by outcome (replied, duplicate, ignored, invalid, failed).",
+}, []string{"result"})
+
+// QueueMessage is a message fetched from the event bus
+type QueueMessage struct {
+	Key   []byte
+	Value []byte
+
+	// Ack is opaque to the ingestor and handed back to Commit (e.g. the
+	// Kafka message or the NATS JetStream message)
+	Ack interface{}
+}
+
+// EventConsumer reads Lark events from Kafka or NATS (implemented by the
+// bot around its client, e.g. kafka-go's FetchMessage/CommitMessages or a
+// JetStream pull subscription's Fetch/Ack)
+type EventConsumer interface {
+	Fetch(ctx context.Context) (QueueMessage, error)
+	Commit(ctx context.Context, msg QueueMessage) error
+}
+
+// NATSProducer adapts a NATS connection to KafkaProducer, publishing values
+// to the topic as the subject
+type NATSProducer struct {
+	Conn NATSConn
+}
+
+// Produce implements KafkaProducer
+func (p NATSProducer) Produce(ctx context.Context, topic string, key, value []byte) error {
+	return p.Conn.Publish(topic, value)
+}
+
+// LarkMessageEvent is the part of an im.message.receive_v1 event the
+// ingestor uses
+type LarkMessageEvent struct {
+	Header struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+		AppID     string `json:"app_id"`
+	} `json:"header"`
+	Event struct {
+		Sender struct {
+			SenderID struct {
+				OpenID string `json:"open_id"`
+			} `json:"sender_id"`
+			SenderType string `json:"sender_type"`
+		} `json:"sender"`
+		Message struct {
+			MessageID   string `json:"message_id"`
+			ChatID      string `json:"chat_id"`
+			ChatType    string `json:"chat_type"`
+			ThreadID    string `json:"thread_id,omitempty"`
+			MessageType string `json:"message_type"`
+			Content     string `json:"content"`
+		} `json:"message"`
+	} `json:"event"`
+}
+
+// Text returns the text of a text message, or "" for other message types
+func (e *LarkMessageEvent) Text() string {
+	if e.Event.Message.MessageType != "text" {
+		return ""
+	}
+	var content struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal([]byte(e.Event.Message.Content), &content)
+	return content.Text
+}
+
+// DefaultSessionID resolves the session of a message: one per thread, per
+// group chat, or per user in direct messages
+func DefaultSessionID(e *LarkMessageEvent) string {
+	switch {
+	case e.Event.Message.ThreadID != "":
+		return "thread-" + e.Event.Message.ThreadID
+	case e.Event.Message.ChatType == "p2p":
+		return "user-" + e.Event.Sender.SenderID.OpenID
+	default:
+		return "chat-" + e.Event.Message.ChatID
+	}
+}
+
+// QueueIngestor consumes Lark events from an event bus instead of webhooks,
+// answers them with the Agno client and publishes the replies (as
+// OutboxMessage JSON) to ReplyTopic for the sender service. Delivery is at
+// least once: an event is committed only after its reply was published, so
+// a crash redelivers it. Replied event IDs are remembered in Done for
+// deduplication; unlike EventGate they are recorded after the reply, so a
+// redelivered event that was never answered is not dropped.
+type QueueIngestor struct {
+	Consumer   EventConsumer
+	Producer   KafkaProducer // or NATSProducer
+	ReplyTopic string
+	Client     AgnoService
+	Done       SessionStore
+	DoneTTL    time.Duration // how long replied event IDs are remembered
+
+	// SessionID resolves the session of an event (default DefaultSessionID)
+	SessionID func(e *LarkMessageEvent) string
+
+	Guard       *LoopGuard // optional
+	MaxAttempts int        // Agno attempts before replying with the error
+	Backoff     time.Duration
+
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// NewQueueIngestor creates an ingestor remembering replied events for 12h
+// and trying the Agno call 3 times
+func NewQueueIngestor(consumer EventConsumer, producer KafkaProducer, replyTopic string, client AgnoService, done SessionStore) *QueueIngestor {
+	return &QueueIngestor{
+		Consumer:    consumer,
+		Producer:    producer,
+		ReplyTopic:  replyTopic,
+		Client:      client,
+		Done:        done,
+		DoneTTL:     12 * time.Hour,
+		SessionID:   DefaultSessionID,
+		MaxAttempts: 3,
+		Backoff:     2 * time.Second,
+	}
+}
+
+// Start consumes events in the background until Stop
+func (q *QueueIngestor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.done = make(chan struct{})
+	go func() {
+		defer close(q.done)
+		q.Run(ctx)
+	}()
+}
+
+// Stop stops consuming; the event being handled is not committed and will
+// be redelivered
+func (q *QueueIngestor) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	<-q.done
+	q.cancel = nil
+}
+
+// Run consumes events until ctx is done
+func (q *QueueIngestor) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		msg, err := q.Consumer.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("Failed to fetch event from the event bus: %v", err)
+				q.sleep(ctx, q.Backoff)
+			}
+			continue
+		}
+		// Retry in place: moving on would let a later commit skip this event
+		for err = q.Handle(ctx, msg); err != nil && ctx.Err() == nil; err = q.Handle(ctx, msg) {
+			logger.Warnf("Failed to handle event, retrying: %v", err)
+			q.sleep(ctx, q.Backoff)
+		}
+		if err != nil {
+			return // not committed: the event is redelivered after a restart
+		}
+		if err := q.Consumer.Commit(ctx, msg); err != nil {
+			logger.Errorf("Failed to commit event: %v", err)
+		}
+	}
+}
+
+// Handle answers one event and publishes the reply. It returns an error
+// only when the event must not be committed (ctx canceled or the reply
+// could not be published).
+func (q *QueueIngestor) Handle(ctx context.Context, msg QueueMessage) error {
+	var event LarkMessageEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		ingestedEvents.WithLabelValues("invalid").Inc()
+		logger.Errorf("Dropping unreadable event from the event bus: %v", err)
+		return nil
+	}
+	eventID := event.Header.EventID
+	if eventID != "" {
+		if _, err := q.Done.Get(ctx, "replied:"+eventID); err == nil {
+			ingestedEvents.WithLabelValues("duplicate").Inc()
+			logger.Infof("Dropping duplicate Lark event %s", eventID)
+			return nil
+		}
+	}
+
+	text := event.Text()
+	if event.Header.EventType != "im.message.receive_v1" || text == "" {
+		ingestedEvents.WithLabelValues("ignored").Inc()
+		return nil
+	}
+	m := event.Event.Message
+	sender := event.Event.Sender
+	if q.Guard != nil {
+		if _, ok := q.Guard.Check(IncomingMessage{ChatID: m.ChatID, SenderID: sender.SenderID.OpenID, SenderType: sender.SenderType, Text: text}); !ok {
+			ingestedEvents.WithLabelValues("ignored").Inc()
+			return nil
+		}
+	}
+
+	result := "replied"
+	answer, err := q.answer(ctx, &event, text)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result = "failed"
+		logger.Errorf("Failed to answer event %s: %v", eventID, err)
+		answer = UserMessage(err)
+	}
+	if q.Guard != nil {
+		answer = q.Guard.Sign(m.ChatID, answer)
+	}
+
+	content, err := json.Marshal(map[string]string{"text": answer})
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply: %w", err)
+	}
+	reply, err := json.Marshal(OutboxMessage{
+		ID:            eventID,
+		ReceiveID:     m.ChatID,
+		ReceiveIDType: "chat_id",
+		ReplyTo:       m.MessageID,
+		MsgType:       "text",
+		Content:       string(content),
+		SessionID:     q.SessionID(&event),
+		Status:        OutboxPending,
+		CreatedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply: %w", err)
+	}
+	if err := q.Producer.Produce(ctx, q.ReplyTopic, []byte(m.ChatID), reply); err != nil {
+		return fmt.Errorf("failed to publish reply to event %s: %w", eventID, err)
+	}
+
+	if eventID != "" {
+		if err := q.Done.Set(ctx, "replied:"+eventID, []byte{1}, q.DoneTTL); err != nil {
+			logger.Warnf("Failed to record reply to event %s: %v", eventID, err)
+		}
+	}
+	ingestedEvents.WithLabelValues(result).Inc()
+	return nil
+}
+
+// answer asks the agent, retrying transient failures with backoff
+func (q *QueueIngestor) answer(ctx context.Context, event *LarkMessageEvent, text string) (string, error) {
+	m := event.Event.Message
+	ctx = WithAppID(WithRequester(ctx, event.Event.Sender.SenderID.OpenID, m.ChatID), event.Header.AppID)
+	req := ChatRequest{SessionID: q.SessionID(event), Message: text}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var resp *ChatResponse
+		if resp, err = q.Client.SendChat(ctx, req); err == nil {
+			return resp.Response, nil
+		}
+		transient := shouldFallback(ctx, err) || errors.Is(err, ErrRateLimited)
+		if !transient || attempt >= q.MaxAttempts {
+			return "", err
+		}
+		logger.Warnf("Agno call for event %s failed (attempt %d), retrying: %v", event.Header.EventID, attempt, err)
+		if !q.sleep(ctx, q.Backoff*time.Duration(attempt)) {
+			return "", ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d, returning false if ctx ends first
+func (q *QueueIngestor) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
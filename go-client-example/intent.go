@@ -0,0 +1,170 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// IntentUnknown is the label used when classification fails
+const IntentUnknown = "unknown"
+
+// IntentLabel is one class of traffic and the description shown to the classifier
+type IntentLabel struct {
+	Name        string
+	Description string
+}
+
+// DefaultIntents are the intent labels used unless configured otherwise
+var DefaultIntents = []IntentLabel{
+	{"coding", "programming help, code review, debugging, scripts, SQL"},
+	{"it_support", "VPN, accounts, passwords, laptops, software access"},
+	{"hr", "leave, payroll, benefits, policies, hiring, onboarding"},
+	{"knowledge", "questions about the company, products, processes or documents"},
+	{"writing", "drafting, rewriting, translating or summarizing text"},
+	{"chit_chat", "greetings, thanks, small talk, jokes"},
+	{"other", "anything else"},
+}
+
+// intentPrompt asks the model for a single label
+const intentPrompt = "Classify the user's message into exactly one of these intents:\n%s\n" +
+	"Reply with the intent name only, in lowercase, with no punctuation."
+
+// chitChatPattern matches messages that are obviously small talk, which
+// are labelled without a model call
+var chitChatPattern = regexp.MustCompile(`(?i)^\s*(hi|hello|hey|yo|thanks?|thank you|thx|ok(ay)?|good (morning|afternoon|evening|night)|bye|xin chào|cảm ơn|你好|谢谢)[\s!.?~]*$`)
+
+var intentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Name:      "intents_total",
+	Help:      "Chat messages by classified intent.",
+}, []string{"intent", "tenant"})
+
+// IntentClassifier labels messages with an intent using the cheap model, so
+// traffic can be broken down into coding help, HR questions, chit-chat, ...
+// Labels are counted in agno_intents_total and attached to analytics events
+// as the "intent" property.
+type IntentClassifier struct {
+	Client    AgnoService
+	Model     string // cheap model used for classification
+	Labels    []IntentLabel
+	Timeout   time.Duration
+	Analytics AnalyticsRecorder // receives the events passed to Chat (optional)
+}
+
+// NewIntentClassifierFromEnv classifies with AGNO_FAST_MODEL (default gpt-4o-mini)
+func NewIntentClassifierFromEnv(client AgnoService) *IntentClassifier {
+	model := os.Getenv("AGNO_FAST_MODEL")
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &IntentClassifier{
+		Client:  client,
+		Model:   model,
+		Labels:  DefaultIntents,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Classify returns the intent of message, or IntentUnknown with an error if
+// the model could not be asked
+func (c *IntentClassifier) Classify(ctx context.Context, sessionID, message string) (string, error) {
+	if chitChatPattern.MatchString(message) && c.known("chit_chat") {
+		return "chit_chat", nil
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var labels strings.Builder
+	for _, label := range c.Labels {
+		fmt.Fprintf(&labels, "- %s: %s\n", label.Name, label.Description)
+	}
+
+	// Classify in a throwaway session so the turn doesn't pollute the conversation
+	classifySession := sessionID + ":intent"
+	resp, err := c.Client.SendChat(ctx, ChatRequest{
+		SessionID:    classifySession,
+		Message:      message,
+		SystemPrompt: fmt.Sprintf(intentPrompt, labels.String()),
+		Model:        c.Model,
+	})
+	if err != nil {
+		return IntentUnknown, fmt.Errorf("failed to classify intent: %w", err)
+	}
+	if err := c.Client.ClearSessionContext(ctx, classifySession); err != nil {
+		logger.Warnf("Failed to clear intent session %s: %v", classifySession, err)
+	}
+
+	answer := strings.ToLower(strings.Trim(strings.TrimSpace(resp.Response), "`\"'."))
+	for _, label := range c.Labels {
+		if answer == label.Name || strings.HasPrefix(answer, label.Name) {
+			return label.Name, nil
+		}
+	}
+	logger.Debugf("Intent classifier answered %q, which is not a known label", answer)
+	if c.known("other") {
+		return "other", nil
+	}
+	return IntentUnknown, nil
+}
+
+// Chat answers req while classifying it in parallel, so classification adds
+// no latency. The intent is counted for tenant and, when event has a name
+// (e.g. HeatEventMessage), attached to it and recorded in Analytics.
+func (c *IntentClassifier) Chat(ctx context.Context, tenant string, req ChatRequest, event AnalyticsEvent) (*ChatResponse, string, error) {
+	intents := make(chan string, 1)
+	go func() {
+		// Keep classifying even if the answer fails; the traffic still counts
+		intent, err := c.Classify(context.WithoutCancel(ctx), req.SessionID, req.Message)
+		if err != nil {
+			logger.Warnf("Intent classification for session %s failed: %v", req.SessionID, err)
+		}
+		intents <- intent
+	}()
+
+	resp, err := c.Client.SendChat(ctx, req)
+	intent := <-intents
+	c.Observe(ctx, tenant, intent, event)
+	return resp, intent, err
+}
+
+// Observe counts an intent and records event with it
+func (c *IntentClassifier) Observe(ctx context.Context, tenant, intent string, event AnalyticsEvent) {
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	intentsTotal.WithLabelValues(intent, tenant).Inc()
+
+	if event.Name == "" {
+		return
+	}
+	properties := make(map[string]interface{}, len(event.Properties)+1)
+	for key, value := range event.Properties {
+		properties[key] = value
+	}
+	properties["intent"] = intent
+	event.Properties = properties
+	recordAnalytics(ctx, c.Analytics, event)
+}
+
+// known reports whether name is one of the configured labels
+func (c *IntentClassifier) known(name string) bool {
+	for _, label := range c.Labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package agno
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// defaultMaxTranscript is the number of recent messages a thread keeps for
+// its summary and archive
+const defaultMaxTranscript = 200
+
+const defaultThreadSummaryPrompt = "This conversation is being closed due to inactivity. " +
+	"Summarize the discussion in a few bullet points, including any decisions and open follow-ups."
+
+// ThreadKey identifies a bot thread inside a Lark group chat
+type ThreadKey struct {
+	ChatID   string
+	ThreadID string
+}
+
+// ThreadPoster posts messages into a Lark thread (implemented by the bot)
+type ThreadPoster interface {
+	PostToThread(key ThreadKey, text string) error
+}
+
+// ThreadArchiver stores the transcript of a closed thread
+type ThreadArchiver interface {
+	ArchiveThread(key ThreadKey, sessionID string, transcript []Message, summary string) error
+}
+
+// threadState tracks the activity of a single bot thread
+type threadState struct {
+	sessionID    string
+	lastActivity time.Time
+	transcript   []Message
+	closingText  string // set once the thread is closed; only the announcement is left
+}
+
+// ThreadJanitor closes stale bot threads: it posts a closing summary,
+// archives the transcript and clears the Agno session
+type ThreadJanitor struct {
+	Client        AgnoService
+	Poster        ThreadPoster
+	Archiver      ThreadArchiver
+	StaleAfter    time.Duration
+	Interval      time.Duration
+	SummaryPrompt string
+	MaxTranscript int // messages kept per thread, oldest dropped first
+
+	mu      sync.Mutex
+	threads map[ThreadKey]*threadState
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewThreadJanitor creates a janitor with a 24h staleness window checked every 10 minutes
+func NewThreadJanitor(client AgnoService, poster ThreadPoster, archiver ThreadArchiver) *ThreadJanitor {
+	return &ThreadJanitor{
+		Client:        client,
+		Poster:        poster,
+		Archiver:      archiver,
+		StaleAfter:    24 * time.Hour,
+		Interval:      10 * time.Minute,
+		SummaryPrompt: defaultThreadSummaryPrompt,
+		MaxTranscript: defaultMaxTranscript,
+		threads:       make(map[ThreadKey]*threadState),
+	}
+}
+
+// Track records a message exchanged in a thread and refreshes its activity
+// time. A message in a closed thread whose announcement is still pending
+// starts it over.
+func (j *ThreadJanitor) Track(key ThreadKey, sessionID string, msg Message) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state, ok := j.threads[key]
+	if !ok || state.closingText != "" {
+		state = &threadState{sessionID: sessionID}
+		j.threads[key] = state
+	}
+	state.sessionID = sessionID
+	state.lastActivity = time.Now()
+	state.transcript = append(state.transcript, msg)
+	if j.MaxTranscript > 0 && len(state.transcript) > j.MaxTranscript {
+		state.transcript = append([]Message(nil), state.transcript[len(state.transcript)-j.MaxTranscript:]...)
+	}
+}
+
+// Forget stops tracking a thread without closing it
+func (j *ThreadJanitor) Forget(key ThreadKey) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.threads, key)
+}
+
+// Start runs the sweep loop in the background until Stop is called
+func (j *ThreadJanitor) Start() {
+	j.mu.Lock()
+	if j.stop != nil {
+		j.mu.Unlock()
+		return
+	}
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+	stop, done := j.stop, j.done
+	j.mu.Unlock()
+
+	logger.Infof("Thread janitor started (stale after %s, interval %s)", j.StaleAfter, j.Interval)
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.Sweep()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the sweep loop and waits for the current sweep to finish
+func (j *ThreadJanitor) Stop() {
+	j.mu.Lock()
+	stop, done := j.stop, j.done
+	j.stop, j.done = nil, nil
+	j.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	logger.Info("Thread janitor stopped")
+}
+
+// Sweep closes every thread that has been inactive longer than StaleAfter
+func (j *ThreadJanitor) Sweep() {
+	cutoff := time.Now().Add(-j.StaleAfter)
+
+	j.mu.Lock()
+	stale := make(map[ThreadKey]threadState)
+	for key, state := range j.threads {
+		if state.lastActivity.Before(cutoff) {
+			stale[key] = *state
+		}
+	}
+	j.mu.Unlock()
+
+	for key, state := range stale {
+		err := j.closeThread(key, &state)
+
+		j.mu.Lock()
+		// Only touch the thread if nobody wrote to it while we were closing it
+		if current, ok := j.threads[key]; ok && !current.lastActivity.After(state.lastActivity) {
+			if err == nil {
+				delete(j.threads, key)
+			} else {
+				// Keep the thread tracked so the next sweep retries what is left
+				current.closingText = state.closingText
+			}
+		}
+		j.mu.Unlock()
+
+		if err != nil {
+			logger.Errorf("Failed to close stale thread %s/%s: %v", key.ChatID, key.ThreadID, err)
+		}
+	}
+}
+
+// closeThread summarizes, archives, clears and announces a single thread.
+// Once the session is cleared the thread is closed and state.closingText is
+// set, so a failed announcement is all that a retry repeats.
+func (j *ThreadJanitor) closeThread(key ThreadKey, state *threadState) error {
+	if state.closingText == "" {
+		if err := j.archiveThread(key, state); err != nil {
+			return err
+		}
+	}
+
+	if err := j.Poster.PostToThread(key, state.closingText); err != nil {
+		return fmt.Errorf("failed to post thread summary: %w", err)
+	}
+	return nil
+}
+
+// archiveThread summarizes, archives and clears a thread, then sets its
+// closing announcement
+func (j *ThreadJanitor) archiveThread(key ThreadKey, state *threadState) error {
+	logger.Infof("Closing stale thread %s/%s (session %s)", key.ChatID, key.ThreadID, state.sessionID)
+
+	summary, err := j.Client.Chat(state.sessionID, j.SummaryPrompt, state.transcript)
+	if err != nil {
+		return fmt.Errorf("failed to summarize thread: %w", err)
+	}
+
+	if j.Archiver != nil {
+		if err := j.Archiver.ArchiveThread(key, state.sessionID, state.transcript, summary); err != nil {
+			return fmt.Errorf("failed to archive thread: %w", err)
+		}
+	}
+
+	if err := j.Client.ClearSession(state.sessionID); errors.Is(err, ErrLegalHold) {
+		logger.Infof("Keeping session %s of closed thread: %v", state.sessionID, err)
+	} else if err != nil {
+		return fmt.Errorf("failed to clear session: %w", err)
+	}
+
+	state.closingText = fmt.Sprintf("🧵 Closing this thread after %s of inactivity.\n\n%s", j.StaleAfter, summary)
+	return nil
+}
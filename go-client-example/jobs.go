@@ -0,0 +1,455 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// Job states reported by the Agno service
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// ErrJobPending is returned by GetJobResult while the job is still running
+var ErrJobPending = errors.New("agno: job not finished")
+
+// JobStatus is the state of an async chat job
+type JobStatus struct {
+	JobID     string `json:"job_id"`
+	SessionID string `json:"session_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// Finished reports whether the job has succeeded or failed
+func (s JobStatus) Finished() bool {
+	return s.Status == JobSucceeded || s.Status == JobFailed
+}
+
+// asyncChatRequest is the body of POST /chat/async
+type asyncChatRequest struct {
+	ChatRequest
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// JobService is the async job API; *AgnoClient implements it
+type JobService interface {
+	ChatAsync(ctx context.Context, req ChatRequest, callbackURL string) (string, error)
+	GetJobStatus(ctx context.Context, jobID string) (*JobStatus, error)
+	GetJobResult(ctx context.Context, jobID string) (*ChatResponse, error)
+	AuditJob(cb JobCallback)
+}
+
+var _ JobService = (*AgnoClient)(nil)
+
+// ChatAsync submits a chat request as a background job and returns its ID.
+// When callbackURL is set the service POSTs the finished job there (see
+// JobCallbackHandler); otherwise poll with GetJobStatus or WaitForJob.
+func (c *AgnoClient) ChatAsync(ctx context.Context, reqBody ChatRequest, callbackURL string) (jobID string, err error) {
+	ctx, span := startSpan(ctx, "ChatAsync", attribute.String("agno.session_id", reqBody.SessionID))
+	defer func() { endSpan(span, err) }()
+	if c.ChatAudit != nil {
+		start, asked := time.Now(), reqBody
+		defer func() {
+			if err != nil {
+				c.auditChat(ctx, asked, nil, err, time.Since(start))
+				return
+			}
+			c.ChatAudit.submitJob(ctx, jobID, c.auditRecord(ctx, asked))
+		}()
+	}
+
+	if err := c.guardRequest(&reqBody); err != nil {
+		return "", err
+	}
+	if err := c.applyModelParams(&reqBody); err != nil {
+		return "", err
+	}
+	if reqBody.ToolTimeouts == nil {
+		reqBody.ToolTimeouts = c.toolTimeouts(ctx)
+	}
+
+	jsonData, err := json.Marshal(asyncChatRequest{ChatRequest: reqBody, CallbackURL: callbackURL})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/chat/async", c.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := c.doRequest(req, "chat-async", reqBody.SessionID)
+	if err != nil {
+		logger.Errorf("Failed to submit Agno job: %v", err)
+		return "", err
+	}
+
+	if statusCode != http.StatusOK && statusCode != http.StatusAccepted {
+		logger.Errorf("Agno job submission returned status %d: %s", statusCode, string(body))
+		return "", newAPIError(statusCode, body)
+	}
+
+	var status JobStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return "", fmt.Errorf("failed to unmarshal job response: %w", err)
+	}
+	if status.JobID == "" {
+		return "", errors.New("job response has no job_id")
+	}
+
+	logger.Infof("Submitted Agno job %s for session %s", status.JobID, reqBody.SessionID)
+	return status.JobID, nil
+}
+
+// GetJobStatus returns the current state of a job
+func (c *AgnoClient) GetJobStatus(ctx context.Context, jobID string) (_ *JobStatus, err error) {
+	ctx, span := startSpan(ctx, "GetJobStatus", attribute.String("agno.job_id", jobID))
+	defer func() { endSpan(span, err) }()
+
+	statusCode, body, err := c.getJob(ctx, "job-status", fmt.Sprintf("%s/jobs/%s", c.BaseURL, url.PathEscape(jobID)))
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		logger.Errorf("Get job status failed (status %d): %s", statusCode, string(body))
+		return nil, newAPIError(statusCode, body)
+	}
+
+	var status JobStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job status: %w", err)
+	}
+	if c.ChatAudit != nil && status.Status == JobFailed {
+		c.ChatAudit.finishJob(ctx, jobID, nil, fmt.Errorf("job failed: %s", status.Error))
+	}
+	return &status, nil
+}
+
+// GetJobResult returns the answer of a finished job, ErrJobPending while it
+// is still running, or an error describing why it failed
+func (c *AgnoClient) GetJobResult(ctx context.Context, jobID string) (_ *ChatResponse, err error) {
+	ctx, span := startSpan(ctx, "GetJobResult", attribute.String("agno.job_id", jobID))
+	defer func() { endSpan(span, err) }()
+
+	statusCode, body, err := c.getJob(ctx, "job-result", fmt.Sprintf("%s/jobs/%s/result", c.BaseURL, url.PathEscape(jobID)))
+	if err != nil {
+		return nil, err
+	}
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusAccepted, http.StatusConflict:
+		return nil, ErrJobPending
+	default:
+		logger.Errorf("Get job result failed (status %d): %s", statusCode, string(body))
+		return nil, newAPIError(statusCode, body)
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job result: %w", err)
+	}
+	if c.ChatAudit != nil {
+		c.ChatAudit.finishJob(ctx, jobID, &chatResp, nil)
+	}
+	return &chatResp, nil
+}
+
+// AuditJob records the outcome of a job delivered through a callback with
+// the ChatAudit record of its submission. AsyncJobs calls it; use it in
+// onDone when mounting JobCallbackHandler directly.
+func (c *AgnoClient) AuditJob(cb JobCallback) {
+	if c.ChatAudit == nil || !cb.Finished() {
+		return
+	}
+	if cb.Status == JobFailed {
+		c.ChatAudit.finishJob(context.Background(), cb.JobID, nil, fmt.Errorf("job failed: %s", cb.Error))
+		return
+	}
+	c.ChatAudit.finishJob(context.Background(), cb.JobID, cb.Result, nil)
+}
+
+// WaitForJob polls a job every interval until it finishes, then returns its result
+func (c *AgnoClient) WaitForJob(ctx context.Context, jobID string, interval time.Duration) (*ChatResponse, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.GetJobStatus(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if status.Status == JobFailed {
+			return nil, fmt.Errorf("job %s failed: %s", jobID, status.Error)
+		}
+		if status.Finished() {
+			return c.GetJobResult(ctx, jobID)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// getJob issues a GET for one of the job endpoints
+func (c *AgnoClient) getJob(ctx context.Context, endpoint, url string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	statusCode, body, err := c.doRequest(req, endpoint, "")
+	if err != nil {
+		logger.Errorf("Failed to query Agno job: %v", err)
+		return 0, nil, err
+	}
+	return statusCode, body, nil
+}
+
+// JobCallback is the body the Agno service POSTs to the callback URL
+type JobCallback struct {
+	JobStatus
+	Result *ChatResponse `json:"result,omitempty"`
+}
+
+// JobCallbackHandler receives job completion callbacks from the Agno service.
+// Callbacks must carry a valid HMAC signature for secret (see VerifyRequest);
+// an empty secret returns ErrMissingSecret. onDone runs synchronously; keep
+// it short or hand off.
+func JobCallbackHandler(secret []byte, onDone func(JobCallback)) (http.Handler, error) {
+	if err := checkSecret("JobCallbackHandler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected job callback from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		var callback JobCallback
+		if err := json.Unmarshal(body, &callback); err != nil || callback.JobID == "" {
+			http.Error(w, "invalid job callback", http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("Received callback for Agno job %s (%s)", callback.JobID, callback.Status)
+		onDone(callback)
+		w.WriteHeader(http.StatusNoContent)
+	}), nil
+}
+
+// asyncJob is a submitted job awaiting its answer
+type asyncJob struct {
+	key       ThreadKey
+	submitted time.Time
+}
+
+// ErrTooManyJobs is returned by AsyncJobs.Submit when MaxPending jobs are
+// still awaiting an answer
+var ErrTooManyJobs = errors.New("agno: too many pending jobs")
+
+// AsyncJobs submits long-running questions as jobs and posts each answer
+// into the thread it was asked in once the job finishes
+type AsyncJobs struct {
+	Client      JobService
+	Poster      ThreadPoster
+	CallbackURL string        // if empty, jobs are polled instead
+	PollEvery   time.Duration // poll interval when CallbackURL is empty
+	MaxAge      time.Duration // give up on jobs older than this
+	MaxPending  int           // jobs tracked at once; Submit fails beyond it
+
+	mu   sync.Mutex
+	jobs map[string]asyncJob
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAsyncJobs creates a job tracker that polls every 15s and gives up after 2h
+func NewAsyncJobs(client JobService, poster ThreadPoster, callbackURL string) *AsyncJobs {
+	return &AsyncJobs{
+		Client:      client,
+		Poster:      poster,
+		CallbackURL: callbackURL,
+		PollEvery:   15 * time.Second,
+		MaxAge:      2 * time.Hour,
+		MaxPending:  1000,
+		jobs:        make(map[string]asyncJob),
+	}
+}
+
+// Submit starts a job for req whose answer will be posted to key. Jobs
+// older than MaxAge are given up first, so lost callbacks don't pile up
+// without the poller; with MaxPending jobs still tracked it returns
+// ErrTooManyJobs.
+func (a *AsyncJobs) Submit(ctx context.Context, key ThreadKey, req ChatRequest) (string, error) {
+	a.mu.Lock()
+	ids := make([]string, 0, len(a.jobs))
+	for id := range a.jobs {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+	for _, id := range ids {
+		a.expire(id)
+	}
+	if a.MaxPending > 0 && a.Pending() >= a.MaxPending {
+		return "", ErrTooManyJobs
+	}
+
+	jobID, err := a.Client.ChatAsync(ctx, req, a.CallbackURL)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	a.jobs[jobID] = asyncJob{key: key, submitted: time.Now()}
+	a.mu.Unlock()
+	return jobID, nil
+}
+
+// Pending returns the number of jobs still awaiting an answer
+func (a *AsyncJobs) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.jobs)
+}
+
+// Handler returns the callback receiver to mount at CallbackURL
+func (a *AsyncJobs) Handler(secret []byte) (http.Handler, error) {
+	return JobCallbackHandler(secret, func(cb JobCallback) {
+		a.Client.AuditJob(cb)
+		if cb.Finished() {
+			a.complete(cb.JobID, cb.Status, cb.Result, cb.Error)
+		}
+	})
+}
+
+// Start launches the background poller. It is only needed when jobs are not
+// delivered through callbacks, but also acts as a safety net for lost callbacks.
+func (a *AsyncJobs) Start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.PollEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.Poll(context.Background())
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background poller
+func (a *AsyncJobs) Stop() {
+	if a.stop == nil {
+		return
+	}
+	close(a.stop)
+	<-a.done
+	a.stop = nil
+}
+
+// Poll checks every pending job once and posts the finished ones
+func (a *AsyncJobs) Poll(ctx context.Context) {
+	a.mu.Lock()
+	ids := make([]string, 0, len(a.jobs))
+	for id := range a.jobs {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+
+	for _, id := range ids {
+		status, err := a.Client.GetJobStatus(ctx, id)
+		if err != nil {
+			logger.Warnf("Failed to poll Agno job %s: %v", id, err)
+			a.expire(id)
+			continue
+		}
+		if !status.Finished() {
+			a.expire(id)
+			continue
+		}
+
+		var result *ChatResponse
+		if status.Status == JobSucceeded {
+			if result, err = a.Client.GetJobResult(ctx, id); err != nil {
+				logger.Warnf("Failed to fetch result of Agno job %s: %v", id, err)
+				continue
+			}
+		}
+		a.complete(id, status.Status, result, status.Error)
+	}
+}
+
+// expire gives up on a job that has been pending longer than MaxAge
+func (a *AsyncJobs) expire(jobID string) {
+	a.mu.Lock()
+	job, ok := a.jobs[jobID]
+	if !ok || time.Since(job.submitted) < a.MaxAge {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.jobs, jobID)
+	a.mu.Unlock()
+
+	logger.Warnf("Giving up on Agno job %s after %s", jobID, a.MaxAge)
+	if err := a.Poster.PostToThread(job.key, "⌛ Sorry, this request took too long and was abandoned. Please try again."); err != nil {
+		logger.Errorf("Failed to post job timeout to thread %s: %v", job.key.ThreadID, err)
+	}
+}
+
+// complete posts the outcome of a finished job once
+func (a *AsyncJobs) complete(jobID, status string, result *ChatResponse, jobErr string) {
+	a.mu.Lock()
+	job, ok := a.jobs[jobID]
+	delete(a.jobs, jobID)
+	a.mu.Unlock()
+	if !ok {
+		return // unknown job or already posted via the other delivery path
+	}
+
+	text := "❌ Sorry, I couldn't finish this request. Please try again later."
+	if status == JobSucceeded && result != nil {
+		text = result.Response
+	} else {
+		logger.Errorf("Agno job %s failed: %s", jobID, jobErr)
+	}
+	if err := a.Poster.PostToThread(job.key, text); err != nil {
+		logger.Errorf("Failed to post result of job %s to thread %s: %v", jobID, job.key.ThreadID, err)
+	}
+}
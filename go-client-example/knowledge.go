@@ -0,0 +1,273 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"start-feishubot/logger"
+)
+
+// maxDocumentSize caps documents uploaded to a knowledge collection
+const maxDocumentSize = 50 << 20
+
+// Collection is a knowledge collection in the agent's retrieval index
+type Collection struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Documents   int    `json:"documents,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+}
+
+// DocumentMetadata describes an uploaded document. SourceID should be stable
+// (e.g. the Lark doc token): uploading the same SourceID again replaces the
+// previous version instead of adding a duplicate.
+type DocumentMetadata struct {
+	SourceID    string            `json:"source_id"`
+	Title       string            `json:"title,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	ContentType string            `json:"content_type,omitempty"` // e.g. text/markdown, application/pdf
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+// Document is a document stored in a collection
+type Document struct {
+	ID string `json:"id"`
+	DocumentMetadata
+	Chunks int `json:"chunks,omitempty"`
+}
+
+// SearchResult is one chunk returned by a knowledge search
+type SearchResult struct {
+	DocumentID string           `json:"document_id"`
+	Content    string           `json:"content"`
+	Score      float64          `json:"score"`
+	Metadata   DocumentMetadata `json:"metadata"`
+}
+
+// searchRequest is the body of POST /knowledge/collections/{name}/search
+type searchRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// searchResponse is the body returned by the search endpoint
+type searchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+// KnowledgeClient manages the Agno service's knowledge collections (RAG),
+// so admins can sync Lark Docs/Wiki content into the agent's retrieval index
+type KnowledgeClient struct {
+	Client *AgnoClient
+}
+
+// NewKnowledgeClient creates a knowledge client sharing client's transport,
+// authentication and metrics
+func NewKnowledgeClient(client *AgnoClient) *KnowledgeClient {
+	return &KnowledgeClient{Client: client}
+}
+
+// CreateCollection creates a knowledge collection
+func (k *KnowledgeClient) CreateCollection(ctx context.Context, name, description string) (_ *Collection, err error) {
+	ctx, span := startSpan(ctx, "CreateCollection", attribute.String("agno.collection", name))
+	defer func() { endSpan(span, err) }()
+
+	jsonData, err := json.Marshal(Collection{Name: name, Description: description})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal collection: %w", err)
+	}
+
+	var collection Collection
+	if err := k.do(ctx, "POST", "/knowledge/collections", "knowledge-create", "application/json", jsonData, &collection); err != nil {
+		return nil, err
+	}
+	logger.Infof("Created knowledge collection %s", name)
+	return &collection, nil
+}
+
+// ListCollections returns all knowledge collections
+func (k *KnowledgeClient) ListCollections(ctx context.Context) (_ []Collection, err error) {
+	ctx, span := startSpan(ctx, "ListCollections")
+	defer func() { endSpan(span, err) }()
+
+	var resp struct {
+		Collections []Collection `json:"collections"`
+	}
+	if err := k.do(ctx, "GET", "/knowledge/collections", "knowledge-list", "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Collections, nil
+}
+
+// UploadDocument adds or replaces a document in a collection. The service
+// chunks and embeds it; the returned Document carries its ID.
+func (k *KnowledgeClient) UploadDocument(ctx context.Context, collection string, document io.Reader, metadata DocumentMetadata) (_ *Document, err error) {
+	ctx, span := startSpan(ctx, "UploadDocument",
+		attribute.String("agno.collection", collection),
+		attribute.String("agno.source_id", metadata.SourceID))
+	defer func() { endSpan(span, err) }()
+
+	content, err := io.ReadAll(io.LimitReader(document, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	if len(content) > maxDocumentSize {
+		return nil, fmt.Errorf("%w: document exceeds %d bytes", ErrPayloadTooLarge, maxDocumentSize)
+	}
+
+	metaJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document metadata: %w", err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("metadata", string(metaJSON)); err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+	header := make(textproto.MIMEHeader)
+	filename := metadata.Title
+	if filename == "" {
+		filename = metadata.SourceID
+	}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+
+	var doc Document
+	path := fmt.Sprintf("/knowledge/collections/%s/documents", url.PathEscape(collection))
+	if err := k.do(ctx, "POST", path, "knowledge-upload", form.FormDataContentType(), body.Bytes(), &doc); err != nil {
+		return nil, err
+	}
+	logger.Infof("Uploaded %s (%d bytes) to knowledge collection %s", metadata.SourceID, len(content), collection)
+	return &doc, nil
+}
+
+// DeleteDocument removes a document from a collection
+func (k *KnowledgeClient) DeleteDocument(ctx context.Context, collection, documentID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteDocument", attribute.String("agno.collection", collection))
+	defer func() { endSpan(span, err) }()
+
+	path := fmt.Sprintf("/knowledge/collections/%s/documents/%s", url.PathEscape(collection), url.PathEscape(documentID))
+	return k.do(ctx, "DELETE", path, "knowledge-delete", "", nil, nil)
+}
+
+// Reindex rebuilds a collection's embeddings, e.g. after changing the
+// embedding model or chunking settings. It returns once the service has
+// accepted the job.
+func (k *KnowledgeClient) Reindex(ctx context.Context, collection string) (err error) {
+	ctx, span := startSpan(ctx, "Reindex", attribute.String("agno.collection", collection))
+	defer func() { endSpan(span, err) }()
+
+	path := fmt.Sprintf("/knowledge/collections/%s/reindex", url.PathEscape(collection))
+	if err := k.do(ctx, "POST", path, "knowledge-reindex", "", nil, nil); err != nil {
+		return err
+	}
+	logger.Infof("Reindexing knowledge collection %s", collection)
+	return nil
+}
+
+// Search returns the limit chunks of a collection most relevant to query
+func (k *KnowledgeClient) Search(ctx context.Context, collection, query string, limit int) (_ []SearchResult, err error) {
+	ctx, span := startSpan(ctx, "Search", attribute.String("agno.collection", collection))
+	defer func() { endSpan(span, err) }()
+
+	jsonData, err := json.Marshal(searchRequest{Query: query, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search request: %w", err)
+	}
+
+	var resp searchResponse
+	path := fmt.Sprintf("/knowledge/collections/%s/search", url.PathEscape(collection))
+	if err := k.do(ctx, "POST", path, "knowledge-search", "application/json", jsonData, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// SourceDocument is a document to sync, e.g. a Lark Doc or Wiki page
+// exported as markdown
+type SourceDocument struct {
+	Metadata DocumentMetadata
+	Content  io.Reader
+}
+
+// DocumentSource lists the documents to sync into a collection
+// (implemented by the bot's Lark Docs/Wiki client)
+type DocumentSource interface {
+	Documents(ctx context.Context) ([]SourceDocument, error)
+}
+
+// Sync uploads every document of source into collection and returns the
+// number uploaded. Documents that fail are logged and skipped.
+func (k *KnowledgeClient) Sync(ctx context.Context, collection string, source DocumentSource) (int, error) {
+	docs, err := source.Documents(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list source documents: %w", err)
+	}
+
+	uploaded := 0
+	for _, doc := range docs {
+		if _, err := k.UploadDocument(ctx, collection, doc.Content, doc.Metadata); err != nil {
+			logger.Errorf("Failed to sync %s into %s: %v", doc.Metadata.SourceID, collection, err)
+			continue
+		}
+		uploaded++
+	}
+	logger.Infof("Synced %d/%d documents into knowledge collection %s", uploaded, len(docs), collection)
+	return uploaded, nil
+}
+
+// do sends a knowledge API request and decodes a 2xx response into out (if not nil)
+func (k *KnowledgeClient) do(ctx context.Context, method, path, endpoint, contentType string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.Client.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	statusCode, respBody, err := k.Client.doRequest(req, endpoint, "")
+	if err != nil {
+		logger.Errorf("Knowledge request %s %s failed: %v", method, path, err)
+		return err
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		logger.Errorf("Knowledge request %s %s returned status %d: %s", method, path, statusCode, string(respBody))
+		return newAPIError(statusCode, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", endpoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// translatePrompt asks the agent to translate a reply without changing it
+const translatePrompt = "Translate the following text into %s. Keep markdown formatting, code, links " +
+	"and names unchanged. Reply with the translation only.\n\n%s"
+
+// languageNames are used in prompts for the supported language codes
+var languageNames = map[string]string{
+	"en": "English",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"th": "Thai",
+	"ru": "Russian",
+	"fr": "French",
+	"de": "German",
+	"es": "Spanish",
+	"pt": "Portuguese",
+	"it": "Italian",
+	"nl": "Dutch",
+	"id": "Indonesian",
+}
+
+// LanguageUndetermined is reported by DetectLanguage for Latin-script text
+// it can't attribute to a known language. No policy allows it, so such
+// replies are translated.
+const LanguageUndetermined = "und"
+
+// vietnameseLetters only occur in Vietnamese among Latin-script languages
+const vietnameseLetters = "ăâđêôơưạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ"
+
+// latinStopWords are frequent function words that tell Latin-script
+// languages apart
+var latinStopWords = stopWordSets(map[string]string{
+	"en": "the and is are of to in that it you for with this be not on have can your was will if or",
+	"fr": "le la les et est des une un du que pour dans pas vous sur avec ce qui sont il nous au aux",
+	"de": "der die das und ist nicht ein eine zu den mit sie ich es auf für von sind auch wird dem",
+	"es": "el la los las y es de que en un una por para con no se su del está como al lo",
+	"pt": "o a os as e é de que em um uma para com não se do da no na você ao",
+	"it": "il lo la gli le e è di che un una per con non si del della sono anche questo nel",
+	"nl": "de het een en is van dat niet ik je op te zijn met voor er maar ook wat u",
+	"id": "dan yang di ini itu dengan untuk tidak ada dari akan saya anda kami bisa juga atau pada ke sudah",
+})
+
+// stopWordSets splits each language's space-separated word list into a set
+func stopWordSets(lists map[string]string) map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(lists))
+	for lang, list := range lists {
+		sets[lang] = make(map[string]bool)
+		for _, word := range strings.Fields(list) {
+			sets[lang][word] = true
+		}
+	}
+	return sets
+}
+
+// LanguagePolicy restricts the languages a tenant's responses may use
+type LanguagePolicy struct {
+	Allowed []string // ISO 639-1 codes, e.g. ["en", "vi"]
+	Default string   // language to translate into when a reply is not allowed
+}
+
+// allows reports whether lang is in the approved set
+func (p LanguagePolicy) allows(lang string) bool {
+	for _, allowed := range p.Allowed {
+		if allowed == lang {
+			return true
+		}
+	}
+	return false
+}
+
+var languageTranslations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "language",
+	Name:      "translations_total",
+	Help:      "Replies translated because they were not in an approved language.",
+}, []string{"tenant", "from", "to"})
+
+// LanguageEnforcer forces replies into each tenant's approved languages
+type LanguageEnforcer struct {
+	Client AgnoService
+	Detect func(text string) string // returns an ISO 639-1 code or "" if unsure
+
+	mu       sync.RWMutex
+	policies map[string]LanguagePolicy
+}
+
+// NewLanguageEnforcer creates an enforcer using the built-in script-based detector
+func NewLanguageEnforcer(client AgnoService) *LanguageEnforcer {
+	return &LanguageEnforcer{
+		Client:   client,
+		Detect:   DetectLanguage,
+		policies: make(map[string]LanguagePolicy),
+	}
+}
+
+// NewLanguageEnforcerFromEnv loads policies from AGNO_LANGUAGE_POLICY, a
+// semicolon-separated list of tenant=lang,lang entries whose first language
+// is the default, e.g. "acme=en,vi;globex=zh"
+func NewLanguageEnforcerFromEnv(client AgnoService) *LanguageEnforcer {
+	e := NewLanguageEnforcer(client)
+	for _, entry := range strings.Split(os.Getenv("AGNO_LANGUAGE_POLICY"), ";") {
+		tenant, langs, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			if entry != "" {
+				logger.Warnf("Ignoring malformed AGNO_LANGUAGE_POLICY entry %q", entry)
+			}
+			continue
+		}
+		var allowed []string
+		for _, lang := range strings.Split(langs, ",") {
+			if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+				allowed = append(allowed, lang)
+			}
+		}
+		if len(allowed) > 0 {
+			e.SetPolicy(strings.TrimSpace(tenant), LanguagePolicy{Allowed: allowed, Default: allowed[0]})
+		}
+	}
+	return e
+}
+
+// SetPolicy configures the policy for a tenant
+func (e *LanguageEnforcer) SetPolicy(tenant string, policy LanguagePolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policies[tenant] = policy
+}
+
+// Policy returns the tenant's policy, if any
+func (e *LanguageEnforcer) Policy(tenant string) (LanguagePolicy, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	policy, ok := e.policies[tenant]
+	return policy, ok && len(policy.Allowed) > 0
+}
+
+// SystemInstruction returns the instruction to add to the system prompt so
+// the model answers in an approved language in the first place
+func (e *LanguageEnforcer) SystemInstruction(tenant string) string {
+	policy, ok := e.Policy(tenant)
+	if !ok {
+		return ""
+	}
+	names := make([]string, 0, len(policy.Allowed))
+	for _, lang := range policy.Allowed {
+		names = append(names, languageName(lang))
+	}
+	return fmt.Sprintf("Always respond in one of these languages: %s. If the user writes in another language, respond in %s.",
+		strings.Join(names, ", "), languageName(policy.Default))
+}
+
+// Chat sends a message with the tenant's language instruction and enforces
+// the policy on the reply
+func (e *LanguageEnforcer) Chat(ctx context.Context, tenant string, req ChatRequest) (*ChatResponse, error) {
+	if instruction := e.SystemInstruction(tenant); instruction != "" {
+		req.SystemPrompt = strings.TrimSpace(req.SystemPrompt + "\n\n" + instruction)
+	}
+
+	resp, err := e.Client.SendChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Response, err = e.Enforce(ctx, tenant, req.SessionID, resp.Response)
+	return resp, err
+}
+
+// Enforce translates reply into the tenant's default language when it is
+// not written in an approved one
+func (e *LanguageEnforcer) Enforce(ctx context.Context, tenant, sessionID, reply string) (string, error) {
+	policy, ok := e.Policy(tenant)
+	if !ok {
+		return reply, nil
+	}
+
+	lang := e.Detect(reply)
+	if lang == "" || policy.allows(lang) {
+		return reply, nil
+	}
+
+	logger.Infof("Reply for tenant %s is in %s, translating to %s", tenant, lang, policy.Default)
+	languageTranslations.WithLabelValues(tenant, lang, policy.Default).Inc()
+
+	// Translate in a throwaway session so the translation turn doesn't pollute the conversation
+	translateSession := sessionID + ":translate"
+	translated, err := e.Client.ChatContext(ctx, translateSession,
+		fmt.Sprintf(translatePrompt, languageName(policy.Default), reply), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to translate reply: %w", err)
+	}
+	if err := e.Client.ClearSessionContext(ctx, translateSession); err != nil {
+		logger.Warnf("Failed to clear translation session %s: %v", translateSession, err)
+	}
+	return translated, nil
+}
+
+// languageName returns the English name of a language code
+func languageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return lang
+}
+
+// DetectLanguage guesses the language of text from its script. Latin text is
+// reported as Vietnamese when it contains Vietnamese-only letters, and
+// otherwise by its stop words (English, French, German, Spanish, Portuguese,
+// Italian, Dutch or Indonesian); Latin text matching none of them is
+// LanguageUndetermined. "" means there were too few letters to tell.
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	letters, vietnamese := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			counts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+			if strings.ContainsRune(vietnameseLetters, unicode.ToLower(r)) {
+				vietnamese++
+			}
+		}
+	}
+	if letters < 8 {
+		return ""
+	}
+
+	// Japanese mixes kana with Han characters; any meaningful kana means Japanese
+	if counts["ja"] > 0 && counts["ja"]*10 >= counts["zh"] {
+		counts["ja"] += counts["zh"]
+		counts["zh"] = 0
+	}
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if best == "latin" {
+		if vietnamese*20 >= counts["latin"] {
+			return "vi"
+		}
+		return detectLatinLanguage(text)
+	}
+	return best
+}
+
+// detectLatinLanguage picks the language whose stop words occur most often
+// in text. A tie or fewer than two hits is LanguageUndetermined.
+func detectLatinLanguage(text string) string {
+	scores := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for lang, words := range latinStopWords {
+			if words[word] {
+				scores[lang]++
+			}
+		}
+	}
+	best, bestScore, tied := LanguageUndetermined, 0, false
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = lang, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if bestScore < 2 || tied {
+		return LanguageUndetermined
+	}
+	return best
+}
@@ -0,0 +1,147 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"start-feishubot/logger"
+)
+
+// GroupSizer reports the member count of a Lark chat (implemented by the bot
+// via GET /im/v1/chats/:chat_id)
+type GroupSizer interface {
+	MemberCount(ctx context.Context, chatID string) (int, error)
+}
+
+// groupSize is a cached member count
+type groupSize struct {
+	members int
+	fetched time.Time
+}
+
+// LargeGroups applies stricter rules in very large group chats: a tighter
+// chat quota, no proactive messages, and answers only to messages that
+// @mention the bot and start with the command prefix
+type LargeGroups struct {
+	Sizer     GroupSizer
+	Threshold int           // member count from which a group is large
+	Prefix    string        // required command prefix, e.g. "/ask"
+	Quota     BucketConfig  // chat quota replacing RateLimiter.Chat in large groups
+	CacheTTL  time.Duration // how long member counts are cached
+
+	mu     sync.Mutex
+	sizes  map[string]groupSize
+	sweeps int
+}
+
+// NewLargeGroupsFromEnv configures thresholds from AGNO_LARGE_GROUP_MEMBERS
+// (default 1000), AGNO_LARGE_GROUP_PREFIX (default "/ask"),
+// AGNO_LARGE_GROUP_BURST and AGNO_LARGE_GROUP_REFILL (default 5 / 30s)
+func NewLargeGroupsFromEnv(sizer GroupSizer) *LargeGroups {
+	prefix := os.Getenv("AGNO_LARGE_GROUP_PREFIX")
+	if prefix == "" {
+		prefix = "/ask"
+	}
+	return &LargeGroups{
+		Sizer:     sizer,
+		Threshold: envInt("AGNO_LARGE_GROUP_MEMBERS", 1000),
+		Prefix:    prefix,
+		Quota: BucketConfig{
+			Burst:  envInt("AGNO_LARGE_GROUP_BURST", 5),
+			Refill: envDuration("AGNO_LARGE_GROUP_REFILL", 30*time.Second),
+		},
+		CacheTTL: time.Hour,
+		sizes:    make(map[string]groupSize),
+	}
+}
+
+// IsLarge reports whether chatID has at least Threshold members. Lookup
+// failures are treated as "not large" so a Lark API hiccup doesn't mute the bot.
+func (g *LargeGroups) IsLarge(ctx context.Context, chatID string) bool {
+	if g.Threshold <= 0 {
+		return false
+	}
+
+	g.mu.Lock()
+	cached, ok := g.sizes[chatID]
+	g.mu.Unlock()
+	if ok && time.Since(cached.fetched) < g.CacheTTL {
+		return cached.members >= g.Threshold
+	}
+
+	members, err := g.Sizer.MemberCount(ctx, chatID)
+	if err != nil {
+		logger.Warnf("Failed to get member count of chat %s: %v", chatID, err)
+		return ok && cached.members >= g.Threshold
+	}
+
+	g.mu.Lock()
+	g.sizes[chatID] = groupSize{members: members, fetched: time.Now()}
+	g.sweeps++
+	if g.sweeps%1000 == 0 {
+		g.dropStale(time.Now())
+	}
+	g.mu.Unlock()
+	if members >= g.Threshold && (!ok || cached.members < g.Threshold) {
+		logger.Infof("Chat %s has %d members, applying large-group rules", chatID, members)
+	}
+	return members >= g.Threshold
+}
+
+// dropStale forgets member counts of chats not looked up for two CacheTTLs,
+// keeping recently expired ones as the fallback for lookup failures;
+// callers must hold g.mu
+func (g *LargeGroups) dropStale(now time.Time) {
+	for chatID, size := range g.sizes {
+		if now.Sub(size.fetched) > 2*g.CacheTTL {
+			delete(g.sizes, chatID)
+		}
+	}
+}
+
+// Admit decides whether the bot should answer a group message. In large
+// groups the message must @mention the bot and start with Prefix as its
+// own word (followed by a space or nothing, so "/asking" isn't "/ask"); the
+// returned question has the prefix stripped. When the message is ignored
+// the returned hint (possibly empty) tells the user how to ask.
+func (g *LargeGroups) Admit(ctx context.Context, chatID, chatType string, mentioned bool, text string) (question, hint string, ok bool) {
+	if chatType == "p2p" || !g.IsLarge(ctx, chatID) {
+		return text, "", true
+	}
+	if !mentioned {
+		return "", "", false // stay silent instead of nagging thousands of people
+	}
+
+	trimmed := strings.TrimSpace(text)
+	rest := strings.TrimPrefix(trimmed, g.Prefix)
+	if next, _ := utf8.DecodeRuneInString(rest); !strings.HasPrefix(trimmed, g.Prefix) || (rest != "" && !unicode.IsSpace(next)) {
+		return "", fmt.Sprintf("👋 This is a large group, so I only answer questions that start with %s, e.g. `%s how do I request VPN access?`", g.Prefix, g.Prefix), false
+	}
+	return strings.TrimSpace(rest), "", true
+}
+
+// ProactiveAllowed reports whether unsolicited messages (duplicate
+// suggestions, thread-closing notices, digests) may be posted in the chat
+func (g *LargeGroups) ProactiveAllowed(ctx context.Context, chatID string) bool {
+	return !g.IsLarge(ctx, chatID)
+}
+
+// ChatQuota returns a RateLimiter.ChatConfigFunc applying Quota in large
+// groups and the limiter's current Chat elsewhere, so limits swapped in by
+// SetLimits apply
+func (g *LargeGroups) ChatQuota(limiter *RateLimiter) func(ctx context.Context, chatID string) BucketConfig {
+	return func(ctx context.Context, chatID string) BucketConfig {
+		if g.IsLarge(ctx, chatID) {
+			return g.Quota
+		}
+		limiter.mu.RLock()
+		defer limiter.mu.RUnlock()
+		return limiter.Chat
+	}
+}
@@ -0,0 +1,288 @@
+// Package larktest provides a fake Lark Open API server that records what a
+// handler sent, for end-to-end handler tests. Point the Lark SDK at URL, e.g.
+// lark.NewClient(appID, appSecret, lark.WithOpenBaseUrl(srv.URL)).
+package larktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Request is a raw request captured by the fake server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// SentMessage is a message created through send or reply
+type SentMessage struct {
+	MessageID     string
+	ReceiveIDType string // empty for replies
+	ReceiveID     string // empty for replies
+	ReplyTo       string // parent message ID for replies
+	ReplyInThread bool
+	MsgType       string
+	Content       string
+}
+
+// Text returns the text of a "text" message (empty for other types)
+func (m SentMessage) Text() string {
+	var content struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal([]byte(m.Content), &content)
+	return content.Text
+}
+
+// Card decodes the content of an "interactive" message
+func (m SentMessage) Card() map[string]interface{} {
+	var card map[string]interface{}
+	json.Unmarshal([]byte(m.Content), &card)
+	return card
+}
+
+// CardUpdate is a PATCH of an interactive card
+type CardUpdate struct {
+	MessageID string
+	Content   string
+}
+
+// resource is a downloadable message resource (image, file, audio)
+type resource struct {
+	data        []byte
+	contentType string
+}
+
+// failure is an injected error response
+type failure struct {
+	method     string
+	pathPrefix string
+	status     int
+	code       int
+}
+
+// Server is a fake Lark Open API server
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	requests    []Request
+	messages    []SentMessage
+	cardUpdates []CardUpdate
+	resources   map[string]resource
+	failures    []failure
+	nextID      int
+}
+
+// NewServer starts a fake Lark server; call Close when done
+func NewServer() *Server {
+	s := &Server{resources: make(map[string]resource)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Messages returns every message sent or replied so far
+func (s *Server) Messages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.messages...)
+}
+
+// CardUpdates returns every card update so far
+func (s *Server) CardUpdates() []CardUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CardUpdate(nil), s.cardUpdates...)
+}
+
+// AddResource makes a message resource downloadable
+func (s *Server) AddResource(messageID, fileKey string, data []byte, contentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[messageID+"/"+fileKey] = resource{data: data, contentType: contentType}
+}
+
+// FailNext makes the next request matching method and path prefix fail with
+// the given HTTP status and Lark error code (e.g. 429 / 99991400)
+func (s *Server) FailNext(method, pathPrefix string, status, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method: method, pathPrefix: pathPrefix, status: status, code: code})
+}
+
+// Reset clears all captured state and injected failures
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.messages, s.cardUpdates, s.failures = nil, nil, nil, nil
+	s.resources = make(map[string]resource)
+}
+
+// handle routes requests to the fake endpoints
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	if f, ok := s.takeFailure(r); ok {
+		s.mu.Unlock()
+		writeJSON(w, f.status, map[string]interface{}{"code": f.code, "msg": "injected failure"})
+		return
+	}
+	s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/open-apis")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case strings.HasPrefix(path, "/auth/v3/"):
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"code": 0, "msg": "ok",
+			"tenant_access_token": "t-fake", "app_access_token": "a-fake", "expire": 7200,
+		})
+	case r.Method == http.MethodPost && path == "/im/v1/messages":
+		s.handleSend(w, r, body)
+	case r.Method == http.MethodPost && len(parts) == 5 && parts[4] == "reply":
+		s.handleReply(w, parts[3], body)
+	case r.Method == http.MethodPatch && len(parts) == 4 && parts[2] == "messages":
+		s.handleCardUpdate(w, parts[3], body)
+	case r.Method == http.MethodGet && len(parts) == 6 && parts[4] == "resources":
+		s.handleResource(w, parts[3], parts[5])
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"code": 404, "msg": "larktest: unhandled " + r.Method + " " + r.URL.Path})
+	}
+}
+
+// takeFailure pops the first injected failure matching r; callers must hold s.mu
+func (s *Server) takeFailure(r *http.Request) (failure, bool) {
+	for i, f := range s.failures {
+		if (f.method == "" || f.method == r.Method) && strings.HasPrefix(r.URL.Path, f.pathPrefix) {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f, true
+		}
+	}
+	return failure{}, false
+}
+
+// messagePayload is the body of send and reply requests
+type messagePayload struct {
+	ReceiveID     string `json:"receive_id"`
+	MsgType       string `json:"msg_type"`
+	Content       string `json:"content"`
+	ReplyInThread bool   `json:"reply_in_thread"`
+}
+
+// handleSend implements POST /im/v1/messages
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request, body []byte) {
+	var p messagePayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "msg": err.Error()})
+		return
+	}
+	msg := s.record(SentMessage{
+		ReceiveIDType: r.URL.Query().Get("receive_id_type"),
+		ReceiveID:     p.ReceiveID,
+		MsgType:       p.MsgType,
+		Content:       p.Content,
+	})
+	writeJSON(w, http.StatusOK, messageResponse(msg))
+}
+
+// handleReply implements POST /im/v1/messages/:message_id/reply
+func (s *Server) handleReply(w http.ResponseWriter, parentID string, body []byte) {
+	var p messagePayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "msg": err.Error()})
+		return
+	}
+	msg := s.record(SentMessage{
+		ReplyTo:       parentID,
+		ReplyInThread: p.ReplyInThread,
+		MsgType:       p.MsgType,
+		Content:       p.Content,
+	})
+	writeJSON(w, http.StatusOK, messageResponse(msg))
+}
+
+// handleCardUpdate implements PATCH /im/v1/messages/:message_id
+func (s *Server) handleCardUpdate(w http.ResponseWriter, messageID string, body []byte) {
+	var p struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "msg": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	s.cardUpdates = append(s.cardUpdates, CardUpdate{MessageID: messageID, Content: p.Content})
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": 0, "msg": "success"})
+}
+
+// handleResource implements GET /im/v1/messages/:message_id/resources/:file_key
+func (s *Server) handleResource(w http.ResponseWriter, messageID, fileKey string) {
+	s.mu.Lock()
+	res, ok := s.resources[messageID+"/"+fileKey]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"code": 234003, "msg": "resource not found"})
+		return
+	}
+	w.Header().Set("Content-Type", res.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileKey))
+	w.WriteHeader(http.StatusOK)
+	w.Write(res.data)
+}
+
+// record assigns a message ID and stores the message
+func (s *Server) record(msg SentMessage) SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	msg.MessageID = fmt.Sprintf("om_fake_%d", s.nextID)
+	s.messages = append(s.messages, msg)
+	return msg
+}
+
+// messageResponse is the Lark response body for a created message
+func messageResponse(msg SentMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{
+			"message_id": msg.MessageID,
+			"msg_type":   msg.MsgType,
+			"parent_id":  msg.ReplyTo,
+			"body":       map[string]interface{}{"content": msg.Content},
+		},
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,422 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// ErrLegalHold is returned when deleting data of a user or chat under legal hold
+var ErrLegalHold = errors.New("agno: subject is under legal hold")
+
+// Legal hold subject kinds
+const (
+	HoldUser = "user"
+	HoldChat = "chat"
+)
+
+// AuditEvent is an entry in the audit log
+type AuditEvent struct {
+	Time    time.Time         `json:"time"`
+	Actor   string            `json:"actor"`
+	Action  string            `json:"action"`
+	Subject string            `json:"subject"`
+	Detail  map[string]string `json:"detail,omitempty"`
+}
+
+// AuditLog records security-relevant actions
+type AuditLog interface {
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// LoggerAuditLog writes audit events to the application log
+type LoggerAuditLog struct{}
+
+// Record implements AuditLog
+func (LoggerAuditLog) Record(ctx context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	logger.Infof("AUDIT %s", data)
+	return nil
+}
+
+// LegalHold exempts a user's or chat's transcripts from deletion
+type LegalHold struct {
+	Kind     string    `json:"kind"` // HoldUser or HoldChat
+	ID       string    `json:"id"`
+	Matter   string    `json:"matter,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	PlacedBy string    `json:"placed_by"`
+	PlacedAt time.Time `json:"placed_at"`
+}
+
+// Subject returns the hold's audit subject, e.g. "user:ou_123"
+func (h LegalHold) Subject() string {
+	return h.Kind + ":" + h.ID
+}
+
+// LegalHolds manages legal holds. Holds live in a SessionStore without
+// expiry; every change and every access to held data is audited.
+type LegalHolds struct {
+	Store SessionStore
+	Audit AuditLog
+
+	// Subjects maps sessions to their user and chat for CheckSession,
+	// AgnoClient.Holds and ProtectStore; without it only CheckDeletion
+	// with explicit subjects finds holds
+	Subjects SessionSubjects
+}
+
+// NewLegalHolds creates a hold registry; a nil audit log writes to the application log
+func NewLegalHolds(store SessionStore, audit AuditLog) *LegalHolds {
+	if audit == nil {
+		audit = LoggerAuditLog{}
+	}
+	return &LegalHolds{Store: store, Audit: audit}
+}
+
+// Place puts a user or chat under legal hold
+func (h *LegalHolds) Place(ctx context.Context, actor string, hold LegalHold) error {
+	if hold.Kind != HoldUser && hold.Kind != HoldChat {
+		return fmt.Errorf("invalid hold kind %q", hold.Kind)
+	}
+	if hold.ID == "" {
+		return errors.New("hold ID is required")
+	}
+	hold.PlacedBy = actor
+	hold.PlacedAt = time.Now().UTC()
+
+	data, err := legalHoldSchema.Encode(hold)
+	if err != nil {
+		return fmt.Errorf("failed to marshal legal hold: %w", err)
+	}
+	if err := h.Store.Set(ctx, holdKey(hold.Kind, hold.ID), data, 0); err != nil {
+		return fmt.Errorf("failed to store legal hold: %w", err)
+	}
+	h.record(ctx, actor, "legal_hold.place", hold.Subject(), map[string]string{"matter": hold.Matter, "reason": hold.Reason})
+	return nil
+}
+
+// Release lifts the legal hold on a user or chat
+func (h *LegalHolds) Release(ctx context.Context, actor, kind, id string) error {
+	if _, err := h.Get(ctx, kind, id); err != nil {
+		return err
+	}
+	if err := h.Store.Delete(ctx, holdKey(kind, id)); err != nil {
+		return fmt.Errorf("failed to release legal hold: %w", err)
+	}
+	h.record(ctx, actor, "legal_hold.release", kind+":"+id, nil)
+	return nil
+}
+
+// Get returns the hold on a user or chat, or ErrKeyNotFound
+func (h *LegalHolds) Get(ctx context.Context, kind, id string) (*LegalHold, error) {
+	var hold LegalHold
+	if _, err := legalHoldSchema.loadRecord(ctx, h.Store, holdKey(kind, id), &hold, 0); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// List returns all active holds
+func (h *LegalHolds) List(ctx context.Context) ([]LegalHold, error) {
+	keys, err := h.Store.Keys(ctx, "hold:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legal holds: %w", err)
+	}
+	holds := make([]LegalHold, 0, len(keys))
+	for _, key := range keys {
+		var hold LegalHold
+		if _, err := legalHoldSchema.loadRecord(ctx, h.Store, key, &hold, 0); errors.Is(err, ErrKeyNotFound) {
+			continue
+		} else if err != nil {
+			logger.Warnf("Skipping unreadable legal hold %s: %v", key, err)
+			continue
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
+
+// Held returns the hold covering a user or chat, if any. Lookup errors are
+// treated as held so that a store outage never lets held data be deleted.
+func (h *LegalHolds) Held(ctx context.Context, userID, chatID string) (*LegalHold, bool) {
+	for _, subject := range [][2]string{{HoldUser, userID}, {HoldChat, chatID}} {
+		if subject[1] == "" {
+			continue
+		}
+		hold, err := h.Get(ctx, subject[0], subject[1])
+		if err == nil {
+			return hold, true
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			logger.Errorf("Legal hold lookup for %s:%s failed, assuming held: %v", subject[0], subject[1], err)
+			return &LegalHold{Kind: subject[0], ID: subject[1]}, true
+		}
+	}
+	return nil, false
+}
+
+// CheckDeletion returns ErrLegalHold if data of userID or chatID must not be
+// deleted. GC and GDPR erasure jobs call it before deleting anything; a
+// blocked deletion is audited.
+func (h *LegalHolds) CheckDeletion(ctx context.Context, actor, userID, chatID, sessionID string) error {
+	hold, held := h.Held(ctx, userID, chatID)
+	if !held {
+		return nil
+	}
+	h.record(ctx, actor, "legal_hold.deletion_blocked", hold.Subject(), map[string]string{"session_id": sessionID})
+	return fmt.Errorf("%w: %s", ErrLegalHold, hold.Subject())
+}
+
+// CheckSession is CheckDeletion for the user and chat owning sessionID
+func (h *LegalHolds) CheckSession(ctx context.Context, actor, sessionID string) error {
+	userID, chatID := h.subjects(sessionID)
+	return h.CheckDeletion(ctx, actor, userID, chatID, sessionID)
+}
+
+// subjects returns the user and chat owning sessionID, if known
+func (h *LegalHolds) subjects(sessionID string) (userID, chatID string) {
+	if h.Subjects == nil {
+		return "", ""
+	}
+	return h.Subjects(sessionID)
+}
+
+// RecordAccess audits a read of conversation data if the user or chat is held
+func (h *LegalHolds) RecordAccess(ctx context.Context, actor, userID, chatID, sessionID, action string) {
+	if hold, held := h.Held(ctx, userID, chatID); held {
+		h.record(ctx, actor, action, hold.Subject(), map[string]string{"session_id": sessionID})
+	}
+}
+
+// record writes an audit event, logging (not failing on) audit errors
+func (h *LegalHolds) record(ctx context.Context, actor, action, subject string, detail map[string]string) {
+	event := AuditEvent{Time: time.Now().UTC(), Actor: actor, Action: action, Subject: subject, Detail: detail}
+	if err := h.Audit.Record(ctx, event); err != nil {
+		logger.Errorf("Failed to write audit event %s on %s: %v", action, subject, err)
+	}
+}
+
+// holdKey is the store key of a legal hold
+func holdKey(kind, id string) string {
+	return "hold:" + kind + ":" + id
+}
+
+// SessionSubjects maps a session to the user and chat owning it
+type SessionSubjects func(sessionID string) (userID, chatID string)
+
+// heldService is an AgnoService refusing to clear sessions under legal hold
+type heldService struct {
+	AgnoService
+	holds    *LegalHolds
+	subjects SessionSubjects
+}
+
+// Protect wraps svc so that ClearSession fails with ErrLegalHold for
+// sessions of held users or chats. Use the wrapped service for GC (e.g.
+// ThreadJanitor) and /clear handling.
+func (h *LegalHolds) Protect(svc AgnoService, subjects SessionSubjects) AgnoService {
+	return &heldService{AgnoService: svc, holds: h, subjects: subjects}
+}
+
+// ClearSession implements AgnoService
+func (s *heldService) ClearSession(sessionID string) error {
+	return s.ClearSessionContext(context.Background(), sessionID)
+}
+
+// ClearSessionContext implements AgnoService
+func (s *heldService) ClearSessionContext(ctx context.Context, sessionID string) error {
+	userID, chatID := s.subjects(sessionID)
+	if err := s.holds.CheckDeletion(ctx, "system", userID, chatID, sessionID); err != nil {
+		return err
+	}
+	return s.AgnoService.ClearSessionContext(ctx, sessionID)
+}
+
+// heldKeyPrefixes are the store key prefixes of conversation data, each
+// followed by the session ID
+var heldKeyPrefixes = []string{"chathistory:", "attachments:"}
+
+// heldStore is a SessionStore keeping the conversation data of held sessions
+type heldStore struct {
+	SessionStore
+	holds *LegalHolds
+}
+
+// ProtectStore wraps store so that the conversation data of sessions under
+// legal hold (chathistory: and attachments: keys) is neither deleted nor
+// left to expire: Delete fails with ErrLegalHold and Set drops the TTL.
+// Use the wrapped store for HistoryManager and erasure jobs. Records kept
+// this way expire again with their first write after the hold is released.
+func (h *LegalHolds) ProtectStore(store SessionStore) SessionStore {
+	return &heldStore{SessionStore: store, holds: h}
+}
+
+// Set implements SessionStore
+func (s *heldStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if sessionID, ok := heldSessionKey(key); ok && ttl > 0 {
+		userID, chatID := s.holds.subjects(sessionID)
+		if _, held := s.holds.Held(ctx, userID, chatID); held {
+			ttl = 0
+		}
+	}
+	return s.SessionStore.Set(ctx, key, value, ttl)
+}
+
+// Delete implements SessionStore
+func (s *heldStore) Delete(ctx context.Context, key string) error {
+	if sessionID, ok := heldSessionKey(key); ok {
+		if err := s.holds.CheckSession(ctx, "system", sessionID); err != nil {
+			return err
+		}
+	}
+	return s.SessionStore.Delete(ctx, key)
+}
+
+// heldSessionKey returns the session of a conversation data key
+func heldSessionKey(key string) (string, bool) {
+	for _, prefix := range heldKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return strings.TrimPrefix(key, prefix), true
+		}
+	}
+	return "", false
+}
+
+// AdminHandler serves the legal hold admin API:
+//
+//	GET    /holds                                list holds
+//	POST   /holds                                place a hold ({"kind","id","matter","reason"})
+//	GET    /holds/{kind}/{id}                    get a hold
+//	DELETE /holds/{kind}/{id}                    release a hold
+//	GET    /holds/transcripts/{session}?format=  export a transcript (audited)
+//
+// Requests must be signed with secret (see VerifyRequest) and name the
+// acting admin in the X-Agno-Actor header. client may be nil to disable
+// transcript export; subjects maps sessions to their user and chat.
+// It returns ErrMissingSecret when secret is empty.
+func (h *LegalHolds) AdminHandler(secret []byte, client *AgnoClient, subjects SessionSubjects) (http.Handler, error) {
+	if err := checkSecret("LegalHolds.AdminHandler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected legal hold admin request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		actor := r.Header.Get("X-Agno-Actor")
+		if actor == "" {
+			http.Error(w, "X-Agno-Actor header is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/holds"), "/"), "/")
+		switch {
+		case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodGet:
+			holds, err := h.List(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			h.record(ctx, actor, "legal_hold.list", "*", nil)
+			writeJSON(w, http.StatusOK, holds)
+
+		case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
+			}
+			var hold LegalHold
+			if err := json.Unmarshal(body, &hold); err != nil {
+				http.Error(w, "invalid legal hold", http.StatusBadRequest)
+				return
+			}
+			if err := h.Place(ctx, actor, hold); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+
+		case len(parts) == 2 && parts[0] == "transcripts" && r.Method == http.MethodGet:
+			if client == nil {
+				http.Error(w, "transcript export is disabled", http.StatusNotFound)
+				return
+			}
+			h.exportTranscript(w, r, client, subjects, actor, parts[1], r.URL.Query().Get("format"))
+
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			hold, err := h.Get(ctx, parts[0], parts[1])
+			if errors.Is(err, ErrKeyNotFound) {
+				http.Error(w, "no legal hold", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			h.record(ctx, actor, "legal_hold.get", hold.Subject(), nil)
+			writeJSON(w, http.StatusOK, hold)
+
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			err := h.Release(ctx, actor, parts[0], parts[1])
+			if errors.Is(err, ErrKeyNotFound) {
+				http.Error(w, "no legal hold", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}), nil
+}
+
+// exportTranscript writes a session transcript and audits the access
+func (h *LegalHolds) exportTranscript(w http.ResponseWriter, r *http.Request, client *AgnoClient, subjects SessionSubjects, actor, sessionID, format string) {
+	if format == "" {
+		format = ExportJSON
+	}
+	userID, chatID := "", ""
+	if subjects != nil {
+		userID, chatID = subjects(sessionID)
+	}
+	subject := "session:" + sessionID
+	if hold, held := h.Held(r.Context(), userID, chatID); held {
+		subject = hold.Subject()
+	}
+	h.record(r.Context(), actor, "transcript.export", subject, map[string]string{"session_id": sessionID, "format": format})
+
+	data, err := client.ExportSessionContext(r.Context(), sessionID, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if format == ExportMarkdown {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(data)
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,177 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Lark event delivery modes
+const (
+	EventModeWebhook   = "webhook"
+	EventModeWebSocket = "websocket"
+)
+
+var (
+	longConnUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "long_connection",
+		Name:      "up",
+		Help:      "Whether the Lark long connection (WebSocket) is established.",
+	})
+
+	longConnReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "long_connection",
+		Name:      "reconnects_total",
+		Help:      "Times the Lark long connection was re-established after dropping.",
+	})
+)
+
+// EventModeFromEnv returns the event delivery mode from AGNO_LARK_EVENT_MODE
+// ("webhook", the default, or "websocket" for deployments without a public
+// ingress)
+func EventModeFromEnv() (string, error) {
+	switch mode := os.Getenv("AGNO_LARK_EVENT_MODE"); mode {
+	case "", EventModeWebhook:
+		return EventModeWebhook, nil
+	case EventModeWebSocket:
+		return EventModeWebSocket, nil
+	default:
+		return "", fmt.Errorf("invalid AGNO_LARK_EVENT_MODE %q: want %s or %s", mode, EventModeWebhook, EventModeWebSocket)
+	}
+}
+
+// LongConnection receives Lark events over Lark's long-connection
+// (WebSocket) mode instead of webhooks. Connect runs the connection, e.g.
+// the Lark SDK's ws client Start with auto-reconnect disabled, and returns
+// when it drops; LongConnection reconnects with exponential backoff.
+// Events passed to Dispatch are deduplicated by Gate and handled in the
+// background, because Lark redelivers events not acknowledged within
+// 3 seconds.
+type LongConnection struct {
+	Connect    func(ctx context.Context) error
+	Gate       *EventGate // optional
+	Handler    EventHandler
+	Workers    int // events handled concurrently
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLongConnection creates a long connection handling up to 16 events at
+// once and reconnecting after 1s, backing off up to a minute
+func NewLongConnection(connect func(ctx context.Context) error, gate *EventGate, handler EventHandler) *LongConnection {
+	return &LongConnection{
+		Connect:    connect,
+		Gate:       gate,
+		Handler:    handler,
+		Workers:    16,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Dispatch accepts the raw payload of a Lark event from the connection and
+// returns at once; the event is handled in the background. Register it as
+// the SDK's event callback.
+func (l *LongConnection) Dispatch(ctx context.Context, payload []byte) error {
+	var envelope struct {
+		Header struct {
+			EventID string `json:"event_id"`
+		} `json:"header"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return fmt.Errorf("failed to parse Lark event: %w", err)
+	}
+
+	l.slots <- struct{}{}
+	l.wg.Add(1)
+	go func() {
+		defer func() {
+			<-l.slots
+			l.wg.Done()
+		}()
+		// Detached from the connection's context: a reconnect must not cancel handling
+		bg := context.Background()
+		var err error
+		if l.Gate != nil {
+			err = l.Gate.Handle(bg, envelope.Header.EventID, payload, l.Handler)
+		} else {
+			err = runHandler(bg, l.Handler, payload)
+		}
+		if err != nil {
+			logger.Errorf("Failed to handle Lark event %s: %v", envelope.Header.EventID, err)
+		}
+	}()
+	return nil
+}
+
+// Start connects in the background, reconnecting until Stop
+func (l *LongConnection) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.slots = make(chan struct{}, l.Workers)
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		l.run(ctx)
+	}()
+}
+
+// Stop closes the connection and waits for events being handled
+func (l *LongConnection) Stop() {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+	l.wg.Wait()
+	l.cancel = nil
+}
+
+// run keeps the connection up until ctx is done
+func (l *LongConnection) run(ctx context.Context) {
+	backoff := l.MinBackoff
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		if attempt > 0 {
+			longConnReconnects.Inc()
+		}
+		logger.Infof("Opening Lark long connection")
+		started := time.Now()
+		longConnUp.Set(1)
+		err := l.Connect(ctx)
+		longConnUp.Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(started) > l.MaxBackoff {
+			backoff = l.MinBackoff
+		}
+		logger.Warnf("Lark long connection dropped, reconnecting in %s: %v", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		if backoff *= 2; backoff > l.MaxBackoff {
+			backoff = l.MaxBackoff
+		}
+	}
+}
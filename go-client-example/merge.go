@@ -0,0 +1,208 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// defaultMergeSummaryPrompt asks for the summary added to a merged session
+const defaultMergeSummaryPrompt = "This conversation is being continued in another chat. " +
+	"Summarize it in a few bullet points, including the topic, decisions and open questions, " +
+	"so the discussion can pick up where it left off."
+
+// MergeResult describes a completed session merge
+type MergeResult struct {
+	Source         string `json:"source"`
+	Target         string `json:"target"`
+	SourceMessages int    `json:"source_messages"`
+	TargetMessages int    `json:"target_messages"`
+	Summary        string `json:"summary"`
+	SourceCleared  bool   `json:"source_cleared"`
+}
+
+// SessionMerger merges one session into another, e.g. when a user
+// continues a DM topic in a group. The histories are concatenated in
+// timestamp order and the source conversation is summarized, then written
+// into the target via the import API. Message timestamps and metadata are
+// kept; merged messages are tagged with "merged_from".
+type SessionMerger struct {
+	Client        *AgnoClient
+	Service       AgnoService // clears the source; wrap with LegalHolds.Protect to honor holds
+	Audit         AuditLog
+	SummaryPrompt string
+}
+
+// NewSessionMerger creates a merger auditing to the application log
+func NewSessionMerger(client *AgnoClient) *SessionMerger {
+	return &SessionMerger{
+		Client:        client,
+		Service:       client,
+		Audit:         LoggerAuditLog{},
+		SummaryPrompt: defaultMergeSummaryPrompt,
+	}
+}
+
+// Merge merges source into target on behalf of actor and optionally clears source
+func (m *SessionMerger) Merge(ctx context.Context, actor, source, target string, clearSource bool) (*MergeResult, error) {
+	if source == "" || target == "" || source == target {
+		return nil, fmt.Errorf("%w: merge needs two different sessions", ErrInvalidRequest)
+	}
+
+	sourceHistory, err := m.Client.GetHistoryContext(ctx, source, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export session %s: %w", source, err)
+	}
+	if len(sourceHistory) == 0 {
+		return nil, fmt.Errorf("%w: session %s is empty", ErrInvalidRequest, source)
+	}
+	targetHistory, err := m.Client.GetHistoryContext(ctx, target, 0)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, fmt.Errorf("failed to export session %s: %w", target, err)
+	}
+
+	summary, err := m.summarize(ctx, target, sourceHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make([]Message, 0, len(sourceHistory)+len(targetHistory)+1)
+	for _, msg := range sourceHistory {
+		metadata := make(map[string]string, len(msg.Metadata)+1)
+		for key, value := range msg.Metadata {
+			metadata[key] = value
+		}
+		metadata["merged_from"] = source
+		msg.Metadata = metadata
+		merged = append(merged, msg)
+	}
+	merged = append(merged, targetHistory...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return messageTime(merged[i]).Before(messageTime(merged[j]))
+	})
+	merged = append(merged, Message{
+		Role:      "system",
+		Content:   fmt.Sprintf("Summary of the conversation merged from session %s:\n%s", source, summary),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Metadata:  map[string]string{"merged_from": source, "kind": "merge_summary"},
+	})
+
+	if err := m.Client.ImportSessionContext(ctx, target, merged, ImportReplace); err != nil {
+		return nil, fmt.Errorf("failed to import merged history into %s: %w", target, err)
+	}
+
+	result := &MergeResult{
+		Source:         source,
+		Target:         target,
+		SourceMessages: len(sourceHistory),
+		TargetMessages: len(targetHistory),
+		Summary:        summary,
+	}
+	if clearSource {
+		if err := m.Service.ClearSessionContext(ctx, source); err != nil {
+			logger.Warnf("Merged session %s into %s but could not clear it: %v", source, target, err)
+		} else {
+			result.SourceCleared = true
+		}
+	}
+
+	event := AuditEvent{
+		Time:    time.Now().UTC(),
+		Actor:   actor,
+		Action:  "session.merge",
+		Subject: target,
+		Detail: map[string]string{
+			"source":          source,
+			"source_messages": fmt.Sprint(result.SourceMessages),
+			"source_cleared":  fmt.Sprint(result.SourceCleared),
+		},
+	}
+	if err := m.Audit.Record(ctx, event); err != nil {
+		logger.Errorf("Failed to write audit event session.merge on %s: %v", target, err)
+	}
+	logger.Infof("%s merged session %s (%d messages) into %s", actor, source, len(sourceHistory), target)
+	return result, nil
+}
+
+// summarize summarizes history in a throwaway session
+func (m *SessionMerger) summarize(ctx context.Context, target string, history []Message) (string, error) {
+	summarySession := target + ":merge"
+	summary, err := m.Client.ChatContext(ctx, summarySession, m.SummaryPrompt, history)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize merged session: %w", err)
+	}
+	if err := m.Client.ClearSessionContext(ctx, summarySession); err != nil {
+		logger.Warnf("Failed to clear merge session %s: %v", summarySession, err)
+	}
+	return summary, nil
+}
+
+// mergeRequest is the body of POST /sessions/merge
+type mergeRequest struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	ClearSource bool   `json:"clear_source"`
+}
+
+// Handler serves POST /sessions/merge ({"source","target","clear_source"})
+// for ops tooling. Requests must be signed with secret (see VerifyRequest)
+// and name the acting operator in the X-Agno-Actor header.
+// It returns ErrMissingSecret when secret is empty.
+func (m *SessionMerger) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("SessionMerger.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected session merge request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		actor := r.Header.Get("X-Agno-Actor")
+		if actor == "" {
+			http.Error(w, "X-Agno-Actor header is required", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		var req mergeRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid merge request", http.StatusBadRequest)
+			return
+		}
+
+		result, err := m.Merge(r.Context(), actor, req.Source, req.Target, req.ClearSource)
+		if errors.Is(err, ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}), nil
+}
+
+// messageTime parses a message timestamp; messages without one sort first
+func messageTime(msg Message) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
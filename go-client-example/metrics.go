@@ -0,0 +1,58 @@
+package agno
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultTenant is the tenant label used when no tenant can be resolved
+const defaultTenant = "default"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "client",
+		Name:      "requests_total",
+		Help:      "Requests sent to the Agno service.",
+	}, []string{"endpoint", "status", "tenant"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "agno",
+		Subsystem: "client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of requests sent to the Agno service.",
+		// Agent runs are slow; cover sub-second health checks up to the 90s client timeout
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 90},
+	}, []string{"endpoint", "status", "tenant"})
+
+	inFlightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "client",
+		Name:      "in_flight_requests",
+		Help:      "Requests to the Agno service currently in flight.",
+	}, []string{"endpoint"})
+
+	serviceUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "service",
+		Name:      "up",
+		Help:      "Whether the last Agno health check succeeded (1) or failed (0).",
+	})
+)
+
+// observeRequest records the outcome of a single request to the Agno service.
+// status is the HTTP status code, or "error" for transport failures.
+func observeRequest(endpoint, status, tenant string, elapsed time.Duration) {
+	requestsTotal.WithLabelValues(endpoint, status, tenant).Inc()
+	requestDuration.WithLabelValues(endpoint, status, tenant).Observe(elapsed.Seconds())
+}
+
+// MetricsHandler returns the Prometheus handler to mount on the bot's HTTP
+// server, e.g. http.Handle("/metrics", agno.MetricsHandler())
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,186 @@
+package agno
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// ErrPayloadTooLarge is returned when a request or response exceeds the size limit
+var ErrPayloadTooLarge = errors.New("agno: payload too large")
+
+// Middleware wraps the transport used for every call to the Agno service
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use appends middlewares to the client. The first middleware added is the
+// outermost one, i.e. it sees the request first and the response last.
+// Use is not safe to call concurrently with requests; configure the client at startup.
+func (c *AgnoClient) Use(mw ...Middleware) {
+	c.middlewares = append(c.middlewares, mw...)
+}
+
+// httpClient returns HTTPClient with the middleware chain applied to its transport
+func (c *AgnoClient) httpClient() *http.Client {
+	if len(c.middlewares) == 0 {
+		return c.HTTPClient
+	}
+
+	transport := c.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		transport = c.middlewares[i](transport)
+	}
+
+	client := *c.HTTPClient
+	client.Transport = transport
+	return &client
+}
+
+// HeaderMiddleware sets fixed headers (e.g. auth or tenant headers) on every request
+func HeaderMiddleware(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// piiPatterns match personal data that must not reach the logs
+var piiPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), // email
+	regexp.MustCompile(`\+?\d[\d\s\-().]{7,}\d`),                           // phone number
+	regexp.MustCompile(`\b(?:\d[ \-]?){13,19}\b`),                          // card number
+	regexp.MustCompile(`(?i)(sk|pk|xai|ghp)[-_][A-Za-z0-9\-_]{16,}`),       // API key
+}
+
+// RedactPII masks emails, phone numbers, card numbers and API keys
+func RedactPII(s string) string {
+	for _, p := range piiPatterns {
+		s = p.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// LoggingMiddleware logs every request and response at debug level, passing
+// payloads through redact first (use RedactPII; nil logs no payloads)
+func LoggingMiddleware(redact func(string) string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if redact != nil && req.GetBody != nil {
+				if body, err := req.GetBody(); err == nil {
+					payload, _ := io.ReadAll(body)
+					body.Close()
+					logger.Debugf("Agno request %s %s: %s", req.Method, req.URL.Path, redact(string(payload)))
+				}
+			}
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logger.Debugf("Agno request %s %s failed after %s: %v", req.Method, req.URL.Path, time.Since(start), err)
+				return nil, err
+			}
+			logger.Debugf("Agno response %s %s: status %d in %s", req.Method, req.URL.Path, resp.StatusCode, time.Since(start))
+
+			// Streaming responses are consumed incrementally; don't buffer them
+			if redact != nil && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+				payload, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					return nil, fmt.Errorf("failed to read response: %w", err)
+				}
+				logger.Debugf("Agno response body: %s", redact(string(payload)))
+				resp.Body = io.NopCloser(bytes.NewReader(payload))
+			}
+			return resp, nil
+		})
+	}
+}
+
+// PayloadLimitMiddleware rejects requests whose body exceeds maxRequest bytes
+// and fails reads of response bodies larger than maxResponse bytes (0 disables a limit)
+func PayloadLimitMiddleware(maxRequest, maxResponse int64) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if maxRequest > 0 && req.ContentLength > maxRequest {
+				return nil, fmt.Errorf("%w: request is %d bytes (limit %d)", ErrPayloadTooLarge, req.ContentLength, maxRequest)
+			}
+			if maxRequest > 0 && req.ContentLength <= 0 && req.Body != nil && req.Body != http.NoBody {
+				// chunked or unknown length (0 with a body): count the bytes as they are sent
+				req = req.Clone(req.Context())
+				req.Body = &limitedBody{ReadCloser: req.Body, remaining: maxRequest}
+				if getBody := req.GetBody; getBody != nil {
+					req.GetBody = func() (io.ReadCloser, error) {
+						body, err := getBody()
+						if err != nil {
+							return nil, err
+						}
+						return &limitedBody{ReadCloser: body, remaining: maxRequest}, nil
+					}
+				}
+				resp, err := next.RoundTrip(req)
+				if errors.Is(err, ErrPayloadTooLarge) {
+					return nil, fmt.Errorf("%w: request exceeds %d bytes", ErrPayloadTooLarge, maxRequest)
+				}
+				if err != nil || maxResponse <= 0 {
+					return resp, err
+				}
+				return limitResponse(resp, maxResponse)
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || maxResponse <= 0 {
+				return resp, err
+			}
+			return limitResponse(resp, maxResponse)
+		})
+	}
+}
+
+// limitResponse fails responses larger than maxResponse bytes, up front when
+// the length is known and while reading otherwise
+func limitResponse(resp *http.Response, maxResponse int64) (*http.Response, error) {
+	if resp.ContentLength > maxResponse {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: response is %d bytes (limit %d)", ErrPayloadTooLarge, resp.ContentLength, maxResponse)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: maxResponse}
+	return resp, nil
+}
+
+// limitedBody errors once more than the allowed number of bytes has been read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// Read implements io.Reader
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, ErrPayloadTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,126 @@
+package agno
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Model tiers chosen by ModelRouter
+const (
+	TierFast    = "fast"
+	TierPremium = "premium"
+)
+
+// complexHints are phrases that usually call for reasoning rather than lookup
+var complexHints = []string{
+	"step by step", "explain why", "compare", "trade-off", "tradeoff", "analyze", "analyse",
+	"design", "architecture", "refactor", "debug", "prove", "calculate", "optimi",
+}
+
+// codePattern matches code fences and common code-like constructs
+var codePattern = regexp.MustCompile("```|\\bfunc\\s+\\w+\\(|\\bdef\\s+\\w+\\(|\\bclass\\s+\\w+|=>|;\\s*$|\\bSELECT\\b.+\\bFROM\\b|Traceback|panic:|Exception")
+
+var modelRoutes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Name:      "model_routes_total",
+	Help:      "Chat requests routed to each model tier.",
+}, []string{"tier"})
+
+// ComplexityClassifier is an optional tiny classifier deciding whether a
+// question needs the premium model
+type ComplexityClassifier interface {
+	Complex(ctx context.Context, message string) (bool, error)
+}
+
+// ModelRouter sends simple questions to a fast, cheap model and complex ones
+// (long prompts, code, attachments, long conversations) to the premium model
+type ModelRouter struct {
+	Client       AgnoService
+	FastModel    string
+	PremiumModel string
+
+	LongMessage int                  // messages with at least this many runes are complex
+	LongHistory int                  // conversations with at least this many messages are complex
+	Classifier  ComplexityClassifier // consulted when the heuristic finds nothing (optional)
+}
+
+// NewModelRouterFromEnv reads the models from AGNO_FAST_MODEL (default
+// gpt-4o-mini) and AGNO_PREMIUM_MODEL (default gpt-4o)
+func NewModelRouterFromEnv(client AgnoService) *ModelRouter {
+	fast := os.Getenv("AGNO_FAST_MODEL")
+	if fast == "" {
+		fast = "gpt-4o-mini"
+	}
+	premium := os.Getenv("AGNO_PREMIUM_MODEL")
+	if premium == "" {
+		premium = "gpt-4o"
+	}
+	return &ModelRouter{
+		Client:       client,
+		FastModel:    fast,
+		PremiumModel: premium,
+		LongMessage:  400,
+		LongHistory:  12,
+	}
+}
+
+// Tier classifies a request; attachments is the number of files or images
+// sent with the question
+func (r *ModelRouter) Tier(ctx context.Context, req ChatRequest, attachments int) string {
+	message := req.Message
+	lower := strings.ToLower(message)
+	switch {
+	case attachments > 0,
+		utf8.RuneCountInString(message) >= r.LongMessage,
+		r.LongHistory > 0 && len(req.History) >= r.LongHistory,
+		codePattern.MatchString(message):
+		return TierPremium
+	}
+	for _, hint := range complexHints {
+		if strings.Contains(lower, hint) {
+			return TierPremium
+		}
+	}
+
+	if r.Classifier != nil {
+		complex, err := r.Classifier.Complex(ctx, message)
+		if err != nil {
+			logger.Warnf("Complexity classifier failed, using premium model: %v", err)
+			return TierPremium
+		}
+		if complex {
+			return TierPremium
+		}
+	}
+	return TierFast
+}
+
+// Route sets req.Model according to the request's tier unless a model is
+// already set, and returns the tier
+func (r *ModelRouter) Route(ctx context.Context, req *ChatRequest, attachments int) string {
+	if req.Model != "" {
+		return ""
+	}
+	tier := r.Tier(ctx, *req, attachments)
+	req.Model = r.FastModel
+	if tier == TierPremium {
+		req.Model = r.PremiumModel
+	}
+	modelRoutes.WithLabelValues(tier).Inc()
+	logger.Debugf("Routing session %s to %s model %s", req.SessionID, tier, req.Model)
+	return tier
+}
+
+// Chat routes and sends a request
+func (r *ModelRouter) Chat(ctx context.Context, req ChatRequest, attachments int) (*ChatResponse, error) {
+	r.Route(ctx, &req, attachments)
+	return r.Client.SendChat(ctx, req)
+}
@@ -0,0 +1,305 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// ErrModerated is returned when moderation blocks a prompt
+var ErrModerated = errors.New("agno: blocked by content moderation")
+
+// ModeratedNotice replaces a model answer blocked by output moderation
+const ModeratedNotice = "🤖️: The answer was withheld by content moderation."
+
+// Moderation stages
+const (
+	ModerationInput  = "input"
+	ModerationOutput = "output"
+)
+
+// ModerationAction is what moderation does with a piece of text
+type ModerationAction string
+
+const (
+	ModerationAllow  ModerationAction = "allow"
+	ModerationRedact ModerationAction = "redact"
+	ModerationBlock  ModerationAction = "block"
+)
+
+// ModerationVerdict is the outcome of moderating a text
+type ModerationVerdict struct {
+	Action     ModerationAction `json:"action"`
+	Text       string           `json:"text,omitempty"` // redacted text when Action is ModerationRedact
+	Categories []string         `json:"categories,omitempty"`
+}
+
+// Moderator checks prompts before they are sent (ModerationInput) and
+// answers before they reach a Lark chat (ModerationOutput)
+type Moderator interface {
+	Moderate(ctx context.Context, stage, text string) (ModerationVerdict, error)
+}
+
+var moderationActions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "moderation",
+	Name:      "actions_total",
+	Help:      "Texts redacted or blocked by content moderation.",
+}, []string{"stage", "action", "category"})
+
+// ModerationRule is a named pattern and what to do when it matches
+type ModerationRule struct {
+	Category string
+	Stage    string // ModerationInput, ModerationOutput or "" for both
+	Regex    *regexp.Regexp
+	Action   ModerationAction
+}
+
+// DefaultModerationRules block secrets in prompts and redact personal data
+// in both directions
+var DefaultModerationRules = []ModerationRule{
+	{"secret", ModerationInput, regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`), ModerationBlock},
+	{"secret", ModerationInput, regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), ModerationBlock},
+	{"secret", ModerationInput, regexp.MustCompile(`(?i)(sk|pk|xai|ghp|xox[abp])[-_][A-Za-z0-9\-_]{16,}`), ModerationBlock},
+	{"secret", ModerationInput, regexp.MustCompile(`(?i)\b(password|passwd|pwd)\s*[:=]\s*\S{6,}`), ModerationBlock},
+	{"pii", "", piiPatterns[0], ModerationRedact}, // email
+	{"pii", "", piiPatterns[1], ModerationRedact}, // phone number
+	{"pii", "", piiPatterns[2], ModerationRedact}, // card number
+}
+
+// KeywordModerator is a regex/keyword Moderator
+type KeywordModerator struct {
+	Rules []ModerationRule
+}
+
+// NewKeywordModerator creates a moderator with DefaultModerationRules plus
+// a block rule for each keyword in blocked (matched case-insensitively on
+// word boundaries, in both stages)
+func NewKeywordModerator(blocked ...string) *KeywordModerator {
+	rules := append([]ModerationRule(nil), DefaultModerationRules...)
+	for _, keyword := range blocked {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			rules = append(rules, ModerationRule{
+				Category: "keyword",
+				Regex:    regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(keyword) + `\b`),
+				Action:   ModerationBlock,
+			})
+		}
+	}
+	return &KeywordModerator{Rules: rules}
+}
+
+// Moderate implements Moderator
+func (m *KeywordModerator) Moderate(ctx context.Context, stage, text string) (ModerationVerdict, error) {
+	verdict := ModerationVerdict{Action: ModerationAllow, Text: text}
+	for _, rule := range m.Rules {
+		if rule.Stage != "" && rule.Stage != stage {
+			continue
+		}
+		if !rule.Regex.MatchString(verdict.Text) {
+			continue
+		}
+		verdict.Categories = append(verdict.Categories, rule.Category)
+		switch rule.Action {
+		case ModerationBlock:
+			verdict.Action = ModerationBlock
+			return verdict, nil
+		case ModerationRedact:
+			verdict.Action = ModerationRedact
+			verdict.Text = rule.Regex.ReplaceAllString(verdict.Text, "[REDACTED]")
+		}
+	}
+	return verdict, nil
+}
+
+// ServiceModerator delegates to the Agno service's POST /moderate endpoint
+type ServiceModerator struct {
+	Client *AgnoClient
+}
+
+// moderateRequest is the body of POST /moderate
+type moderateRequest struct {
+	Stage string `json:"stage"`
+	Text  string `json:"text"`
+}
+
+// Moderate implements Moderator
+func (m *ServiceModerator) Moderate(ctx context.Context, stage, text string) (ModerationVerdict, error) {
+	jsonData, err := json.Marshal(moderateRequest{Stage: stage, Text: text})
+	if err != nil {
+		return ModerationVerdict{}, fmt.Errorf("failed to marshal moderation request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", m.Client.BaseURL+"/moderate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return ModerationVerdict{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, body, err := m.Client.doRequest(req, "moderate", "")
+	if err != nil {
+		return ModerationVerdict{}, err
+	}
+	if statusCode != http.StatusOK {
+		return ModerationVerdict{}, newAPIError(statusCode, body)
+	}
+
+	var verdict ModerationVerdict
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return ModerationVerdict{}, fmt.Errorf("failed to unmarshal moderation response: %w", err)
+	}
+	if verdict.Action == "" {
+		verdict.Action = ModerationAllow
+	}
+	if verdict.Action != ModerationRedact {
+		verdict.Text = text
+	}
+	return verdict, nil
+}
+
+// ChatModeration applies a Moderator per chat around agent calls. Chats
+// without their own moderator use Default; a nil moderator disables
+// moderation for a chat.
+type ChatModeration struct {
+	Client   AgnoService
+	Default  Moderator
+	FailOpen bool // send/deliver text unmoderated when the moderator errors
+
+	mu    sync.RWMutex
+	chats map[string]Moderator
+}
+
+// NewChatModeration creates per-chat moderation with def as the default moderator
+func NewChatModeration(client AgnoService, def Moderator) *ChatModeration {
+	return &ChatModeration{Client: client, Default: def, chats: make(map[string]Moderator)}
+}
+
+// NewChatModerationFromEnv configures moderation from AGNO_MODERATION (the
+// default: keyword, service or off), AGNO_MODERATION_KEYWORDS
+// (comma-separated blocked keywords) and AGNO_MODERATION_CHATS, a
+// comma-separated list of chat=mode overrides, e.g. "oc_123=service,oc_456=off"
+func NewChatModerationFromEnv(client *AgnoClient) *ChatModeration {
+	keyword := NewKeywordModerator(strings.Split(os.Getenv("AGNO_MODERATION_KEYWORDS"), ",")...)
+	modes := map[string]Moderator{
+		"keyword": keyword,
+		"service": &ServiceModerator{Client: client},
+		"off":     nil,
+	}
+
+	def, ok := modes[os.Getenv("AGNO_MODERATION")]
+	if !ok {
+		def = keyword
+	}
+	m := NewChatModeration(client, def)
+	for _, entry := range strings.Split(os.Getenv("AGNO_MODERATION_CHATS"), ",") {
+		chatID, mode, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		moderator, ok := modes[strings.TrimSpace(mode)]
+		if !ok {
+			if entry != "" {
+				logger.Warnf("Ignoring malformed AGNO_MODERATION_CHATS entry %q", entry)
+			}
+			continue
+		}
+		m.SetChat(strings.TrimSpace(chatID), moderator)
+	}
+	return m
+}
+
+// SetChat configures the moderator of a chat (nil disables moderation there)
+func (m *ChatModeration) SetChat(chatID string, moderator Moderator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chats[chatID] = moderator
+}
+
+// For returns the moderator of a chat
+func (m *ChatModeration) For(chatID string) Moderator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if moderator, ok := m.chats[chatID]; ok {
+		return moderator
+	}
+	return m.Default
+}
+
+// Chat moderates req's message, sends it and moderates the answer. A
+// blocked prompt fails with ErrModerated; a blocked answer is replaced by
+// ModeratedNotice.
+func (m *ChatModeration) Chat(ctx context.Context, chatID string, req ChatRequest) (*ChatResponse, error) {
+	moderator := m.For(chatID)
+	if moderator == nil {
+		return m.Client.SendChat(ctx, req)
+	}
+
+	text, err := m.apply(ctx, moderator, chatID, ModerationInput, req.Message)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Parts) > 0 {
+		// text parts carry the prompt too, so moderate them like the message
+		parts := make([]ContentPart, len(req.Parts))
+		for i, part := range req.Parts {
+			if part.Type == PartText {
+				if part.Text == req.Message {
+					part.Text = text
+				} else if part.Text, err = m.apply(ctx, moderator, chatID, ModerationInput, part.Text); err != nil {
+					return nil, err
+				}
+			}
+			parts[i] = part
+		}
+		req.Parts = parts
+	}
+	req.Message = text
+
+	resp, err := m.Client.SendChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err = m.apply(ctx, moderator, chatID, ModerationOutput, resp.Response)
+	if errors.Is(err, ErrModerated) {
+		text, err = ModeratedNotice, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Response = text
+	return resp, nil
+}
+
+// apply moderates text for one stage and returns the text to use
+func (m *ChatModeration) apply(ctx context.Context, moderator Moderator, chatID, stage, text string) (string, error) {
+	verdict, err := moderator.Moderate(ctx, stage, text)
+	if err != nil {
+		if m.FailOpen {
+			logger.Warnf("Moderation of %s in chat %s failed, passing it through: %v", stage, chatID, err)
+			return text, nil
+		}
+		return "", fmt.Errorf("%w: moderator unavailable: %v", ErrModerated, err)
+	}
+
+	if verdict.Action == ModerationAllow {
+		return text, nil
+	}
+	for _, category := range verdict.Categories {
+		moderationActions.WithLabelValues(stage, string(verdict.Action), category).Inc()
+	}
+	logger.Infof("Moderation %s %s in chat %s (%s)", verdict.Action, stage, chatID, strings.Join(verdict.Categories, ","))
+
+	if verdict.Action == ModerationBlock {
+		return "", fmt.Errorf("%w: %s", ErrModerated, strings.Join(verdict.Categories, ","))
+	}
+	return verdict.Text, nil
+}
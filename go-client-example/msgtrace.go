@@ -0,0 +1,294 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"start-feishubot/logger"
+)
+
+// Processing stages of a message, in pipeline order
+const (
+	StageEvent  = "event"  // the Lark event was received
+	StageQueue  = "queue"  // waited for earlier messages of the session
+	StageAgno   = "agno"   // the agent call
+	StageRender = "render" // building the reply
+	StageSend   = "send"   // posting the reply to Lark
+)
+
+// traceStages lists the stages in pipeline order
+var traceStages = []string{StageEvent, StageQueue, StageAgno, StageRender, StageSend}
+
+// messageTracePrefix prefixes the keys of stored trace marks
+const messageTracePrefix = "msgtrace:"
+
+// TraceMark records one stage of processing a message
+type TraceMark struct {
+	MessageID  string            `json:"message_id"`
+	Stage      string            `json:"stage"`
+	Start      time.Time         `json:"start"`
+	DurationMS int64             `json:"duration_ms"`
+	Error      string            `json:"error,omitempty"`
+	SessionID  string            `json:"session_id,omitempty"`
+	TraceID    string            `json:"trace_id,omitempty"` // OpenTelemetry trace, for the tracing backend
+	Detail     map[string]string `json:"detail,omitempty"`
+}
+
+// MessageTracer stores the processing timeline of each incoming Lark
+// message, so support can see where a message that got no answer stopped
+// (see botctl trace). Marks are kept for TTL.
+type MessageTracer struct {
+	Store SessionStore
+	TTL   time.Duration
+}
+
+// NewMessageTracer creates a tracer keeping timelines for 7 days
+func NewMessageTracer(store SessionStore) *MessageTracer {
+	return &MessageTracer{Store: store, TTL: 7 * 24 * time.Hour}
+}
+
+// Record stores a mark; marks of a message are ordered by start time
+func (t *MessageTracer) Record(ctx context.Context, mark TraceMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trace mark: %w", err)
+	}
+	key := fmt.Sprintf("%s%s:%020d:%s", messageTracePrefix, mark.MessageID, mark.Start.UnixNano(), mark.Stage)
+	if err := t.Store.Set(ctx, key, data, t.TTL); err != nil {
+		return fmt.Errorf("failed to store trace mark: %w", err)
+	}
+	return nil
+}
+
+// Marks returns the recorded marks of a message in start order
+func (t *MessageTracer) Marks(ctx context.Context, messageID string) ([]TraceMark, error) {
+	keys, err := t.Store.Keys(ctx, messageTracePrefix+messageID+":")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trace marks: %w", err)
+	}
+	marks := make([]TraceMark, 0, len(keys))
+	for _, key := range keys {
+		data, err := t.Store.Get(ctx, key)
+		if err != nil {
+			continue // expired since listing
+		}
+		var mark TraceMark
+		if err := json.Unmarshal(data, &mark); err != nil {
+			logger.Warnf("Skipping unreadable trace mark %s: %v", key, err)
+			continue
+		}
+		marks = append(marks, mark)
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].Start.Before(marks[j].Start) })
+	return marks, nil
+}
+
+// messageTraceKey is the context key carrying the traced message
+type messageTraceKey struct{}
+
+// messageTrace is the message carried by WithMessageTrace
+type messageTrace struct {
+	tracer    *MessageTracer
+	messageID string
+	sessionID string
+}
+
+// WithMessageTrace returns a context whose processing stages are recorded
+// for the Lark message messageID. The session queue, the client and the
+// outbox record their stages on their own; the bot records the event and
+// render stages with TraceStage.
+func WithMessageTrace(ctx context.Context, tracer *MessageTracer, messageID, sessionID string) context.Context {
+	if tracer == nil || messageID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, messageTraceKey{}, messageTrace{tracer: tracer, messageID: messageID, sessionID: sessionID})
+}
+
+// TraceStage starts timing a stage of the message traced by ctx. Call the
+// returned function when the stage ends, with its error and optional
+// details; it does nothing when ctx is not traced.
+func TraceStage(ctx context.Context, stage string) func(err error, detail map[string]string) {
+	mt, ok := ctx.Value(messageTraceKey{}).(messageTrace)
+	if !ok {
+		return func(error, map[string]string) {}
+	}
+	start := time.Now()
+	return func(err error, detail map[string]string) {
+		mark := TraceMark{
+			MessageID:  mt.messageID,
+			Stage:      stage,
+			Start:      start.UTC(),
+			DurationMS: time.Since(start).Milliseconds(),
+			SessionID:  mt.sessionID,
+			Detail:     detail,
+		}
+		if err != nil {
+			mark.Error = err.Error()
+		}
+		if sc := trace.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+			mark.TraceID = sc.TraceID().String()
+		}
+		// Tracing must never fail the message; the marks outlive ctx
+		if err := mt.tracer.Record(context.WithoutCancel(ctx), mark); err != nil {
+			logger.Warnf("Failed to record %s stage of message %s: %v", stage, mt.messageID, err)
+		}
+	}
+}
+
+// agnoTraceDetail describes an agent call in its trace mark
+func agnoTraceDetail(req ChatRequest, resp *ChatResponse) map[string]string {
+	detail := make(map[string]string)
+	if req.AgentID != "" {
+		detail["agent"] = req.AgentID
+	}
+	if resp == nil {
+		return detail
+	}
+	if resp.Usage != nil && resp.Usage.Model != "" {
+		detail["model"] = resp.Usage.Model
+	}
+	if resp.MessageID != "" {
+		detail["agent_message_id"] = resp.MessageID
+	}
+	if resp.Cached {
+		detail["cached"] = "true"
+	}
+	if resp.Degraded {
+		detail["degraded"] = "true"
+	}
+	return detail
+}
+
+// TraceReport is the processing timeline of one message
+type TraceReport struct {
+	MessageID string      `json:"message_id"`
+	SessionID string      `json:"session_id,omitempty"`
+	TraceIDs  []string    `json:"trace_ids,omitempty"`
+	Marks     []TraceMark `json:"marks"`
+	Missing   []string    `json:"missing,omitempty"` // stages with no mark
+	Verdict   string      `json:"verdict"`
+}
+
+// Report assembles the timeline of a message and a one-line verdict on
+// where it stopped
+func (t *MessageTracer) Report(ctx context.Context, messageID string) (*TraceReport, error) {
+	marks, err := t.Marks(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(marks) == 0 {
+		return nil, fmt.Errorf("no trace recorded for message %s (never received, not traced, or older than %s)", messageID, t.TTL)
+	}
+
+	report := &TraceReport{MessageID: messageID, Marks: marks}
+	seen := make(map[string]bool)
+	traceIDs := make(map[string]bool)
+	for _, mark := range marks {
+		seen[mark.Stage] = true
+		if mark.SessionID != "" {
+			report.SessionID = mark.SessionID
+		}
+		if mark.TraceID != "" && !traceIDs[mark.TraceID] {
+			traceIDs[mark.TraceID] = true
+			report.TraceIDs = append(report.TraceIDs, mark.TraceID)
+		}
+	}
+	for _, stage := range traceStages {
+		if !seen[stage] {
+			report.Missing = append(report.Missing, stage)
+		}
+	}
+	report.Verdict = traceVerdict(marks, seen)
+	return report, nil
+}
+
+// traceVerdict summarizes a timeline: answered, failed at a stage, or
+// stopped after the last recorded stage
+func traceVerdict(marks []TraceMark, seen map[string]bool) string {
+	var sent *TraceMark
+	for i := range marks {
+		if marks[i].Stage == StageSend && marks[i].Error == "" {
+			sent = &marks[i]
+		}
+	}
+	total := traceEnd(marks).Sub(marks[0].Start).Round(time.Millisecond)
+	if sent != nil {
+		return fmt.Sprintf("answered in %s", total)
+	}
+	last := marks[len(marks)-1]
+	if last.Error != "" {
+		return fmt.Sprintf("failed at %s: %s", last.Stage, last.Error)
+	}
+	for _, stage := range traceStages {
+		if !seen[stage] && stage != StageQueue && stageIndex(stage) > stageIndex(last.Stage) {
+			return fmt.Sprintf("stopped after %s: no %s recorded", last.Stage, stage)
+		}
+	}
+	return fmt.Sprintf("stopped after %s", last.Stage)
+}
+
+// stageIndex returns the position of a stage in the pipeline
+func stageIndex(stage string) int {
+	for i, s := range traceStages {
+		if s == stage {
+			return i
+		}
+	}
+	return len(traceStages)
+}
+
+// traceEnd returns when the last stage of a timeline ended
+func traceEnd(marks []TraceMark) time.Time {
+	var end time.Time
+	for _, mark := range marks {
+		if e := mark.Start.Add(time.Duration(mark.DurationMS) * time.Millisecond); e.After(end) {
+			end = e
+		}
+	}
+	return end
+}
+
+// Format writes the report as a human-readable timeline
+func (r *TraceReport) Format(w io.Writer) {
+	fmt.Fprintf(w, "Message  %s\n", r.MessageID)
+	if r.SessionID != "" {
+		fmt.Fprintf(w, "Session  %s\n", r.SessionID)
+	}
+	if len(r.TraceIDs) > 0 {
+		fmt.Fprintf(w, "Traces   %s\n", strings.Join(r.TraceIDs, ", "))
+	}
+	fmt.Fprintf(w, "Started  %s\n\n", r.Marks[0].Start.Format(time.RFC3339Nano))
+
+	origin := r.Marks[0].Start
+	for _, mark := range r.Marks {
+		outcome := "ok"
+		if mark.Error != "" {
+			outcome = "ERROR " + mark.Error
+		}
+		fmt.Fprintf(w, "  +%-9s %-7s %8s  %s", mark.Start.Sub(origin).Round(time.Millisecond),
+			mark.Stage, (time.Duration(mark.DurationMS) * time.Millisecond).String(), outcome)
+		if len(mark.Detail) > 0 {
+			keys := make([]string, 0, len(mark.Detail))
+			for key := range mark.Detail {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(w, " %s=%s", key, mark.Detail[key])
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(w, "\nNo marks for: %s\n", strings.Join(r.Missing, ", "))
+	}
+	fmt.Fprintf(w, "\nVerdict: %s\n", r.Verdict)
+}
@@ -0,0 +1,99 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// Address families for dialing the service and binding servers, as Go
+// network names
+const (
+	FamilyDual = "tcp"  // IPv4 and IPv6 (Happy Eyeballs when dialing)
+	FamilyIPv4 = "tcp4" // IPv4 only
+	FamilyIPv6 = "tcp6" // IPv6 only, e.g. on IPv6-only clusters
+)
+
+// ParseAddressFamily parses an address family: "dual" (or ""), "ipv4" or
+// "ipv6"; Go network names and "4"/"6" are accepted too
+func ParseAddressFamily(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "dual", "tcp":
+		return FamilyDual, nil
+	case "ipv4", "4", "tcp4":
+		return FamilyIPv4, nil
+	case "ipv6", "6", "tcp6":
+		return FamilyIPv6, nil
+	default:
+		return "", fmt.Errorf("invalid address family %q: want dual, ipv4 or ipv6", s)
+	}
+}
+
+// AddressFamilyFromEnv returns the family set in AGNO_IP_FAMILY, falling
+// back to dual-stack when it is unset or invalid
+func AddressFamilyFromEnv() string {
+	family, err := ParseAddressFamily(os.Getenv("AGNO_IP_FAMILY"))
+	if err != nil {
+		logger.Warnf("Ignoring AGNO_IP_FAMILY: %v", err)
+		return FamilyDual
+	}
+	return family
+}
+
+// FamilyDialer wraps dial so TCP connections use family; other networks
+// (e.g. unix sockets) are left alone
+func FamilyDialer(family string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if family == FamilyDual {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network == "tcp" {
+			network = family
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// Listen binds addr for family. An empty host (":8080") binds all
+// addresses, which is dual-stack ([::]) when family is FamilyDual; a
+// literal address of the other family is rejected instead of failing
+// obscurely at bind time.
+func Listen(addr, family string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if (family == FamilyIPv4 && ip.To4() == nil) || (family == FamilyIPv6 && ip.To4() != nil) {
+			return nil, fmt.Errorf("listen address %q does not match address family %s", addr, family)
+		}
+	}
+	ln, err := net.Listen(family, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s (%s): %w", addr, family, err)
+	}
+	return ln, nil
+}
+
+// ListenAndServe serves handler (e.g. the admin and metrics handlers) on
+// addr, binding the family from AGNO_IP_FAMILY. It returns nil after
+// Shutdown.
+func ListenAndServe(addr string, handler http.Handler) error {
+	ln, err := Listen(addr, AddressFamilyFromEnv())
+	if err != nil {
+		return err
+	}
+	logger.Infof("Listening on %s", ln.Addr())
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package agno
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Option configures a client built by NewAgnoClientWithOptions
+type Option func(*clientOptions) error
+
+// clientOptions collects the settings applied by Options
+type clientOptions struct {
+	baseURL    string
+	timeout    time.Duration
+	httpClient *http.Client
+
+	maxIdleConns          int
+	maxIdleConnsPerHost   int
+	maxConnsPerHost       int
+	idleConnTimeout       time.Duration
+	dialTimeout           time.Duration
+	keepAlive             time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	tlsConfig             *tls.Config
+	proxy                 func(*http.Request) (*url.URL, error)
+	family                string
+
+	middlewares   []Middleware
+	modelDefaults map[string]ModelParams
+	apiVersion    *int
+	env           bool
+}
+
+// defaultClientOptions are tuned for a single upstream service: unlike
+// http.DefaultTransport (2 idle connections per host) they keep enough
+// idle connections around for concurrent chats to reuse them
+func defaultClientOptions() *clientOptions {
+	return &clientOptions{
+		timeout:             90 * time.Second,
+		maxIdleConns:        100,
+		maxIdleConnsPerHost: 32,
+		idleConnTimeout:     90 * time.Second,
+		dialTimeout:         10 * time.Second,
+		keepAlive:           30 * time.Second,
+		tlsHandshakeTimeout: 10 * time.Second,
+		proxy:               http.ProxyFromEnvironment,
+		family:              AddressFamilyFromEnv(),
+	}
+}
+
+// transport builds the HTTP transport described by the options
+func (o *clientOptions) transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: o.dialTimeout, KeepAlive: o.keepAlive}
+	return &http.Transport{
+		Proxy:                 o.proxy,
+		DialContext:           FamilyDialer(o.family, dialer.DialContext),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          o.maxIdleConns,
+		MaxIdleConnsPerHost:   o.maxIdleConnsPerHost,
+		MaxConnsPerHost:       o.maxConnsPerHost,
+		IdleConnTimeout:       o.idleConnTimeout,
+		TLSHandshakeTimeout:   o.tlsHandshakeTimeout,
+		ResponseHeaderTimeout: o.responseHeaderTimeout,
+		TLSClientConfig:       o.tlsConfig,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewAgnoClientWithOptions creates a client configured by opts only: it
+// reads no environment variables and starts no goroutines unless WithEnv
+// is passed
+func NewAgnoClientWithOptions(opts ...Option) (*AgnoClient, error) {
+	o := defaultClientOptions()
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return newAgnoClient(o), nil
+}
+
+// WithEnv configures the client from the environment like NewAgnoClient:
+// AGNO_SERVICE_URL, AGNO_API_VERSION, the credentials and the AGNO_PROMPT_DIR
+// templates. Options set the URL and API version over the environment.
+// Prompt templates are loaded but not watched; call Prompts.Watch for that.
+func WithEnv() Option {
+	return func(o *clientOptions) error {
+		o.env = true
+		return nil
+	}
+}
+
+// WithBaseURL sets the service URL, overriding AGNO_SERVICE_URL
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) error {
+		o.baseURL = baseURL
+		return nil
+	}
+}
+
+// WithConfig applies the restart-only settings of cfg: the service URL,
+// request timeout and API version (see ConfigManager.Bind for the others)
+func WithConfig(cfg *Config) Option {
+	return func(o *clientOptions) error {
+		o.baseURL, o.timeout = cfg.ServiceURL, cfg.RequestTimeout
+		version := 0
+		if cfg.APIVersion != "" && cfg.APIVersion != "auto" {
+			v, err := strconv.Atoi(cfg.APIVersion)
+			if err != nil {
+				return fmt.Errorf("invalid API version %q: %w", cfg.APIVersion, err)
+			}
+			version = v
+		}
+		o.apiVersion = &version
+		return nil
+	}
+}
+
+// WithTimeout sets the default overall timeout of a request, including
+// reading the response, for calls whose context has no deadline (default 90s)
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithHTTPClient uses client as is; all other transport options are ignored
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) error {
+		o.httpClient = client
+		return nil
+	}
+}
+
+// WithMaxIdleConns sets the idle keep-alive connections kept in total and per
+// host (defaults 100 and 32)
+func WithMaxIdleConns(total, perHost int) Option {
+	return func(o *clientOptions) error {
+		o.maxIdleConns, o.maxIdleConnsPerHost = total, perHost
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost caps concurrent connections to the service (0 = no limit)
+func WithMaxConnsPerHost(n int) Option {
+	return func(o *clientOptions) error {
+		o.maxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long idle connections are kept (default 90s)
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.idleConnTimeout = d
+		return nil
+	}
+}
+
+// WithDialTimeout sets the TCP connect timeout and keep-alive probe interval
+// (defaults 10s and 30s)
+func WithDialTimeout(timeout, keepAlive time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.dialTimeout, o.keepAlive = timeout, keepAlive
+		return nil
+	}
+}
+
+// WithAddressFamily dials the service over family (FamilyDual, FamilyIPv4
+// or FamilyIPv6), overriding AGNO_IP_FAMILY
+func WithAddressFamily(family string) Option {
+	return func(o *clientOptions) error {
+		f, err := ParseAddressFamily(family)
+		if err != nil {
+			return err
+		}
+		o.family = f
+		return nil
+	}
+}
+
+// WithTLSHandshakeTimeout sets the TLS handshake timeout (default 10s)
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.tlsHandshakeTimeout = d
+		return nil
+	}
+}
+
+// WithResponseHeaderTimeout limits the wait for response headers (default none)
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.responseHeaderTimeout = d
+		return nil
+	}
+}
+
+// WithTLSConfig uses cfg for TLS connections; combine with the certificate
+// options below only if they should modify cfg
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *clientOptions) error {
+		o.tlsConfig = cfg
+		return nil
+	}
+}
+
+// WithClientCertificate presents a client certificate (mTLS) loaded from PEM files
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(o *clientOptions) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg := o.tls()
+		cfg.Certificates = append(cfg.Certificates, cert)
+		return nil
+	}
+}
+
+// WithRootCAs trusts the CA certificates in caFile (PEM) for the service,
+// e.g. an internal gateway's private CA
+func WithRootCAs(caFile string) Option {
+	return func(o *clientOptions) error {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in CA file")
+		}
+		o.tls().RootCAs = pool
+		return nil
+	}
+}
+
+// WithProxy sends requests through proxyURL instead of HTTP(S)_PROXY from
+// the environment; an empty URL disables proxying
+func WithProxy(proxyURL string) Option {
+	return func(o *clientOptions) error {
+		if proxyURL == "" {
+			o.proxy = nil
+			return nil
+		}
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		o.proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+// WithModelDefaults sets the model parameters of requests that leave them
+// unset, for all tenants
+func WithModelDefaults(p ModelParams) Option {
+	return WithTenantModelDefaults("", p)
+}
+
+// WithTenantModelDefaults sets the model parameter defaults of one tenant
+// (as resolved by TenantFunc), e.g. a cheap model for a casual-chat tenant
+// and a reasoning model for engineering; they take precedence over
+// WithModelDefaults
+func WithTenantModelDefaults(tenant string, p ModelParams) Option {
+	return func(o *clientOptions) error {
+		req := ChatRequest{}
+		req.fillParams(p)
+		if err := ValidateModelParams(req); err != nil {
+			return err
+		}
+		if o.modelDefaults == nil {
+			o.modelDefaults = make(map[string]ModelParams)
+		}
+		o.modelDefaults[tenant] = p
+		return nil
+	}
+}
+
+// WithMiddleware adds middlewares, as Use does
+func WithMiddleware(mw ...Middleware) Option {
+	return func(o *clientOptions) error {
+		o.middlewares = append(o.middlewares, mw...)
+		return nil
+	}
+}
+
+// tls returns the TLS config, creating one on first use
+func (o *clientOptions) tls() *tls.Config {
+	if o.tlsConfig == nil {
+		o.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return o.tlsConfig
+}
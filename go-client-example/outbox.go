@@ -0,0 +1,292 @@
+package agno
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Outbox message states
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+var (
+	outboxMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "outbox",
+		Name:      "messages_total",
+		Help:      "Outbound Lark messages by send result (sent, retry, failed).",
+	}, []string{"result"})
+
+	outboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "outbox",
+		Name:      "pending",
+		Help:      "Outbound Lark messages waiting to be (re)sent, as of the last sweep.",
+	})
+)
+
+// OutboxMessage is an outbound Lark message persisted before it is sent
+type OutboxMessage struct {
+	// ID identifies the row; senders should pass it as Lark's "uuid" request
+	// parameter so a retry after a lost acknowledgment is not delivered twice
+	ID            string `json:"id"`
+	ReceiveID     string `json:"receive_id"`
+	ReceiveIDType string `json:"receive_id_type"` // chat_id, open_id, ...
+	ReplyTo       string `json:"reply_to,omitempty"`
+	MsgType       string `json:"msg_type"` // text, post, interactive
+	Content       string `json:"content"`  // JSON content as sent to Lark
+	SessionID     string `json:"session_id,omitempty"`
+
+	// Proactive marks messages the recipient didn't ask for (digests,
+	// reminders), which Outbox.Quiet may hold back
+	Proactive bool `json:"proactive,omitempty"`
+
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	LarkMessageID string    `json:"lark_message_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttempt   time.Time `json:"next_attempt"`
+}
+
+// MessageSender delivers a message to Lark and returns its message ID
+// (implemented by the bot)
+type MessageSender interface {
+	SendMessage(ctx context.Context, msg OutboxMessage) (string, error)
+}
+
+// Outbox persists outbound messages before sending them and marks them sent
+// once Lark acknowledges them. A sweeper retries unsent rows with backoff,
+// so a crash between the Agno answer and the Lark send never loses it.
+type Outbox struct {
+	Store       SessionStore
+	Sender      MessageSender
+	Claims      IdempotencyStore // stops replicas retrying the same row at once (optional)
+	Quiet       ProactiveGate    // holds back proactive messages to users in quiet mode (optional)
+	MaxAttempts int
+	Interval    time.Duration // how often the sweeper runs
+	Lease       time.Duration // how long an in-flight send is given before it is retried
+	Retention   time.Duration // how long sent and failed rows are kept
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewOutbox creates an outbox sweeping every 30s, giving sends a minute to
+// complete and up to 10 attempts, and keeping finished rows for a day
+func NewOutbox(store SessionStore, sender MessageSender) *Outbox {
+	return &Outbox{
+		Store:       store,
+		Sender:      sender,
+		MaxAttempts: 10,
+		Interval:    30 * time.Second,
+		Lease:       time.Minute,
+		Retention:   24 * time.Hour,
+	}
+}
+
+// Send persists msg and sends it, returning the Lark message ID. If the
+// send fails the message stays in the outbox and the sweeper retries it,
+// so callers must not resend on error. Proactive messages that Quiet holds
+// back return ErrProactiveSuppressed.
+func (o *Outbox) Send(ctx context.Context, msg OutboxMessage) (string, error) {
+	if msg.Proactive && o.Quiet != nil && !o.Quiet.ProactiveAllowed(ctx, msg.ReceiveIDType, msg.ReceiveID) {
+		proactiveSuppressed.WithLabelValues("outbox").Inc()
+		logger.Debugf("Not sending proactive message to %s %s in quiet mode", msg.ReceiveIDType, msg.ReceiveID)
+		return "", ErrProactiveSuppressed
+	}
+	if msg.ID == "" {
+		id := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, id); err != nil {
+			return "", fmt.Errorf("failed to generate outbox ID: %w", err)
+		}
+		msg.ID = hex.EncodeToString(id)
+	}
+	now := time.Now()
+	msg.Status = OutboxPending
+	msg.CreatedAt = now
+	msg.NextAttempt = now.Add(o.Lease)
+	if err := o.save(ctx, msg); err != nil {
+		return "", fmt.Errorf("failed to persist outbound message: %w", err)
+	}
+	return o.deliver(ctx, msg)
+}
+
+// SendMessage implements MessageSender, so an Outbox can be passed where a
+// sender is expected
+func (o *Outbox) SendMessage(ctx context.Context, msg OutboxMessage) (string, error) {
+	return o.Send(ctx, msg)
+}
+
+// Get returns an outbox row
+func (o *Outbox) Get(ctx context.Context, id string) (*OutboxMessage, error) {
+	data, err := o.Store.Get(ctx, "outbox:"+id)
+	if err != nil {
+		return nil, err
+	}
+	// migrated rows are written back by their next save, which knows their TTL
+	var msg OutboxMessage
+	if _, err := outboxSchema.Decode(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outbox row %s: %w", id, err)
+	}
+	return &msg, nil
+}
+
+// Pending returns the rows not yet sent, including permanently failed ones
+func (o *Outbox) Pending(ctx context.Context) ([]OutboxMessage, error) {
+	keys, err := o.Store.Keys(ctx, "outbox:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	var pending []OutboxMessage
+	for _, key := range keys {
+		msg, err := o.Get(ctx, key[len("outbox:"):])
+		if err != nil {
+			continue
+		}
+		if msg.Status != OutboxSent {
+			pending = append(pending, *msg)
+		}
+	}
+	return pending, nil
+}
+
+// Sweep retries pending rows whose next attempt is due
+func (o *Outbox) Sweep(ctx context.Context) {
+	pending, err := o.Pending(ctx)
+	if err != nil {
+		logger.Errorf("Outbox sweep failed: %v", err)
+		return
+	}
+
+	waiting := 0
+	for _, msg := range pending {
+		if msg.Status != OutboxPending {
+			continue
+		}
+		waiting++
+		if time.Now().Before(msg.NextAttempt) {
+			continue
+		}
+		if o.Claims != nil {
+			first, err := o.Claims.Claim(ctx, fmt.Sprintf("outbox:%s:%d", msg.ID, msg.Attempts), o.Lease)
+			if err != nil || !first {
+				continue
+			}
+		}
+
+		msg.NextAttempt = time.Now().Add(o.Lease)
+		if err := o.save(ctx, msg); err != nil {
+			logger.Warnf("Failed to lease outbox row %s: %v", msg.ID, err)
+			continue
+		}
+		logger.Infof("Retrying outbound message %s to %s (attempt %d)", msg.ID, msg.ReceiveID, msg.Attempts+1)
+		if _, err := o.deliver(ctx, msg); err == nil {
+			waiting--
+		}
+	}
+	outboxPending.Set(float64(waiting))
+}
+
+// Start sweeps the outbox every Interval
+func (o *Outbox) Start() {
+	o.stop = make(chan struct{})
+	o.done = make(chan struct{})
+	go func() {
+		defer close(o.done)
+		ticker := time.NewTicker(o.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				o.Sweep(context.Background())
+			case <-o.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background sweeper
+func (o *Outbox) Stop() {
+	if o.stop == nil {
+		return
+	}
+	close(o.stop)
+	<-o.done
+	o.stop = nil
+}
+
+// deliver sends a leased row and records the outcome
+func (o *Outbox) deliver(ctx context.Context, msg OutboxMessage) (string, error) {
+	msg.Attempts++
+	traced := TraceStage(ctx, StageSend)
+	messageID, err := o.Sender.SendMessage(ctx, msg)
+	traced(err, map[string]string{"attempt": strconv.Itoa(msg.Attempts), "lark_message_id": messageID})
+	if err == nil {
+		msg.Status = OutboxSent
+		msg.LarkMessageID = messageID
+		msg.LastError = ""
+		outboxMessages.WithLabelValues("sent").Inc()
+		if err := o.save(ctx, msg); err != nil {
+			// The message was delivered; the sweeper's retry is deduplicated by Lark's uuid
+			logger.Warnf("Failed to mark outbound message %s as sent: %v", msg.ID, err)
+		}
+		return messageID, nil
+	}
+
+	msg.LastError = err.Error()
+	if msg.Attempts >= o.MaxAttempts {
+		msg.Status = OutboxFailed
+		outboxMessages.WithLabelValues("failed").Inc()
+		logger.Errorf("Giving up on outbound message %s to %s after %d attempts: %v", msg.ID, msg.ReceiveID, msg.Attempts, err)
+	} else {
+		msg.NextAttempt = time.Now().Add(o.backoff(msg.Attempts))
+		outboxMessages.WithLabelValues("retry").Inc()
+		logger.Warnf("Failed to send outbound message %s to %s, retrying at %s: %v",
+			msg.ID, msg.ReceiveID, msg.NextAttempt.Format(time.RFC3339), err)
+	}
+	if saveErr := o.save(ctx, msg); saveErr != nil {
+		logger.Errorf("Failed to record send failure of outbound message %s: %v", msg.ID, saveErr)
+	}
+	return "", fmt.Errorf("failed to send message %s: %w", msg.ID, err)
+}
+
+// backoff returns the delay before retry attempt+1: Interval doubled per
+// attempt, capped at an hour
+func (o *Outbox) backoff(attempt int) time.Duration {
+	delay := o.Interval
+	for i := 1; i < attempt && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// save writes a row; pending rows are kept until they finish
+func (o *Outbox) save(ctx context.Context, msg OutboxMessage) error {
+	data, err := outboxSchema.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox row: %w", err)
+	}
+	ttl := o.Retention
+	if msg.Status == OutboxPending {
+		ttl = 0
+	}
+	return o.Store.Set(ctx, "outbox:"+msg.ID, data, ttl)
+}
@@ -0,0 +1,148 @@
+package agno
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// paletteCommandKey is the card action value field carrying the command name
+const paletteCommandKey = "palette_command"
+
+// CommandArg describes an argument of a bot command
+type CommandArg struct {
+	Name        string
+	Placeholder string
+	Required    bool
+	Options     []string // rendered as a dropdown when set
+}
+
+// PaletteCommand describes a command listed in the command palette card
+type PaletteCommand struct {
+	Name        string
+	Description string
+	Args        []CommandArg
+}
+
+// IsPaletteTrigger reports whether a message should open the command palette
+func IsPaletteTrigger(text string) bool {
+	return strings.TrimSpace(text) == "/"
+}
+
+// BuildCommandPaletteCard builds a Lark interactive card listing the commands
+// with a form per command so users can fill in arguments and submit
+func BuildCommandPaletteCard(commands []PaletteCommand) map[string]interface{} {
+	elements := []interface{}{
+		markdownElement("Pick a command below. Fill in the fields and press **Run**."),
+	}
+
+	for _, cmd := range commands {
+		elements = append(elements,
+			map[string]interface{}{"tag": "hr"},
+			markdownElement(fmt.Sprintf("**/%s** — %s", cmd.Name, cmd.Description)),
+		)
+
+		value := map[string]interface{}{paletteCommandKey: cmd.Name}
+		if len(cmd.Args) == 0 {
+			elements = append(elements, actionModule(callbackButton("Run", "primary", value)))
+			continue
+		}
+
+		formElements := make([]interface{}, 0, len(cmd.Args)+1)
+		for _, arg := range cmd.Args {
+			formElements = append(formElements, paletteArgElement(arg))
+		}
+		submit := callbackButton("Run", "primary", value)
+		submit["action_type"] = "form_submit"
+		submit["name"] = "submit_" + cmd.Name
+		formElements = append(formElements, submit)
+
+		elements = append(elements, map[string]interface{}{
+			"tag":      "form",
+			"name":     "form_" + cmd.Name,
+			"elements": formElements,
+		})
+	}
+
+	return map[string]interface{}{
+		"config":   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header":   cardHeader("🧭 Commands", "blue"),
+		"elements": elements,
+	}
+}
+
+// paletteArgElement renders a single command argument as a form field
+func paletteArgElement(arg CommandArg) map[string]interface{} {
+	placeholder := arg.Placeholder
+	if placeholder == "" {
+		placeholder = arg.Name
+	}
+
+	if len(arg.Options) > 0 {
+		options := make([]interface{}, 0, len(arg.Options))
+		for _, opt := range arg.Options {
+			options = append(options, map[string]interface{}{"text": plainText(opt), "value": opt})
+		}
+		return map[string]interface{}{
+			"tag":         "select_static",
+			"name":        arg.Name,
+			"placeholder": plainText(placeholder),
+			"required":    arg.Required,
+			"options":     options,
+		}
+	}
+
+	return map[string]interface{}{
+		"tag":         "input",
+		"name":        arg.Name,
+		"placeholder": plainText(placeholder),
+		"required":    arg.Required,
+	}
+}
+
+// ParsePaletteAction converts a palette card callback into command text
+// (e.g. "/use sales"). It returns false if the action did not come from the palette.
+func ParsePaletteAction(action CardAction, commands []PaletteCommand) (string, bool, error) {
+	name := action.StringValue(paletteCommandKey)
+	if name == "" {
+		return "", false, nil
+	}
+
+	var cmd *PaletteCommand
+	for i := range commands {
+		if commands[i].Name == name {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		return "", true, fmt.Errorf("unknown command: %s", name)
+	}
+
+	parts := []string{"/" + cmd.Name}
+	filled := len(parts) // parts up to the last non-empty argument
+	for _, arg := range cmd.Args {
+		raw, _ := action.FormValue[arg.Name].(string)
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			if arg.Required {
+				return "", true, fmt.Errorf("missing required argument: %s", arg.Name)
+			}
+			// an empty placeholder keeps later arguments in their position
+			parts = append(parts, `""`)
+			continue
+		}
+		// splitArgs has no escapes, so a value can be quoted but not
+		// contain quotes itself
+		if strings.ContainsAny(raw, `"“”`) {
+			return "", true, fmt.Errorf("argument %s must not contain quotes", arg.Name)
+		}
+		if strings.IndexFunc(raw, unicode.IsSpace) >= 0 {
+			raw = `"` + raw + `"`
+		}
+		parts = append(parts, raw)
+		filled = len(parts)
+	}
+
+	return strings.Join(parts[:filled], " "), true, nil
+}
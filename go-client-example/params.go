@@ -0,0 +1,78 @@
+package agno
+
+import (
+	"fmt"
+)
+
+// Reasoning effort levels of ChatRequest.ReasoningEffort
+const (
+	ReasoningMinimal = "minimal"
+	ReasoningLow     = "low"
+	ReasoningMedium  = "medium"
+	ReasoningHigh    = "high"
+)
+
+// Parameter limits checked before a request is sent
+const (
+	MaxTemperature  = 2.0
+	MaxOutputTokens = 128000
+)
+
+// ModelParams are the model and sampling parameters of a chat; as defaults
+// they fill the fields a request leaves unset
+type ModelParams struct {
+	Model           string   `json:"model,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxTokens       int      `json:"max_tokens,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"`
+}
+
+// Temperature returns a pointer to t, for ChatRequest.Temperature and
+// ModelParams.Temperature (nil means the agent's default, 0 is greedy)
+func Temperature(t float64) *float64 {
+	return &t
+}
+
+// ValidateModelParams checks the parameters of a request; errors wrap
+// ErrInvalidRequest
+func ValidateModelParams(req ChatRequest) error {
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > MaxTemperature) {
+		return fmt.Errorf("%w: temperature %g is outside 0..%g", ErrInvalidRequest, *req.Temperature, MaxTemperature)
+	}
+	if req.MaxTokens < 0 || req.MaxTokens > MaxOutputTokens {
+		return fmt.Errorf("%w: max_tokens %d is outside 0..%d", ErrInvalidRequest, req.MaxTokens, MaxOutputTokens)
+	}
+	switch req.ReasoningEffort {
+	case "", ReasoningMinimal, ReasoningLow, ReasoningMedium, ReasoningHigh:
+	default:
+		return fmt.Errorf("%w: unknown reasoning effort %q", ErrInvalidRequest, req.ReasoningEffort)
+	}
+	return nil
+}
+
+// applyModelParams fills unset parameters of req from the defaults of its
+// session's tenant, then from the defaults for all tenants, and validates
+// the result
+func (c *AgnoClient) applyModelParams(req *ChatRequest) error {
+	if tenant := c.tenant(req.SessionID); tenant != defaultTenant {
+		req.fillParams(c.ModelDefaults[tenant])
+	}
+	req.fillParams(c.ModelDefaults[""])
+	return ValidateModelParams(*req)
+}
+
+// fillParams sets the fields of req that are unset from p
+func (req *ChatRequest) fillParams(p ModelParams) {
+	if req.Model == "" {
+		req.Model = p.Model
+	}
+	if req.Temperature == nil && p.Temperature != nil {
+		req.Temperature = Temperature(*p.Temperature)
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = p.MaxTokens
+	}
+	if req.ReasoningEffort == "" {
+		req.ReasoningEffort = p.ReasoningEffort
+	}
+}
@@ -0,0 +1,184 @@
+package agno
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// prefetchSessionID is the Agno session used for generating prefetched answers
+const prefetchSessionID = "faq-prefetch"
+
+var prefetchPrompts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "prefetch",
+	Name:      "prompts_total",
+	Help:      "FAQ prompts handled by the cache prefetcher by result (generated, cached, skipped, failed).",
+}, []string{"result"})
+
+// CachePrefetcher fills the response cache with answers to the top FAQ
+// prompts after a deploy, so the most common questions are instant from the
+// first message. Prompts are generated one at a time with a pause in
+// between, leaving the service's capacity to real users.
+type CachePrefetcher struct {
+	Client  *AgnoClient
+	Prompts []string
+
+	// Template is the request the bot sends for these questions (agent,
+	// model, system prompt); answers are only hit when its fields match
+	Template ChatRequest
+
+	Delay  time.Duration    // pause between generated prompts
+	Claims IdempotencyStore // lets one replica generate each prompt (optional)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewCachePrefetcher creates a prefetcher pausing 2s between prompts
+func NewCachePrefetcher(client *AgnoClient, prompts []string) *CachePrefetcher {
+	return &CachePrefetcher{
+		Client:  client,
+		Prompts: prompts,
+		Delay:   2 * time.Second,
+	}
+}
+
+// LoadPrefetchPrompts reads one prompt per line from path, skipping blank
+// lines and # comments
+func LoadPrefetchPrompts(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prefetch prompts: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			prompts = append(prompts, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prefetch prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// NewCachePrefetcherFromEnv loads prompts from the file at
+// AGNO_PREFETCH_FILE; it returns nil when the variable is unset
+func NewCachePrefetcherFromEnv(client *AgnoClient) (*CachePrefetcher, error) {
+	path := os.Getenv("AGNO_PREFETCH_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	prompts, err := LoadPrefetchPrompts(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCachePrefetcher(client, prompts), nil
+}
+
+// Run generates the answers of prompts missing from the cache and returns
+// how many were generated
+func (p *CachePrefetcher) Run(ctx context.Context) (int, error) {
+	cache := p.Client.Cache
+	if cache == nil {
+		return 0, fmt.Errorf("%w: prefetching needs a response cache", ErrInvalidRequest)
+	}
+
+	generated := 0
+	for _, prompt := range p.Prompts {
+		req := p.Template
+		req.SessionID, req.Message = prefetchSessionID, prompt
+		key, ok := p.Client.cacheKey(req)
+		if !ok {
+			prefetchPrompts.WithLabelValues("skipped").Inc()
+			continue
+		}
+		if _, hit := cache.Get(ctx, key); hit {
+			prefetchPrompts.WithLabelValues("cached").Inc()
+			continue
+		}
+		if p.Claims != nil {
+			first, err := p.Claims.Claim(ctx, "prefetch:"+key, cache.TTL)
+			if err != nil || !first {
+				prefetchPrompts.WithLabelValues("skipped").Inc()
+				continue
+			}
+		}
+
+		if generated > 0 {
+			select {
+			case <-time.After(p.Delay):
+			case <-ctx.Done():
+				return generated, ctx.Err()
+			}
+		}
+		if err := p.generate(ctx, req); err != nil {
+			if ctx.Err() != nil {
+				return generated, ctx.Err()
+			}
+			prefetchPrompts.WithLabelValues("failed").Inc()
+			logger.Warnf("Failed to prefetch answer to %q: %v", prompt, err)
+			continue
+		}
+		prefetchPrompts.WithLabelValues("generated").Inc()
+		generated++
+	}
+	return generated, nil
+}
+
+// generate asks the model and caches the answer; the session is cleared
+// afterwards so every answer is independent
+func (p *CachePrefetcher) generate(ctx context.Context, req ChatRequest) error {
+	resp, err := p.Client.SendChat(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.Degraded {
+		return errors.New("service unavailable, got a degraded answer")
+	}
+	if err := p.Client.ClearSessionContext(ctx, prefetchSessionID); err != nil {
+		logger.Warnf("Failed to clear prefetch session: %v", err)
+	}
+	return nil
+}
+
+// Start prefetches in the background; Stop abandons the remaining prompts
+func (p *CachePrefetcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		start := time.Now()
+		generated, err := p.Run(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Errorf("FAQ prefetch failed: %v", err)
+			return
+		}
+		logger.Infof("FAQ prefetch generated %d of %d answers in %s", generated, len(p.Prompts), time.Since(start).Round(time.Second))
+	}()
+}
+
+// Stop stops prefetching
+func (p *CachePrefetcher) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+	p.cancel = nil
+}
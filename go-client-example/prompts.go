@@ -0,0 +1,222 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// ErrUnknownTemplate is returned when a prompt template does not exist
+var ErrUnknownTemplate = errors.New("agno: unknown prompt template")
+
+// promptExtensions are the file types loaded as prompt templates
+var promptExtensions = map[string]bool{".tmpl": true, ".txt": true, ".md": true}
+
+// PromptTemplates holds named system prompt templates using Go template
+// syntax, e.g. "You are helping {{.UserName}} from {{.Department}}. Answer
+// in {{.Language}}." Templates come from files in Dir (name = file name
+// without extension) and/or are added from config with Add.
+type PromptTemplates struct {
+	Dir string
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	fromFiles map[string]bool
+	modTimes  map[string]time.Time
+}
+
+// NewPromptTemplates creates an empty template set
+func NewPromptTemplates() *PromptTemplates {
+	return &PromptTemplates{
+		templates: make(map[string]*template.Template),
+		fromFiles: make(map[string]bool),
+		modTimes:  make(map[string]time.Time),
+	}
+}
+
+// LoadPromptTemplates loads every *.tmpl, *.txt and *.md file in dir
+func LoadPromptTemplates(dir string) (*PromptTemplates, error) {
+	p := NewPromptTemplates()
+	p.Dir = dir
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Add parses and registers a template from config, replacing any template of the same name
+func (p *PromptTemplates) Add(name, text string) error {
+	tmpl, err := parsePrompt(name, text)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.templates[name] = tmpl
+	delete(p.fromFiles, name)
+	return nil
+}
+
+// Names returns the names of all templates
+func (p *PromptTemplates) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.templates))
+	for name := range p.templates {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Render executes the named template with vars
+func (p *PromptTemplates) Render(name string, vars map[string]any) (string, error) {
+	p.mu.RLock()
+	tmpl, ok := p.templates[name]
+	p.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Reload re-reads Dir if any template file was added, changed or removed and
+// reports whether anything changed. A template that fails to parse keeps its
+// previous version.
+func (p *PromptTemplates) Reload() (bool, error) {
+	if p.Dir == "" {
+		return false, nil
+	}
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read prompt template dir: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	changed := false
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !promptExtensions[ext] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		seen[name] = true
+
+		p.mu.RLock()
+		unchanged := p.fromFiles[name] && p.modTimes[name].Equal(info.ModTime())
+		p.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(p.Dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tmpl, err := parsePrompt(name, string(data))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.mu.Lock()
+		p.templates[name] = tmpl
+		p.fromFiles[name] = true
+		p.modTimes[name] = info.ModTime()
+		p.mu.Unlock()
+		changed = true
+	}
+
+	p.mu.Lock()
+	for name := range p.fromFiles {
+		if !seen[name] {
+			delete(p.templates, name)
+			delete(p.fromFiles, name)
+			delete(p.modTimes, name)
+			changed = true
+		}
+	}
+	p.mu.Unlock()
+
+	return changed, errors.Join(errs...)
+}
+
+// Watch reloads Dir every interval until the returned function is called
+func (p *PromptTemplates) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				changed, err := p.Reload()
+				if err != nil {
+					logger.Errorf("Prompt template reload failed: %v", err)
+				}
+				if changed {
+					logger.Info("Prompt templates reloaded")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// parsePrompt parses a template that fails on missing variables
+func parsePrompt(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// ChatWithTemplate sends a message using the named prompt template, rendered
+// with vars, as the system prompt
+func (c *AgnoClient) ChatWithTemplate(sessionID, templateName string, vars map[string]any, message string) (string, error) {
+	return c.ChatWithTemplateContext(context.Background(), sessionID, templateName, vars, message)
+}
+
+// ChatWithTemplateContext is like ChatWithTemplate but carries ctx for cancellation and tracing
+func (c *AgnoClient) ChatWithTemplateContext(ctx context.Context, sessionID, templateName string, vars map[string]any, message string) (string, error) {
+	if c.Prompts == nil {
+		return "", fmt.Errorf("%w: no prompt templates configured", ErrUnknownTemplate)
+	}
+	systemPrompt, err := c.Prompts.Render(templateName, vars)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.SendChat(ctx, ChatRequest{
+		SessionID:    sessionID,
+		Message:      message,
+		SystemPrompt: systemPrompt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Response, nil
+}
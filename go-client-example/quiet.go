@@ -0,0 +1,193 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// quietUsage is the usage of the /quiet command
+const quietUsage = "/quiet [on|off]"
+
+// ErrProactiveSuppressed is returned for a proactive message whose
+// recipient is in quiet mode; nothing was sent
+var ErrProactiveSuppressed = errors.New("agno: recipient is in quiet mode")
+
+var proactiveSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "quiet",
+	Name:      "suppressed_total",
+	Help:      "Proactive messages not sent because the recipient is in quiet mode, by subsystem.",
+}, []string{"subsystem"})
+
+// UserSettings are a Lark user's preferences, persisted across sessions
+type UserSettings struct {
+	// Quiet suppresses proactive messages (digests, nudges, suggestions);
+	// answers to the user's own messages are unaffected
+	Quiet bool `json:"quiet,omitempty"`
+	// DMChatID is the user's direct chat with the bot, muted with the user
+	DMChatID string `json:"dm_chat_id,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProactiveGate decides whether a proactive message may be sent to a
+// recipient, identified like an OutboxMessage
+type ProactiveGate interface {
+	ProactiveAllowed(ctx context.Context, receiveIDType, receiveID string) bool
+}
+
+// UserSettingsStore keeps UserSettings under "usersettings:<open_id>" keys
+// and, for users in quiet mode, their direct chat under "quietchat:<chat>"
+// so messages addressed by chat ID are gated too
+type UserSettingsStore struct {
+	Store SessionStore
+}
+
+var _ ProactiveGate = (*UserSettingsStore)(nil)
+
+// NewUserSettingsStore creates a settings store
+func NewUserSettingsStore(store SessionStore) *UserSettingsStore {
+	return &UserSettingsStore{Store: store}
+}
+
+// Get returns a user's settings; users without settings get the defaults
+func (s *UserSettingsStore) Get(ctx context.Context, userID string) (*UserSettings, error) {
+	var settings UserSettings
+	_, err := userSettingsSchema.loadRecord(ctx, s.Store, "usersettings:"+userID, &settings, 0)
+	if errors.Is(err, ErrKeyNotFound) {
+		return &UserSettings{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read settings of user %s: %w", userID, err)
+	}
+	return &settings, nil
+}
+
+// Update applies fn to a user's settings and saves them
+func (s *UserSettingsStore) Update(ctx context.Context, userID string, fn func(*UserSettings)) (*UserSettings, error) {
+	settings, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	fn(settings)
+	settings.UpdatedAt = time.Now().UTC()
+	data, err := userSettingsSchema.Encode(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user settings: %w", err)
+	}
+	if err := s.Store.Set(ctx, "usersettings:"+userID, data, 0); err != nil {
+		return nil, fmt.Errorf("failed to save settings of user %s: %w", userID, err)
+	}
+	return settings, nil
+}
+
+// SetQuiet turns quiet mode on or off; dmChatID, when known, is the user's
+// direct chat with the bot, whose proactive messages are muted as well
+func (s *UserSettingsStore) SetQuiet(ctx context.Context, userID, dmChatID string, quiet bool) error {
+	settings, err := s.Update(ctx, userID, func(settings *UserSettings) {
+		settings.Quiet = quiet
+		if dmChatID != "" {
+			settings.DMChatID = dmChatID
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if settings.DMChatID != "" {
+		key := "quietchat:" + settings.DMChatID
+		if quiet {
+			err = s.Store.Set(ctx, key, []byte(userID), 0)
+		} else {
+			err = s.Store.Delete(ctx, key)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update quiet chat %s: %w", settings.DMChatID, err)
+		}
+	}
+	logger.Infof("User %s turned quiet mode %s", userID, onOff(quiet))
+	return nil
+}
+
+// Quiet reports whether a user is in quiet mode. Lookup failures report
+// false, so a store hiccup delays no more than a digest.
+func (s *UserSettingsStore) Quiet(ctx context.Context, userID string) bool {
+	if userID == "" {
+		return false
+	}
+	settings, err := s.Get(ctx, userID)
+	if err != nil {
+		logger.Warnf("Failed to check quiet mode of user %s: %v", userID, err)
+		return false
+	}
+	return settings.Quiet
+}
+
+// ProactiveAllowed implements ProactiveGate: messages to a quiet user, or to
+// their direct chat, are not allowed. Group chats are always allowed.
+func (s *UserSettingsStore) ProactiveAllowed(ctx context.Context, receiveIDType, receiveID string) bool {
+	switch receiveIDType {
+	case "open_id":
+		return !s.Quiet(ctx, receiveID)
+	case "chat_id":
+		_, err := s.Store.Get(ctx, "quietchat:"+receiveID)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			logger.Warnf("Failed to check quiet mode of chat %s: %v", receiveID, err)
+		}
+		return err != nil
+	}
+	return true
+}
+
+// QuietCommand is "/quiet [on|off]", which turns the sender's quiet mode on
+// or off, or shows it. Register it with the bot's other commands.
+func QuietCommand(settings *UserSettingsStore) Command {
+	return Command{
+		Name:        "quiet",
+		Usage:       quietUsage,
+		Description: "Pause digests, reminders and suggestions; I still answer your messages",
+		MaxArgs:     1,
+		Handler: func(ctx context.Context, cmd CommandContext) (string, error) {
+			if len(cmd.Args) == 0 {
+				if settings.Quiet(ctx, cmd.UserID) {
+					return "🔕 Quiet mode is on. Send `/quiet off` to get digests and suggestions again.", nil
+				}
+				return "🔔 Quiet mode is off. Send `/quiet on` to pause digests, reminders and suggestions.", nil
+			}
+
+			var quiet bool
+			switch strings.ToLower(cmd.Args[0]) {
+			case "on":
+				quiet = true
+			case "off":
+			default:
+				return fmt.Sprintf("Usage: `%s`", quietUsage), nil
+			}
+			dmChatID := ""
+			if cmd.ChatType == "p2p" {
+				dmChatID = cmd.ChatID
+			}
+			if err := settings.SetQuiet(ctx, cmd.UserID, dmChatID, quiet); err != nil {
+				return "", err
+			}
+			if quiet {
+				return "🔕 Quiet mode on. I won't send you digests, reminders or suggestions, but I'll still answer your messages.", nil
+			}
+			return "🔔 Quiet mode off.", nil
+		},
+	}
+}
+
+// onOff formats a switch for logs
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
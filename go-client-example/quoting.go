@@ -0,0 +1,110 @@
+package agno
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ReplyMode controls how answers reference the question in busy groups
+type ReplyMode string
+
+const (
+	// ReplyQuote replies to the question message, which Lark shows as a quote
+	ReplyQuote ReplyMode = "quote"
+	// ReplyThread replies in a thread under the question message
+	ReplyThread ReplyMode = "thread"
+	// ReplyOff always sends answers as plain chat messages
+	ReplyOff ReplyMode = "off"
+)
+
+// ReplyTarget tells the bot how to send an answer. With an empty MessageID
+// the answer is a plain message; otherwise it goes through
+// POST /im/v1/messages/:message_id/reply with reply_in_thread = InThread.
+type ReplyTarget struct {
+	MessageID string
+	InThread  bool
+}
+
+// ReplyRouter quotes the source question when a group is busy, so it is
+// obvious which question each answer addresses
+type ReplyRouter struct {
+	Mode          ReplyMode
+	BusyThreshold int           // messages within Window that make a chat busy (0 = always busy)
+	Window        time.Duration // activity window
+
+	mu       sync.Mutex
+	activity map[string][]time.Time
+}
+
+// NewReplyRouterFromEnv reads the mode from AGNO_REPLY_MODE (quote, thread
+// or off; default quote). A group is busy after 5 messages in 2 minutes.
+func NewReplyRouterFromEnv() *ReplyRouter {
+	mode := ReplyMode(strings.ToLower(os.Getenv("AGNO_REPLY_MODE")))
+	if mode != ReplyThread && mode != ReplyOff {
+		mode = ReplyQuote
+	}
+	return &ReplyRouter{
+		Mode:          mode,
+		BusyThreshold: 5,
+		Window:        2 * time.Minute,
+		activity:      make(map[string][]time.Time),
+	}
+}
+
+// Observe records an incoming group message; call it for every message the
+// bot receives, not only the ones it answers
+func (r *ReplyRouter) Observe(chatID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.activity[chatID] = append(r.recent(chatID, now), now)
+}
+
+// Busy reports whether the chat has seen at least BusyThreshold messages within Window
+func (r *ReplyRouter) Busy(chatID string) bool {
+	if r.BusyThreshold <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := r.recent(chatID, time.Now())
+	if len(recent) == 0 {
+		delete(r.activity, chatID)
+	} else {
+		r.activity[chatID] = recent
+	}
+	return len(recent) >= r.BusyThreshold
+}
+
+// recent drops timestamps older than Window; callers must hold r.mu
+func (r *ReplyRouter) recent(chatID string, now time.Time) []time.Time {
+	times := r.activity[chatID]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > r.Window {
+		i++
+	}
+	return times[i:]
+}
+
+// Target decides how to send the answer to messageID. Direct messages and
+// quiet groups get plain messages; busy groups reply to the question.
+func (r *ReplyRouter) Target(chatID, chatType, messageID string) ReplyTarget {
+	if r.Mode == ReplyOff || chatType == "p2p" || messageID == "" || !r.Busy(chatID) {
+		return ReplyTarget{}
+	}
+	return ReplyTarget{MessageID: messageID, InThread: r.Mode == ReplyThread}
+}
+
+// QuoteQuestion renders question as a markdown quote for answers sent as
+// cards, where Lark's reply preview is not shown. Long questions are cut to
+// maxRunes runes.
+func QuoteQuestion(question string, maxRunes int) string {
+	question = strings.Join(strings.Fields(question), " ")
+	if maxRunes > 0 && utf8.RuneCountInString(question) > maxRunes {
+		question = string([]rune(question)[:maxRunes]) + "…"
+	}
+	return "> " + question + "\n\n"
+}
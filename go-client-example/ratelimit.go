@@ -0,0 +1,343 @@
+package agno
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+
+	"start-feishubot/logger"
+)
+
+// Rate limit scopes
+const (
+	RateScopeUser = "user"
+	RateScopeChat = "chat"
+)
+
+// BucketConfig configures a token bucket: Burst tokens, one token added every Refill
+type BucketConfig struct {
+	Burst  int
+	Refill time.Duration
+}
+
+// enabled reports whether the bucket limits anything
+func (b BucketConfig) enabled() bool {
+	return b.Burst > 0 && b.Refill > 0
+}
+
+// BucketStore takes tokens from named buckets
+type BucketStore interface {
+	// Take removes one token from the bucket. If none is available it returns
+	// false and how long until the next token is added.
+	Take(ctx context.Context, key string, cfg BucketConfig) (bool, time.Duration, error)
+	// Refund returns a token taken by Take, up to the bucket's burst
+	Refund(ctx context.Context, key string, cfg BucketConfig) error
+}
+
+// QuotaError is returned when a user or chat has exhausted its quota
+type QuotaError struct {
+	Scope      string // RateScopeUser or RateScopeChat
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded, retry after %s", e.Scope, e.RetryAfter)
+}
+
+// Unwrap makes errors.Is(err, ErrRateLimited) true
+func (e *QuotaError) Unwrap() error { return ErrRateLimited }
+
+// UserMessage returns the friendly quota message for the bot to relay
+func (e *QuotaError) UserMessage() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if e.Scope == RateScopeChat {
+		return fmt.Sprintf("🚦 This chat is sending me a lot of messages. Please wait %s before asking again.", wait)
+	}
+	return fmt.Sprintf("🚦 You're sending messages faster than I can keep up. Please wait %s and try again.", wait)
+}
+
+var rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "ratelimit",
+	Name:      "rejected_total",
+	Help:      "Messages rejected by the per-user/per-chat rate limiter.",
+}, []string{"scope"})
+
+// RateLimiter enforces per-user and per-chat token buckets
+type RateLimiter struct {
+	Store BucketStore
+	User  BucketConfig
+	Chat  BucketConfig
+
+	// MaxWait queues a message for up to this long instead of rejecting it
+	// immediately (0 rejects right away)
+	MaxWait time.Duration
+
+	// ChatConfigFunc, when set, picks the chat bucket per chat instead of
+	// Chat (see LargeGroups.ChatQuota)
+	ChatConfigFunc func(ctx context.Context, chatID string) BucketConfig
+
+	mu sync.RWMutex
+}
+
+// NewRateLimiterFromEnv configures limits from AGNO_RATE_USER_BURST,
+// AGNO_RATE_USER_REFILL, AGNO_RATE_CHAT_BURST, AGNO_RATE_CHAT_REFILL and
+// AGNO_RATE_MAX_WAIT (durations like "10s"). Defaults: 5 messages per user
+// with one more every 10s, 20 per chat with one more every 3s.
+func NewRateLimiterFromEnv(store BucketStore) *RateLimiter {
+	return &RateLimiter{
+		Store: store,
+		User: BucketConfig{
+			Burst:  envInt("AGNO_RATE_USER_BURST", 5),
+			Refill: envDuration("AGNO_RATE_USER_REFILL", 10*time.Second),
+		},
+		Chat: BucketConfig{
+			Burst:  envInt("AGNO_RATE_CHAT_BURST", 20),
+			Refill: envDuration("AGNO_RATE_CHAT_REFILL", 3*time.Second),
+		},
+		MaxWait: envDuration("AGNO_RATE_MAX_WAIT", 0),
+	}
+}
+
+// Allow takes a token for the user and the chat, waiting up to MaxWait for
+// one to become available. It returns a *QuotaError when the message must
+// be rejected; tokens already taken for it are then refunded, so a message
+// the chat quota rejects doesn't count against the user.
+func (r *RateLimiter) Allow(ctx context.Context, userID, chatID string) error {
+	r.mu.RLock()
+	userCfg, chatCfg, maxWait := r.User, r.Chat, r.MaxWait
+	r.mu.RUnlock()
+
+	deadline := time.Now().Add(maxWait)
+	if r.ChatConfigFunc != nil {
+		chatCfg = r.ChatConfigFunc(ctx, chatID)
+	}
+
+	var taken []takenToken
+	for _, check := range []struct {
+		scope, key string
+		cfg        BucketConfig
+	}{
+		{RateScopeUser, "user:" + userID, userCfg},
+		{RateScopeChat, "chat:" + chatID, chatCfg},
+	} {
+		if !check.cfg.enabled() {
+			continue
+		}
+		for {
+			ok, retryAfter, err := r.Store.Take(ctx, check.key, check.cfg)
+			if err != nil {
+				// Fail open: a broken limiter backend must not take the bot down
+				logger.Errorf("Rate limiter unavailable, allowing message: %v", err)
+				break
+			}
+			if ok {
+				taken = append(taken, takenToken{check.key, check.cfg})
+				break
+			}
+			if time.Now().Add(retryAfter).After(deadline) {
+				rateLimited.WithLabelValues(check.scope).Inc()
+				r.refund(ctx, taken)
+				return &QuotaError{Scope: check.scope, RetryAfter: retryAfter}
+			}
+			select {
+			case <-time.After(retryAfter):
+			case <-ctx.Done():
+				r.refund(ctx, taken)
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// takenToken is a token Allow took for a message it may still reject
+type takenToken struct {
+	key string
+	cfg BucketConfig
+}
+
+// refund returns the tokens of a rejected message
+func (r *RateLimiter) refund(ctx context.Context, taken []takenToken) {
+	for _, t := range taken {
+		if err := r.Store.Refund(context.WithoutCancel(ctx), t.key, t.cfg); err != nil {
+			logger.Warnf("Failed to refund rate limit token of %s: %v", t.key, err)
+		}
+	}
+}
+
+// SetLimits replaces the limits of a running limiter, e.g. on a
+// configuration reload; buckets keep their tokens
+func (r *RateLimiter) SetLimits(user, chat BucketConfig, maxWait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.User, r.Chat, r.MaxWait = user, chat, maxWait
+}
+
+// MemoryBucketStore keeps token buckets in process memory (single replica)
+type MemoryBucketStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	sweeps  int
+}
+
+// memoryBucket is the state of one in-memory token bucket
+type memoryBucket struct {
+	tokens    float64
+	updated   time.Time
+	idleAfter time.Duration // after this long untouched the bucket is full again
+}
+
+// NewMemoryBucketStore creates an empty in-memory store
+func NewMemoryBucketStore() *MemoryBucketStore {
+	return &MemoryBucketStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take implements BucketStore
+func (s *MemoryBucketStore) Take(ctx context.Context, key string, cfg BucketConfig) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(cfg.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	refilled := float64(now.Sub(b.updated)) / float64(cfg.Refill)
+	b.tokens = math.Min(float64(cfg.Burst), b.tokens+refilled)
+	b.updated = now
+	b.idleAfter = time.Duration(cfg.Burst+1) * cfg.Refill
+
+	s.sweeps++
+	if s.sweeps%1000 == 0 {
+		s.dropIdle(now)
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) * float64(cfg.Refill)), nil
+}
+
+// Refund implements BucketStore
+func (s *MemoryBucketStore) Refund(ctx context.Context, key string, cfg BucketConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(cfg.Burst), b.tokens+1)
+	}
+	return nil
+}
+
+// dropIdle forgets buckets that have refilled completely; callers must hold s.mu
+func (s *MemoryBucketStore) dropIdle(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.idleAfter {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// redisTokenBucket atomically refills and takes from a bucket stored as a hash.
+// Returns {allowed, wait_ms}.
+var redisTokenBucket = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local refill_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + (now - updated) / refill_ms)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) * refill_ms)
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "updated", now)
+redis.call("PEXPIRE", KEYS[1], (burst + 1) * refill_ms)
+return {allowed, wait}
+`)
+
+// redisTokenRefund returns one token to a bucket, up to its burst; a bucket
+// that expired is full already
+var redisTokenRefund = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens then
+  redis.call("HSET", KEYS[1], "tokens", math.min(burst, tokens + 1))
+end
+return 0
+`)
+
+// RedisBucketStore keeps token buckets in Redis so all replicas share quotas
+type RedisBucketStore struct {
+	Client redis.Scripter
+	Prefix string
+}
+
+// NewRedisBucketStore creates a store using keys prefixed with "agno:ratelimit:"
+func NewRedisBucketStore(client redis.Scripter) *RedisBucketStore {
+	return &RedisBucketStore{Client: client, Prefix: "agno:ratelimit:"}
+}
+
+// Take implements BucketStore
+func (s *RedisBucketStore) Take(ctx context.Context, key string, cfg BucketConfig) (bool, time.Duration, error) {
+	result, err := redisTokenBucket.Run(ctx, s.Client, []string{s.Prefix + key},
+		cfg.Burst, cfg.Refill.Milliseconds(), time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result: %v", result)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// Refund implements BucketStore
+func (s *RedisBucketStore) Refund(ctx context.Context, key string, cfg BucketConfig) error {
+	if err := redisTokenRefund.Run(ctx, s.Client, []string{s.Prefix + key}, cfg.Burst).Err(); err != nil {
+		return fmt.Errorf("failed to run rate limit refund script: %w", err)
+	}
+	return nil
+}
+
+// envInt reads an integer environment variable, falling back to def
+func envInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		logger.Warnf("Invalid %s=%q, using default %d", name, v, def)
+	}
+	return def
+}
+
+// envDuration reads a duration environment variable, falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		logger.Warnf("Invalid %s=%q, using default %s", name, v, def)
+	}
+	return def
+}
@@ -0,0 +1,331 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// DeliveryHeader carries the ID of a relayed exchange, which stays the same
+// across retries so receivers can drop duplicates
+const DeliveryHeader = "X-Agno-Delivery"
+
+var relayDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "relay",
+	Name:      "deliveries_total",
+	Help:      "Q&A pairs relayed to per-chat webhooks by result (sent, retry, failed, dropped).",
+}, []string{"result"})
+
+// RelayConfig is the outbound webhook a chat's Q&A pairs are mirrored to,
+// e.g. a CRM timeline
+type RelayConfig struct {
+	ChatID string `json:"chat_id"`
+	URL    string `json:"url"`
+
+	// Secret signs deliveries like SignRequest, so receivers can check them
+	// with VerifyRequest; KeyID names the secret for rotation (both optional)
+	Secret string `json:"secret,omitempty"`
+	KeyID  string `json:"key_id,omitempty"`
+
+	// Headers are added to every delivery, e.g. the receiver's API key
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// RelayedExchange is the JSON body posted to a relay
+type RelayedExchange struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	ChatID    string    `json:"chat_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id"`
+	MessageID string    `json:"message_id,omitempty"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Model     string    `json:"model,omitempty"`
+}
+
+// relayJob is a pending delivery
+type relayJob struct {
+	config   RelayConfig
+	exchange RelayedExchange
+}
+
+// AnswerRelay mirrors the Q&A pairs of configured chats to their webhooks
+// (set AgnoClient.Relay, or call Mirror for streamed answers). Deliveries
+// are posted in the background and retried with backoff; the exchange ID
+// stays the same across attempts.
+type AnswerRelay struct {
+	HTTPClient  *http.Client
+	MaxAttempts int
+	Backoff     time.Duration // delay before the first retry, doubled per attempt
+	Queue       int           // deliveries buffered for the background sender
+
+	mu     sync.RWMutex
+	relays map[string]RelayConfig
+	queue  chan relayJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewAnswerRelay creates a relay without chats, giving each delivery 5
+// attempts starting 2s apart
+func NewAnswerRelay() *AnswerRelay {
+	return &AnswerRelay{
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     2 * time.Second,
+		Queue:       256,
+		relays:      make(map[string]RelayConfig),
+	}
+}
+
+// NewAnswerRelayFromEnv loads relays from the JSON file at AGNO_RELAYS_FILE
+// (an array of RelayConfig); AGNO_RELAY_MAX_ATTEMPTS overrides the attempts
+func NewAnswerRelayFromEnv() (*AnswerRelay, error) {
+	r := NewAnswerRelay()
+	if v := os.Getenv("AGNO_RELAY_MAX_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("invalid AGNO_RELAY_MAX_ATTEMPTS %q: want a positive number", v)
+		}
+		r.MaxAttempts = attempts
+	}
+	path := os.Getenv("AGNO_RELAYS_FILE")
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relay file: %w", err)
+	}
+	var configs []RelayConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse relay file %s: %w", path, err)
+	}
+	for _, cfg := range configs {
+		if err := r.Set(cfg); err != nil {
+			return nil, err
+		}
+	}
+	logger.Infof("Relaying answers of %d chat(s)", len(configs))
+	return r, nil
+}
+
+// Set configures the relay of a chat, replacing any previous one
+func (r *AnswerRelay) Set(cfg RelayConfig) error {
+	if cfg.ChatID == "" {
+		return fmt.Errorf("%w: relay without chat_id", ErrInvalidRequest)
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: relay of chat %s has invalid url %q", ErrInvalidRequest, cfg.ChatID, cfg.URL)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.relays[cfg.ChatID] = cfg
+	return nil
+}
+
+// Remove stops relaying a chat
+func (r *AnswerRelay) Remove(chatID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.relays, chatID)
+}
+
+// Get returns the relay of a chat
+func (r *AnswerRelay) Get(chatID string) (RelayConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cfg, ok := r.relays[chatID]
+	return cfg, ok
+}
+
+// Mirror relays an exchange if its chat has a relay. It returns at once:
+// the delivery is queued when the relay is started, or sent from its own
+// goroutine otherwise.
+func (r *AnswerRelay) Mirror(ctx context.Context, exchange RelayedExchange) {
+	cfg, ok := r.Get(exchange.ChatID)
+	if !ok {
+		return
+	}
+	if exchange.ID == "" {
+		id := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, id); err != nil {
+			logger.Errorf("Failed to generate relay delivery ID: %v", err)
+			return
+		}
+		exchange.ID = hex.EncodeToString(id)
+	}
+	if exchange.Time.IsZero() {
+		exchange.Time = time.Now().UTC()
+	}
+
+	job := relayJob{config: cfg, exchange: exchange}
+	r.mu.RLock()
+	queue := r.queue
+	r.mu.RUnlock()
+	if queue == nil {
+		go r.deliver(context.Background(), job)
+		return
+	}
+	select {
+	case queue <- job:
+	default:
+		relayDeliveries.WithLabelValues("dropped").Inc()
+		logger.Warnf("Relay queue full, dropping exchange %s of chat %s", exchange.ID, exchange.ChatID)
+	}
+}
+
+// Start delivers queued exchanges in the background
+func (r *AnswerRelay) Start() {
+	queue := make(chan relayJob, r.Queue)
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for {
+			select {
+			case job := <-queue:
+				r.deliver(ctx, job)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	r.mu.Lock()
+	r.queue = queue
+	r.mu.Unlock()
+}
+
+// Stop stops the background sender, abandoning the delivery in progress;
+// queued exchanges are dropped
+func (r *AnswerRelay) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.mu.Lock()
+	r.queue = nil
+	r.mu.Unlock()
+	r.cancel()
+	<-r.done
+	r.cancel = nil
+}
+
+// deliver posts an exchange, retrying with backoff
+func (r *AnswerRelay) deliver(ctx context.Context, job relayJob) {
+	body, err := json.Marshal(job.exchange)
+	if err != nil {
+		logger.Errorf("Failed to marshal relayed exchange %s: %v", job.exchange.ID, err)
+		return
+	}
+
+	delay := r.Backoff
+	for attempt := 1; ; attempt++ {
+		err := r.post(ctx, job.config, job.exchange.ID, body)
+		if err == nil {
+			relayDeliveries.WithLabelValues("sent").Inc()
+			return
+		}
+		if attempt >= r.MaxAttempts || !retryableRelayError(err) {
+			relayDeliveries.WithLabelValues("failed").Inc()
+			logger.Errorf("Giving up relaying exchange %s of chat %s after %d attempt(s): %v",
+				job.exchange.ID, job.config.ChatID, attempt, err)
+			return
+		}
+		relayDeliveries.WithLabelValues("retry").Inc()
+		logger.Warnf("Failed to relay exchange %s of chat %s (attempt %d), retrying in %s: %v",
+			job.exchange.ID, job.config.ChatID, attempt, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay *= 2
+	}
+}
+
+// post sends one signed delivery
+func (r *AnswerRelay) post(ctx context.Context, cfg RelayConfig, deliveryID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set(DeliveryHeader, deliveryID)
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, SignRequest([]byte(cfg.Secret), timestamp, req.Method, req.URL.RequestURI(), body))
+		if cfg.KeyID != "" {
+			req.Header.Set(KeyIDHeader, cfg.KeyID)
+		}
+	}
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return newAPIError(resp.StatusCode, respBody)
+}
+
+// retryableRelayError reports whether a failed delivery may succeed later:
+// network errors, rate limiting and server errors
+func retryableRelayError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500 ||
+		apiErr.StatusCode == http.StatusRequestTimeout
+}
+
+// relayChat mirrors a finished chat made for a Lark chat (see WithRequester)
+func (c *AgnoClient) relayChat(ctx context.Context, req ChatRequest, resp *ChatResponse) {
+	userID, chatID := RequesterFromContext(ctx)
+	if chatID == "" {
+		return
+	}
+	exchange := RelayedExchange{
+		ChatID:    chatID,
+		UserID:    userID,
+		SessionID: req.SessionID,
+		MessageID: resp.MessageID,
+		Question:  req.Message,
+		Answer:    resp.Response,
+		Model:     req.Model,
+	}
+	if resp.Usage != nil && resp.Usage.Model != "" {
+		exchange.Model = resp.Usage.Model
+	}
+	c.Relay.Mirror(ctx, exchange)
+}
@@ -0,0 +1,356 @@
+package agno
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// scheduleUsage is the help text of the /schedule command
+const scheduleUsage = "Usage:\n" +
+	"`/schedule` lists this chat's schedules\n" +
+	"`/schedule add <cron> <prompt>`, e.g. `/schedule add 30 9 * * mon-fri Post today's stand-up reminder`\n" +
+	"`/schedule remove <id>`"
+
+// ScheduledMessage is a prompt the agent answers on a cron schedule, posting
+// the result to a Lark chat (stand-up reminders, report digests, ...)
+type ScheduledMessage struct {
+	ID           string    `json:"id"`
+	ChatID       string    `json:"chat_id"`
+	Cron         string    `json:"cron"`
+	TimeZone     string    `json:"time_zone,omitempty"` // IANA name, default the scheduler's
+	Prompt       string    `json:"prompt"`
+	AgentID      string    `json:"agent_id,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`        // picks the holiday calendar
+	SkipHolidays bool      `json:"skip_holidays,omitempty"` // skip runs on weekends and holidays
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	NextRun      time.Time `json:"next_run"`
+	LastRun      time.Time `json:"last_run,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// Scheduler runs scheduled messages. Schedules are persisted under
+// "schedule:<id>" keys so they survive restarts, and each run is claimed so
+// only one replica posts it.
+type Scheduler struct {
+	Store     SessionStore
+	Client    AgnoService
+	Sender    MessageSender    // e.g. an Outbox
+	Claims    IdempotencyStore // optional with a single replica
+	Calendars *HolidayCalendars
+	Quiet     ProactiveGate  // skips runs for chats in quiet mode before asking the agent (optional)
+	Location  *time.Location // default time zone of new schedules
+	Interval  time.Duration  // how often due schedules are checked
+	Grace     time.Duration  // runs missed by more than this (e.g. while down) are skipped
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler creates a scheduler checking every 30s in UTC and skipping
+// runs missed by more than an hour
+func NewScheduler(store SessionStore, client AgnoService, sender MessageSender) *Scheduler {
+	return &Scheduler{
+		Store:    store,
+		Client:   client,
+		Sender:   sender,
+		Location: time.UTC,
+		Interval: 30 * time.Second,
+		Grace:    time.Hour,
+	}
+}
+
+// Add validates and stores a schedule, returning it with its ID and first run
+func (s *Scheduler) Add(ctx context.Context, msg ScheduledMessage) (*ScheduledMessage, error) {
+	if msg.ChatID == "" || strings.TrimSpace(msg.Prompt) == "" {
+		return nil, fmt.Errorf("%w: a schedule needs a chat and a prompt", ErrInvalidRequest)
+	}
+	cron, err := s.cron(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	id := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, fmt.Errorf("failed to generate schedule ID: %w", err)
+	}
+	msg.ID = hex.EncodeToString(id)
+	msg.CreatedAt = time.Now().UTC()
+	msg.NextRun = s.next(cron, msg, time.Now())
+	if msg.NextRun.IsZero() {
+		return nil, fmt.Errorf("%w: schedule %q never runs", ErrInvalidRequest, msg.Cron)
+	}
+	if err := s.save(ctx, msg); err != nil {
+		return nil, err
+	}
+	logger.Infof("Added schedule %s (%s) for chat %s", msg.ID, msg.Cron, msg.ChatID)
+	return &msg, nil
+}
+
+// Get returns a schedule
+func (s *Scheduler) Get(ctx context.Context, id string) (*ScheduledMessage, error) {
+	var msg ScheduledMessage
+	if _, err := scheduleSchema.loadRecord(ctx, s.Store, "schedule:"+id, &msg, 0); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// List returns the schedules of a chat ("" for all chats), by next run
+func (s *Scheduler) List(ctx context.Context, chatID string) ([]ScheduledMessage, error) {
+	keys, err := s.Store.Keys(ctx, "schedule:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	var list []ScheduledMessage
+	for _, key := range keys {
+		msg, err := s.Get(ctx, strings.TrimPrefix(key, "schedule:"))
+		if err != nil {
+			continue
+		}
+		if chatID == "" || msg.ChatID == chatID {
+			list = append(list, *msg)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NextRun.Before(list[j].NextRun) })
+	return list, nil
+}
+
+// Remove deletes a schedule of a chat
+func (s *Scheduler) Remove(ctx context.Context, chatID, id string) error {
+	msg, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if msg.ChatID != chatID {
+		return fmt.Errorf("%w: schedule %s", ErrKeyNotFound, id)
+	}
+	if err := s.Store.Delete(ctx, "schedule:"+id); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	logger.Infof("Removed schedule %s of chat %s", id, chatID)
+	return nil
+}
+
+// Start runs due schedules every Interval
+func (s *Scheduler) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.RunDue(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background runs
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// RunDue runs every schedule whose next run has come
+func (s *Scheduler) RunDue(ctx context.Context) {
+	list, err := s.List(ctx, "")
+	if err != nil {
+		logger.Errorf("Scheduler run failed: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, msg := range list {
+		if now.Before(msg.NextRun) {
+			continue
+		}
+		if s.Claims != nil {
+			first, err := s.Claims.Claim(ctx, fmt.Sprintf("schedule:%s:%d", msg.ID, msg.NextRun.Unix()), 10*time.Minute)
+			if err != nil || !first {
+				continue
+			}
+		}
+		s.run(ctx, msg, now)
+	}
+}
+
+// run answers a due schedule's prompt, posts the answer and plans the next run
+func (s *Scheduler) run(ctx context.Context, msg ScheduledMessage, now time.Time) {
+	cron, err := s.cron(msg)
+	if err != nil {
+		logger.Errorf("Schedule %s has an invalid cron expression, removing it: %v", msg.ID, err)
+		s.Store.Delete(ctx, "schedule:"+msg.ID)
+		return
+	}
+
+	if late := now.Sub(msg.NextRun); late > s.Grace {
+		logger.Warnf("Skipping run of schedule %s missed by %s", msg.ID, late.Round(time.Second))
+	} else {
+		msg.LastRun, msg.LastError = now.UTC(), ""
+		if err := s.deliver(ctx, msg); errors.Is(err, ErrProactiveSuppressed) {
+			logger.Infof("Skipping run of schedule %s, chat %s is in quiet mode", msg.ID, msg.ChatID)
+		} else if err != nil {
+			msg.LastError = err.Error()
+			logger.Errorf("Scheduled message %s for chat %s failed: %v", msg.ID, msg.ChatID, err)
+		}
+	}
+
+	msg.NextRun = s.next(cron, msg, now)
+	if msg.NextRun.IsZero() {
+		logger.Warnf("Schedule %s never runs again, removing it", msg.ID)
+		s.Store.Delete(ctx, "schedule:"+msg.ID)
+		return
+	}
+	if err := s.save(ctx, msg); err != nil {
+		logger.Errorf("Failed to save next run of schedule %s: %v", msg.ID, err)
+	}
+}
+
+// deliver asks the agent for the scheduled message and posts it
+func (s *Scheduler) deliver(ctx context.Context, msg ScheduledMessage) error {
+	if s.Quiet != nil && !s.Quiet.ProactiveAllowed(ctx, "chat_id", msg.ChatID) {
+		proactiveSuppressed.WithLabelValues("scheduler").Inc()
+		return ErrProactiveSuppressed
+	}
+	resp, err := s.Client.SendChat(WithRequester(ctx, msg.CreatedBy, msg.ChatID), ChatRequest{
+		SessionID: "schedule-" + msg.ID,
+		Message:   msg.Prompt,
+		AgentID:   msg.AgentID,
+		NoCache:   true,
+		Metadata:  map[string]string{"trigger": "schedule", "schedule_id": msg.ID},
+	})
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(map[string]string{"text": resp.Response})
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled message: %w", err)
+	}
+	_, err = s.Sender.SendMessage(ctx, OutboxMessage{
+		ReceiveID:     msg.ChatID,
+		ReceiveIDType: "chat_id",
+		MsgType:       "text",
+		Content:       string(content),
+		SessionID:     "schedule-" + msg.ID,
+		Proactive:     true,
+	})
+	return err
+}
+
+// cron parses a schedule's expression in its time zone
+func (s *Scheduler) cron(msg ScheduledMessage) (*CronSchedule, error) {
+	loc := s.Location
+	if msg.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(msg.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone: %w", err)
+		}
+	}
+	return ParseCron(msg.Cron, loc)
+}
+
+// next returns the run after t, skipping non-business days when asked
+func (s *Scheduler) next(cron *CronSchedule, msg ScheduledMessage, t time.Time) time.Time {
+	next := cron.Next(t)
+	if !msg.SkipHolidays || s.Calendars == nil {
+		return next
+	}
+	calendar := s.Calendars.Calendar(msg.Tenant)
+	for i := 0; i < 1000 && !next.IsZero() && !calendar.IsBusinessDay(next); i++ {
+		next = cron.Next(next)
+	}
+	return next
+}
+
+// save writes a schedule
+func (s *Scheduler) save(ctx context.Context, msg ScheduledMessage) error {
+	data, err := scheduleSchema.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+	if err := s.Store.Set(ctx, "schedule:"+msg.ID, data, 0); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// HandleScheduleCommand handles "/schedule" in a chat: listing, adding
+// ("/schedule add <cron> <prompt>", the cron being five fields or a macro
+// like @daily) and removing schedules. It returns false if text is not the
+// command.
+func (s *Scheduler) HandleScheduleCommand(ctx context.Context, chatID, userID, text string) (string, bool, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "/schedule" {
+		return "", false, nil
+	}
+
+	switch {
+	case len(fields) == 1:
+		list, err := s.List(ctx, chatID)
+		if err != nil {
+			return "", true, err
+		}
+		if len(list) == 0 {
+			return "No schedules in this chat.\n" + scheduleUsage, true, nil
+		}
+		var b strings.Builder
+		b.WriteString("**Scheduled messages**\n")
+		for _, msg := range list {
+			fmt.Fprintf(&b, "- `%s` `%s` — %s (next: %s)\n", msg.ID, msg.Cron, msg.Prompt,
+				msg.NextRun.In(s.Location).Format("Mon Jan 2, 15:04 MST"))
+		}
+		return b.String(), true, nil
+
+	case fields[1] == "add":
+		cronFields := 5
+		if len(fields) > 2 && strings.HasPrefix(fields[2], "@") {
+			cronFields = 1
+		}
+		if len(fields) < 3+cronFields {
+			return scheduleUsage, true, nil
+		}
+		msg, err := s.Add(ctx, ScheduledMessage{
+			ChatID:    chatID,
+			Cron:      strings.Join(fields[2:2+cronFields], " "),
+			Prompt:    strings.Join(fields[2+cronFields:], " "),
+			CreatedBy: userID,
+		})
+		if errors.Is(err, ErrInvalidRequest) {
+			return fmt.Sprintf("%v\n%s", err, scheduleUsage), true, nil
+		} else if err != nil {
+			return "", true, err
+		}
+		return fmt.Sprintf("⏰ Scheduled `%s`. First run: %s", msg.ID,
+			msg.NextRun.In(s.Location).Format("Mon Jan 2, 15:04 MST")), true, nil
+
+	case fields[1] == "remove" && len(fields) == 3:
+		err := s.Remove(ctx, chatID, fields[2])
+		if errors.Is(err, ErrKeyNotFound) {
+			return fmt.Sprintf("No schedule `%s` in this chat.", fields[2]), true, nil
+		} else if err != nil {
+			return "", true, err
+		}
+		return fmt.Sprintf("Removed schedule `%s`.", fields[2]), true, nil
+
+	default:
+		return scheduleUsage, true, nil
+	}
+}
@@ -0,0 +1,185 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// schemaVersionField is the field holding a stored record's schema version
+const schemaVersionField = "schema_version"
+
+// legacyValueField holds a version 0 record that is not a JSON object
+// (e.g. a bare string or array) for the migrations to convert
+const legacyValueField = "legacy_value"
+
+var (
+	schemaMigrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "schema",
+		Name:      "migrated_total",
+		Help:      "Stored records upgraded to the current schema on read, by kind and original version.",
+	}, []string{"kind", "from"})
+
+	schemaNewer = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "schema",
+		Name:      "newer_total",
+		Help:      "Stored records read with a schema newer than this release knows, by kind.",
+	}, []string{"kind"})
+)
+
+// Migration upgrades a decoded record from one schema version to the next
+type Migration func(record map[string]interface{}) error
+
+// RecordSchema versions the JSON records of one kind kept in a
+// SessionStore, so their format can change between releases without
+// wiping the store. Records carry their version in a "schema_version"
+// field; records written before versioning are version 0. On read, older
+// records are upgraded through Migrations[v] (from v to v+1) and written
+// back. Version 0 records that aren't JSON objects reach the migrations
+// as {"legacy_value": <value>}. Records from a newer release, e.g. during
+// a rolling deploy, are decoded as well as possible and left untouched.
+type RecordSchema struct {
+	Kind       string
+	Version    int
+	Migrations map[int]Migration
+}
+
+// Record schemas of the stored records. Bump Version and add a migration
+// from the previous version when changing a record's format.
+var (
+	sessionHistorySchema = &RecordSchema{Kind: "chathistory", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	userSettingsSchema   = &RecordSchema{Kind: "usersettings", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	tenantConfigSchema   = &RecordSchema{Kind: "tenant", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	scheduleSchema       = &RecordSchema{Kind: "schedule", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	holidaySchema        = &RecordSchema{Kind: "holiday", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	legalHoldSchema      = &RecordSchema{Kind: "hold", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	outboxSchema         = &RecordSchema{Kind: "outbox", Version: 1, Migrations: map[int]Migration{0: stampSchema}}
+	usageSchema          = &RecordSchema{Kind: "usage", Version: 1, Migrations: map[int]Migration{0: wrapUsageModels}}
+)
+
+// stampSchema is the migration of records written before versioning,
+// whose format is version 1's
+func stampSchema(record map[string]interface{}) error {
+	return nil
+}
+
+// wrapUsageModels moves the per-model totals of a version 0 usage record,
+// stored as the whole object, under "models"
+func wrapUsageModels(record map[string]interface{}) error {
+	models := make(map[string]interface{}, len(record))
+	for model, totals := range record {
+		models[model] = totals
+		delete(record, model)
+	}
+	record["models"] = models
+	return nil
+}
+
+// Encode marshals v, a struct or map encoding to a JSON object, with the
+// current schema version
+func (s *RecordSchema) Encode(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("%s record is not a JSON object", s.Kind)
+	}
+	version := []byte(`{"` + schemaVersionField + `":` + strconv.Itoa(s.Version))
+	if !bytes.Equal(data, []byte("{}")) {
+		version = append(version, ',')
+	}
+	return append(version, data[1:]...), nil
+}
+
+// Decode unmarshals data into v, migrating it to the current schema first.
+// It returns the record in the current schema when it was migrated, to be
+// written back, and nil otherwise.
+func (s *RecordSchema) Decode(data []byte, v interface{}) ([]byte, error) {
+	var header struct {
+		Version int `json:"schema_version"`
+	}
+	object := bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	if object {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case header.Version == s.Version:
+		return nil, json.Unmarshal(data, v)
+	case header.Version > s.Version:
+		schemaNewer.WithLabelValues(s.Kind).Inc()
+		logger.Debugf("Reading %s record of schema %d with schema %d", s.Kind, header.Version, s.Version)
+		return nil, json.Unmarshal(data, v)
+	}
+
+	record := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep large integers exact
+	if object {
+		if err := dec.Decode(&record); err != nil {
+			return nil, err
+		}
+	} else {
+		var legacy interface{}
+		if err := dec.Decode(&legacy); err != nil {
+			return nil, err
+		}
+		record[legacyValueField] = legacy
+	}
+	for version := header.Version; version < s.Version; version++ {
+		migrate, ok := s.Migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration of %s records from schema %d", s.Kind, version)
+		}
+		if err := migrate(record); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s record from schema %d: %w", s.Kind, version, err)
+		}
+	}
+	if _, ok := record[legacyValueField]; ok {
+		return nil, fmt.Errorf("%s record is not a JSON object and no migration converted it", s.Kind)
+	}
+	delete(record, schemaVersionField)
+	upgraded, err := s.Encode(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(upgraded, v); err != nil {
+		return nil, err
+	}
+	schemaMigrations.WithLabelValues(s.Kind, strconv.Itoa(header.Version)).Inc()
+	return upgraded, nil
+}
+
+// loadRecord reads the record at key into v, writing it back with ttl when
+// it was migrated. A failed write-back is logged; the next read migrates
+// again. Like the stores' other read-modify-write paths, the write-back is
+// not atomic: a write of the same key in between is overwritten.
+func (s *RecordSchema) loadRecord(ctx context.Context, store SessionStore, key string, v interface{}, ttl time.Duration) ([]byte, error) {
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	upgraded, err := s.Decode(data, v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	if upgraded == nil {
+		return data, nil
+	}
+	if err := store.Set(ctx, key, upgraded, ttl); err != nil {
+		logger.Warnf("Failed to write back migrated %s: %v", key, err)
+	}
+	return upgraded, nil
+}
@@ -0,0 +1,22 @@
+package agno
+
+import "context"
+
+// AgnoService is the set of Agno operations the bot depends on. *AgnoClient
+// implements it; tests can substitute agnotest.Fake.
+type AgnoService interface {
+	Chat(sessionID, message string, history []Message) (string, error)
+	ChatContext(ctx context.Context, sessionID, message string, history []Message) (string, error)
+	SendChat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error)
+	Health() (*HealthResponse, error)
+	HealthContext(ctx context.Context) (*HealthResponse, error)
+	ClearSession(sessionID string) error
+	ClearSessionContext(ctx context.Context, sessionID string) error
+	ListAgents() ([]AgentInfo, error)
+	ListAgentsContext(ctx context.Context) ([]AgentInfo, error)
+	CheckConnection() error
+	CheckConnectionContext(ctx context.Context) error
+}
+
+var _ AgnoService = (*AgnoClient)(nil)
@@ -0,0 +1,227 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// Overflow policies of a SessionQueue
+const (
+	// QueueOverflowReject turns away new messages while a session's queue is full
+	QueueOverflowReject = "reject"
+	// QueueOverflowDropOldest drops the oldest waiting message to make room
+	QueueOverflowDropOldest = "drop_oldest"
+)
+
+// ErrSessionBusy is returned for a message that was turned away or dropped
+// because its session had too many messages queued
+var ErrSessionBusy = errors.New("agno: too many messages queued for this session")
+
+// DefaultSessionBusyReply is sent when a message is turned away
+const DefaultSessionBusyReply = "⏳ I'm still working on your earlier messages. Please wait for my reply before sending more."
+
+var (
+	sessionQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "session_queue",
+		Name:      "depth",
+		Help:      "Messages waiting for an earlier message of their session to finish.",
+	})
+
+	sessionQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "agno",
+		Subsystem: "session_queue",
+		Name:      "wait_seconds",
+		Help:      "Time messages waited for their session before being processed.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	})
+
+	sessionQueueOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "session_queue",
+		Name:      "overflows_total",
+		Help:      "Messages turned away or dropped because their session's queue was full, by policy.",
+	}, []string{"policy"})
+)
+
+// queueTicket is a message waiting for its session
+type queueTicket struct {
+	ready chan struct{} // closed when the message may run, or was dropped
+	err   error         // set before ready is closed when dropped
+}
+
+// sessionLane is the queue of one session; it exists while a message of
+// the session is running
+type sessionLane struct {
+	waiting []*queueTicket
+}
+
+// SessionQueue serializes work within a session while sessions run in
+// parallel, so quick successive messages reach the agent, and their replies
+// the chat, in the order they were sent. At most MaxPending messages wait
+// per session; Overflow decides what happens to the next one.
+type SessionQueue struct {
+	MaxPending int
+	Overflow   string // QueueOverflowReject or QueueOverflowDropOldest
+
+	mu     sync.Mutex
+	lanes  map[string]*sessionLane
+	closed bool
+}
+
+// NewSessionQueue creates a queue holding up to 5 waiting messages per
+// session and rejecting more
+func NewSessionQueue() *SessionQueue {
+	return &SessionQueue{
+		MaxPending: 5,
+		Overflow:   QueueOverflowReject,
+		lanes:      make(map[string]*sessionLane),
+	}
+}
+
+// NewSessionQueueFromEnv configures a queue from AGNO_SESSION_QUEUE_MAX
+// (default 5) and AGNO_SESSION_QUEUE_OVERFLOW ("reject" or "drop_oldest")
+func NewSessionQueueFromEnv() *SessionQueue {
+	q := NewSessionQueue()
+	q.MaxPending = envInt("AGNO_SESSION_QUEUE_MAX", q.MaxPending)
+	switch v := os.Getenv("AGNO_SESSION_QUEUE_OVERFLOW"); v {
+	case "":
+	case QueueOverflowReject, QueueOverflowDropOldest:
+		q.Overflow = v
+	default:
+		logger.Warnf("Invalid AGNO_SESSION_QUEUE_OVERFLOW=%q, using %s", v, q.Overflow)
+	}
+	return q
+}
+
+// Do runs fn once every earlier call for sessionID has returned. It
+// returns ErrSessionBusy if the message was turned away or dropped, and
+// ctx's error if ctx ends while waiting, and ErrShuttingDown once the
+// queue is closed.
+func (q *SessionQueue) Do(ctx context.Context, sessionID string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	traced := TraceStage(ctx, StageQueue)
+	err := q.acquire(ctx, sessionID)
+	traced(err, nil)
+	if err != nil {
+		return err
+	}
+	defer q.release(sessionID)
+	sessionQueueWait.Observe(time.Since(start).Seconds())
+	return fn(ctx)
+}
+
+// Pending returns how many messages of a session are waiting
+func (q *SessionQueue) Pending(sessionID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if lane, ok := q.lanes[sessionID]; ok {
+		return len(lane.waiting)
+	}
+	return 0
+}
+
+// Close turns away new messages and the waiting ones with ErrShuttingDown,
+// letting running ones finish, and returns how many were waiting
+func (q *SessionQueue) Close() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	n := 0
+	for _, lane := range q.lanes {
+		for _, ticket := range lane.waiting {
+			ticket.err = ErrShuttingDown
+			close(ticket.ready)
+			sessionQueueDepth.Dec()
+			n++
+		}
+		lane.waiting = nil
+	}
+	return n
+}
+
+// acquire waits until the caller owns the session's lane
+func (q *SessionQueue) acquire(ctx context.Context, sessionID string) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrShuttingDown
+	}
+	lane, busy := q.lanes[sessionID]
+	if !busy {
+		q.lanes[sessionID] = &sessionLane{}
+		q.mu.Unlock()
+		return nil
+	}
+
+	if len(lane.waiting) >= q.MaxPending {
+		sessionQueueOverflows.WithLabelValues(q.Overflow).Inc()
+		if q.Overflow != QueueOverflowDropOldest || len(lane.waiting) == 0 {
+			q.mu.Unlock()
+			logger.Warnf("Session %s has %d messages queued, turning one away", sessionID, len(lane.waiting))
+			return ErrSessionBusy
+		}
+		oldest := lane.waiting[0]
+		lane.waiting = lane.waiting[1:]
+		oldest.err = ErrSessionBusy
+		close(oldest.ready)
+		sessionQueueDepth.Dec()
+		logger.Warnf("Session %s has %d messages queued, dropping the oldest", sessionID, len(lane.waiting)+1)
+	}
+	ticket := &queueTicket{ready: make(chan struct{})}
+	lane.waiting = append(lane.waiting, ticket)
+	sessionQueueDepth.Inc()
+	q.mu.Unlock()
+
+	select {
+	case <-ticket.ready:
+		return ticket.err
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, t := range lane.waiting {
+		if t == ticket {
+			lane.waiting = append(lane.waiting[:i], lane.waiting[i+1:]...)
+			sessionQueueDepth.Dec()
+			return ctx.Err()
+		}
+	}
+	// The lane was handed over (or the ticket dropped) as ctx ended
+	if ticket.err == nil {
+		q.releaseLocked(sessionID)
+	}
+	return ctx.Err()
+}
+
+// release hands the session's lane to its next waiting message
+func (q *SessionQueue) release(sessionID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked(sessionID)
+}
+
+// releaseLocked is release with q.mu held
+func (q *SessionQueue) releaseLocked(sessionID string) {
+	lane, ok := q.lanes[sessionID]
+	if !ok {
+		return
+	}
+	if len(lane.waiting) == 0 {
+		delete(q.lanes, sessionID)
+		return
+	}
+	next := lane.waiting[0]
+	lane.waiting = lane.waiting[1:]
+	sessionQueueDepth.Dec()
+	close(next.ready)
+}
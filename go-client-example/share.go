@@ -0,0 +1,235 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// errShareNotAsker is returned when someone other than the asker tries to share
+var errShareNotAsker = errors.New("only the person who asked can share this answer")
+
+// Card action values used by the share flow
+const (
+	shareActionKey    = "share_action"
+	shareAnswerKey    = "share_answer_id"
+	shareActionOpen   = "open"
+	shareActionSubmit = "submit"
+
+	shareUserField = "share_user"
+	shareChatField = "share_chat"
+)
+
+// SharedAnswer is a question/answer pair that can be shared with colleagues
+type SharedAnswer struct {
+	SessionID string
+	AskerID   string
+	Question  string
+	Answer    string
+	CreatedAt time.Time
+}
+
+// ShareTarget is where a shared answer is posted
+type ShareTarget struct {
+	ReceiveIDType string // "chat_id" or "open_id"
+	ReceiveID     string
+}
+
+// ShareChatOption is a chat offered in the share picker
+type ShareChatOption struct {
+	ChatID string
+	Name   string
+}
+
+// ShareSender posts an interactive card to a Lark chat or user (implemented by the bot)
+type ShareSender interface {
+	SendCard(ctx context.Context, target ShareTarget, card map[string]interface{}) error
+}
+
+// Sharer implements the "Share this answer" card flow
+type Sharer struct {
+	Sender    ShareSender
+	Analytics AnalyticsRecorder
+	TTL       time.Duration // how long answers stay shareable
+
+	mu      sync.Mutex
+	answers map[string]SharedAnswer
+}
+
+// NewSharer creates a Sharer whose answers stay shareable for 7 days
+func NewSharer(sender ShareSender, analytics AnalyticsRecorder) *Sharer {
+	return &Sharer{
+		Sender:    sender,
+		Analytics: analytics,
+		TTL:       7 * 24 * time.Hour,
+		answers:   make(map[string]SharedAnswer),
+	}
+}
+
+// Remember stores an answer under the Lark message ID of the bot reply
+func (s *Sharer) Remember(answerMsgID string, answer SharedAnswer) {
+	if answer.CreatedAt.IsZero() {
+		answer.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired answers while we hold the lock
+	cutoff := time.Now().Add(-s.TTL)
+	for id, a := range s.answers {
+		if a.CreatedAt.Before(cutoff) {
+			delete(s.answers, id)
+		}
+	}
+	s.answers[answerMsgID] = answer
+}
+
+// lookup returns a remembered, unexpired answer
+func (s *Sharer) lookup(answerMsgID string) (SharedAnswer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, ok := s.answers[answerMsgID]
+	if !ok || time.Since(answer.CreatedAt) > s.TTL {
+		return SharedAnswer{}, false
+	}
+	return answer, true
+}
+
+// ShareButton returns the "Share this answer" button to add to an answer card
+func ShareButton(answerMsgID string) map[string]interface{} {
+	return callbackButton("📤 Share this answer", "default", map[string]interface{}{
+		shareActionKey: shareActionOpen,
+		shareAnswerKey: answerMsgID,
+	})
+}
+
+// BuildSharePickerCard builds the card that lets the user pick a colleague or
+// chat to share with. Submitting asks for explicit confirmation first.
+func BuildSharePickerCard(answerMsgID string, chats []ShareChatOption) map[string]interface{} {
+	chatOptions := make([]interface{}, 0, len(chats))
+	for _, chat := range chats {
+		chatOptions = append(chatOptions, map[string]interface{}{"text": plainText(chat.Name), "value": chat.ChatID})
+	}
+
+	submit := callbackButton("Share", "primary", map[string]interface{}{
+		shareActionKey: shareActionSubmit,
+		shareAnswerKey: answerMsgID,
+	})
+	submit["action_type"] = "form_submit"
+	submit["name"] = "share_submit"
+	submit["confirm"] = map[string]interface{}{
+		"title": plainText("Share this Q&A?"),
+		"text":  plainText("The question and the bot's answer will be visible to the selected recipients."),
+	}
+
+	formElements := []interface{}{
+		map[string]interface{}{
+			"tag":         "select_person",
+			"name":        shareUserField,
+			"placeholder": plainText("Choose a colleague"),
+		},
+	}
+	if len(chatOptions) > 0 {
+		formElements = append(formElements, map[string]interface{}{
+			"tag":         "select_static",
+			"name":        shareChatField,
+			"placeholder": plainText("…or a chat"),
+			"options":     chatOptions,
+		})
+	}
+	formElements = append(formElements, submit)
+
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": cardHeader("📤 Share this answer", "turquoise"),
+		"elements": []interface{}{
+			markdownElement("Pick one colleague or chat to send this question and answer to."),
+			map[string]interface{}{"tag": "form", "name": "share_form", "elements": formElements},
+		},
+	}
+}
+
+// buildSharedAnswerCard renders the Q&A as posted to the recipient
+func buildSharedAnswerCard(answer SharedAnswer, sharerID string) map[string]interface{} {
+	return map[string]interface{}{
+		"config": map[string]interface{}{"wide_screen_mode": true},
+		"header": cardHeader("💡 Shared answer", "green"),
+		"elements": []interface{}{
+			markdownElement(fmt.Sprintf("<at id=%s></at> shared this answer with you.", sharerID)),
+			map[string]interface{}{"tag": "hr"},
+			markdownElement("**Question**\n" + answer.Question),
+			markdownElement("**Answer**\n" + answer.Answer),
+		},
+	}
+}
+
+// HandleShareAction processes share card callbacks. It returns the card to
+// show in reply (the picker for "open", nil otherwise) and false if the action
+// does not belong to the share flow.
+func (s *Sharer) HandleShareAction(ctx context.Context, action CardAction, operatorID, chatID string, chats []ShareChatOption) (map[string]interface{}, bool, error) {
+	answerMsgID := action.StringValue(shareAnswerKey)
+	switch action.StringValue(shareActionKey) {
+	case shareActionOpen:
+		answer, ok := s.lookup(answerMsgID)
+		if !ok {
+			return nil, true, errors.New("this answer can no longer be shared")
+		}
+		if operatorID != answer.AskerID {
+			return nil, true, errShareNotAsker
+		}
+		return BuildSharePickerCard(answerMsgID, chats), true, nil
+	case shareActionSubmit:
+		return nil, true, s.share(ctx, action, answerMsgID, operatorID, chatID)
+	default:
+		return nil, false, nil
+	}
+}
+
+// share posts the answer to the selected recipient and records the share.
+// Only the asker can share, so confirming the share is their consent.
+func (s *Sharer) share(ctx context.Context, action CardAction, answerMsgID, operatorID, chatID string) error {
+	answer, ok := s.lookup(answerMsgID)
+	if !ok {
+		return errors.New("this answer can no longer be shared")
+	}
+	if operatorID != answer.AskerID {
+		logger.Warnf("User %s tried to share answer %s asked by %s", operatorID, answerMsgID, answer.AskerID)
+		return errShareNotAsker
+	}
+
+	var target ShareTarget
+	if userID, _ := action.FormValue[shareUserField].(string); userID != "" {
+		target = ShareTarget{ReceiveIDType: "open_id", ReceiveID: userID}
+	} else if targetChat, _ := action.FormValue[shareChatField].(string); targetChat != "" {
+		target = ShareTarget{ReceiveIDType: "chat_id", ReceiveID: targetChat}
+	} else {
+		return errors.New("pick a colleague or a chat to share with")
+	}
+
+	if err := s.Sender.SendCard(ctx, target, buildSharedAnswerCard(answer, operatorID)); err != nil {
+		logger.Errorf("Failed to share answer %s: %v", answerMsgID, err)
+		return fmt.Errorf("failed to share answer: %w", err)
+	}
+
+	logger.Infof("Answer %s shared by %s to %s %s", answerMsgID, operatorID, target.ReceiveIDType, target.ReceiveID)
+	recordAnalytics(ctx, s.Analytics, AnalyticsEvent{
+		Name:      "answer_shared",
+		SessionID: answer.SessionID,
+		UserID:    operatorID,
+		ChatID:    chatID,
+		Properties: map[string]interface{}{
+			"answer_message_id": answerMsgID,
+			"target_type":       target.ReceiveIDType,
+			"target_id":         target.ReceiveID,
+			"asker_id":          answer.AskerID,
+			"consented":         true,
+		},
+	})
+	return nil
+}
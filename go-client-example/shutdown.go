@@ -0,0 +1,352 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// ErrShuttingDown is returned for work refused or abandoned because the
+// process is shutting down. Through an EventGate, such events stay in the
+// replay buffer and are handled again after the restart.
+var ErrShuttingDown = errors.New("agno: shutting down")
+
+var (
+	shutdownInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "agno",
+		Subsystem: "shutdown",
+		Name:      "in_flight",
+		Help:      "Events and Agno requests the shutdown coordinator waits for.",
+	})
+
+	shutdownAbandoned = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "agno",
+		Subsystem: "shutdown",
+		Name:      "abandoned_total",
+		Help:      "In-flight work cancelled because it outlived the shutdown deadline.",
+	})
+)
+
+// shutdownHook is a named step of a shutdown phase
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Shutdown coordinates a graceful exit, so a deploy doesn't cut off agent
+// calls mid-generation. Run, or WaitForSignal, goes through these phases:
+//
+//  1. intake: new work is refused with ErrShuttingDown and the OnIntake
+//     hooks stop the event sources (HTTP server, long connection, ...)
+//  2. queues: messages still waiting in the added session queues fail with
+//     ErrShuttingDown, leaving them in the replay buffer for the restart
+//  3. drain: work started with Begin and Agno requests made through
+//     Middleware get up to Deadline to finish; then their contexts are
+//     cancelled
+//  4. flush: the OnFlush hooks write out buffered audit, usage and metrics
+//     data, with FlushTimeout
+type Shutdown struct {
+	Deadline     time.Duration // how long in-flight work may run on
+	FlushTimeout time.Duration // budget of the flush phase
+
+	mu       sync.Mutex
+	idle     *sync.Cond
+	closing  bool
+	inFlight int
+	intake   []shutdownHook
+	flush    []shutdownHook
+	queues   []*SessionQueue
+
+	// work contexts are cancelled when the deadline passes
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewShutdown creates a coordinator giving in-flight work 25s, within
+// Kubernetes' default 30s termination grace period, and the flush 5s
+func NewShutdown() *Shutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Shutdown{
+		Deadline:     25 * time.Second,
+		FlushTimeout: 5 * time.Second,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	s.idle = sync.NewCond(&s.mu)
+	return s
+}
+
+// NewShutdownFromEnv reads the drain deadline from AGNO_SHUTDOWN_TIMEOUT
+// (default 25s)
+func NewShutdownFromEnv() *Shutdown {
+	s := NewShutdown()
+	s.Deadline = envDuration("AGNO_SHUTDOWN_TIMEOUT", s.Deadline)
+	return s
+}
+
+// OnIntake adds a hook stopping an event source, e.g. an http.Server's
+// Shutdown or StopHook(longConn.Stop). Hooks run in the order added.
+func (s *Shutdown) OnIntake(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intake = append(s.intake, shutdownHook{name: name, fn: fn})
+}
+
+// OnFlush adds a hook writing out buffered data, e.g.
+// StopHook(auditor.Stop) or usage.Flush. Hooks run in the order added.
+func (s *Shutdown) OnFlush(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush = append(s.flush, shutdownHook{name: name, fn: fn})
+}
+
+// AddQueue closes q when the drain starts, so its waiting messages are
+// persisted for the restart instead of lengthening the drain
+func (s *Shutdown) AddQueue(q *SessionQueue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues = append(s.queues, q)
+}
+
+// StopHook adapts a Stop method to a shutdown hook
+func StopHook(stop func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		stop()
+		return nil
+	}
+}
+
+// Closing reports whether the shutdown has started
+func (s *Shutdown) Closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// Begin registers a unit of work, e.g. handling one Lark event. It returns
+// a context that is cancelled if the work outlives the drain deadline and
+// a function to call when the work is done. Once the shutdown has started
+// it returns ErrShuttingDown.
+func (s *Shutdown) Begin(ctx context.Context) (context.Context, func(), error) {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return ctx, func() {}, ErrShuttingDown
+	}
+	s.track()
+	s.mu.Unlock()
+
+	ctx, done := s.workContext(ctx)
+	return ctx, done, nil
+}
+
+// workContext derives the context of tracked work from ctx, cancelled
+// when the drain deadline passes. done ends the work; it may be called
+// more than once.
+func (s *Shutdown) workContext(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.ctx, cancel)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			s.untrack()
+		})
+	}
+}
+
+// Handler wraps an event endpoint (e.g. the Lark webhook): requests are
+// tracked like Begin, and refused with 503 once the shutdown has started so
+// Lark retries them against another replica
+func (s *Shutdown) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx, done, err := s.Begin(req.Context())
+		if err != nil {
+			rw.Header().Set("Connection", "close")
+			http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+// Middleware tracks the client's requests to the Agno service, so the
+// drain also waits for calls made outside Begin (scheduler, prefetch, ...).
+// A request stays in flight until its response body is read to the end or
+// closed, so streams count until they finish, and is cancelled like Begin's
+// work when the drain deadline passes.
+func (s *Shutdown) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			s.mu.Lock()
+			s.track()
+			s.mu.Unlock()
+			ctx, done := s.workContext(req.Context())
+
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+			if err != nil || resp.Body == nil {
+				done()
+				return resp, err
+			}
+			resp.Body = &trackedBody{ReadCloser: resp.Body, done: done}
+			return resp, nil
+		})
+	}
+}
+
+// trackedBody ends a tracked request when its body hits EOF or is closed
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+// Read implements io.Reader
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+// track counts a unit of in-flight work; callers must hold s.mu
+func (s *Shutdown) track() {
+	s.inFlight++
+	shutdownInFlight.Inc()
+}
+
+// untrack ends a unit of in-flight work
+func (s *Shutdown) untrack() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	shutdownInFlight.Dec()
+	if s.inFlight == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// Run shuts down through all phases and returns the first hook error; it
+// is meant to be called once, right before the process exits
+func (s *Shutdown) Run(ctx context.Context) error {
+	start := time.Now()
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return errors.New("agno: shutdown already running")
+	}
+	s.closing = true
+	intake, flush, queues := s.intake, s.flush, s.queues
+	s.mu.Unlock()
+	logger.Infof("Shutting down: %d request(s) in flight, draining for up to %s", s.pending(), s.Deadline)
+
+	var firstErr error
+	runHooks := func(phase string, hooks []shutdownHook, ctx context.Context) {
+		for _, hook := range hooks {
+			if err := hook.fn(ctx); err != nil {
+				logger.Errorf("Shutdown %s step %q failed: %v", phase, hook.name, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(ctx, s.Deadline)
+	defer cancelDrain()
+	runHooks("intake", intake, drainCtx)
+
+	for _, q := range queues {
+		if n := q.Close(); n > 0 {
+			logger.Infof("Left %d queued message(s) for after the restart", n)
+		}
+	}
+
+	if !s.drain(drainCtx) {
+		n := s.pending()
+		shutdownAbandoned.Add(float64(n))
+		logger.Warnf("Shutdown deadline passed, cancelling %d request(s) still in flight", n)
+		s.cancel()
+		// give the cancelled work a moment to record its failure
+		graceCtx, cancelGrace := context.WithTimeout(ctx, time.Second)
+		s.drain(graceCtx)
+		cancelGrace()
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(ctx, s.FlushTimeout)
+	defer cancelFlush()
+	runHooks("flush", flush, flushCtx)
+
+	logger.Infof("Shutdown complete in %s", time.Since(start).Round(time.Millisecond))
+	return firstErr
+}
+
+// pending returns the in-flight work count
+func (s *Shutdown) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// drain waits until no work is in flight; false when ctx ended first
+func (s *Shutdown) drain(ctx context.Context) bool {
+	idle := make(chan struct{})
+	go func() {
+		s.mu.Lock()
+		for s.inFlight > 0 && ctx.Err() == nil {
+			s.idle.Wait()
+		}
+		s.mu.Unlock()
+		close(idle)
+	}()
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.idle.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+
+	select {
+	case <-idle:
+		return s.pending() == 0
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// WaitForSignal blocks until SIGTERM or SIGINT and then runs the shutdown.
+// A second signal exits at once.
+func (s *Shutdown) WaitForSignal() error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	sig := <-signals
+	logger.Infof("Received %s", sig)
+	go func() {
+		sig := <-signals
+		logger.Errorf("Received %s during shutdown, exiting now", sig)
+		os.Exit(1)
+	}()
+	return s.Run(context.Background())
+}
@@ -0,0 +1,158 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrKeyNotFound is returned by SessionStore.Get for missing keys
+var ErrKeyNotFound = errors.New("agno: key not found")
+
+// SessionStore is the key/value store the bot persists session-scoped state
+// in (usage aggregates, per-chat settings, ...). Values are opaque bytes;
+// callers encode them, usually as JSON.
+type SessionStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key; ttl 0 keeps it forever
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// Keys returns the keys starting with prefix, sorted
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// memoryEntry is a value held by MemorySessionStore
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemorySessionStore is a SessionStore in process memory (single replica, lost on restart)
+type MemorySessionStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+// NewMemorySessionStore creates an empty in-memory store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements SessionStore
+func (s *MemorySessionStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Set implements SessionStore
+func (s *MemorySessionStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+	return nil
+}
+
+// Delete implements SessionStore
+func (s *MemorySessionStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Keys implements SessionStore
+func (s *MemorySessionStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var keys []string
+	for key, entry := range s.entries {
+		if entry.expired(now) {
+			delete(s.entries, key)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// expired reports whether the entry's TTL has passed
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// RedisSessionStore is a SessionStore in Redis, shared by all replicas
+type RedisSessionStore struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+// NewRedisSessionStore creates a store using keys prefixed with "agno:store:"
+func NewRedisSessionStore(client redis.Cmdable) *RedisSessionStore {
+	return &RedisSessionStore{Client: client, Prefix: "agno:store:"}
+}
+
+// Get implements SessionStore
+func (s *RedisSessionStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from redis: %w", key, err)
+	}
+	return value, nil
+}
+
+// Set implements SessionStore
+func (s *RedisSessionStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.Client.Set(ctx, s.Prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write %s to redis: %w", key, err)
+	}
+	return nil
+}
+
+// Delete implements SessionStore
+func (s *RedisSessionStore) Delete(ctx context.Context, key string) error {
+	if err := s.Client.Del(ctx, s.Prefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete %s from redis: %w", key, err)
+	}
+	return nil
+}
+
+// Keys implements SessionStore using SCAN, so it is safe on large databases
+func (s *RedisSessionStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	iter := s.Client.Scan(ctx, 0, s.Prefix+escapeGlob(prefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), s.Prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan redis keys: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// escapeGlob escapes Redis MATCH pattern metacharacters
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
@@ -0,0 +1,223 @@
+package agno
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"start-feishubot/logger"
+)
+
+// StreamChunk is one server-sent event from POST /chat/stream
+type StreamChunk struct {
+	Content string `json:"content"`
+	Done    bool   `json:"done,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ChatStream sends a chat request to /chat/stream and returns a channel of
+// response chunks. The channel is closed after the final chunk (Done or
+// Error set) or when ctx is cancelled.
+func (c *AgnoClient) ChatStream(ctx context.Context, reqBody ChatRequest) (<-chan StreamChunk, error) {
+	if c.ChatAudit == nil {
+		return c.chatStream(ctx, reqBody)
+	}
+	start := time.Now()
+	chunks, err := c.chatStream(ctx, reqBody)
+	if err != nil {
+		c.auditChat(ctx, reqBody, nil, err, time.Since(start))
+		return nil, err
+	}
+	return c.auditStream(ctx, reqBody, chunks, start), nil
+}
+
+// chatStream opens the stream of reqBody over HTTP or the RPC transport
+func (c *AgnoClient) chatStream(ctx context.Context, reqBody ChatRequest) (_ <-chan StreamChunk, err error) {
+	ctx, span := startSpan(ctx, "ChatStream", attribute.String("agno.session_id", reqBody.SessionID))
+	defer func() {
+		if err != nil {
+			endSpan(span, err)
+		}
+	}()
+
+	if err := c.guardRequest(&reqBody); err != nil {
+		return nil, err
+	}
+	if err := c.applyModelParams(&reqBody); err != nil {
+		return nil, err
+	}
+	if reqBody.ToolTimeouts == nil {
+		reqBody.ToolTimeouts = c.toolTimeouts(ctx)
+	}
+	if c.Debug.Enabled(ctx, reqBody.SessionID) {
+		reqBody.Debug = true
+		span.SetAttributes(attribute.Bool("agno.debug", true))
+		c.Debug.logPayload(reqBody.SessionID, "stream request", reqBody)
+	}
+
+	if c.RPC != nil {
+		return c.rpcStream(ctx, span, reqBody)
+	}
+
+	// v2 services take the v2 body and frame chunks by event name
+	var body interface{} = reqBody
+	path, readStream := "/chat/stream", ReadEventStream
+	if c.apiVersion(ctx) == APIVersion2 {
+		body, path, readStream = toV2Request(reqBody), "/v2/chat/stream", readEventStreamV2
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// the deadline covers the whole stream, so cancel it when the stream ends
+	ctx, cancel := c.withRequestTimeout(ctx)
+	streaming := false
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
+
+	url := c.BaseURL + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	injectTraceHeaders(req)
+	setDeadlineHeader(req)
+
+	tenant := c.tenant(reqBody.SessionID)
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		observeRequest("chat-stream", "error", tenant, time.Since(start))
+		logger.Errorf("Failed to open Agno stream: %v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		observeRequest("chat-stream", strconv.Itoa(resp.StatusCode), tenant, time.Since(start))
+		logger.Errorf("Agno stream returned status %d: %s", resp.StatusCode, string(errBody))
+		apiErr := newAPIError(resp.StatusCode, errBody)
+		c.forgetAPIVersion(apiErr)
+		return nil, apiErr
+	}
+
+	chunks := make(chan StreamChunk)
+	streaming = true
+	go func() {
+		defer close(chunks)
+		defer cancel()
+		defer resp.Body.Close()
+		streamErr := readStream(ctx, resp.Body, chunks)
+		observeRequest("chat-stream", strconv.Itoa(resp.StatusCode), tenant, time.Since(start))
+		endSpan(span, streamErr)
+	}()
+	return chunks, nil
+}
+
+// rpcStream opens the stream over the RPC transport; span ends with the stream
+func (c *AgnoClient) rpcStream(ctx context.Context, span trace.Span, reqBody ChatRequest) (<-chan StreamChunk, error) {
+	tenant := c.tenant(reqBody.SessionID)
+	start := time.Now()
+	in, err := c.RPC.Stream(ctx, reqBody)
+	if err != nil {
+		status := "error"
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			status = strconv.Itoa(apiErr.StatusCode)
+		}
+		observeRequest("chat-stream", status, tenant, time.Since(start))
+		logger.Errorf("Failed to open Agno stream: %v", err)
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk)
+	go func() {
+		defer close(chunks)
+		var streamErr error
+		for chunk := range in {
+			if chunk.Error != "" {
+				streamErr = errors.New(chunk.Error)
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				streamErr = ctx.Err()
+			}
+			if streamErr != nil {
+				break
+			}
+		}
+		status := "200"
+		if streamErr != nil {
+			status = "error"
+		}
+		observeRequest("chat-stream", status, tenant, time.Since(start))
+		endSpan(span, streamErr)
+	}()
+	return chunks, nil
+}
+
+// ReadEventStream parses "data: {...}" lines of a /chat/stream response
+// into chunks until the final one
+func ReadEventStream(ctx context.Context, body io.Reader, chunks chan<- StreamChunk) error {
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, comments and event names
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			send(StreamChunk{Done: true})
+			return nil
+		}
+
+		var chunk StreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			err = fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+			send(StreamChunk{Error: err.Error()})
+			return err
+		}
+		if !send(chunk) {
+			return ctx.Err()
+		}
+		if chunk.Done || chunk.Error != "" {
+			return nil
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	send(StreamChunk{Error: fmt.Sprintf("stream ended unexpectedly: %v", err)})
+	return err
+}
@@ -0,0 +1,367 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// BusinessHours is a weekly support schedule in one time zone
+type BusinessHours struct {
+	Location *time.Location
+	Days     map[time.Weekday]bool
+	Open     time.Duration // offset from midnight, e.g. 9h
+	Close    time.Duration // offset from midnight, e.g. 18h
+
+	// Holidays, when set, closes support on holidays and opens it on
+	// make-up working days
+	Holidays *HolidayCalendar
+}
+
+// weekdays maps schedule abbreviations to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseBusinessHours parses schedules like "Mon-Fri 09:00-18:00" or
+// "Mon,Wed,Fri 10:00-16:00" in the given time zone
+func ParseBusinessHours(spec string, loc *time.Location) (*BusinessHours, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid business hours %q: want \"<days> <HH:MM>-<HH:MM>\"", spec)
+	}
+
+	hours := &BusinessHours{Location: loc, Days: make(map[time.Weekday]bool)}
+	for _, part := range strings.Split(strings.ToLower(fields[0]), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok1 := weekdays[first]
+		to, ok2 := weekdays[last]
+		if !isRange {
+			to, ok2 = from, ok1
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid business days %q", fields[0])
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			hours.Days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	open, close, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid business hours %q", fields[1])
+	}
+	var err error
+	if hours.Open, err = parseClock(open); err != nil {
+		return nil, err
+	}
+	if hours.Close, err = parseClock(close); err != nil {
+		return nil, err
+	}
+	if hours.Close <= hours.Open {
+		return nil, fmt.Errorf("business hours %q close before they open", fields[1])
+	}
+	return hours, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls within support hours
+func (h *BusinessHours) Contains(t time.Time) bool {
+	t = t.In(h.Location)
+	if !h.openDay(t) {
+		return false
+	}
+	offset := t.Sub(midnight(t))
+	return offset >= h.Open && offset < h.Close
+}
+
+// NextOpen returns when support next opens at or after t (t itself if open)
+func (h *BusinessHours) NextOpen(t time.Time) time.Time {
+	t = t.In(h.Location)
+	if h.Contains(t) {
+		return t
+	}
+	day := midnight(t)
+	for i := 0; i < 366; i++ {
+		open := day.Add(h.Open)
+		if h.openDay(day) && !open.Before(t) {
+			return open
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return t // no business days configured
+}
+
+// Deadline returns when d of support time has elapsed after start, counting
+// only open hours, e.g. for SLA timers that pause over nights, weekends and
+// holidays
+func (h *BusinessHours) Deadline(start time.Time, d time.Duration) time.Time {
+	t := h.NextOpen(start)
+	for i := 0; i < 366; i++ {
+		close := midnight(t).Add(h.Close)
+		if !t.Add(d).After(close) {
+			return t.Add(d)
+		}
+		d -= close.Sub(t)
+		next := h.NextOpen(close)
+		if !next.After(close) {
+			break // no business days configured
+		}
+		t = next
+	}
+	return t.Add(d)
+}
+
+// openDay reports whether support works on t's day
+func (h *BusinessHours) openDay(t time.Time) bool {
+	if h.Holidays == nil {
+		return h.Days[t.Weekday()]
+	}
+	return h.Holidays.workingDay(t, h.Days[t.Weekday()])
+}
+
+// midnight returns the start of t's day in t's location
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Escalation is a question handed over to the human support team
+type Escalation struct {
+	ChatID     string    `json:"chat_id"`
+	ThreadID   string    `json:"thread_id,omitempty"`
+	MessageID  string    `json:"message_id"`
+	UserID     string    `json:"user_id"`
+	SessionID  string    `json:"session_id"`
+	Question   string    `json:"question"`
+	Transcript []Message `json:"transcript,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Ticket is a support ticket created for an escalation
+type Ticket struct {
+	ID  string `json:"ticket_id"`
+	URL string `json:"url,omitempty"`
+}
+
+// TicketCreator files support tickets (a webhook, or a Bitable table via the bot)
+type TicketCreator interface {
+	CreateTicket(ctx context.Context, esc Escalation) (Ticket, error)
+}
+
+// ticketWebhookClient is used when WebhookTicketCreator.HTTPClient is unset,
+// so a hanging ticket system can't block the escalation
+var ticketWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookTicketCreator creates tickets by POSTing the escalation as JSON to
+// URL, which must answer with {"ticket_id": "...", "url": "..."}. Without
+// HTTPClient, requests time out after 10 seconds.
+type WebhookTicketCreator struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// CreateTicket implements TicketCreator
+func (w *WebhookTicketCreator) CreateTicket(ctx context.Context, esc Escalation) (Ticket, error) {
+	jsonData, err := json.Marshal(esc)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("failed to marshal escalation: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return Ticket{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.HTTPClient
+	if client == nil {
+		client = ticketWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("failed to send ticket webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return Ticket{}, fmt.Errorf("ticket webhook returned status %d: %s", resp.StatusCode, string(body))
+	}
+	var ticket Ticket
+	if err := json.Unmarshal(body, &ticket); err != nil || ticket.ID == "" {
+		return Ticket{}, fmt.Errorf("invalid ticket webhook response: %s", string(body))
+	}
+	return ticket, nil
+}
+
+// AfterHoursDesk turns escalations nobody answered into tickets: immediately
+// outside support hours, or after UnansweredAfter during them. The user is
+// told the ticket number and when to expect a response.
+type AfterHoursDesk struct {
+	Hours           *BusinessHours
+	Tickets         TicketCreator
+	Poster          ThreadPoster
+	ResponseTime    time.Duration // promised response time after support opens
+	UnansweredAfter time.Duration // during hours, file a ticket if nobody answered within this
+	Interval        time.Duration
+
+	mu      sync.Mutex
+	pending map[string]Escalation // by MessageID
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAfterHoursDeskFromEnv reads the schedule from AGNO_SUPPORT_HOURS
+// (default "Mon-Fri 09:00-18:00") in AGNO_SUPPORT_TZ (default UTC)
+func NewAfterHoursDeskFromEnv(tickets TicketCreator, poster ThreadPoster) (*AfterHoursDesk, error) {
+	loc := time.UTC
+	if tz := os.Getenv("AGNO_SUPPORT_TZ"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid AGNO_SUPPORT_TZ: %w", err)
+		}
+	}
+	spec := os.Getenv("AGNO_SUPPORT_HOURS")
+	if spec == "" {
+		spec = "Mon-Fri 09:00-18:00"
+	}
+	hours, err := ParseBusinessHours(spec, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &AfterHoursDesk{
+		Hours:           hours,
+		Tickets:         tickets,
+		Poster:          poster,
+		ResponseTime:    4 * time.Hour,
+		UnansweredAfter: 30 * time.Minute,
+		Interval:        time.Minute,
+		pending:         make(map[string]Escalation),
+	}, nil
+}
+
+// Escalate hands a question to support. Outside support hours a ticket is
+// created right away and the returned reply tells the user about it;
+// during hours it returns "" and the escalation waits for a human.
+func (d *AfterHoursDesk) Escalate(ctx context.Context, esc Escalation) (string, error) {
+	if esc.CreatedAt.IsZero() {
+		esc.CreatedAt = time.Now()
+	}
+	if d.Hours.Contains(esc.CreatedAt) {
+		d.mu.Lock()
+		d.pending[esc.MessageID] = esc
+		d.mu.Unlock()
+		return "", nil
+	}
+
+	ticket, err := d.Tickets.CreateTicket(ctx, esc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create ticket: %w", err)
+	}
+	logger.Infof("Created ticket %s for after-hours escalation %s", ticket.ID, esc.MessageID)
+	return d.ticketReply(ticket, esc.CreatedAt, "Our support team isn't available right now"), nil
+}
+
+// Answered marks an escalation as handled by a human
+func (d *AfterHoursDesk) Answered(messageID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, messageID)
+}
+
+// Start files tickets for unanswered escalations every Interval
+func (d *AfterHoursDesk) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.Sweep(context.Background())
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background sweeps
+func (d *AfterHoursDesk) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.stop = nil
+}
+
+// Sweep files tickets for escalations that have waited too long or whose
+// support hours have ended, and tells their users
+func (d *AfterHoursDesk) Sweep(ctx context.Context) {
+	now := time.Now()
+	d.mu.Lock()
+	var due []Escalation
+	for id, esc := range d.pending {
+		if now.Sub(esc.CreatedAt) >= d.UnansweredAfter || !d.Hours.Contains(now) {
+			due = append(due, esc)
+			delete(d.pending, id)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, esc := range due {
+		ticket, err := d.Tickets.CreateTicket(ctx, esc)
+		if err != nil {
+			logger.Errorf("Failed to create ticket for escalation %s: %v", esc.MessageID, err)
+			d.mu.Lock()
+			d.pending[esc.MessageID] = esc // retry on the next sweep
+			d.mu.Unlock()
+			continue
+		}
+		logger.Infof("Created ticket %s for unanswered escalation %s", ticket.ID, esc.MessageID)
+
+		key := ThreadKey{ChatID: esc.ChatID, ThreadID: esc.ThreadID}
+		reason := "Our support team isn't available right now"
+		if d.Hours.Contains(now) {
+			reason = "Nobody from support has picked this up yet"
+		}
+		if err := d.Poster.PostToThread(key, d.ticketReply(ticket, now, reason)); err != nil {
+			logger.Errorf("Failed to post ticket %s to chat %s: %v", ticket.ID, esc.ChatID, err)
+		}
+	}
+}
+
+// ticketReply tells the user their ticket number and expected response time
+func (d *AfterHoursDesk) ticketReply(ticket Ticket, at time.Time, reason string) string {
+	expected := d.Hours.Deadline(at, d.ResponseTime)
+	id := ticket.ID
+	if ticket.URL != "" {
+		id = fmt.Sprintf("[%s](%s)", ticket.ID, ticket.URL)
+	}
+	return fmt.Sprintf("🎫 %s, so I've created ticket %s for you. You can expect a response by %s.",
+		reason, id, expected.Format("Mon Jan 2, 15:04 MST"))
+}
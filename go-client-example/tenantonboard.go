@@ -0,0 +1,342 @@
+package agno
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// ErrTenantExists is returned when onboarding an app that is already registered
+var ErrTenantExists = errors.New("agno: tenant already registered")
+
+// TenantOnboarding is a request to register a new Lark app as a tenant
+type TenantOnboarding struct {
+	TenantConfig
+
+	// Replace re-registers an existing app instead of failing
+	Replace bool `json:"replace,omitempty"`
+	// SkipSmokeTest registers the tenant without a test conversation
+	SkipSmokeTest bool `json:"skip_smoke_test,omitempty"`
+}
+
+// OnboardingStep is the outcome of one onboarding step
+type OnboardingStep struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// OnboardingReport lists the steps of an onboarding; Tenant, with its
+// secrets left out, is set once the tenant is registered
+type OnboardingReport struct {
+	AppID  string           `json:"app_id"`
+	Steps  []OnboardingStep `json:"steps"`
+	Tenant *TenantConfig    `json:"tenant,omitempty"`
+}
+
+// OK reports whether every step succeeded
+func (r *OnboardingReport) OK() bool {
+	for _, step := range r.Steps {
+		if !step.OK {
+			return false
+		}
+	}
+	return r.Tenant != nil
+}
+
+// Format writes the report as one line per step
+func (r *OnboardingReport) Format(w io.Writer) {
+	for _, step := range r.Steps {
+		mark := "ok  "
+		if !step.OK {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %-18s %8s  %s\n", mark, step.Name, step.Duration.Round(time.Millisecond), step.Detail)
+	}
+	if r.Tenant != nil {
+		fmt.Fprintf(w, "Tenant %s (app %s) registered.\n", r.Tenant.Name, r.AppID)
+	}
+}
+
+// TenantOnboarder registers new tenants in a store read by StoreTenants,
+// after checking that they work: the Lark app credentials are validated,
+// defaults are filled in, the Agno deployment's health is checked and a
+// smoke test conversation is run. Nothing is registered unless every step
+// passes.
+type TenantOnboarder struct {
+	Store    SessionStore
+	Registry *TenantRegistry // reloaded after a registration, when set
+
+	HTTPClient  *http.Client
+	LarkBaseURL string // Lark Open API, e.g. https://open.larksuite.com
+
+	// Defaults fill in the model, quotas, params and system prompt of
+	// tenants that don't set their own
+	Defaults TenantConfig
+	// SmokeTestMessage is sent to the new tenant's default agent
+	SmokeTestMessage string
+}
+
+// NewTenantOnboarder creates an onboarder for Feishu apps, giving new
+// tenants a 5 message burst per user refilled every 10s
+func NewTenantOnboarder(store SessionStore) *TenantOnboarder {
+	return &TenantOnboarder{
+		Store:       store,
+		HTTPClient:  &http.Client{Timeout: 90 * time.Second},
+		LarkBaseURL: "https://open.feishu.cn",
+		Defaults: TenantConfig{
+			Quotas: TenantQuotas{UserBurst: 5, UserRefill: "10s", ChatBurst: 20, ChatRefill: "3s"},
+		},
+		SmokeTestMessage: "This is a setup check. Reply with a short greeting.",
+	}
+}
+
+// Onboard runs the onboarding steps and registers the tenant. The report
+// is returned also on failure, showing which step failed.
+func (o *TenantOnboarder) Onboard(ctx context.Context, req TenantOnboarding) (*OnboardingReport, error) {
+	cfg := req.TenantConfig
+	report := &OnboardingReport{AppID: cfg.AppID}
+	step := func(name string, fn func() (string, error)) error {
+		start := time.Now()
+		detail, err := fn()
+		if err != nil {
+			detail = err.Error()
+		}
+		report.Steps = append(report.Steps, OnboardingStep{
+			Name:     name,
+			OK:       err == nil,
+			Detail:   detail,
+			Duration: time.Since(start),
+		})
+		if err != nil {
+			return fmt.Errorf("onboarding step %q failed: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := step("validate", func() (string, error) {
+		return o.validate(ctx, &cfg, req.Replace)
+	}); err != nil {
+		return report, err
+	}
+	if err := step("lark credentials", func() (string, error) {
+		return o.checkLarkCredentials(ctx, cfg.AppID, cfg.AppSecret)
+	}); err != nil {
+		return report, err
+	}
+	if err := step("defaults", func() (string, error) {
+		return o.applyDefaults(&cfg), nil
+	}); err != nil {
+		return report, err
+	}
+
+	var tenant *Tenant
+	if err := step("agno service", func() (string, error) {
+		var err error
+		registry := &TenantRegistry{HTTPClient: o.HTTPClient}
+		if tenant, err = registry.newTenant(cfg); err != nil {
+			return "", err
+		}
+		health, err := tenant.Client.HealthContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		if health.Status != "healthy" {
+			return "", fmt.Errorf("service reports %q", health.Status)
+		}
+		return cfg.BaseURL + " is healthy", nil
+	}); err != nil {
+		return report, err
+	}
+
+	if !req.SkipSmokeTest {
+		if err := step("smoke test", func() (string, error) {
+			return o.smokeTest(ctx, tenant)
+		}); err != nil {
+			return report, err
+		}
+	}
+
+	if err := step("register", func() (string, error) {
+		data, err := tenantConfigSchema.Encode(cfg)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal tenant: %w", err)
+		}
+		if err := o.Store.Set(ctx, "tenant:"+cfg.AppID, data, 0); err != nil {
+			return "", fmt.Errorf("failed to save tenant: %w", err)
+		}
+		if o.Registry != nil {
+			if err := o.Registry.Reload(ctx); err != nil {
+				return "", fmt.Errorf("saved, but the registry failed to reload: %w", err)
+			}
+			return "saved and live", nil
+		}
+		return "saved; live on the next registry reload", nil
+	}); err != nil {
+		return report, err
+	}
+
+	registered := cfg
+	registered.AppSecret, registered.APIKey = "", ""
+	report.Tenant = &registered
+	logger.Infof("Onboarded tenant %s (app %s)", cfg.Name, cfg.AppID)
+	return report, nil
+}
+
+// validate checks the required fields and that the app isn't registered yet
+func (o *TenantOnboarder) validate(ctx context.Context, cfg *TenantConfig, replace bool) (string, error) {
+	cfg.AppID = strings.TrimSpace(cfg.AppID)
+	cfg.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if cfg.AppID == "" || cfg.AppSecret == "" || cfg.BaseURL == "" {
+		return "", fmt.Errorf("%w: app_id, app_secret and base_url are required", ErrInvalidRequest)
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.AppID
+	}
+	if _, err := o.Store.Get(ctx, "tenant:"+cfg.AppID); err == nil {
+		if !replace {
+			return "", fmt.Errorf("%w: app %s", ErrTenantExists, cfg.AppID)
+		}
+		return "replacing the registered tenant", nil
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return "", fmt.Errorf("failed to look up tenant: %w", err)
+	}
+	return "new app " + cfg.AppID, nil
+}
+
+// checkLarkCredentials requests a tenant access token with the app's credentials
+func (o *TenantOnboarder) checkLarkCredentials(ctx context.Context, appID, appSecret string) (string, error) {
+	payload, _ := json.Marshal(map[string]string{"app_id": appID, "app_secret": appSecret})
+	endpoint := strings.TrimRight(o.LarkBaseURL, "/") + "/open-apis/auth/v3/tenant_access_token/internal"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach Lark: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to read Lark response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Code != 0 {
+		return "", fmt.Errorf("%w: Lark rejected the app credentials: %s (code %d)", ErrInvalidRequest, result.Msg, result.Code)
+	}
+	return "tenant access token issued", nil
+}
+
+// applyDefaults fills in unset settings from o.Defaults and lists them
+func (o *TenantOnboarder) applyDefaults(cfg *TenantConfig) string {
+	var applied []string
+	if cfg.Model == "" && o.Defaults.Model != "" {
+		cfg.Model = o.Defaults.Model
+		applied = append(applied, "model")
+	}
+	if cfg.DefaultAgent == "" && o.Defaults.DefaultAgent != "" {
+		cfg.DefaultAgent = o.Defaults.DefaultAgent
+		applied = append(applied, "agent")
+	}
+	if cfg.SystemPrompt == "" && o.Defaults.SystemPrompt != "" {
+		cfg.SystemPrompt = o.Defaults.SystemPrompt
+		applied = append(applied, "system prompt")
+	}
+	if cfg.Quotas == (TenantQuotas{}) && o.Defaults.Quotas != (TenantQuotas{}) {
+		cfg.Quotas = o.Defaults.Quotas
+		applied = append(applied, "quotas")
+	}
+	if cfg.Params == (ModelParams{}) && o.Defaults.Params != (ModelParams{}) {
+		cfg.Params = o.Defaults.Params
+		applied = append(applied, "model params")
+	}
+	if len(applied) == 0 {
+		return "none needed"
+	}
+	return "applied " + strings.Join(applied, ", ")
+}
+
+// smokeTest has a short conversation with the tenant's default agent in a
+// throwaway session
+func (o *TenantOnboarder) smokeTest(ctx context.Context, tenant *Tenant) (string, error) {
+	sessionID := fmt.Sprintf("onboarding:%s:%d", tenant.Config.AppID, time.Now().Unix())
+	resp, err := tenant.Client.SendChat(ctx, ChatRequest{
+		SessionID:    sessionID,
+		Message:      o.SmokeTestMessage,
+		AgentID:      tenant.Config.DefaultAgent,
+		Model:        tenant.Config.Model,
+		SystemPrompt: tenant.Config.SystemPrompt,
+		NoCache:      true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := tenant.Client.ClearSessionContext(ctx, sessionID); err != nil {
+		logger.Warnf("Failed to clear onboarding session %s: %v", sessionID, err)
+	}
+	answer := strings.TrimSpace(resp.Response)
+	if answer == "" {
+		return "", errors.New("the agent answered with nothing")
+	}
+	if len([]rune(answer)) > 60 {
+		answer = string([]rune(answer)[:60]) + "…"
+	}
+	return fmt.Sprintf("agent answered %q", answer), nil
+}
+
+// Handler serves POST /tenants (a TenantOnboarding) for admin tooling and
+// answers with the OnboardingReport: 201 when the tenant was registered,
+// 409 when it exists, 422 when a step failed. Requests must be signed with
+// secret (see VerifyRequest).
+// It returns ErrMissingSecret when secret is empty.
+func (o *TenantOnboarder) Handler(secret []byte) (http.Handler, error) {
+	if err := checkSecret("TenantOnboarder.Handler", secret); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyRequest(r, secret); err != nil {
+			logger.Warnf("Rejected tenant onboarding request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		var req TenantOnboarding
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid onboarding request", http.StatusBadRequest)
+			return
+		}
+
+		report, err := o.Onboard(r.Context(), req)
+		switch {
+		case errors.Is(err, ErrTenantExists):
+			writeJSON(w, http.StatusConflict, report)
+		case err != nil:
+			writeJSON(w, http.StatusUnprocessableEntity, report)
+		default:
+			writeJSON(w, http.StatusCreated, report)
+		}
+	}), nil
+}
@@ -0,0 +1,313 @@
+package agno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// ErrUnknownTenant is returned when an event comes from an unregistered Lark app
+var ErrUnknownTenant = errors.New("agno: unknown tenant")
+
+// TenantQuotas are the per-user and per-chat rate limits of a tenant;
+// refills are durations like "10s" (zero values disable a limit)
+type TenantQuotas struct {
+	UserBurst  int    `json:"user_burst,omitempty"`
+	UserRefill string `json:"user_refill,omitempty"`
+	ChatBurst  int    `json:"chat_burst,omitempty"`
+	ChatRefill string `json:"chat_refill,omitempty"`
+}
+
+// TenantConfig maps a Lark (Feishu) app to its Agno deployment
+type TenantConfig struct {
+	AppID        string       `json:"app_id"`
+	AppSecret    string       `json:"app_secret,omitempty"` // Lark app secret, for the bot's Lark client
+	Name         string       `json:"name"`                 // tenant label in metrics, analytics and quotas
+	BaseURL      string       `json:"base_url"`
+	APIKey       string       `json:"api_key,omitempty"`
+	APIKeyFile   string       `json:"api_key_file,omitempty"` // re-read on reload, for rotation
+	DefaultAgent string       `json:"default_agent,omitempty"`
+	Model        string       `json:"model,omitempty"`
+	Quotas       TenantQuotas `json:"quotas,omitempty"`
+	SystemPrompt string       `json:"system_prompt,omitempty"` // used by requests without their own
+
+	// Params are the tenant's default temperature, token limit and
+	// reasoning effort (Model above takes precedence over Params.Model)
+	Params ModelParams `json:"params,omitempty"`
+
+	// Calendar is the tenant's holiday calendar (see HolidayCalendars)
+	Calendar *CalendarConfig `json:"calendar,omitempty"`
+}
+
+// Tenant is a registered tenant and the client for its Agno deployment
+type Tenant struct {
+	Config  TenantConfig
+	Client  *AgnoClient
+	Limiter *RateLimiter // nil when the tenant has no quotas
+}
+
+// TenantSource loads the tenant configurations, e.g. from a file or a database
+type TenantSource func(ctx context.Context) ([]TenantConfig, error)
+
+// TenantFile loads tenants from a JSON file holding an array of TenantConfig
+func TenantFile(path string) TenantSource {
+	return func(ctx context.Context) ([]TenantConfig, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tenant file: %w", err)
+		}
+		var configs []TenantConfig
+		if err := json.Unmarshal(data, &configs); err != nil {
+			return nil, fmt.Errorf("failed to parse tenant file %s: %w", path, err)
+		}
+		return configs, nil
+	}
+}
+
+// StoreTenants loads tenants stored as JSON under "tenant:<app_id>" keys,
+// e.g. in the shared Redis store
+func StoreTenants(store SessionStore) TenantSource {
+	return func(ctx context.Context) ([]TenantConfig, error) {
+		keys, err := store.Keys(ctx, "tenant:")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tenants: %w", err)
+		}
+		configs := make([]TenantConfig, 0, len(keys))
+		for _, key := range keys {
+			var cfg TenantConfig
+			_, err := tenantConfigSchema.loadRecord(ctx, store, key, &cfg, 0)
+			if errors.Is(err, ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				logger.Warnf("Skipping unreadable tenant %s: %v", key, err)
+				continue
+			}
+			configs = append(configs, cfg)
+		}
+		return configs, nil
+	}
+}
+
+// tenantKey is the context key carrying the Lark app ID of an event
+type tenantKey struct{}
+
+// WithAppID returns a context carrying the Lark app ID of the incoming
+// event (header.app_id), which TenantRegistry resolves the tenant from
+func WithAppID(ctx context.Context, appID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, appID)
+}
+
+// AppIDFromContext returns the Lark app ID set by WithAppID
+func AppIDFromContext(ctx context.Context) string {
+	appID, _ := ctx.Value(tenantKey{}).(string)
+	return appID
+}
+
+// TenantRegistry routes each Lark app to its own Agno deployment, auth key,
+// default agent, model and quotas, for one Go backend serving several
+// Feishu apps (e.g. departments)
+type TenantRegistry struct {
+	Source     TenantSource
+	HTTPClient *http.Client // shared by all tenant clients
+	Buckets    BucketStore  // backs tenant quotas (nil disables them)
+
+	// Configure, when set, is called on each new tenant client, e.g. to
+	// attach a cache, fallback or event publisher
+	Configure func(cfg TenantConfig, client *AgnoClient)
+
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewTenantRegistry creates a registry and loads its tenants from source
+func NewTenantRegistry(ctx context.Context, source TenantSource, buckets BucketStore) (*TenantRegistry, error) {
+	r := &TenantRegistry{
+		Source: source,
+		HTTPClient: &http.Client{
+			Transport: defaultClientOptions().transport(),
+		},
+		Buckets: buckets,
+		tenants: make(map[string]*Tenant),
+	}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the tenants. Clients of unchanged tenants are kept; on
+// failure the previous tenants stay active.
+func (r *TenantRegistry) Reload(ctx context.Context) error {
+	configs, err := r.Source(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load tenants: %w", err)
+	}
+
+	r.mu.RLock()
+	previous := r.tenants
+	r.mu.RUnlock()
+
+	tenants := make(map[string]*Tenant, len(configs))
+	for _, cfg := range configs {
+		if cfg.AppID == "" || cfg.BaseURL == "" {
+			return fmt.Errorf("%w: tenant %q needs app_id and base_url", ErrInvalidRequest, cfg.Name)
+		}
+		if cfg.Name == "" {
+			cfg.Name = cfg.AppID
+		}
+		if _, dup := tenants[cfg.AppID]; dup {
+			return fmt.Errorf("%w: app %s is registered twice", ErrInvalidRequest, cfg.AppID)
+		}
+		if old, ok := previous[cfg.AppID]; ok && reflect.DeepEqual(old.Config, cfg) {
+			if err := old.Client.Auth.Reload(); err != nil {
+				logger.Warnf("Failed to reload credentials of tenant %s: %v", cfg.Name, err)
+			}
+			tenants[cfg.AppID] = old
+			continue
+		}
+		tenant, err := r.newTenant(cfg)
+		if err != nil {
+			return err
+		}
+		tenants[cfg.AppID] = tenant
+	}
+
+	r.mu.Lock()
+	r.tenants = tenants
+	r.mu.Unlock()
+	logger.Infof("Loaded %d tenant(s)", len(tenants))
+	return nil
+}
+
+// Tenant returns the tenant of a Lark app
+func (r *TenantRegistry) Tenant(appID string) (*Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenant, ok := r.tenants[appID]
+	if !ok {
+		return nil, fmt.Errorf("%w: app %q", ErrUnknownTenant, appID)
+	}
+	return tenant, nil
+}
+
+// Resolve returns the tenant of the event in ctx (see WithAppID)
+func (r *TenantRegistry) Resolve(ctx context.Context) (*Tenant, error) {
+	return r.Tenant(AppIDFromContext(ctx))
+}
+
+// Tenants returns the configurations of all tenants, sorted by app ID
+func (r *TenantRegistry) Tenants() []TenantConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	configs := make([]TenantConfig, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		configs = append(configs, tenant.Config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].AppID < configs[j].AppID })
+	return configs
+}
+
+// Chat answers req with the deployment of the tenant in ctx, applying its
+// quotas for userID and chatID and its default agent, model and system prompt
+func (r *TenantRegistry) Chat(ctx context.Context, userID, chatID string, req ChatRequest) (*ChatResponse, error) {
+	tenant, err := r.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Limiter != nil {
+		if err := tenant.Limiter.Allow(ctx, userID, chatID); err != nil {
+			return nil, err
+		}
+	}
+	if u, c := RequesterFromContext(ctx); u == "" && c == "" {
+		ctx = WithRequester(ctx, userID, chatID)
+	}
+	if req.AgentID == "" {
+		req.AgentID = tenant.Config.DefaultAgent
+	}
+	if req.Model == "" {
+		req.Model = tenant.Config.Model
+	}
+	if req.SystemPrompt == "" {
+		req.SystemPrompt = tenant.Config.SystemPrompt
+	}
+	return tenant.Client.SendChat(ctx, req)
+}
+
+// newTenant builds the client and rate limiter of a tenant
+func (r *TenantRegistry) newTenant(cfg TenantConfig) (*Tenant, error) {
+	auth, err := NewAuthenticator(cfg.credentials)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", cfg.Name, err)
+	}
+
+	params := ChatRequest{}
+	params.fillParams(cfg.Params)
+	if err := ValidateModelParams(params); err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", cfg.Name, err)
+	}
+
+	name := cfg.Name
+	client := &AgnoClient{
+		BaseURL:        strings.TrimRight(cfg.BaseURL, "/"),
+		HTTPClient:     r.HTTPClient,
+		RequestTimeout: 90 * time.Second,
+		TenantFunc:     func(string) string { return name },
+		Timeouts:       copyTimeoutPolicies(DefaultTimeoutPolicies),
+		Auth:           auth,
+		ModelDefaults:  map[string]ModelParams{"": cfg.Params},
+	}
+	client.Use(auth.Middleware())
+	if r.Configure != nil {
+		r.Configure(cfg, client)
+	}
+
+	tenant := &Tenant{Config: cfg, Client: client}
+	if r.Buckets != nil && (cfg.Quotas.UserBurst > 0 || cfg.Quotas.ChatBurst > 0) {
+		user, err := quotaBucket(cfg.Quotas.UserBurst, cfg.Quotas.UserRefill)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", cfg.Name, err)
+		}
+		chat, err := quotaBucket(cfg.Quotas.ChatBurst, cfg.Quotas.ChatRefill)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", cfg.Name, err)
+		}
+		tenant.Limiter = &RateLimiter{Store: r.Buckets, User: user, Chat: chat}
+	}
+	logger.Infof("Tenant %s (app %s) uses %s", cfg.Name, cfg.AppID, client.BaseURL)
+	return tenant, nil
+}
+
+// credentials is the CredentialSource of a tenant
+func (cfg TenantConfig) credentials() (Credentials, error) {
+	if cfg.APIKeyFile == "" {
+		return Credentials{BearerToken: cfg.APIKey}, nil
+	}
+	data, err := os.ReadFile(cfg.APIKeyFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read api_key_file: %w", err)
+	}
+	return Credentials{BearerToken: strings.TrimSpace(string(data))}, nil
+}
+
+// quotaBucket converts a burst and refill duration into a BucketConfig
+func quotaBucket(burst int, refill string) (BucketConfig, error) {
+	if burst <= 0 || refill == "" {
+		return BucketConfig{}, nil
+	}
+	d, err := time.ParseDuration(refill)
+	if err != nil {
+		return BucketConfig{}, fmt.Errorf("invalid quota refill %q: %w", refill, err)
+	}
+	return BucketConfig{Burst: burst, Refill: d}, nil
+}
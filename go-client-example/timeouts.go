@@ -0,0 +1,138 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"start-feishubot/logger"
+)
+
+// DeadlineHeader tells the Agno service how much of the request budget is left (milliseconds)
+const DeadlineHeader = "X-Agno-Deadline-Ms"
+
+// TimeoutPolicy is the latency SLA for a command or tool
+type TimeoutPolicy struct {
+	Timeout  time.Duration
+	Fallback string // reply used when the deadline is exceeded; empty returns the error
+}
+
+// DefaultTimeoutPolicies are sensible SLAs for the tools the agent commonly uses
+var DefaultTimeoutPolicies = map[string]TimeoutPolicy{
+	"calendar": {
+		Timeout:  5 * time.Second,
+		Fallback: "⏱️ The calendar is taking too long to respond. Please try again in a moment.",
+	},
+	"web_search": {
+		Timeout:  10 * time.Second,
+		Fallback: "⏱️ Web search timed out. Try rephrasing or narrowing your question.",
+	},
+	"doc_summarize": {
+		Timeout:  60 * time.Second,
+		Fallback: "⏱️ This document is taking too long to summarize. Try a shorter section.",
+	},
+}
+
+var timeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "agno",
+	Subsystem: "client",
+	Name:      "sla_timeouts_total",
+	Help:      "Calls that exceeded their per-command timeout policy.",
+}, []string{"policy"})
+
+// timeoutPolicyKey is the context key carrying the policy selected by ChatWithPolicy
+type timeoutPolicyKey struct{}
+
+// ChatWithPolicy sends a message under the timeout policy registered for name
+// (a command or tool). The policy deadline replaces the client's
+// RequestTimeout, so it may be longer, but never extends a deadline ctx
+// already has. Only the policy's cap is sent as tool_timeouts. When it
+// expires and the policy has a fallback, the fallback is returned as the
+// reply instead of an error.
+func (c *AgnoClient) ChatWithPolicy(ctx context.Context, name, sessionID, message string, history []Message) (string, error) {
+	policy, ok := c.timeoutPolicy(name)
+	if !ok || policy.Timeout <= 0 {
+		return c.ChatContext(ctx, sessionID, message, history)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, timeoutPolicyKey{}, name)
+
+	response, err := c.ChatContext(ctx, sessionID, message, history)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		timeoutsTotal.WithLabelValues(name).Inc()
+		logger.Warnf("Agno call for %q exceeded its %s SLA (session %s)", name, policy.Timeout, sessionID)
+		if policy.Fallback != "" {
+			return policy.Fallback, nil
+		}
+	}
+	return response, err
+}
+
+// SetTimeouts replaces the timeout policies of a running client, e.g. on a
+// configuration reload
+func (c *AgnoClient) SetTimeouts(policies map[string]TimeoutPolicy) {
+	policies = copyTimeoutPolicies(policies)
+	c.timeoutsMu.Lock()
+	defer c.timeoutsMu.Unlock()
+	c.Timeouts = policies
+}
+
+// timeoutPolicy returns the timeout policy registered for name
+func (c *AgnoClient) timeoutPolicy(name string) (TimeoutPolicy, bool) {
+	c.timeoutsMu.RLock()
+	defer c.timeoutsMu.RUnlock()
+	policy, ok := c.Timeouts[name]
+	return policy, ok
+}
+
+// copyTimeoutPolicies returns a copy so clients never share a policy map
+func copyTimeoutPolicies(policies map[string]TimeoutPolicy) map[string]TimeoutPolicy {
+	copied := make(map[string]TimeoutPolicy, len(policies))
+	for name, policy := range policies {
+		copied[name] = policy
+	}
+	return copied
+}
+
+// toolTimeouts returns the per-tool cap sent to the service: that of the
+// policy ChatWithPolicy selected in ctx, if any
+func (c *AgnoClient) toolTimeouts(ctx context.Context) map[string]float64 {
+	name, ok := ctx.Value(timeoutPolicyKey{}).(string)
+	if !ok {
+		return nil
+	}
+	policy, ok := c.timeoutPolicy(name)
+	if !ok || policy.Timeout <= 0 {
+		return nil
+	}
+	return map[string]float64{name: policy.Timeout.Seconds()}
+}
+
+// withRequestTimeout bounds ctx by RequestTimeout unless it has a deadline
+func (c *AgnoClient) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.RequestTimeout)
+}
+
+// setDeadlineHeader forwards the remaining request budget so the service can
+// stop work the client will no longer wait for
+func setDeadlineHeader(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	req.Header.Set(DeadlineHeader, strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,54 @@
+package agno
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope reported on client spans
+const tracerName = "start-feishubot/services/agno"
+
+// traceHeaders always writes W3C traceparent/tracestate and baggage headers,
+// independent of the globally configured propagator, so traces continue
+// into the Python service
+var traceHeaders = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// startSpan starts a client span named after the Agno client method
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "agno."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan records err on the span (if any) and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceHeaders propagates the span context of req into its headers
+func injectTraceHeaders(req *http.Request) {
+	traceHeaders.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// recordStatus attaches the HTTP status code to the active span
+func recordStatus(ctx context.Context, statusCode int) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+}
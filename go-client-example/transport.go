@@ -0,0 +1,44 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RPCTransport carries the core Agno calls over a protocol other than
+// JSON-over-HTTP (see package agnogrpc). When AgnoClient.RPC is set, Chat,
+// ChatStream, ClearSession and Health use it; guards, usage accounting,
+// fallback and metrics work the same. Calls outside the contract (agents,
+// history, jobs, ...) keep using HTTP.
+//
+// Implementations report service failures as *APIError so that errors.Is
+// matches the usual sentinels.
+type RPCTransport interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	Stream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error)
+	ClearSession(ctx context.Context, sessionID string) error
+	Health(ctx context.Context) (*HealthResponse, error)
+	Close() error
+}
+
+// rpcCall runs an RPC transport call with the same metrics as doRequest
+func (c *AgnoClient) rpcCall(ctx context.Context, endpoint, sessionID string, call func() error) error {
+	inFlightRequests.WithLabelValues(endpoint).Inc()
+	defer inFlightRequests.WithLabelValues(endpoint).Dec()
+
+	start := time.Now()
+	err := call()
+
+	status := "200"
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		status = strconv.Itoa(apiErr.StatusCode)
+		recordStatus(ctx, apiErr.StatusCode)
+	} else if err != nil {
+		status = "error"
+	}
+	observeRequest(endpoint, status, c.tenant(sessionID), time.Since(start))
+	return err
+}
@@ -0,0 +1,409 @@
+package agno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"start-feishubot/logger"
+)
+
+// usageDay is the date layout of usage keys and report days
+const usageDay = "2006-01-02"
+
+// usageRetention is how long daily usage aggregates are kept in the store
+const usageRetention = 400 * 24 * time.Hour
+
+// Usage is the token usage reported by the Agno service for one agent run
+type Usage struct {
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	Model            string `json:"model"`
+}
+
+// ModelPrice is the USD price per 1000 tokens of a model
+type ModelPrice struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+}
+
+// DefaultModelPrices are list prices for the models the service commonly uses
+var DefaultModelPrices = map[string]ModelPrice{
+	"gpt-4o":                 {PromptPer1K: 0.0025, CompletionPer1K: 0.01},
+	"gpt-4o-mini":            {PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+	"gpt-4.1":                {PromptPer1K: 0.002, CompletionPer1K: 0.008},
+	"gpt-4.1-mini":           {PromptPer1K: 0.0004, CompletionPer1K: 0.0016},
+	"o3-mini":                {PromptPer1K: 0.0011, CompletionPer1K: 0.0044},
+	"text-embedding-3-small": {PromptPer1K: 0.00002},
+}
+
+// UsageTotals aggregates usage over many runs
+type UsageTotals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// add accumulates other into t
+func (t *UsageTotals) add(other UsageTotals) {
+	t.Requests += other.Requests
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.CostUSD += other.CostUSD
+}
+
+// usageRecord is the stored form of a dailyUsage
+type usageRecord struct {
+	Models map[string]UsageTotals `json:"models"`
+}
+
+// dailyUsage is one replica's usage of a tenant on one day, by model
+type dailyUsage struct {
+	tenant  string
+	day     string
+	models  map[string]UsageTotals
+	loaded  bool
+	changed bool
+}
+
+// sessionUsage is a session's totals and when it last ran
+type sessionUsage struct {
+	totals UsageTotals
+	seen   time.Time
+}
+
+// UsageAccumulator tracks token usage and cost per session and tenant and
+// persists daily per-tenant aggregates through a SessionStore. Each replica
+// writes its own keys (usage:<tenant>:<day>:<instance>), so no cross-replica
+// locking is needed; GetUsage sums them.
+type UsageAccumulator struct {
+	Store    SessionStore
+	Prices   map[string]ModelPrice
+	Instance string        // replica name, defaults to the hostname
+	Interval time.Duration // how often aggregates are flushed
+
+	// SessionIdle is how long a session's totals are kept after its last
+	// run; Flush drops older ones (default 24h)
+	SessionIdle time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*sessionUsage
+	days     map[string]*dailyUsage
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewUsageAccumulator creates an accumulator flushing to store every minute
+func NewUsageAccumulator(store SessionStore) *UsageAccumulator {
+	instance, err := os.Hostname()
+	if err != nil || instance == "" {
+		instance = "local"
+	}
+	return &UsageAccumulator{
+		Store:       store,
+		Prices:      DefaultModelPrices,
+		Instance:    instance,
+		Interval:    time.Minute,
+		SessionIdle: 24 * time.Hour,
+		sessions:    make(map[string]*sessionUsage),
+		days:        make(map[string]*dailyUsage),
+	}
+}
+
+// Cost returns the USD cost of a run, 0 for models without a price
+func (a *UsageAccumulator) Cost(u Usage) float64 {
+	price, ok := a.Prices[u.Model]
+	if !ok {
+		return 0
+	}
+	return float64(u.PromptTokens)/1000*price.PromptPer1K + float64(u.CompletionTokens)/1000*price.CompletionPer1K
+}
+
+// Add records the usage of one run
+func (a *UsageAccumulator) Add(tenant, sessionID string, u Usage) {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return
+	}
+	if u.Model == "" {
+		u.Model = "unknown"
+	}
+	totals := UsageTotals{Requests: 1, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, CostUSD: a.Cost(u)}
+	day := time.Now().UTC().Format(usageDay)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[sessionID]
+	if !ok {
+		session = &sessionUsage{}
+		a.sessions[sessionID] = session
+	}
+	session.totals.add(totals)
+	session.seen = time.Now()
+
+	key := usageKey(tenant, day, a.Instance)
+	d, ok := a.days[key]
+	if !ok {
+		d = &dailyUsage{tenant: tenant, day: day, models: make(map[string]UsageTotals)}
+		a.days[key] = d
+	}
+	model := d.models[u.Model]
+	model.add(totals)
+	d.models[u.Model] = model
+	d.changed = true
+}
+
+// Session returns the usage of a session since the process started, or
+// since it was last idle for SessionIdle
+func (a *UsageAccumulator) Session(sessionID string) UsageTotals {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if session, ok := a.sessions[sessionID]; ok {
+		return session.totals
+	}
+	return UsageTotals{}
+}
+
+// ForgetSession drops the per-session totals (e.g. after ClearSession)
+func (a *UsageAccumulator) ForgetSession(sessionID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, sessionID)
+}
+
+// Start flushes aggregates every Interval
+func (a *UsageAccumulator) Start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.Flush(context.Background()); err != nil {
+					logger.Errorf("Failed to flush usage: %v", err)
+				}
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background flushes and flushes what is left
+func (a *UsageAccumulator) Stop() {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+		a.stop = nil
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		logger.Errorf("Failed to flush usage: %v", err)
+	}
+}
+
+// Flush writes changed daily aggregates to the store and drops the totals
+// of sessions idle for SessionIdle
+func (a *UsageAccumulator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	idle := a.SessionIdle
+	if idle <= 0 {
+		idle = 24 * time.Hour
+	}
+	for id, session := range a.sessions {
+		if time.Since(session.seen) > idle {
+			delete(a.sessions, id)
+		}
+	}
+
+	today := time.Now().UTC().Format(usageDay)
+	var firstErr error
+	for key, d := range a.days {
+		if !d.changed {
+			if d.day != today {
+				delete(a.days, key)
+			}
+			continue
+		}
+		if err := a.write(ctx, key, d); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		d.changed = false
+	}
+	return firstErr
+}
+
+// write merges a day's aggregate with what this replica stored before
+// (e.g. before a restart) and saves it; callers must hold a.mu
+func (a *UsageAccumulator) write(ctx context.Context, key string, d *dailyUsage) error {
+	if !d.loaded {
+		stored, err := a.Store.Get(ctx, key)
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			var previous usageRecord
+			if _, err := usageSchema.Decode(stored, &previous); err != nil {
+				return fmt.Errorf("failed to unmarshal usage %s: %w", key, err)
+			}
+			for model, totals := range previous.Models {
+				merged := d.models[model]
+				merged.add(totals)
+				d.models[model] = merged
+			}
+		}
+		d.loaded = true
+	}
+
+	data, err := usageSchema.Encode(usageRecord{Models: d.models})
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	return a.Store.Set(ctx, key, data, usageRetention)
+}
+
+// DailyUsage is a tenant's usage on one day
+type DailyUsage struct {
+	Day   string      `json:"day"`
+	Total UsageTotals `json:"total"`
+}
+
+// UsageReport is a tenant's usage over a date range
+type UsageReport struct {
+	Tenant  string                 `json:"tenant"`
+	From    string                 `json:"from"`
+	To      string                 `json:"to"`
+	Total   UsageTotals            `json:"total"`
+	ByModel map[string]UsageTotals `json:"by_model"`
+	Days    []DailyUsage           `json:"days"`
+}
+
+// GetUsage returns a tenant's usage for the UTC days from..to (inclusive),
+// including aggregates not yet flushed by this replica
+func (a *UsageAccumulator) GetUsage(ctx context.Context, tenantID string, from, to time.Time) (*UsageReport, error) {
+	if err := a.Flush(ctx); err != nil {
+		logger.Warnf("Usage report may miss recent usage: %v", err)
+	}
+
+	report := &UsageReport{
+		Tenant:  tenantID,
+		From:    from.UTC().Format(usageDay),
+		To:      to.UTC().Format(usageDay),
+		ByModel: make(map[string]UsageTotals),
+	}
+
+	for day := from.UTC(); day.Format(usageDay) <= report.To; day = day.AddDate(0, 0, 1) {
+		date := day.Format(usageDay)
+		keys, err := a.Store.Keys(ctx, usageKey(tenantID, date, ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list usage keys: %w", err)
+		}
+
+		daily := DailyUsage{Day: date}
+		for _, key := range keys {
+			data, err := a.Store.Get(ctx, key)
+			if errors.Is(err, ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read usage: %w", err)
+			}
+			var record usageRecord
+			if _, err := usageSchema.Decode(data, &record); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal usage %s: %w", key, err)
+			}
+			for model, totals := range record.Models {
+				merged := report.ByModel[model]
+				merged.add(totals)
+				report.ByModel[model] = merged
+				daily.Total.add(totals)
+			}
+		}
+		if daily.Total.Requests > 0 {
+			report.Days = append(report.Days, daily)
+			report.Total.add(daily.Total)
+		}
+	}
+	return report, nil
+}
+
+// usageKey is the store key of one replica's daily aggregate; with an empty
+// instance it is the prefix of all replicas' keys for that day
+func usageKey(tenant, day, instance string) string {
+	return fmt.Sprintf("usage:%s:%s:%s", tenant, day, instance)
+}
+
+// HandleUsageCommand handles "/usage [days]" and "/usage <from> <to>"
+// (dates as YYYY-MM-DD) for admins. It returns the reply to send and false
+// if text is not a /usage command.
+func (a *UsageAccumulator) HandleUsageCommand(ctx context.Context, tenantID, text string) (string, bool, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "/usage" {
+		return "", false, nil
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -6)
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := strconv.Atoi(fields[1])
+		if err != nil || days < 1 || days > 366 {
+			return "Usage: `/usage [days]` or `/usage <from> <to>` (dates as YYYY-MM-DD)", true, nil
+		}
+		from = to.AddDate(0, 0, 1-days)
+	default:
+		var err1, err2 error
+		from, err1 = time.Parse(usageDay, fields[1])
+		to, err2 = time.Parse(usageDay, fields[2])
+		if err1 != nil || err2 != nil || to.Before(from) {
+			return "Usage: `/usage [days]` or `/usage <from> <to>` (dates as YYYY-MM-DD)", true, nil
+		}
+	}
+
+	report, err := a.GetUsage(ctx, tenantID, from, to)
+	if err != nil {
+		return "", true, err
+	}
+	return formatUsageReport(report), true, nil
+}
+
+// formatUsageReport renders a report as markdown
+func formatUsageReport(r *UsageReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Usage for %s** (%s → %s)\n", r.Tenant, r.From, r.To)
+	fmt.Fprintf(&b, "Requests: %d · Tokens: %d in / %d out · Cost: $%.2f\n",
+		r.Total.Requests, r.Total.PromptTokens, r.Total.CompletionTokens, r.Total.CostUSD)
+	if len(r.ByModel) == 0 {
+		return b.String()
+	}
+
+	models := make([]string, 0, len(r.ByModel))
+	for model := range r.ByModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	b.WriteString("\nBy model:\n")
+	for _, model := range models {
+		t := r.ByModel[model]
+		fmt.Fprintf(&b, "- `%s`: %d requests, %d / %d tokens, $%.2f\n",
+			model, t.Requests, t.PromptTokens, t.CompletionTokens, t.CostUSD)
+	}
+	return b.String()
+}